@@ -20,16 +20,33 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
 	_ "zpmeow/docs" // Import for swagger docs
 	"zpmeow/internal/config"
+	"zpmeow/internal/domain/broadcast"
+	"zpmeow/internal/domain/idempotency"
+	"zpmeow/internal/domain/message"
+	"zpmeow/internal/domain/retention"
+	"zpmeow/internal/domain/schedule"
 	"zpmeow/internal/domain/session"
+	"zpmeow/internal/domain/template"
+	"zpmeow/internal/infra/broadcaster"
 	"zpmeow/internal/infra/database"
+	"zpmeow/internal/infra/export"
+	"zpmeow/internal/infra/janitor"
 	"zpmeow/internal/infra/http/handler"
 	"zpmeow/internal/infra/http/router"
 	"zpmeow/internal/infra/logger"
 	"zpmeow/internal/infra/meow"
+	"zpmeow/internal/infra/reindex"
+	"zpmeow/internal/infra/scheduler"
+	"zpmeow/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"go.mau.fi/whatsmeow/store/sqlstore"
@@ -50,6 +67,21 @@ func main() {
 	log.Info("Starting zpmeow server")
 
 
+	fetchNetworks, err := utils.ParseNetworks(cfg.MediaFetchAllowedNetworks)
+	if err != nil {
+		log.Fatalf("Invalid MEDIA_FETCH_ALLOWED_NETWORKS: %v", err)
+	}
+	utils.SetDefaultFetchPolicy(&utils.FetchPolicy{
+		AllowedSchemes:  cfg.MediaFetchAllowedSchemes,
+		AllowedDomains:  cfg.MediaFetchAllowedDomains,
+		BlockedDomains:  cfg.MediaFetchBlockedDomains,
+		AllowedNetworks: fetchNetworks,
+		MaxRedirects:    cfg.MediaFetchMaxRedirects,
+	})
+	meow.SetUploadCacheTTL(time.Duration(cfg.UploadCacheTTLMinutes) * time.Minute)
+	utils.SetMediaDir(cfg.MediaDir)
+
+
 	db, err := database.Connect(cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
@@ -70,15 +102,32 @@ func main() {
 
 
 	sessionRepo := database.NewPostgresSessionRepository(db)
+	messageRepo := database.NewPostgresMessageRepository(db)
+	retentionRepo := database.NewPostgresRetentionRepository(db)
+	idempotencyRepo := database.NewPostgresIdempotencyRepository(db)
+	scheduleRepo := database.NewPostgresScheduleRepository(db)
+	broadcastRepo := database.NewPostgresBroadcastRepository(db)
+	templateRepo := database.NewPostgresTemplateRepository(db)
 
 
 	waLogger := logger.GetWALogger("MeowService")
 
 	// Create session service first (without whatsapp service)
 	sessionService := session.NewSessionService(sessionRepo, nil)
+	messageService := message.NewMessageService(messageRepo)
+	retentionService := retention.NewRetentionService(retentionRepo, retention.Policy{
+		Enabled:      cfg.RetentionEnabled,
+		MessagesDays: cfg.RetentionMessagesDays,
+		MediaDays:    cfg.RetentionMediaDays,
+		ReceiptsDays: cfg.RetentionReceiptsDays,
+	}, cfg.RetentionBatchSize)
+	idempotencyService := idempotency.NewIdempotencyService(idempotencyRepo, time.Duration(cfg.IdempotencyWindowMinutes)*time.Minute)
+	scheduleService := schedule.NewScheduleService(scheduleRepo)
+	broadcastService := broadcast.NewBroadcastService(broadcastRepo)
+	templateService := template.NewTemplateService(templateRepo)
 
 	// Create whatsapp service with session service
-	whatsappService := meow.NewMeowService(db, container, waLogger, sessionService)
+	whatsappService := meow.NewMeowService(db, container, waLogger, sessionService, messageService)
 
 	// Update session service with whatsapp service
 	sessionService = session.NewSessionService(sessionRepo, whatsappService)
@@ -90,24 +139,82 @@ func main() {
 	}
 
 
-	sessionHandler := handler.NewSessionHandler(sessionService)
+	exporter := export.NewExporter(messageService, whatsappService, cfg.ExportDir, time.Duration(cfg.ExportRetentionHours)*time.Hour)
+	if failed, err := exporter.Recover(ctx); err != nil {
+		log.Warnf("Failed to recover interrupted exports: %v", err)
+	} else if failed > 0 {
+		log.Warnf("Marked %d interrupted exports as failed", failed)
+	}
+	defer exporter.Stop()
+
+	reindexer := reindex.NewReindexer(messageService)
+	if sessions, err := sessionService.GetAllSessions(ctx); err != nil {
+		log.Warnf("Failed to load sessions to resume reindexing: %v", err)
+	} else if started, err := reindexer.Recover(ctx, sessions); err != nil {
+		log.Warnf("Failed to resume interrupted reindexes: %v", err)
+	} else if started > 0 {
+		log.Infof("Resumed reindexing of %d sessions", started)
+	}
+	defer reindexer.Stop()
+
+	storedMediaRepo := database.NewPostgresStoredMediaRepository(db)
+	retentionJanitor := janitor.NewJanitor(retentionService, idempotencyService, exporter, storedMediaRepo, time.Duration(cfg.RetentionIntervalMinutes)*time.Minute)
+	retentionJanitor.Start(ctx)
+	defer retentionJanitor.Stop()
+
+	messageScheduler := scheduler.NewScheduler(scheduleService, whatsappService.(*meow.MeowServiceImpl), time.Duration(cfg.SchedulerIntervalSeconds)*time.Second)
+	messageScheduler.Start(ctx)
+	defer messageScheduler.Stop()
+
+	messageBroadcaster := broadcaster.NewBroadcaster(broadcastService, whatsappService.(*meow.MeowServiceImpl), cfg.BroadcastMessagesPerMinute)
+	messageBroadcaster.Start(ctx)
+	defer messageBroadcaster.Stop()
+
+
+	sessionHandler := handler.NewSessionHandler(sessionService, whatsappService.(*meow.MeowServiceImpl), messageService)
 	healthHandler := handler.NewHealthHandler()
-	sendHandler := handler.NewSendHandler(sessionService, whatsappService.(*meow.MeowServiceImpl))
+	sendHandler := handler.NewSendHandler(sessionService, whatsappService.(*meow.MeowServiceImpl), idempotencyService, scheduleService, templateService)
 	chatHandler := handler.NewChatHandler(sessionService, whatsappService.(*meow.MeowServiceImpl))
 	groupHandler := handler.NewGroupHandler(sessionService, whatsappService.(*meow.MeowServiceImpl))
 	webhookHandler := handler.NewWebhookHandler(sessionService)
 	userHandler := handler.NewUserHandler(sessionService, whatsappService.(*meow.MeowServiceImpl))
 	newsletterHandler := handler.NewNewsletterHandler(sessionService, whatsappService.(*meow.MeowServiceImpl))
+	messageHandler := handler.NewMessageHandler(sessionService, messageService, whatsappService.(*meow.MeowServiceImpl), reindexer)
+	retentionHandler := handler.NewRetentionHandler(sessionService, retentionService)
+	exportHandler := handler.NewExportHandler(sessionService, messageService, exporter)
+	scheduleHandler := handler.NewScheduleHandler(sessionService, scheduleService)
+	broadcastHandler := handler.NewBroadcastHandler(sessionService, broadcastService, messageBroadcaster)
+	templateHandler := handler.NewTemplateHandler(sessionService, templateService)
 
 	gin.SetMode(cfg.GinMode)
 
 
 	ginRouter := gin.New()
-	router.SetupRoutes(ginRouter, sessionHandler, healthHandler, sendHandler, chatHandler, groupHandler, webhookHandler, userHandler, newsletterHandler)
+	// Multipart files above this size are kept on disk while parsing instead of in memory
+	ginRouter.MaxMultipartMemory = 8 << 20
+	router.SetupRoutes(ginRouter, sessionHandler, healthHandler, sendHandler, chatHandler, groupHandler, webhookHandler, userHandler, newsletterHandler, messageHandler, retentionHandler, exportHandler, scheduleHandler, broadcastHandler, templateHandler)
 
 	addr := fmt.Sprintf(":%s", cfg.ServerPort)
-	log.Infof("Server listening on %s", addr)
-	if err := ginRouter.Run(addr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{Addr: addr, Handler: ginRouter}
+
+	// On SIGINT or SIGTERM stop accepting requests and return, so the deferred Stop calls let
+	// background work record its state before the process exits
+	shutdownCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		log.Infof("Server listening on %s", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-shutdownCtx.Done()
+	log.Info("Shutting down zpmeow server")
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(timeoutCtx); err != nil {
+		log.Warnf("Server shutdown did not complete: %v", err)
 	}
 }
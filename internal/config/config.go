@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -32,6 +33,40 @@ type Config struct {
 	LogFileMaxAge      int    `env:"LOG_FILE_MAX_AGE"`
 	LogFileCompress    bool   `env:"LOG_FILE_COMPRESS"`
 	LogFileFormat      string `env:"LOG_FILE_FORMAT"`
+
+
+	RetentionEnabled         bool `env:"RETENTION_ENABLED"`
+	RetentionMessagesDays    int  `env:"RETENTION_MESSAGES_DAYS"`
+	RetentionMediaDays       int  `env:"RETENTION_MEDIA_DAYS"`
+	RetentionReceiptsDays    int  `env:"RETENTION_RECEIPTS_DAYS"`
+	RetentionIntervalMinutes int  `env:"RETENTION_INTERVAL_MINUTES"`
+	RetentionBatchSize       int  `env:"RETENTION_BATCH_SIZE"`
+
+
+	ExportDir            string `env:"EXPORT_DIR"`
+	ExportRetentionHours int    `env:"EXPORT_RETENTION_HOURS"`
+
+
+	MediaDir string `env:"MEDIA_DIR"`
+
+
+	IdempotencyWindowMinutes int `env:"IDEMPOTENCY_WINDOW_MINUTES"`
+
+
+	SchedulerIntervalSeconds int `env:"SCHEDULER_INTERVAL_SECONDS"`
+
+
+	BroadcastMessagesPerMinute int `env:"BROADCAST_MESSAGES_PER_MINUTE"`
+
+
+	MediaFetchAllowedSchemes  []string `env:"MEDIA_FETCH_ALLOWED_SCHEMES"`
+	MediaFetchAllowedDomains  []string `env:"MEDIA_FETCH_ALLOWED_DOMAINS"`
+	MediaFetchBlockedDomains  []string `env:"MEDIA_FETCH_BLOCKED_DOMAINS"`
+	MediaFetchAllowedNetworks []string `env:"MEDIA_FETCH_ALLOWED_NETWORKS"`
+	MediaFetchMaxRedirects    int      `env:"MEDIA_FETCH_MAX_REDIRECTS"`
+
+
+	UploadCacheTTLMinutes int `env:"UPLOAD_CACHE_TTL_MINUTES"`
 }
 
 
@@ -60,6 +95,40 @@ func LoadConfig() (*Config, error) {
 		LogFileMaxAge:      getIntEnv("LOG_FILE_MAX_AGE", 28),
 		LogFileCompress:    getBoolEnv("LOG_FILE_COMPRESS", true),
 		LogFileFormat:      os.Getenv("LOG_FILE_FORMAT"),
+
+
+		RetentionEnabled:         getBoolEnv("RETENTION_ENABLED", false),
+		RetentionMessagesDays:    getIntEnv("RETENTION_MESSAGES_DAYS", 0),
+		RetentionMediaDays:       getIntEnv("RETENTION_MEDIA_DAYS", 0),
+		RetentionReceiptsDays:    getIntEnv("RETENTION_RECEIPTS_DAYS", 0),
+		RetentionIntervalMinutes: getIntEnv("RETENTION_INTERVAL_MINUTES", 60),
+		RetentionBatchSize:       getIntEnv("RETENTION_BATCH_SIZE", 1000),
+
+
+		ExportDir:            os.Getenv("EXPORT_DIR"),
+		ExportRetentionHours: getIntEnv("EXPORT_RETENTION_HOURS", 168),
+
+
+		MediaDir: os.Getenv("MEDIA_DIR"),
+
+
+		IdempotencyWindowMinutes: getIntEnv("IDEMPOTENCY_WINDOW_MINUTES", 1440),
+
+
+		SchedulerIntervalSeconds: getIntEnv("SCHEDULER_INTERVAL_SECONDS", 10),
+
+
+		BroadcastMessagesPerMinute: getIntEnv("BROADCAST_MESSAGES_PER_MINUTE", 20),
+
+
+		MediaFetchAllowedSchemes:  getListEnv("MEDIA_FETCH_ALLOWED_SCHEMES", []string{"http", "https"}),
+		MediaFetchAllowedDomains:  getListEnv("MEDIA_FETCH_ALLOWED_DOMAINS", nil),
+		MediaFetchBlockedDomains:  getListEnv("MEDIA_FETCH_BLOCKED_DOMAINS", nil),
+		MediaFetchAllowedNetworks: getListEnv("MEDIA_FETCH_ALLOWED_NETWORKS", nil),
+		MediaFetchMaxRedirects:    getIntEnv("MEDIA_FETCH_MAX_REDIRECTS", 5),
+
+
+		UploadCacheTTLMinutes: getIntEnv("UPLOAD_CACHE_TTL_MINUTES", 60),
 	}
 
 
@@ -69,6 +138,12 @@ func LoadConfig() (*Config, error) {
 	if cfg.ServerPort == "" {
 		cfg.ServerPort = "8080"
 	}
+	if cfg.ExportDir == "" {
+		cfg.ExportDir = "exports"
+	}
+	if cfg.MediaDir == "" {
+		cfg.MediaDir = "media"
+	}
 
 
 	if cfg.LogLevel == "" {
@@ -109,6 +184,22 @@ func getIntEnv(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getListEnv reads a comma-separated list, skipping empty entries
+func getListEnv(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 
 type LoggerConfig struct {
 	Level           string
@@ -0,0 +1,121 @@
+package broadcast
+
+import "zpmeow/internal/types"
+
+
+// CreateRequest starts a broadcast. Placeholders such as {{name}} in the message texts are
+// filled per recipient from the CSV columns; {{phone}} is always available.
+type CreateRequest struct {
+	Type         string         `json:"type" binding:"required" example:"text" enums:"text,image,audio,document,video,sticker,location,contact,buttons,list,poll"`
+	Recipients   []string       `json:"recipients,omitempty" example:"5511999999999,5511888888888"`
+	VariablesCSV string         `json:"variablesCsv,omitempty" example:"phone,name\n5511999999999,Alice"`
+	Message      types.MessageContent `json:"message" binding:"required"`
+}
+
+
+type ProgressDTO struct {
+	Total     int `json:"total" example:"1000"`
+	Pending   int `json:"pending" example:"400"`
+	Sent      int `json:"sent" example:"590"`
+	Failed    int `json:"failed" example:"10"`
+	Cancelled int `json:"cancelled" example:"0"`
+}
+
+
+type JobDTO struct {
+	ID         string      `json:"id" example:"9a6c1f0e-1a4b-4f0c-9a57-5f2d7b1c9e10"`
+	SessionID  string      `json:"sessionId" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Kind       string      `json:"kind" example:"text"`
+	Variables  []string    `json:"variables"`
+	Status     string      `json:"status" example:"running"`
+	LastError  string      `json:"lastError,omitempty"`
+	Progress   ProgressDTO `json:"progress"`
+	CreatedAt  int64       `json:"createdAt" example:"1640995200"`
+	UpdatedAt  int64       `json:"updatedAt" example:"1640995200"`
+	FinishedAt int64       `json:"finishedAt,omitempty" example:"1640998800"`
+}
+
+
+func NewJobDTO(j *Job) JobDTO {
+	variables := j.Variables
+	if variables == nil {
+		variables = []string{}
+	}
+
+	dto := JobDTO{
+		ID:        j.ID,
+		SessionID: j.SessionID,
+		Kind:      j.Payload.Kind,
+		Variables: variables,
+		Status:    string(j.Status),
+		LastError: j.LastError,
+		Progress:  ProgressDTO(j.Progress),
+		CreatedAt: j.CreatedAt.Unix(),
+		UpdatedAt: j.UpdatedAt.Unix(),
+	}
+	if !j.FinishedAt.IsZero() {
+		dto.FinishedAt = j.FinishedAt.Unix()
+	}
+	return dto
+}
+
+
+type JobListResponse struct {
+	Jobs   []JobDTO `json:"jobs"`
+	Limit  int      `json:"limit" example:"100"`
+	Offset int      `json:"offset" example:"0"`
+}
+
+
+func NewJobListResponse(jobs []*Job, filter JobFilter) JobListResponse {
+	dtos := make([]JobDTO, len(jobs))
+	for i, j := range jobs {
+		dtos[i] = NewJobDTO(j)
+	}
+	return JobListResponse{Jobs: dtos, Limit: filter.Limit, Offset: filter.Offset}
+}
+
+
+type RecipientDTO struct {
+	Position  int               `json:"position" example:"0"`
+	Phone     string            `json:"phone" example:"5511999999999"`
+	Variables map[string]string `json:"variables,omitempty"`
+	Status    string            `json:"status" example:"sent"`
+	MessageID string            `json:"messageId,omitempty" example:"3EB0C431C26A1916E07A"`
+	Error     string            `json:"error,omitempty"`
+	UpdatedAt int64             `json:"updatedAt" example:"1640995200"`
+	SentAt    int64             `json:"sentAt,omitempty" example:"1640995260"`
+}
+
+
+func NewRecipientDTO(r *Recipient) RecipientDTO {
+	dto := RecipientDTO{
+		Position:  r.Position,
+		Phone:     r.Phone,
+		Variables: r.Variables,
+		Status:    string(r.Status),
+		MessageID: r.MessageID,
+		Error:     r.Error,
+		UpdatedAt: r.UpdatedAt.Unix(),
+	}
+	if !r.SentAt.IsZero() {
+		dto.SentAt = r.SentAt.Unix()
+	}
+	return dto
+}
+
+
+type RecipientListResponse struct {
+	Recipients []RecipientDTO `json:"recipients"`
+	Limit      int            `json:"limit" example:"100"`
+	Offset     int            `json:"offset" example:"0"`
+}
+
+
+func NewRecipientListResponse(recipients []*Recipient, filter RecipientFilter) RecipientListResponse {
+	dtos := make([]RecipientDTO, len(recipients))
+	for i, r := range recipients {
+		dtos[i] = NewRecipientDTO(r)
+	}
+	return RecipientListResponse{Recipients: dtos, Limit: filter.Limit, Offset: filter.Offset}
+}
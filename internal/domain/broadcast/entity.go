@@ -0,0 +1,313 @@
+package broadcast
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+
+	"zpmeow/internal/domain/schedule"
+	"zpmeow/internal/domain/session"
+
+	"github.com/google/uuid"
+)
+
+
+type Status string
+
+
+const (
+	StatusRunning   Status = "running"
+	StatusPaused    Status = "paused"
+	StatusCompleted Status = "completed"
+	StatusCancelled Status = "cancelled"
+)
+
+
+func (s Status) IsValid() bool {
+	switch s {
+	case StatusRunning, StatusPaused, StatusCompleted, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+
+type RecipientStatus string
+
+
+const (
+	RecipientPending   RecipientStatus = "pending"
+	RecipientSending   RecipientStatus = "sending"
+	RecipientSent      RecipientStatus = "sent"
+	RecipientFailed    RecipientStatus = "failed"
+	RecipientCancelled RecipientStatus = "cancelled"
+)
+
+
+func (s RecipientStatus) IsValid() bool {
+	switch s {
+	case RecipientPending, RecipientSending, RecipientSent, RecipientFailed, RecipientCancelled:
+		return true
+	}
+	return false
+}
+
+
+// MaxRecipients bounds the size of one broadcast.
+const MaxRecipients = 10000
+
+
+// PhoneVariable is always available to the message template and holds the recipient number.
+const PhoneVariable = "phone"
+
+
+// Progress counts the recipients of a job by status.
+type Progress struct {
+	Total     int
+	Pending   int
+	Sent      int
+	Failed    int
+	Cancelled int
+}
+
+
+// Job sends one message template to a list of recipients. Payload has no phone; it is set per
+// recipient when the template is rendered.
+type Job struct {
+	ID         string
+	SessionID  string
+	Payload    schedule.Payload
+	Variables  []string
+	Status     Status
+	LastError  string
+	Progress   Progress
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	FinishedAt time.Time
+}
+
+
+func NewJob(sessionID string, payload schedule.Payload, variables []string) *Job {
+	now := time.Now()
+	return &Job{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		Payload:   payload,
+		Variables: variables,
+		Status:    StatusRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+
+// Validate checks the template against a sample recipient and that every placeholder it uses
+// is a known variable.
+func (j *Job) Validate() error {
+	if strings.TrimSpace(j.SessionID) == "" {
+		return session.ErrInvalidSessionID
+	}
+
+	sample := j.Payload
+	sample.Phone = "0"
+	if err := sample.Validate(); err != nil {
+		return err
+	}
+
+	known := map[string]bool{PhoneVariable: true}
+	for _, v := range j.Variables {
+		known[v] = true
+	}
+	for _, name := range j.Payload.Placeholders() {
+		if !known[name] {
+			return ErrUnknownVariable
+		}
+	}
+	return nil
+}
+
+
+func (j *Job) IsFinal() bool {
+	return j.Status == StatusCompleted || j.Status == StatusCancelled
+}
+
+
+func (j *Job) Pause(now time.Time) error {
+	if j.Status != StatusRunning {
+		return ErrInvalidTransition
+	}
+	j.Status = StatusPaused
+	j.UpdatedAt = now
+	return nil
+}
+
+
+func (j *Job) Resume(now time.Time) error {
+	if j.Status != StatusPaused {
+		return ErrInvalidTransition
+	}
+	j.Status = StatusRunning
+	j.LastError = ""
+	j.UpdatedAt = now
+	return nil
+}
+
+
+func (j *Job) Cancel(now time.Time) error {
+	if j.IsFinal() {
+		return ErrInvalidTransition
+	}
+	j.Status = StatusCancelled
+	j.UpdatedAt = now
+	j.FinishedAt = now
+	return nil
+}
+
+
+func (j *Job) Complete(now time.Time) {
+	j.Status = StatusCompleted
+	j.LastError = ""
+	j.UpdatedAt = now
+	j.FinishedAt = now
+}
+
+
+// Recipient is one target of a job and the outcome of its send. MessageID is reserved before
+// the send so a send retried after a crash keeps the same WhatsApp message ID.
+type Recipient struct {
+	JobID     string
+	Position  int
+	Phone     string
+	Variables map[string]string
+	Status    RecipientStatus
+	MessageID string
+	Error     string
+	UpdatedAt time.Time
+	SentAt    time.Time
+}
+
+
+func (r *Recipient) MarkSending(messageID string, now time.Time) {
+	r.Status = RecipientSending
+	r.MessageID = messageID
+	r.UpdatedAt = now
+}
+
+
+func (r *Recipient) MarkSent(messageID string, now time.Time) {
+	r.Status = RecipientSent
+	r.MessageID = messageID
+	r.Error = ""
+	r.UpdatedAt = now
+	r.SentAt = now
+}
+
+
+func (r *Recipient) MarkFailed(reason string, now time.Time) {
+	r.Status = RecipientFailed
+	r.Error = reason
+	r.UpdatedAt = now
+}
+
+
+// Render builds the message for one recipient from the job template.
+func Render(template *schedule.Payload, r *Recipient) *schedule.Payload {
+	p := template.Render(func(name string) string {
+		if name == PhoneVariable {
+			return r.Phone
+		}
+		return r.Variables[name]
+	})
+	p.Phone = r.Phone
+	return p
+}
+
+
+// ParseRecipients merges a plain phone list with CSV rows. The CSV needs a header row with a
+// phone column; the other columns become template variables. Duplicate phones keep their first
+// occurrence, and CSV rows win over plain phones so their variables are kept.
+func ParseRecipients(phones []string, csvData string) ([]*Recipient, []string, error) {
+	var recipients []*Recipient
+	var columns []string
+	seen := map[string]bool{}
+
+	add := func(phone string, vars map[string]string) {
+		phone = strings.TrimSpace(phone)
+		if phone == "" || seen[phone] {
+			return
+		}
+		seen[phone] = true
+		recipients = append(recipients, &Recipient{
+			Position:  len(recipients),
+			Phone:     phone,
+			Variables: vars,
+			Status:    RecipientPending,
+		})
+	}
+
+	if strings.TrimSpace(csvData) != "" {
+		reader := csv.NewReader(strings.NewReader(csvData))
+		reader.TrimLeadingSpace = true
+
+		header, err := reader.Read()
+		if err != nil {
+			return nil, nil, ErrInvalidCSV
+		}
+
+		phoneColumn := -1
+		for i, name := range header {
+			name = strings.TrimSpace(name)
+			header[i] = name
+			if strings.EqualFold(name, PhoneVariable) {
+				phoneColumn = i
+			} else if name != "" {
+				columns = append(columns, name)
+			}
+		}
+		if phoneColumn < 0 {
+			return nil, nil, ErrInvalidCSV
+		}
+
+		for {
+			row, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, nil, ErrInvalidCSV
+			}
+
+			vars := make(map[string]string, len(columns))
+			for i, value := range row {
+				if i != phoneColumn && header[i] != "" {
+					vars[header[i]] = strings.TrimSpace(value)
+				}
+			}
+			add(row[phoneColumn], vars)
+		}
+	}
+
+	for _, phone := range phones {
+		add(phone, map[string]string{})
+	}
+
+	if len(recipients) == 0 {
+		return nil, nil, ErrNoRecipients
+	}
+	if len(recipients) > MaxRecipients {
+		return nil, nil, ErrTooManyRecipients
+	}
+	return recipients, columns, nil
+}
+
+
+var (
+	ErrJobNotFound         = session.NewDomainError("broadcast job not found")
+	ErrNoRecipients        = session.NewDomainError("broadcast needs at least one recipient")
+	ErrTooManyRecipients   = session.NewDomainError("broadcast has too many recipients")
+	ErrInvalidCSV          = session.NewDomainError("variables CSV must have a header row with a phone column")
+	ErrUnknownVariable     = session.NewDomainError("message uses a variable that is not a CSV column")
+	ErrInvalidTransition   = session.NewDomainError("broadcast job cannot change to that state")
+	ErrInvalidStatusFilter = session.NewDomainError("invalid broadcast status filter")
+)
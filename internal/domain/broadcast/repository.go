@@ -0,0 +1,47 @@
+package broadcast
+
+import (
+	"context"
+	"time"
+)
+
+
+type JobFilter struct {
+	SessionID string
+	Limit     int
+	Offset    int
+}
+
+
+type RecipientFilter struct {
+	JobID  string
+	Status RecipientStatus
+	Limit  int
+	Offset int
+}
+
+
+type BroadcastRepository interface {
+	// CreateJob stores a job together with all its recipients.
+	CreateJob(ctx context.Context, job *Job, recipients []*Recipient) error
+	GetJob(ctx context.Context, sessionID, id string) (*Job, error)
+	ListJobs(ctx context.Context, filter JobFilter) ([]*Job, error)
+
+	// UpdateJob saves job only while the stored row is still in status from and returns
+	// ErrInvalidTransition otherwise.
+	UpdateJob(ctx context.Context, job *Job, from Status) error
+
+	// ActiveJobs returns the running jobs of all sessions.
+	ActiveJobs(ctx context.Context) ([]*Job, error)
+
+	ListRecipients(ctx context.Context, filter RecipientFilter) ([]*Recipient, error)
+
+	// NextRecipients returns up to limit recipients of a job that are pending or were left
+	// sending by a worker that stopped, in list order.
+	NextRecipients(ctx context.Context, jobID string, limit int) ([]*Recipient, error)
+	UpdateRecipient(ctx context.Context, r *Recipient) error
+
+	// CancelPending marks the recipients of a job that are pending, or were left sending by a
+	// worker that stopped, as cancelled.
+	CancelPending(ctx context.Context, jobID string, now time.Time) (int64, error)
+}
@@ -0,0 +1,176 @@
+package broadcast
+
+import (
+	"context"
+	"time"
+)
+
+
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+
+type BroadcastService interface {
+	Create(ctx context.Context, job *Job, recipients []*Recipient) error
+	Get(ctx context.Context, sessionID, id string) (*Job, error)
+	List(ctx context.Context, filter *JobFilter) ([]*Job, error)
+	Recipients(ctx context.Context, sessionID string, filter *RecipientFilter) ([]*Recipient, error)
+	Pause(ctx context.Context, sessionID, id string) (*Job, error)
+	Resume(ctx context.Context, sessionID, id string) (*Job, error)
+	Cancel(ctx context.Context, sessionID, id string) (*Job, error)
+
+
+	ActiveJobs(ctx context.Context) ([]*Job, error)
+	NextRecipients(ctx context.Context, jobID string, limit int) ([]*Recipient, error)
+	RecordRecipient(ctx context.Context, r *Recipient) error
+	Complete(ctx context.Context, job *Job) error
+	RecordError(ctx context.Context, job *Job, reason string) error
+}
+
+
+type BroadcastServiceImpl struct {
+	repo BroadcastRepository
+	now  func() time.Time
+}
+
+
+func NewBroadcastService(repo BroadcastRepository) BroadcastService {
+	return &BroadcastServiceImpl{
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+
+func (s *BroadcastServiceImpl) Create(ctx context.Context, job *Job, recipients []*Recipient) error {
+	if err := job.Validate(); err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return ErrNoRecipients
+	}
+	if len(recipients) > MaxRecipients {
+		return ErrTooManyRecipients
+	}
+
+	for _, r := range recipients {
+		r.JobID = job.ID
+		r.UpdatedAt = job.CreatedAt
+	}
+	job.Progress = Progress{Total: len(recipients), Pending: len(recipients)}
+
+	return s.repo.CreateJob(ctx, job, recipients)
+}
+
+
+func (s *BroadcastServiceImpl) Get(ctx context.Context, sessionID, id string) (*Job, error) {
+	return s.repo.GetJob(ctx, sessionID, id)
+}
+
+
+// List pages through the jobs of a session; filter is normalized in place.
+func (s *BroadcastServiceImpl) List(ctx context.Context, filter *JobFilter) ([]*Job, error) {
+	filter.Limit, filter.Offset = normalizePage(filter.Limit, filter.Offset)
+	return s.repo.ListJobs(ctx, *filter)
+}
+
+
+// Recipients pages through the results of a job of the session; filter is normalized in place.
+func (s *BroadcastServiceImpl) Recipients(ctx context.Context, sessionID string, filter *RecipientFilter) ([]*Recipient, error) {
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, ErrInvalidStatusFilter
+	}
+	if _, err := s.repo.GetJob(ctx, sessionID, filter.JobID); err != nil {
+		return nil, err
+	}
+
+	filter.Limit, filter.Offset = normalizePage(filter.Limit, filter.Offset)
+	return s.repo.ListRecipients(ctx, *filter)
+}
+
+
+func (s *BroadcastServiceImpl) Pause(ctx context.Context, sessionID, id string) (*Job, error) {
+	return s.transition(ctx, sessionID, id, (*Job).Pause)
+}
+
+
+func (s *BroadcastServiceImpl) Resume(ctx context.Context, sessionID, id string) (*Job, error) {
+	return s.transition(ctx, sessionID, id, (*Job).Resume)
+}
+
+
+// Cancel stops a job for good; recipients that were not sent yet are marked cancelled.
+func (s *BroadcastServiceImpl) Cancel(ctx context.Context, sessionID, id string) (*Job, error) {
+	job, err := s.transition(ctx, sessionID, id, (*Job).Cancel)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.CancelPending(ctx, job.ID, job.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return s.repo.GetJob(ctx, sessionID, id)
+}
+
+
+func (s *BroadcastServiceImpl) transition(ctx context.Context, sessionID, id string, change func(*Job, time.Time) error) (*Job, error) {
+	job, err := s.repo.GetJob(ctx, sessionID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	from := job.Status
+	if err := change(job, s.now()); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateJob(ctx, job, from); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+
+func (s *BroadcastServiceImpl) ActiveJobs(ctx context.Context) ([]*Job, error) {
+	return s.repo.ActiveJobs(ctx)
+}
+
+
+func (s *BroadcastServiceImpl) NextRecipients(ctx context.Context, jobID string, limit int) ([]*Recipient, error) {
+	return s.repo.NextRecipients(ctx, jobID, limit)
+}
+
+
+func (s *BroadcastServiceImpl) RecordRecipient(ctx context.Context, r *Recipient) error {
+	return s.repo.UpdateRecipient(ctx, r)
+}
+
+
+// Complete marks a running job whose recipients were all processed as completed.
+func (s *BroadcastServiceImpl) Complete(ctx context.Context, job *Job) error {
+	job.Complete(s.now())
+	return s.repo.UpdateJob(ctx, job, StatusRunning)
+}
+
+
+// RecordError notes why a running job is not making progress, such as a disconnected session.
+func (s *BroadcastServiceImpl) RecordError(ctx context.Context, job *Job, reason string) error {
+	job.LastError = reason
+	job.UpdatedAt = s.now()
+	return s.repo.UpdateJob(ctx, job, StatusRunning)
+}
+
+
+func normalizePage(limit, offset int) (int, int) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
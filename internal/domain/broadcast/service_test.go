@@ -0,0 +1,196 @@
+package broadcast
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"zpmeow/internal/domain/schedule"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+
+type MockBroadcastRepository struct {
+	mock.Mock
+}
+
+func (m *MockBroadcastRepository) CreateJob(ctx context.Context, job *Job, recipients []*Recipient) error {
+	args := m.Called(ctx, job, recipients)
+	return args.Error(0)
+}
+
+func (m *MockBroadcastRepository) GetJob(ctx context.Context, sessionID, id string) (*Job, error) {
+	args := m.Called(ctx, sessionID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Job), args.Error(1)
+}
+
+func (m *MockBroadcastRepository) ListJobs(ctx context.Context, filter JobFilter) ([]*Job, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Job), args.Error(1)
+}
+
+func (m *MockBroadcastRepository) UpdateJob(ctx context.Context, job *Job, from Status) error {
+	args := m.Called(ctx, job, from)
+	return args.Error(0)
+}
+
+func (m *MockBroadcastRepository) ActiveJobs(ctx context.Context) ([]*Job, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Job), args.Error(1)
+}
+
+func (m *MockBroadcastRepository) ListRecipients(ctx context.Context, filter RecipientFilter) ([]*Recipient, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Recipient), args.Error(1)
+}
+
+func (m *MockBroadcastRepository) NextRecipients(ctx context.Context, jobID string, limit int) ([]*Recipient, error) {
+	args := m.Called(ctx, jobID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Recipient), args.Error(1)
+}
+
+func (m *MockBroadcastRepository) UpdateRecipient(ctx context.Context, r *Recipient) error {
+	args := m.Called(ctx, r)
+	return args.Error(0)
+}
+
+func (m *MockBroadcastRepository) CancelPending(ctx context.Context, jobID string, now time.Time) (int64, error) {
+	args := m.Called(ctx, jobID, now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+
+func newTestService(repo BroadcastRepository, now time.Time) *BroadcastServiceImpl {
+	service := NewBroadcastService(repo).(*BroadcastServiceImpl)
+	service.now = func() time.Time { return now }
+	return service
+}
+
+
+func textJob(text string, variables []string) *Job {
+	return NewJob("session-1", schedule.Payload{Kind: schedule.KindText, Text: text}, variables)
+}
+
+
+func TestParseRecipients_MergesCSVAndList(t *testing.T) {
+	csvData := "Phone,name,city\n5511999999999,Alice,São Paulo\n5511888888888,Bob,Rio\n"
+
+	recipients, columns, err := ParseRecipients([]string{"5511888888888", " 5511777777777 ", ""}, csvData)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"name", "city"}, columns)
+	require.Len(t, recipients, 3)
+	assert.Equal(t, "Alice", recipients[0].Variables["name"])
+	assert.Equal(t, "Bob", recipients[1].Variables["name"])
+	assert.Equal(t, "5511777777777", recipients[2].Phone)
+	assert.Equal(t, 2, recipients[2].Position)
+	assert.Equal(t, RecipientPending, recipients[2].Status)
+}
+
+func TestParseRecipients_Invalid(t *testing.T) {
+	_, _, err := ParseRecipients(nil, "name\nAlice\n")
+	assert.Equal(t, ErrInvalidCSV, err)
+
+	_, _, err = ParseRecipients(nil, "phone,name\n5511999999999\n")
+	assert.Equal(t, ErrInvalidCSV, err)
+
+	_, _, err = ParseRecipients([]string{" "}, "")
+	assert.Equal(t, ErrNoRecipients, err)
+}
+
+func TestRender_FillsPlaceholders(t *testing.T) {
+	template := &schedule.Payload{Kind: schedule.KindText, Text: "Hi {{ name }}, your number is {{phone}}{{missing}}", Footer: "{{city}}"}
+	r := &Recipient{Phone: "5511999999999", Variables: map[string]string{"name": "Alice", "city": "Rio"}}
+
+	p := Render(template, r)
+	assert.Equal(t, "5511999999999", p.Phone)
+	assert.Equal(t, "Hi Alice, your number is 5511999999999", p.Text)
+	assert.Equal(t, "Rio", p.Footer)
+	assert.Equal(t, "Hi {{ name }}, your number is {{phone}}{{missing}}", template.Text)
+}
+
+func TestCreate_RejectsUnknownVariable(t *testing.T) {
+	service := newTestService(new(MockBroadcastRepository), time.Unix(1700000000, 0))
+	recipients := []*Recipient{{Phone: "5511999999999"}}
+
+	err := service.Create(context.Background(), textJob("Hi {{name}}", nil), recipients)
+	assert.Equal(t, ErrUnknownVariable, err)
+
+	err = service.Create(context.Background(), textJob(" ", nil), recipients)
+	assert.Equal(t, schedule.ErrEmptyText, err)
+}
+
+func TestCreate_StoresJobAndRecipients(t *testing.T) {
+	repo := new(MockBroadcastRepository)
+	service := newTestService(repo, time.Unix(1700000000, 0))
+
+	job := textJob("Hi {{name}}", []string{"name"})
+	recipients := []*Recipient{{Phone: "5511999999999"}, {Phone: "5511888888888", Position: 1}}
+	repo.On("CreateJob", mock.Anything, job, recipients).Return(nil)
+
+	require.NoError(t, service.Create(context.Background(), job, recipients))
+	assert.Equal(t, job.ID, recipients[1].JobID)
+	assert.Equal(t, Progress{Total: 2, Pending: 2}, job.Progress)
+	repo.AssertExpectations(t)
+}
+
+func TestPauseResume(t *testing.T) {
+	repo := new(MockBroadcastRepository)
+	service := newTestService(repo, time.Unix(1700000000, 0))
+
+	job := textJob("hello", nil)
+	repo.On("GetJob", mock.Anything, "session-1", job.ID).Return(job, nil)
+	repo.On("UpdateJob", mock.Anything, job, StatusRunning).Return(nil).Once()
+	repo.On("UpdateJob", mock.Anything, job, StatusPaused).Return(nil).Once()
+
+	paused, err := service.Pause(context.Background(), "session-1", job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPaused, paused.Status)
+
+	_, err = service.Pause(context.Background(), "session-1", job.ID)
+	assert.Equal(t, ErrInvalidTransition, err)
+
+	resumed, err := service.Resume(context.Background(), "session-1", job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRunning, resumed.Status)
+	repo.AssertExpectations(t)
+}
+
+func TestCancel_CancelsPendingRecipients(t *testing.T) {
+	repo := new(MockBroadcastRepository)
+	now := time.Unix(1700000000, 0)
+	service := newTestService(repo, now)
+
+	job := textJob("hello", nil)
+	job.Status = StatusPaused
+	repo.On("GetJob", mock.Anything, "session-1", job.ID).Return(job, nil)
+	repo.On("UpdateJob", mock.Anything, job, StatusPaused).Return(nil)
+	repo.On("CancelPending", mock.Anything, job.ID, now).Return(int64(3), nil)
+
+	cancelled, err := service.Cancel(context.Background(), "session-1", job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCancelled, cancelled.Status)
+	assert.Equal(t, now, cancelled.FinishedAt)
+
+	_, err = service.Cancel(context.Background(), "session-1", job.ID)
+	assert.Equal(t, ErrInvalidTransition, err)
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,64 @@
+package idempotency
+
+import (
+	"strings"
+	"time"
+
+	"zpmeow/internal/domain/session"
+)
+
+
+// MaxKeyLength bounds client supplied keys so they stay cheap to index.
+const MaxKeyLength = 255
+
+
+type Status string
+
+
+const (
+	StatusPending   Status = "pending"
+	StatusCompleted Status = "completed"
+)
+
+
+// Record remembers a send made under an idempotency key. While the send is in flight the
+// record is pending; once it succeeds Response holds the JSON returned to the client.
+type Record struct {
+	SessionID   string
+	Key         string
+	RequestHash string
+	Status      Status
+	Response    []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+
+func NewRecord(sessionID, key, requestHash string, now time.Time, window time.Duration) *Record {
+	return &Record{
+		SessionID:   sessionID,
+		Key:         strings.TrimSpace(key),
+		RequestHash: requestHash,
+		Status:      StatusPending,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(window),
+	}
+}
+
+
+func (r *Record) Validate() error {
+	if strings.TrimSpace(r.SessionID) == "" {
+		return session.ErrInvalidSessionID
+	}
+	if r.Key == "" || len(r.Key) > MaxKeyLength {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+
+var (
+	ErrInvalidKey        = session.NewDomainError("idempotency key must be between 1 and 255 characters")
+	ErrRequestInProgress = session.NewDomainError("a request with this idempotency key is still in progress")
+	ErrKeyReused         = session.NewDomainError("idempotency key was already used for a different request")
+)
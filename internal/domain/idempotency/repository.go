@@ -0,0 +1,17 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+)
+
+
+type IdempotencyRepository interface {
+	// Reserve stores record unless the key is held by an unexpired record. Pending records
+	// created before staleBefore are considered abandoned and are taken over. It returns the
+	// record holding the key when the reservation fails, or nil when record was stored.
+	Reserve(ctx context.Context, record *Record, staleBefore time.Time) (*Record, error)
+	Complete(ctx context.Context, sessionID, key string, response []byte) error
+	Delete(ctx context.Context, sessionID, key string) error
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
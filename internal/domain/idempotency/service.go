@@ -0,0 +1,101 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+)
+
+
+const (
+	DefaultWindow = 24 * time.Hour
+
+	// PendingTimeout is how long a send may stay in flight before its key can be reclaimed,
+	// so a crash between reserving and completing does not block the key for the whole window.
+	PendingTimeout = 2 * time.Minute
+)
+
+
+type IdempotencyService interface {
+	Begin(ctx context.Context, sessionID, key, requestHash string) ([]byte, error)
+	Complete(ctx context.Context, sessionID, key string, response []byte) error
+	Abort(ctx context.Context, sessionID, key string) error
+	PurgeExpired(ctx context.Context) (int64, error)
+	Enabled() bool
+}
+
+
+type IdempotencyServiceImpl struct {
+	repo   IdempotencyRepository
+	window time.Duration
+	now    func() time.Time
+}
+
+
+// NewIdempotencyService creates a service that remembers sends for window. A window of zero
+// or less disables deduplication.
+func NewIdempotencyService(repo IdempotencyRepository, window time.Duration) IdempotencyService {
+	return &IdempotencyServiceImpl{
+		repo:   repo,
+		window: window,
+		now:    time.Now,
+	}
+}
+
+
+func (s *IdempotencyServiceImpl) Enabled() bool {
+	return s.window > 0
+}
+
+
+// Begin reserves key for a new send. It returns the stored response when the key was already
+// used for the same request, and nil when the caller should go ahead and send.
+func (s *IdempotencyServiceImpl) Begin(ctx context.Context, sessionID, key, requestHash string) ([]byte, error) {
+	if !s.Enabled() {
+		return nil, nil
+	}
+
+	now := s.now()
+	record := NewRecord(sessionID, key, requestHash, now, s.window)
+	if err := record.Validate(); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.Reserve(ctx, record, now.Add(-PendingTimeout))
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, nil
+	}
+
+	if existing.RequestHash != requestHash {
+		return nil, ErrKeyReused
+	}
+	if existing.Status != StatusCompleted {
+		return nil, ErrRequestInProgress
+	}
+
+	return existing.Response, nil
+}
+
+
+func (s *IdempotencyServiceImpl) Complete(ctx context.Context, sessionID, key string, response []byte) error {
+	if !s.Enabled() {
+		return nil
+	}
+	return s.repo.Complete(ctx, sessionID, key, response)
+}
+
+
+// Abort releases the key of a failed send so the client can retry it.
+func (s *IdempotencyServiceImpl) Abort(ctx context.Context, sessionID, key string) error {
+	if !s.Enabled() {
+		return nil
+	}
+	return s.repo.Delete(ctx, sessionID, key)
+}
+
+
+func (s *IdempotencyServiceImpl) PurgeExpired(ctx context.Context) (int64, error) {
+	return s.repo.DeleteExpired(ctx, s.now())
+}
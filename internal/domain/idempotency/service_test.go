@@ -0,0 +1,109 @@
+package idempotency
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+
+type MockIdempotencyRepository struct {
+	mock.Mock
+}
+
+func (m *MockIdempotencyRepository) Reserve(ctx context.Context, record *Record, staleBefore time.Time) (*Record, error) {
+	args := m.Called(ctx, record, staleBefore)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Record), args.Error(1)
+}
+
+func (m *MockIdempotencyRepository) Complete(ctx context.Context, sessionID, key string, response []byte) error {
+	args := m.Called(ctx, sessionID, key, response)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyRepository) Delete(ctx context.Context, sessionID, key string) error {
+	args := m.Called(ctx, sessionID, key)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+
+func newTestService(repo IdempotencyRepository, now time.Time) *IdempotencyServiceImpl {
+	service := NewIdempotencyService(repo, time.Hour).(*IdempotencyServiceImpl)
+	service.now = func() time.Time { return now }
+	return service
+}
+
+
+func TestBegin_ReservesNewKey(t *testing.T) {
+	repo := new(MockIdempotencyRepository)
+	now := time.Unix(1700000000, 0)
+	service := newTestService(repo, now)
+
+	repo.On("Reserve", mock.Anything, mock.MatchedBy(func(r *Record) bool {
+		return r.Key == "key-1" && r.Status == StatusPending && r.ExpiresAt.Equal(now.Add(time.Hour))
+	}), now.Add(-PendingTimeout)).Return(nil, nil)
+
+	replay, err := service.Begin(context.Background(), "session-1", "key-1", "hash")
+	assert.NoError(t, err)
+	assert.Nil(t, replay)
+	repo.AssertExpectations(t)
+}
+
+func TestBegin_ReplaysCompletedSend(t *testing.T) {
+	repo := new(MockIdempotencyRepository)
+	service := newTestService(repo, time.Unix(1700000000, 0))
+
+	stored := &Record{Key: "key-1", RequestHash: "hash", Status: StatusCompleted, Response: []byte(`{"id":"3EB0"}`)}
+	repo.On("Reserve", mock.Anything, mock.Anything, mock.Anything).Return(stored, nil)
+
+	replay, err := service.Begin(context.Background(), "session-1", "key-1", "hash")
+	assert.NoError(t, err)
+	assert.Equal(t, stored.Response, replay)
+}
+
+func TestBegin_Conflicts(t *testing.T) {
+	repo := new(MockIdempotencyRepository)
+	service := newTestService(repo, time.Unix(1700000000, 0))
+
+	pending := &Record{Key: "key-1", RequestHash: "hash", Status: StatusPending}
+	repo.On("Reserve", mock.Anything, mock.Anything, mock.Anything).Return(pending, nil)
+
+	_, err := service.Begin(context.Background(), "session-1", "key-1", "hash")
+	assert.Equal(t, ErrRequestInProgress, err)
+
+	_, err = service.Begin(context.Background(), "session-1", "key-1", "other")
+	assert.Equal(t, ErrKeyReused, err)
+}
+
+func TestBegin_InvalidKey(t *testing.T) {
+	repo := new(MockIdempotencyRepository)
+	service := newTestService(repo, time.Unix(1700000000, 0))
+
+	_, err := service.Begin(context.Background(), "session-1", strings.Repeat("k", MaxKeyLength+1), "hash")
+	assert.Equal(t, ErrInvalidKey, err)
+	repo.AssertNotCalled(t, "Reserve", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDisabledWindow(t *testing.T) {
+	repo := new(MockIdempotencyRepository)
+	service := NewIdempotencyService(repo, 0)
+
+	replay, err := service.Begin(context.Background(), "session-1", "key-1", "hash")
+	assert.NoError(t, err)
+	assert.Nil(t, replay)
+	assert.NoError(t, service.Complete(context.Background(), "session-1", "key-1", []byte("{}")))
+	assert.False(t, service.Enabled())
+	repo.AssertNotCalled(t, "Reserve", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,363 @@
+package message
+
+import "time"
+
+
+// MessageDTO is the normalized message model shared by the history API and the message.normalized
+// and status_update webhooks.
+type MessageDTO struct {
+	ID        string       `json:"id" example:"3EB0C431C26A1916E07A"`
+	SessionID string       `json:"sessionId" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Chat      string       `json:"chat" example:"5511999999999@s.whatsapp.net"`
+	Sender    string       `json:"sender" example:"5511999999999@s.whatsapp.net"`
+	PushName  string       `json:"pushName,omitempty" example:"John Doe"`
+	FromMe    bool         `json:"fromMe" example:"false"`
+	IsGroup   bool         `json:"isGroup" example:"false"`
+	Direction string       `json:"direction" example:"incoming"`
+	Type      string       `json:"type" example:"text"`
+	Text      string       `json:"text,omitempty" example:"Hello, World!"`
+	Caption   string       `json:"caption,omitempty" example:"Image caption"`
+	HasMedia  bool         `json:"hasMedia" example:"false"`
+	ViewOnce  bool         `json:"viewOnce,omitempty" example:"false"`
+	Media     *MediaDTO    `json:"media,omitempty"`
+	Quoted    *QuotedDTO   `json:"quoted,omitempty"`
+	Timestamp int64        `json:"timestamp" example:"1640995200"`
+}
+
+
+type MediaDTO struct {
+	MimeType   string `json:"mimeType,omitempty" example:"image/jpeg"`
+	FileName   string `json:"fileName,omitempty" example:"document.pdf"`
+	FileLength uint64 `json:"fileLength,omitempty" example:"20480"`
+}
+
+
+type QuotedDTO struct {
+	ID          string `json:"id" example:"3EB0C431C26A1916E07B"`
+	Participant string `json:"participant,omitempty" example:"5511888888888@s.whatsapp.net"`
+}
+
+
+
+
+type MessageListResponse struct {
+	Messages   []MessageDTO `json:"messages"`
+	NextCursor string       `json:"nextCursor,omitempty" example:"MTY0MDk5NTIwMDAwMDAwMDAwMDozRUIw"`
+	HasMore    bool         `json:"hasMore" example:"true"`
+}
+
+
+func NewMessageDTO(m *Message) MessageDTO {
+	dto := MessageDTO{
+		ID:        m.ID,
+		SessionID: m.SessionID,
+		Chat:      m.ChatJID,
+		Sender:    m.SenderJID,
+		PushName:  m.PushName,
+		FromMe:    m.FromMe,
+		IsGroup:   m.IsGroup,
+		Direction: string(m.Direction()),
+		Type:      m.Type,
+		Text:      m.Text,
+		Caption:   m.Caption,
+		HasMedia:  m.HasMedia(),
+		ViewOnce:  m.ViewOnce,
+		Timestamp: m.Timestamp.Unix(),
+	}
+
+	if dto.HasMedia {
+		dto.Media = &MediaDTO{
+			MimeType:   m.MimeType,
+			FileName:   m.FileName,
+			FileLength: m.FileLength,
+		}
+	}
+
+	if m.IsQuote() {
+		dto.Quoted = &QuotedDTO{
+			ID:          m.QuotedID,
+			Participant: m.QuotedParticipant,
+		}
+	}
+
+	return dto
+}
+
+
+func NewMessageListResponse(page *MessagePage) MessageListResponse {
+	return MessageListResponse{
+		Messages:   newMessageDTOs(page.Messages),
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+	}
+}
+
+
+// SearchHitDTO is a search match. Snippet is HTML-escaped text in which only the matched terms are
+// markup, wrapped in <mark> tags.
+type SearchHitDTO struct {
+	Message MessageDTO       `json:"message"`
+	Snippet string           `json:"snippet" example:"please pay <mark>invoice</mark> <mark>4821</mark> by friday"`
+	Rank    float64          `json:"rank" example:"0.0759"`
+	Context SearchContextDTO `json:"context"`
+}
+
+
+// SearchContextDTO holds the chat messages right before and after a match, in chronological order.
+type SearchContextDTO struct {
+	Before []MessageDTO `json:"before"`
+	After  []MessageDTO `json:"after"`
+}
+
+
+type SearchResponse struct {
+	Query      string         `json:"query" example:"invoice 4821"`
+	Language   string         `json:"language" example:"portuguese"`
+	Results    []SearchHitDTO `json:"results"`
+	HasMore    bool           `json:"hasMore" example:"false"`
+	NextOffset int            `json:"nextOffset,omitempty" example:"20"`
+}
+
+
+type SearchLanguageRequest struct {
+	Language string `json:"language" binding:"required" example:"english"`
+}
+
+
+// SearchLanguageResponse reports the search language; Reindex is set once the session's messages
+// were reindexed, or are being reindexed, since the server started.
+type SearchLanguageResponse struct {
+	Language  string         `json:"language" example:"portuguese"`
+	Supported []string       `json:"supported"`
+	Reindex   *ReindexJobDTO `json:"reindex,omitempty"`
+}
+
+
+type ReindexJobDTO struct {
+	Language    string `json:"language" example:"english"`
+	Status      string `json:"status" example:"running"`
+	Total       int64  `json:"total" example:"48000"`
+	Reindexed   int64  `json:"reindexed" example:"12000"`
+	Error       string `json:"error,omitempty"`
+	CreatedAt   int64  `json:"createdAt" example:"1640995200"`
+	CompletedAt int64  `json:"completedAt,omitempty" example:"1640995230"`
+}
+
+
+func NewReindexJobDTO(job *ReindexJob) *ReindexJobDTO {
+	if job == nil {
+		return nil
+	}
+	return &ReindexJobDTO{
+		Language:    job.Language,
+		Status:      string(job.Status),
+		Total:       job.Total,
+		Reindexed:   job.Reindexed,
+		Error:       job.Error,
+		CreatedAt:   job.CreatedAt.Unix(),
+		CompletedAt: unixOrZero(job.CompletedAt),
+	}
+}
+
+
+func newMessageDTOs(messages []*Message) []MessageDTO {
+	dtos := make([]MessageDTO, len(messages))
+	for i, m := range messages {
+		dtos[i] = NewMessageDTO(m)
+	}
+	return dtos
+}
+
+
+func NewSearchResponse(query SearchQuery, page *SearchPage) SearchResponse {
+	results := make([]SearchHitDTO, len(page.Hits))
+	for i, hit := range page.Hits {
+		results[i] = SearchHitDTO{
+			Message: NewMessageDTO(hit.Message),
+			Snippet: hit.Snippet,
+			Rank:    hit.Rank,
+			Context: SearchContextDTO{
+				Before: newMessageDTOs(hit.Before),
+				After:  newMessageDTOs(hit.After),
+			},
+		}
+	}
+
+	return SearchResponse{
+		Query:      query.Query,
+		Language:   query.Language,
+		Results:    results,
+		HasMore:    page.HasMore,
+		NextOffset: page.NextOffset,
+	}
+}
+
+
+type ReceiptDTO struct {
+	Participant string `json:"participant" example:"5511999999999@s.whatsapp.net"`
+	Status      string `json:"status" example:"delivered"`
+	Timestamp   int64  `json:"timestamp" example:"1640995260"`
+}
+
+
+type ParticipantStatusDTO struct {
+	JID         string `json:"jid" example:"5511999999999@s.whatsapp.net"`
+	Status      string `json:"status" example:"read"`
+	DeliveredAt int64  `json:"deliveredAt,omitempty" example:"1640995260"`
+	ReadAt      int64  `json:"readAt,omitempty" example:"1640995300"`
+	PlayedAt    int64  `json:"playedAt,omitempty"`
+}
+
+
+type MessageStatusResponse struct {
+	ID           string                 `json:"id" example:"3EB0C431C26A1916E07A"`
+	Chat         string                 `json:"chat" example:"5511999999999@s.whatsapp.net"`
+	IsGroup      bool                   `json:"isGroup" example:"false"`
+	Status       string                 `json:"status" example:"read"`
+	SentAt       int64                  `json:"sentAt" example:"1640995200"`
+	Participants []ParticipantStatusDTO `json:"participants"`
+	Timeline     []ReceiptDTO           `json:"timeline"`
+}
+
+
+// MessageStatusEventDTO is the payload of the message.status webhook.
+type MessageStatusEventDTO struct {
+	SessionID   string   `json:"sessionId" example:"550e8400-e29b-41d4-a716-446655440000"`
+	MessageIDs  []string `json:"messageIds"`
+	Chat        string   `json:"chat" example:"5511999999999@s.whatsapp.net"`
+	Participant string   `json:"participant" example:"5511999999999@s.whatsapp.net"`
+	IsGroup     bool     `json:"isGroup" example:"false"`
+	Status      string   `json:"status" example:"delivered"`
+	Timestamp   int64    `json:"timestamp" example:"1640995260"`
+}
+
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+
+func NewMessageStatusResponse(status *MessageStatus) MessageStatusResponse {
+	participants := make([]ParticipantStatusDTO, len(status.Participants))
+	for i, p := range status.Participants {
+		participants[i] = ParticipantStatusDTO{
+			JID:         p.JID,
+			Status:      string(p.Status),
+			DeliveredAt: unixOrZero(p.DeliveredAt),
+			ReadAt:      unixOrZero(p.ReadAt),
+			PlayedAt:    unixOrZero(p.PlayedAt),
+		}
+	}
+
+	timeline := make([]ReceiptDTO, len(status.Timeline))
+	for i, r := range status.Timeline {
+		timeline[i] = ReceiptDTO{
+			Participant: r.Participant,
+			Status:      string(r.Status),
+			Timestamp:   r.Timestamp.Unix(),
+		}
+	}
+
+	return MessageStatusResponse{
+		ID:           status.Message.ID,
+		Chat:         status.Message.ChatJID,
+		IsGroup:      status.Message.IsGroup,
+		Status:       string(status.Status),
+		SentAt:       status.Message.Timestamp.Unix(),
+		Participants: participants,
+		Timeline:     timeline,
+	}
+}
+
+
+type HistorySyncRequest struct {
+	Chat         string `json:"chat" form:"chat" binding:"required" example:"5511999999999@s.whatsapp.net"`
+	Count        int    `json:"count,omitempty" form:"count" example:"50"`
+	AnchorID     string `json:"anchorId,omitempty" form:"anchorId" example:"3EB0C431C26A1916E07A"`
+	AnchorFromMe bool   `json:"anchorFromMe,omitempty" form:"anchorFromMe" example:"false"`
+	Before       int64  `json:"before,omitempty" form:"before" example:"1640995200"`
+}
+
+
+type HistorySyncJobDTO struct {
+	ID             string `json:"id" example:"7f9c2ba4-e88f-4f2c-9a3b-2f1e6d7c8b90"`
+	SessionID      string `json:"sessionId" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Chat           string `json:"chat" example:"5511999999999@s.whatsapp.net"`
+	AnchorID       string `json:"anchorId" example:"3EB0C431C26A1916E07A"`
+	Count          int    `json:"count" example:"50"`
+	Status         string `json:"status" example:"completed"`
+	Progress       int    `json:"progress" example:"100"`
+	MessagesStored int    `json:"messagesStored" example:"48"`
+	Error          string `json:"error,omitempty"`
+	CreatedAt      int64  `json:"createdAt" example:"1640995200"`
+	UpdatedAt      int64  `json:"updatedAt" example:"1640995205"`
+	CompletedAt    int64  `json:"completedAt,omitempty" example:"1640995205"`
+}
+
+
+func NewHistorySyncJobDTO(job *HistorySyncJob) HistorySyncJobDTO {
+	return HistorySyncJobDTO{
+		ID:             job.ID,
+		SessionID:      job.SessionID,
+		Chat:           job.ChatJID,
+		AnchorID:       job.AnchorID,
+		Count:          job.Count,
+		Status:         string(job.Status),
+		Progress:       job.Progress,
+		MessagesStored: job.MessagesStored,
+		Error:          job.Error,
+		CreatedAt:      job.CreatedAt.Unix(),
+		UpdatedAt:      job.UpdatedAt.Unix(),
+		CompletedAt:    unixOrZero(job.CompletedAt),
+	}
+}
+
+
+type ExportRequest struct {
+	Chat   string `json:"chat,omitempty" example:"5511999999999@s.whatsapp.net"`
+	Format string `json:"format" binding:"required" example:"html"`
+	Media  string `json:"media,omitempty" example:"link"`
+}
+
+
+type ExportJobDTO struct {
+	ID               string `json:"id" example:"0b8f3a52-2a43-4f41-9a57-5f2d7b1c9e10"`
+	SessionID        string `json:"sessionId" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Chat             string `json:"chat,omitempty" example:"5511999999999@s.whatsapp.net"`
+	Format           string `json:"format" example:"html"`
+	Media            string `json:"media" example:"link"`
+	Status           string `json:"status" example:"completed"`
+	MessagesExported int    `json:"messagesExported" example:"1250"`
+	FileSize         int64  `json:"fileSize,omitempty" example:"482133"`
+	DownloadURL      string `json:"downloadUrl,omitempty" example:"/session/550e8400-e29b-41d4-a716-446655440000/exports/0b8f3a52-2a43-4f41-9a57-5f2d7b1c9e10/download"`
+	Error            string `json:"error,omitempty"`
+	CreatedAt        int64  `json:"createdAt" example:"1640995200"`
+	CompletedAt      int64  `json:"completedAt,omitempty" example:"1640995230"`
+}
+
+
+// NewExportJobDTO builds the job response; DownloadURL is only set once the file is ready.
+func NewExportJobDTO(job *ExportJob) ExportJobDTO {
+	dto := ExportJobDTO{
+		ID:               job.ID,
+		SessionID:        job.SessionID,
+		Chat:             job.ChatJID,
+		Format:           job.Format,
+		Media:            job.MediaMode,
+		Status:           string(job.Status),
+		MessagesExported: job.MessagesExported,
+		FileSize:         job.FileSize,
+		Error:            job.Error,
+		CreatedAt:        job.CreatedAt.Unix(),
+		CompletedAt:      unixOrZero(job.CompletedAt),
+	}
+
+	if job.Status == JobStatusCompleted {
+		dto.DownloadURL = "/session/" + job.SessionID + "/exports/" + job.ID + "/download"
+	}
+
+	return dto
+}
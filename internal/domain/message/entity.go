@@ -0,0 +1,447 @@
+package message
+
+import (
+	"strings"
+	"time"
+
+	"zpmeow/internal/domain/session"
+
+	"github.com/google/uuid"
+)
+
+
+type Direction string
+
+
+const (
+	DirectionIncoming Direction = "incoming"
+	DirectionOutgoing Direction = "outgoing"
+)
+
+
+func (d Direction) IsValid() bool {
+	return d == DirectionIncoming || d == DirectionOutgoing
+}
+
+
+const (
+	TypeText     = "text"
+	TypeImage    = "image"
+	TypeVideo    = "video"
+	TypeAudio    = "audio"
+	TypeDocument = "document"
+	TypeSticker  = "sticker"
+	TypeLocation = "location"
+	TypeContact  = "contact"
+	TypePoll     = "poll"
+	TypeReaction = "reaction"
+	TypeAlbum    = "album"
+	TypeUnknown  = "unknown"
+)
+
+
+var mediaTypes = map[string]bool{
+	TypeImage:    true,
+	TypeVideo:    true,
+	TypeAudio:    true,
+	TypeDocument: true,
+	TypeSticker:  true,
+}
+
+
+func IsMediaType(messageType string) bool {
+	return mediaTypes[messageType]
+}
+
+
+// Message is a stored WhatsApp message, either received by or sent from a session.
+// Raw holds the protobuf-encoded waE2E.Message so the original can be rebuilt later.
+type Message struct {
+	ID                string
+	SessionID         string
+	ChatJID           string
+	SenderJID         string
+	PushName          string
+	FromMe            bool
+	IsGroup           bool
+	Type              string
+	Text              string
+	Caption           string
+	MimeType          string
+	FileName          string
+	FileLength        uint64
+	QuotedID          string
+	QuotedParticipant string
+	ViewOnce          bool
+	Raw               []byte
+	Timestamp         time.Time
+	CreatedAt         time.Time
+}
+
+
+func (m *Message) Direction() Direction {
+	if m.FromMe {
+		return DirectionOutgoing
+	}
+	return DirectionIncoming
+}
+
+
+func (m *Message) HasMedia() bool {
+	return IsMediaType(m.Type)
+}
+
+
+func (m *Message) IsQuote() bool {
+	return strings.TrimSpace(m.QuotedID) != ""
+}
+
+
+func (m *Message) Validate() error {
+	if strings.TrimSpace(m.ID) == "" {
+		return ErrInvalidMessageID
+	}
+	if strings.TrimSpace(m.SessionID) == "" {
+		return session.ErrInvalidSessionID
+	}
+	if strings.TrimSpace(m.ChatJID) == "" {
+		return ErrInvalidChatJID
+	}
+	return nil
+}
+
+
+// Status is the delivery state of an outgoing message, ordered from sent to played.
+type Status string
+
+
+const (
+	StatusSent      Status = "sent"
+	StatusDelivered Status = "delivered"
+	StatusRead      Status = "read"
+	StatusPlayed    Status = "played"
+)
+
+
+var statusRanks = map[Status]int{
+	StatusSent:      1,
+	StatusDelivered: 2,
+	StatusRead:      3,
+	StatusPlayed:    4,
+}
+
+
+func (s Status) IsValid() bool {
+	_, ok := statusRanks[s]
+	return ok
+}
+
+
+// Rank orders statuses so that a later delivery state never gets downgraded.
+func (s Status) Rank() int {
+	return statusRanks[s]
+}
+
+
+// Receipt records one status change of an outgoing message, reported by one participant.
+type Receipt struct {
+	SessionID   string
+	MessageID   string
+	ChatJID     string
+	Participant string
+	Status      Status
+	Timestamp   time.Time
+}
+
+
+func (r *Receipt) Validate() error {
+	if strings.TrimSpace(r.MessageID) == "" {
+		return ErrInvalidMessageID
+	}
+	if strings.TrimSpace(r.SessionID) == "" {
+		return session.ErrInvalidSessionID
+	}
+	if !r.Status.IsValid() {
+		return ErrInvalidStatus
+	}
+	return nil
+}
+
+
+// JobStatus is the lifecycle state of a background job.
+type JobStatus string
+
+
+const (
+	JobStatusPending   JobStatus = "pending"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusCompleted JobStatus = "completed"
+	JobStatusFailed    JobStatus = "failed"
+)
+
+
+func (s JobStatus) IsFinished() bool {
+	return s == JobStatusCompleted || s == JobStatusFailed
+}
+
+
+// HistorySyncJob tracks an on-demand history request for one chat, from the request
+// sent to the primary device until the matching history sync arrives.
+type HistorySyncJob struct {
+	ID             string
+	SessionID      string
+	ChatJID        string
+	AnchorID       string
+	Count          int
+	Status         JobStatus
+	Progress       int
+	Conversations  int
+	MessagesStored int
+	Error          string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	CompletedAt    time.Time
+}
+
+
+func NewHistorySyncJob(sessionID, chatJID, anchorID string, count int) *HistorySyncJob {
+	now := time.Now()
+	return &HistorySyncJob{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		ChatJID:   chatJID,
+		AnchorID:  anchorID,
+		Count:     count,
+		Status:    JobStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+
+func (j *HistorySyncJob) MarkRunning() {
+	j.Status = JobStatusRunning
+	j.UpdatedAt = time.Now()
+}
+
+
+// RecordChunk accumulates one received history sync chunk.
+func (j *HistorySyncJob) RecordChunk(conversations, messages, progress int) {
+	j.Conversations += conversations
+	j.MessagesStored += messages
+	if progress > j.Progress {
+		j.Progress = progress
+	}
+	j.UpdatedAt = time.Now()
+}
+
+
+func (j *HistorySyncJob) Complete() {
+	j.Status = JobStatusCompleted
+	j.Progress = 100
+	j.UpdatedAt = time.Now()
+	j.CompletedAt = j.UpdatedAt
+}
+
+
+func (j *HistorySyncJob) Fail(reason string) {
+	j.Status = JobStatusFailed
+	j.Error = reason
+	j.UpdatedAt = time.Now()
+	j.CompletedAt = j.UpdatedAt
+}
+
+
+func (j *HistorySyncJob) Validate() error {
+	if strings.TrimSpace(j.SessionID) == "" {
+		return session.ErrInvalidSessionID
+	}
+	if strings.TrimSpace(j.ChatJID) == "" {
+		return ErrInvalidChatJID
+	}
+	if j.Count <= 0 || j.Count > MaxHistorySyncCount {
+		return ErrInvalidHistoryCount
+	}
+	return nil
+}
+
+
+const (
+	ExportFormatJSONL = "jsonl"
+	ExportFormatCSV   = "csv"
+	ExportFormatHTML  = "html"
+)
+
+
+// Media modes say how exports include media: a download link, the file inlined, or nothing.
+const (
+	MediaModeLink  = "link"
+	MediaModeEmbed = "embed"
+	MediaModeNone  = "none"
+)
+
+
+var exportFormats = map[string]string{
+	ExportFormatJSONL: ".jsonl",
+	ExportFormatCSV:   ".csv",
+	ExportFormatHTML:  ".html",
+}
+
+
+// ExportJob tracks the export of one chat, or of every chat of a session when ChatJID is empty.
+type ExportJob struct {
+	ID               string
+	SessionID        string
+	ChatJID          string
+	Format           string
+	MediaMode        string
+	Status           JobStatus
+	MessagesExported int
+	FilePath         string
+	FileSize         int64
+	Error            string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	CompletedAt      time.Time
+}
+
+
+func NewExportJob(sessionID, chatJID, format, mediaMode string) *ExportJob {
+	now := time.Now()
+	if mediaMode == "" {
+		mediaMode = MediaModeLink
+	}
+	return &ExportJob{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		ChatJID:   chatJID,
+		Format:    strings.ToLower(format),
+		MediaMode: strings.ToLower(mediaMode),
+		Status:    JobStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+
+// FileExtension returns the extension of the export file, including the dot.
+func (j *ExportJob) FileExtension() string {
+	return exportFormats[j.Format]
+}
+
+
+func (j *ExportJob) MarkRunning() {
+	j.Status = JobStatusRunning
+	j.UpdatedAt = time.Now()
+}
+
+
+func (j *ExportJob) Complete(filePath string, fileSize int64, messages int) {
+	j.Status = JobStatusCompleted
+	j.FilePath = filePath
+	j.FileSize = fileSize
+	j.MessagesExported = messages
+	j.UpdatedAt = time.Now()
+	j.CompletedAt = j.UpdatedAt
+}
+
+
+func (j *ExportJob) Fail(reason string) {
+	j.Status = JobStatusFailed
+	j.Error = reason
+	j.UpdatedAt = time.Now()
+	j.CompletedAt = j.UpdatedAt
+}
+
+
+func (j *ExportJob) Validate() error {
+	if strings.TrimSpace(j.SessionID) == "" {
+		return session.ErrInvalidSessionID
+	}
+	if _, ok := exportFormats[j.Format]; !ok {
+		return ErrInvalidExportFormat
+	}
+	switch j.MediaMode {
+	case MediaModeLink, MediaModeEmbed, MediaModeNone:
+		return nil
+	default:
+		return ErrInvalidMediaMode
+	}
+}
+
+
+var (
+	ErrMessageNotFound     = session.NewDomainError("message not found")
+	ErrInvalidMessageID    = session.NewDomainError("message ID cannot be empty")
+	ErrInvalidChatJID      = session.NewDomainError("chat JID cannot be empty")
+	ErrInvalidCursor       = session.NewDomainError("invalid pagination cursor")
+	ErrInvalidDirection    = session.NewDomainError("direction must be incoming or outgoing")
+	ErrInvalidDateRange    = session.NewDomainError("date range start must be before its end")
+	ErrInvalidFilter       = session.NewDomainError("invalid message filter")
+	ErrEmptySearchQuery    = session.NewDomainError("search query cannot be empty")
+	ErrInvalidStatus       = session.NewDomainError("invalid message status")
+	ErrStatusNotTracked    = session.NewDomainError("delivery status is only tracked for outgoing messages")
+	ErrJobNotFound         = session.NewDomainError("job not found")
+	ErrNoHistoryAnchor     = session.NewDomainError("no known message to anchor the history request")
+	ErrInvalidHistoryCount = session.NewDomainError("history message count is out of range")
+	ErrInvalidExportFormat = session.NewDomainError("export format must be jsonl, csv or html")
+	ErrInvalidMediaMode    = session.NewDomainError("media mode must be link, embed or none")
+	ErrExportNotReady      = session.NewDomainError("export is not completed yet")
+	ErrMessageHasNoMedia   = session.NewDomainError("message has no media")
+	ErrMediaUnavailable    = session.NewDomainError("media of this message is no longer available")
+	ErrNotForwardable      = session.NewDomainError("this kind of message cannot be forwarded")
+)
+
+
+// ReindexJob tracks the rebuild of a session's search index after its search language changes.
+type ReindexJob struct {
+	SessionID   string
+	Language    string
+	Status      JobStatus
+	Total       int64
+	Reindexed   int64
+	Error       string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	CompletedAt time.Time
+}
+
+
+func NewReindexJob(sessionID, language string) *ReindexJob {
+	now := time.Now()
+	return &ReindexJob{
+		SessionID: sessionID,
+		Language:  language,
+		Status:    JobStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+
+func (j *ReindexJob) MarkRunning(total int64) {
+	j.Status = JobStatusRunning
+	j.Total = total
+	j.UpdatedAt = time.Now()
+}
+
+
+func (j *ReindexJob) RecordBatch(reindexed int64) {
+	j.Reindexed += reindexed
+	j.UpdatedAt = time.Now()
+}
+
+
+func (j *ReindexJob) Complete() {
+	j.Status = JobStatusCompleted
+	j.UpdatedAt = time.Now()
+	j.CompletedAt = j.UpdatedAt
+}
+
+
+func (j *ReindexJob) Fail(reason string) {
+	j.Status = JobStatusFailed
+	j.Error = reason
+	j.UpdatedAt = time.Now()
+	j.CompletedAt = j.UpdatedAt
+}
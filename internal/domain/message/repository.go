@@ -0,0 +1,116 @@
+package message
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+
+
+type MessageRepository interface {
+
+	Save(ctx context.Context, message *Message) error
+	GetByID(ctx context.Context, sessionID, id string) (*Message, error)
+	List(ctx context.Context, filter ListFilter) ([]*Message, error)
+
+
+	Search(ctx context.Context, query SearchQuery) ([]*SearchHit, error)
+	ListAround(ctx context.Context, sessionID, chatJID string, anchor Cursor, size int) (before, after []*Message, err error)
+	Reindex(ctx context.Context, sessionID, language string, batchSize int) (int64, error)
+	CountUnindexed(ctx context.Context, sessionID, language string) (int64, error)
+
+
+	SaveReceipt(ctx context.Context, receipt *Receipt) (bool, error)
+	ListReceipts(ctx context.Context, sessionID, messageID string) ([]*Receipt, error)
+
+
+	GetOldestInChat(ctx context.Context, sessionID, chatJID string, before time.Time) (*Message, error)
+	SaveHistorySyncJob(ctx context.Context, job *HistorySyncJob) error
+	GetHistorySyncJob(ctx context.Context, sessionID, id string) (*HistorySyncJob, error)
+	FindActiveHistorySyncJob(ctx context.Context, sessionID, chatJID string) (*HistorySyncJob, error)
+
+
+	ListChronological(ctx context.Context, sessionID, chatJID string, after *Cursor, limit int) ([]*Message, error)
+	SaveExportJob(ctx context.Context, job *ExportJob) error
+	GetExportJob(ctx context.Context, sessionID, id string) (*ExportJob, error)
+	ListUnfinishedExportJobs(ctx context.Context) ([]*ExportJob, error)
+	ListExportJobsFinishedBefore(ctx context.Context, before time.Time, limit int) ([]*ExportJob, error)
+	DeleteExportJob(ctx context.Context, sessionID, id string) error
+}
+
+
+// ListFilter narrows a chat history query. Zero values mean "no restriction".
+type ListFilter struct {
+	SessionID string
+	ChatJID   string
+	Direction Direction
+	Types     []string
+	SenderJID string
+	Since     time.Time
+	Until     time.Time
+	HasMedia  *bool
+	Cursor    *Cursor
+	Limit     int
+}
+
+
+// SearchQuery describes a full-text search across the messages of a session.
+type SearchQuery struct {
+	SessionID   string
+	Language    string
+	Query       string
+	ChatJID     string
+	Types       []string
+	Since       time.Time
+	Until       time.Time
+	ContextSize int
+	Offset      int
+	Limit       int
+}
+
+
+// SearchHit is a matching message with its highlighted snippet and surrounding chat messages.
+type SearchHit struct {
+	Message *Message
+	Snippet string
+	Rank    float64
+	Before  []*Message
+	After   []*Message
+}
+
+
+// Cursor points at the last message of a page; the next page starts strictly before it.
+type Cursor struct {
+	Timestamp time.Time
+	ID        string
+}
+
+
+func (c Cursor) Encode() string {
+	raw := fmt.Sprintf("%d:%s", c.Timestamp.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+
+func DecodeCursor(encoded string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{Timestamp: time.Unix(0, nanos), ID: parts[1]}, nil
+}
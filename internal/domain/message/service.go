@@ -0,0 +1,447 @@
+package message
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"zpmeow/internal/domain/session"
+)
+
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+
+	DefaultSearchPageSize = 20
+	MaxSearchPageSize     = 100
+	DefaultContextSize    = 2
+	MaxContextSize        = 10
+
+	DefaultHistorySyncCount = 50
+	MaxHistorySyncCount     = 500
+
+	exportBatchSize  = 500
+	reindexBatchSize = 1000
+)
+
+
+
+type MessageService interface {
+
+	SaveMessage(ctx context.Context, message *Message) error
+	GetMessage(ctx context.Context, sessionID, id string) (*Message, error)
+	ListChatMessages(ctx context.Context, filter ListFilter) (*MessagePage, error)
+
+
+	SearchMessages(ctx context.Context, query SearchQuery) (*SearchPage, error)
+	CountUnindexed(ctx context.Context, sessionID, language string) (int64, error)
+	ReindexSession(ctx context.Context, sessionID, language string, progress func(reindexed int64)) (int64, error)
+
+
+	RecordReceipt(ctx context.Context, receipt *Receipt) (bool, error)
+	GetMessageStatus(ctx context.Context, sessionID, id string) (*MessageStatus, error)
+
+
+	FindHistoryAnchor(ctx context.Context, sessionID, chatJID string, before time.Time) (*Message, error)
+	CreateHistorySyncJob(ctx context.Context, job *HistorySyncJob) error
+	UpdateHistorySyncJob(ctx context.Context, job *HistorySyncJob) error
+	GetHistorySyncJob(ctx context.Context, sessionID, id string) (*HistorySyncJob, error)
+	FindActiveHistorySyncJob(ctx context.Context, sessionID, chatJID string) (*HistorySyncJob, error)
+
+
+	EachMessage(ctx context.Context, sessionID, chatJID string, fn func(*Message) error) (int, error)
+	CreateExportJob(ctx context.Context, job *ExportJob) error
+	UpdateExportJob(ctx context.Context, job *ExportJob) error
+	GetExportJob(ctx context.Context, sessionID, id string) (*ExportJob, error)
+	ListUnfinishedExportJobs(ctx context.Context) ([]*ExportJob, error)
+	ListExpiredExportJobs(ctx context.Context, before time.Time, limit int) ([]*ExportJob, error)
+	DeleteExportJob(ctx context.Context, sessionID, id string) error
+}
+
+
+type MessagePage struct {
+	Messages   []*Message
+	NextCursor string
+	HasMore    bool
+}
+
+
+type SearchPage struct {
+	Hits       []*SearchHit
+	HasMore    bool
+	NextOffset int
+}
+
+
+// MessageStatus is the delivery state of an outgoing message. Status is the furthest
+// state reported by any participant; Participants breaks it down per recipient.
+type MessageStatus struct {
+	Message      *Message
+	Status       Status
+	Timeline     []*Receipt
+	Participants []*ParticipantStatus
+}
+
+
+type ParticipantStatus struct {
+	JID         string
+	Status      Status
+	DeliveredAt time.Time
+	ReadAt      time.Time
+	PlayedAt    time.Time
+}
+
+
+type MessageServiceImpl struct {
+	repo MessageRepository
+}
+
+
+func NewMessageService(repo MessageRepository) MessageService {
+	return &MessageServiceImpl{repo: repo}
+}
+
+
+func (s *MessageServiceImpl) SaveMessage(ctx context.Context, message *Message) error {
+	if err := message.Validate(); err != nil {
+		return err
+	}
+	return s.repo.Save(ctx, message)
+}
+
+
+func (s *MessageServiceImpl) GetMessage(ctx context.Context, sessionID, id string) (*Message, error) {
+	if strings.TrimSpace(id) == "" {
+		return nil, ErrInvalidMessageID
+	}
+	return s.repo.GetByID(ctx, sessionID, id)
+}
+
+
+func (s *MessageServiceImpl) ListChatMessages(ctx context.Context, filter ListFilter) (*MessagePage, error) {
+	if err := normalizeFilter(&filter); err != nil {
+		return nil, err
+	}
+
+	pageSize := filter.Limit
+
+	// Fetch one extra row to know whether another page exists.
+	filter.Limit = pageSize + 1
+	messages, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &MessagePage{Messages: messages}
+	if len(messages) > pageSize {
+		page.Messages = messages[:pageSize]
+		page.HasMore = true
+
+		last := page.Messages[pageSize-1]
+		page.NextCursor = Cursor{Timestamp: last.Timestamp, ID: last.ID}.Encode()
+	}
+
+	return page, nil
+}
+
+
+func (s *MessageServiceImpl) SearchMessages(ctx context.Context, query SearchQuery) (*SearchPage, error) {
+	if err := normalizeSearchQuery(&query); err != nil {
+		return nil, err
+	}
+
+	pageSize := query.Limit
+
+	query.Limit = pageSize + 1
+	hits, err := s.repo.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &SearchPage{Hits: hits}
+	if len(hits) > pageSize {
+		page.Hits = hits[:pageSize]
+		page.HasMore = true
+		page.NextOffset = query.Offset + pageSize
+	}
+
+	if query.ContextSize > 0 {
+		for _, hit := range page.Hits {
+			anchor := Cursor{Timestamp: hit.Message.Timestamp, ID: hit.Message.ID}
+			hit.Before, hit.After, err = s.repo.ListAround(ctx, query.SessionID, hit.Message.ChatJID, anchor, query.ContextSize)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return page, nil
+}
+
+
+// CountUnindexed counts the messages of a session still indexed with another search language.
+func (s *MessageServiceImpl) CountUnindexed(ctx context.Context, sessionID, language string) (int64, error) {
+	if !session.IsSupportedSearchLanguage(language) {
+		return 0, session.ErrUnsupportedSearchLanguage
+	}
+	return s.repo.CountUnindexed(ctx, sessionID, language)
+}
+
+
+// ReindexSession rebuilds the search vectors of a session with language in batches, until a short
+// batch shows no message is left. progress, when set, is called with the size of every batch.
+func (s *MessageServiceImpl) ReindexSession(ctx context.Context, sessionID, language string, progress func(reindexed int64)) (int64, error) {
+	if !session.IsSupportedSearchLanguage(language) {
+		return 0, session.ErrUnsupportedSearchLanguage
+	}
+
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		n, err := s.repo.Reindex(ctx, sessionID, language, reindexBatchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if progress != nil && n > 0 {
+			progress(n)
+		}
+		if n < reindexBatchSize {
+			return total, nil
+		}
+	}
+}
+
+
+// RecordReceipt stores a receipt and reports whether it belongs to a known outgoing message
+// and was not recorded before.
+func (s *MessageServiceImpl) RecordReceipt(ctx context.Context, receipt *Receipt) (bool, error) {
+	if err := receipt.Validate(); err != nil {
+		return false, err
+	}
+	return s.repo.SaveReceipt(ctx, receipt)
+}
+
+
+func (s *MessageServiceImpl) GetMessageStatus(ctx context.Context, sessionID, id string) (*MessageStatus, error) {
+	msg, err := s.GetMessage(ctx, sessionID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !msg.FromMe {
+		return nil, ErrStatusNotTracked
+	}
+
+	receipts, err := s.repo.ListReceipts(ctx, sessionID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildMessageStatus(msg, receipts), nil
+}
+
+
+// BuildMessageStatus folds the receipts of a message, ordered by timestamp, into its status.
+func BuildMessageStatus(msg *Message, receipts []*Receipt) *MessageStatus {
+	status := &MessageStatus{
+		Message:  msg,
+		Status:   StatusSent,
+		Timeline: receipts,
+	}
+
+	byParticipant := make(map[string]*ParticipantStatus)
+	for _, r := range receipts {
+		if r.Status.Rank() > status.Status.Rank() {
+			status.Status = r.Status
+		}
+
+		p, ok := byParticipant[r.Participant]
+		if !ok {
+			p = &ParticipantStatus{JID: r.Participant, Status: StatusSent}
+			byParticipant[r.Participant] = p
+			status.Participants = append(status.Participants, p)
+		}
+		if r.Status.Rank() > p.Status.Rank() {
+			p.Status = r.Status
+		}
+
+		switch r.Status {
+		case StatusDelivered:
+			p.DeliveredAt = r.Timestamp
+		case StatusRead:
+			p.ReadAt = r.Timestamp
+		case StatusPlayed:
+			p.PlayedAt = r.Timestamp
+		}
+	}
+
+	return status
+}
+
+
+// FindHistoryAnchor returns the oldest stored message of a chat, optionally restricted to before a time.
+func (s *MessageServiceImpl) FindHistoryAnchor(ctx context.Context, sessionID, chatJID string, before time.Time) (*Message, error) {
+	anchor, err := s.repo.GetOldestInChat(ctx, sessionID, chatJID, before)
+	if err == ErrMessageNotFound {
+		return nil, ErrNoHistoryAnchor
+	}
+	return anchor, err
+}
+
+
+func (s *MessageServiceImpl) CreateHistorySyncJob(ctx context.Context, job *HistorySyncJob) error {
+	if job.Count == 0 {
+		job.Count = DefaultHistorySyncCount
+	}
+	if err := job.Validate(); err != nil {
+		return err
+	}
+	return s.repo.SaveHistorySyncJob(ctx, job)
+}
+
+
+func (s *MessageServiceImpl) UpdateHistorySyncJob(ctx context.Context, job *HistorySyncJob) error {
+	return s.repo.SaveHistorySyncJob(ctx, job)
+}
+
+
+func (s *MessageServiceImpl) GetHistorySyncJob(ctx context.Context, sessionID, id string) (*HistorySyncJob, error) {
+	return s.repo.GetHistorySyncJob(ctx, sessionID, id)
+}
+
+
+func (s *MessageServiceImpl) FindActiveHistorySyncJob(ctx context.Context, sessionID, chatJID string) (*HistorySyncJob, error) {
+	return s.repo.FindActiveHistorySyncJob(ctx, sessionID, chatJID)
+}
+
+
+// EachMessage calls fn for every stored message of a chat, or of the whole session when chatJID
+// is empty, oldest first. It pages through the store so exports never hold everything in memory.
+func (s *MessageServiceImpl) EachMessage(ctx context.Context, sessionID, chatJID string, fn func(*Message) error) (int, error) {
+	var cursor *Cursor
+	count := 0
+
+	for {
+		messages, err := s.repo.ListChronological(ctx, sessionID, chatJID, cursor, exportBatchSize)
+		if err != nil {
+			return count, err
+		}
+
+		for _, m := range messages {
+			if err := fn(m); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if len(messages) < exportBatchSize {
+			return count, nil
+		}
+
+		last := messages[len(messages)-1]
+		cursor = &Cursor{Timestamp: last.Timestamp, ID: last.ID}
+	}
+}
+
+
+func (s *MessageServiceImpl) CreateExportJob(ctx context.Context, job *ExportJob) error {
+	if err := job.Validate(); err != nil {
+		return err
+	}
+	return s.repo.SaveExportJob(ctx, job)
+}
+
+
+func (s *MessageServiceImpl) UpdateExportJob(ctx context.Context, job *ExportJob) error {
+	return s.repo.SaveExportJob(ctx, job)
+}
+
+
+func (s *MessageServiceImpl) GetExportJob(ctx context.Context, sessionID, id string) (*ExportJob, error) {
+	return s.repo.GetExportJob(ctx, sessionID, id)
+}
+
+
+// ListUnfinishedExportJobs returns the jobs still pending or running, which after a restart are
+// exports that were interrupted.
+func (s *MessageServiceImpl) ListUnfinishedExportJobs(ctx context.Context) ([]*ExportJob, error) {
+	return s.repo.ListUnfinishedExportJobs(ctx)
+}
+
+
+// ListExpiredExportJobs returns up to limit completed or failed jobs that finished before the cutoff.
+func (s *MessageServiceImpl) ListExpiredExportJobs(ctx context.Context, before time.Time, limit int) ([]*ExportJob, error) {
+	return s.repo.ListExportJobsFinishedBefore(ctx, before, limit)
+}
+
+
+func (s *MessageServiceImpl) DeleteExportJob(ctx context.Context, sessionID, id string) error {
+	return s.repo.DeleteExportJob(ctx, sessionID, id)
+}
+
+
+func normalizeFilter(filter *ListFilter) error {
+	if strings.TrimSpace(filter.ChatJID) == "" {
+		return ErrInvalidChatJID
+	}
+
+	if filter.Direction != "" && !filter.Direction.IsValid() {
+		return ErrInvalidDirection
+	}
+
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && filter.Since.After(filter.Until) {
+		return ErrInvalidDateRange
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultPageSize
+	}
+	if filter.Limit > MaxPageSize {
+		filter.Limit = MaxPageSize
+	}
+
+	return nil
+}
+
+
+func normalizeSearchQuery(query *SearchQuery) error {
+	query.Query = strings.TrimSpace(query.Query)
+	if query.Query == "" {
+		return ErrEmptySearchQuery
+	}
+
+	if query.Language == "" {
+		query.Language = session.DefaultSearchLanguage
+	}
+	if !session.IsSupportedSearchLanguage(query.Language) {
+		return session.ErrUnsupportedSearchLanguage
+	}
+
+	if !query.Since.IsZero() && !query.Until.IsZero() && query.Since.After(query.Until) {
+		return ErrInvalidDateRange
+	}
+
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = DefaultSearchPageSize
+	}
+	if query.Limit > MaxSearchPageSize {
+		query.Limit = MaxSearchPageSize
+	}
+
+	if query.ContextSize < 0 {
+		query.ContextSize = 0
+	}
+	if query.ContextSize > MaxContextSize {
+		query.ContextSize = MaxContextSize
+	}
+
+	return nil
+}
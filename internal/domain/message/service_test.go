@@ -0,0 +1,365 @@
+package message
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"zpmeow/internal/domain/session"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+
+type MockMessageRepository struct {
+	mock.Mock
+}
+
+func (m *MockMessageRepository) Save(ctx context.Context, message *Message) error {
+	args := m.Called(ctx, message)
+	return args.Error(0)
+}
+
+func (m *MockMessageRepository) GetByID(ctx context.Context, sessionID, id string) (*Message, error) {
+	args := m.Called(ctx, sessionID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Message), args.Error(1)
+}
+
+func (m *MockMessageRepository) List(ctx context.Context, filter ListFilter) ([]*Message, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*Message), args.Error(1)
+}
+
+func (m *MockMessageRepository) Search(ctx context.Context, query SearchQuery) ([]*SearchHit, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).([]*SearchHit), args.Error(1)
+}
+
+func (m *MockMessageRepository) ListAround(ctx context.Context, sessionID, chatJID string, anchor Cursor, size int) ([]*Message, []*Message, error) {
+	args := m.Called(ctx, sessionID, chatJID, anchor, size)
+	return args.Get(0).([]*Message), args.Get(1).([]*Message), args.Error(2)
+}
+
+func (m *MockMessageRepository) SaveReceipt(ctx context.Context, receipt *Receipt) (bool, error) {
+	args := m.Called(ctx, receipt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockMessageRepository) ListReceipts(ctx context.Context, sessionID, messageID string) ([]*Receipt, error) {
+	args := m.Called(ctx, sessionID, messageID)
+	return args.Get(0).([]*Receipt), args.Error(1)
+}
+
+func (m *MockMessageRepository) GetOldestInChat(ctx context.Context, sessionID, chatJID string, before time.Time) (*Message, error) {
+	args := m.Called(ctx, sessionID, chatJID, before)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Message), args.Error(1)
+}
+
+func (m *MockMessageRepository) SaveHistorySyncJob(ctx context.Context, job *HistorySyncJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockMessageRepository) GetHistorySyncJob(ctx context.Context, sessionID, id string) (*HistorySyncJob, error) {
+	args := m.Called(ctx, sessionID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*HistorySyncJob), args.Error(1)
+}
+
+func (m *MockMessageRepository) FindActiveHistorySyncJob(ctx context.Context, sessionID, chatJID string) (*HistorySyncJob, error) {
+	args := m.Called(ctx, sessionID, chatJID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*HistorySyncJob), args.Error(1)
+}
+
+func (m *MockMessageRepository) Reindex(ctx context.Context, sessionID, language string, batchSize int) (int64, error) {
+	args := m.Called(ctx, sessionID, language, batchSize)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockMessageRepository) CountUnindexed(ctx context.Context, sessionID, language string) (int64, error) {
+	args := m.Called(ctx, sessionID, language)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockMessageRepository) ListChronological(ctx context.Context, sessionID, chatJID string, after *Cursor, limit int) ([]*Message, error) {
+	args := m.Called(ctx, sessionID, chatJID, after, limit)
+	return args.Get(0).([]*Message), args.Error(1)
+}
+
+func (m *MockMessageRepository) SaveExportJob(ctx context.Context, job *ExportJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockMessageRepository) GetExportJob(ctx context.Context, sessionID, id string) (*ExportJob, error) {
+	args := m.Called(ctx, sessionID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ExportJob), args.Error(1)
+}
+
+func (m *MockMessageRepository) ListUnfinishedExportJobs(ctx context.Context) ([]*ExportJob, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*ExportJob), args.Error(1)
+}
+
+func (m *MockMessageRepository) ListExportJobsFinishedBefore(ctx context.Context, before time.Time, limit int) ([]*ExportJob, error) {
+	args := m.Called(ctx, before, limit)
+	return args.Get(0).([]*ExportJob), args.Error(1)
+}
+
+func (m *MockMessageRepository) DeleteExportJob(ctx context.Context, sessionID, id string) error {
+	args := m.Called(ctx, sessionID, id)
+	return args.Error(0)
+}
+
+
+func buildMessages(n int) []*Message {
+	base := time.Unix(1700000000, 0)
+	messages := make([]*Message, n)
+	for i := range messages {
+		messages[i] = &Message{
+			ID:        fmt.Sprintf("MSG%d", i),
+			SessionID: "session-1",
+			ChatJID:   "5511999999999@s.whatsapp.net",
+			Type:      TypeText,
+			Timestamp: base.Add(-time.Duration(i) * time.Second),
+		}
+	}
+	return messages
+}
+
+
+func TestCursor_RoundTrip(t *testing.T) {
+	cursor := Cursor{Timestamp: time.Unix(1700000000, 123), ID: "3EB0:ABC"}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+
+	assert.NoError(t, err)
+	assert.Equal(t, cursor.ID, decoded.ID)
+	assert.True(t, cursor.Timestamp.Equal(decoded.Timestamp))
+}
+
+func TestDecodeCursor_Invalid(t *testing.T) {
+	for _, value := range []string{"!!!", "bm9jb2xvbg", "YWJjOnh5eg"} {
+		_, err := DecodeCursor(value)
+		assert.Equal(t, ErrInvalidCursor, err, value)
+	}
+}
+
+func TestListChatMessages_HasMore(t *testing.T) {
+	repo := new(MockMessageRepository)
+	service := NewMessageService(repo)
+
+	messages := buildMessages(3)
+	repo.On("List", mock.Anything, mock.MatchedBy(func(f ListFilter) bool { return f.Limit == 3 })).Return(messages, nil)
+
+	page, err := service.ListChatMessages(context.Background(), ListFilter{SessionID: "session-1", ChatJID: "5511999999999@s.whatsapp.net", Limit: 2})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Messages, 2)
+	assert.True(t, page.HasMore)
+
+	cursor, err := DecodeCursor(page.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, "MSG1", cursor.ID)
+	repo.AssertExpectations(t)
+}
+
+func TestListChatMessages_LastPage(t *testing.T) {
+	repo := new(MockMessageRepository)
+	service := NewMessageService(repo)
+
+	repo.On("List", mock.Anything, mock.MatchedBy(func(f ListFilter) bool { return f.Limit == DefaultPageSize+1 })).Return(buildMessages(1), nil)
+
+	page, err := service.ListChatMessages(context.Background(), ListFilter{SessionID: "session-1", ChatJID: "5511999999999@s.whatsapp.net"})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Messages, 1)
+	assert.False(t, page.HasMore)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestListChatMessages_InvalidFilter(t *testing.T) {
+	repo := new(MockMessageRepository)
+	service := NewMessageService(repo)
+
+	_, err := service.ListChatMessages(context.Background(), ListFilter{SessionID: "session-1"})
+	assert.Equal(t, ErrInvalidChatJID, err)
+
+	_, err = service.ListChatMessages(context.Background(), ListFilter{SessionID: "session-1", ChatJID: "x@s.whatsapp.net", Direction: "sideways"})
+	assert.Equal(t, ErrInvalidDirection, err)
+
+	_, err = service.ListChatMessages(context.Background(), ListFilter{
+		SessionID: "session-1",
+		ChatJID:   "x@s.whatsapp.net",
+		Since:     time.Unix(200, 0),
+		Until:     time.Unix(100, 0),
+	})
+	assert.Equal(t, ErrInvalidDateRange, err)
+
+	repo.AssertNotCalled(t, "List", mock.Anything, mock.Anything)
+}
+
+func TestSearchMessages_PageWithContext(t *testing.T) {
+	repo := new(MockMessageRepository)
+	service := NewMessageService(repo)
+
+	messages := buildMessages(3)
+	hits := []*SearchHit{{Message: messages[0]}, {Message: messages[1]}, {Message: messages[2]}}
+	repo.On("Search", mock.Anything, mock.MatchedBy(func(q SearchQuery) bool {
+		return q.Limit == 3 && q.Language == "portuguese" && q.Query == "invoice 4821"
+	})).Return(hits, nil)
+	repo.On("ListAround", mock.Anything, "session-1", messages[0].ChatJID, mock.Anything, 1).
+		Return([]*Message{}, []*Message{messages[2]}, nil)
+
+	page, err := service.SearchMessages(context.Background(), SearchQuery{
+		SessionID:   "session-1",
+		Query:       "  invoice 4821 ",
+		Limit:       2,
+		ContextSize: 1,
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Hits, 2)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, 2, page.NextOffset)
+	assert.Len(t, page.Hits[0].After, 1)
+	repo.AssertNumberOfCalls(t, "ListAround", 2)
+}
+
+func TestSearchMessages_InvalidQuery(t *testing.T) {
+	repo := new(MockMessageRepository)
+	service := NewMessageService(repo)
+
+	_, err := service.SearchMessages(context.Background(), SearchQuery{SessionID: "session-1", Query: "   "})
+	assert.Equal(t, ErrEmptySearchQuery, err)
+
+	_, err = service.SearchMessages(context.Background(), SearchQuery{SessionID: "session-1", Query: "invoice", Language: "klingon"})
+	assert.Equal(t, session.ErrUnsupportedSearchLanguage, err)
+
+	repo.AssertNotCalled(t, "Search", mock.Anything, mock.Anything)
+}
+
+func TestReindexSession_Batches(t *testing.T) {
+	repo := new(MockMessageRepository)
+	service := NewMessageService(repo)
+
+	repo.On("Reindex", mock.Anything, "session-1", "english", reindexBatchSize).Return(int64(reindexBatchSize), nil).Twice()
+	repo.On("Reindex", mock.Anything, "session-1", "english", reindexBatchSize).Return(int64(7), nil).Once()
+
+	var batches []int64
+	total, err := service.ReindexSession(context.Background(), "session-1", "english", func(n int64) {
+		batches = append(batches, n)
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2*reindexBatchSize+7), total)
+	assert.Equal(t, []int64{reindexBatchSize, reindexBatchSize, 7}, batches)
+	repo.AssertNumberOfCalls(t, "Reindex", 3)
+
+	_, err = service.ReindexSession(context.Background(), "session-1", "klingon", nil)
+	assert.Equal(t, session.ErrUnsupportedSearchLanguage, err)
+	repo.AssertNumberOfCalls(t, "Reindex", 3)
+}
+
+func TestBuildMessageStatus_Group(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	msg := &Message{ID: "MSG0", FromMe: true, IsGroup: true, Timestamp: base}
+	receipts := []*Receipt{
+		{Participant: "alice", Status: StatusDelivered, Timestamp: base.Add(time.Second)},
+		{Participant: "bob", Status: StatusDelivered, Timestamp: base.Add(2 * time.Second)},
+		{Participant: "alice", Status: StatusRead, Timestamp: base.Add(3 * time.Second)},
+	}
+
+	status := BuildMessageStatus(msg, receipts)
+
+	assert.Equal(t, StatusRead, status.Status)
+	assert.Len(t, status.Participants, 2)
+	assert.Equal(t, StatusRead, status.Participants[0].Status)
+	assert.Equal(t, base.Add(time.Second), status.Participants[0].DeliveredAt)
+	assert.Equal(t, base.Add(3*time.Second), status.Participants[0].ReadAt)
+	assert.Equal(t, StatusDelivered, status.Participants[1].Status)
+	assert.True(t, status.Participants[1].ReadAt.IsZero())
+}
+
+func TestGetMessageStatus_IncomingMessage(t *testing.T) {
+	repo := new(MockMessageRepository)
+	service := NewMessageService(repo)
+
+	repo.On("GetByID", mock.Anything, "session-1", "MSG0").Return(&Message{ID: "MSG0", FromMe: false}, nil)
+
+	_, err := service.GetMessageStatus(context.Background(), "session-1", "MSG0")
+
+	assert.Equal(t, ErrStatusNotTracked, err)
+	repo.AssertNotCalled(t, "ListReceipts", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateHistorySyncJob(t *testing.T) {
+	repo := new(MockMessageRepository)
+	service := NewMessageService(repo)
+
+	repo.On("SaveHistorySyncJob", mock.Anything, mock.Anything).Return(nil)
+
+	job := NewHistorySyncJob("session-1", "5511999999999@s.whatsapp.net", "MSG0", 0)
+	assert.NoError(t, service.CreateHistorySyncJob(context.Background(), job))
+	assert.Equal(t, DefaultHistorySyncCount, job.Count)
+	assert.Equal(t, JobStatusPending, job.Status)
+
+	tooMany := NewHistorySyncJob("session-1", "5511999999999@s.whatsapp.net", "MSG0", MaxHistorySyncCount+1)
+	assert.Equal(t, ErrInvalidHistoryCount, service.CreateHistorySyncJob(context.Background(), tooMany))
+	repo.AssertNumberOfCalls(t, "SaveHistorySyncJob", 1)
+}
+
+func TestFindHistoryAnchor_NoMessages(t *testing.T) {
+	repo := new(MockMessageRepository)
+	service := NewMessageService(repo)
+
+	repo.On("GetOldestInChat", mock.Anything, "session-1", "x@s.whatsapp.net", time.Time{}).Return(nil, ErrMessageNotFound)
+
+	_, err := service.FindHistoryAnchor(context.Background(), "session-1", "x@s.whatsapp.net", time.Time{})
+	assert.Equal(t, ErrNoHistoryAnchor, err)
+}
+
+func TestEachMessage_PagesUntilShortBatch(t *testing.T) {
+	repo := new(MockMessageRepository)
+	service := NewMessageService(repo)
+
+	first := buildMessages(exportBatchSize)
+	last := first[len(first)-1]
+	repo.On("ListChronological", mock.Anything, "session-1", "", (*Cursor)(nil), exportBatchSize).Return(first, nil)
+	repo.On("ListChronological", mock.Anything, "session-1", "", &Cursor{Timestamp: last.Timestamp, ID: last.ID}, exportBatchSize).Return(buildMessages(3), nil)
+
+	seen := 0
+	count, err := service.EachMessage(context.Background(), "session-1", "", func(*Message) error {
+		seen++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, exportBatchSize+3, count)
+	assert.Equal(t, count, seen)
+	repo.AssertNumberOfCalls(t, "ListChronological", 2)
+}
+
+func TestCreateExportJob_InvalidFormat(t *testing.T) {
+	repo := new(MockMessageRepository)
+	service := NewMessageService(repo)
+
+	job := NewExportJob("session-1", "", "pdf", "")
+	assert.Equal(t, ErrInvalidExportFormat, service.CreateExportJob(context.Background(), job))
+	repo.AssertNotCalled(t, "SaveExportJob", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,81 @@
+package retention
+
+import "time"
+
+
+type PolicyRequest struct {
+	Enabled      bool `json:"enabled" example:"true"`
+	MessagesDays int  `json:"messagesDays" example:"90"`
+	MediaDays    int  `json:"mediaDays" example:"30"`
+	ReceiptsDays int  `json:"receiptsDays" example:"7"`
+}
+
+
+func (r *PolicyRequest) ToPolicy(sessionID string) *Policy {
+	return &Policy{
+		SessionID:    sessionID,
+		Enabled:      r.Enabled,
+		MessagesDays: r.MessagesDays,
+		MediaDays:    r.MediaDays,
+		ReceiptsDays: r.ReceiptsDays,
+	}
+}
+
+
+type PolicyResponse struct {
+	Source       string `json:"source" example:"session"`
+	Enabled      bool   `json:"enabled" example:"true"`
+	MessagesDays int    `json:"messagesDays" example:"90"`
+	MediaDays    int    `json:"mediaDays" example:"30"`
+	ReceiptsDays int    `json:"receiptsDays" example:"7"`
+	UpdatedAt    int64  `json:"updatedAt,omitempty" example:"1640995200"`
+}
+
+
+func NewPolicyResponse(p *Policy) PolicyResponse {
+	resp := PolicyResponse{
+		Source:       p.Source(),
+		Enabled:      p.Enabled,
+		MessagesDays: p.MessagesDays,
+		MediaDays:    p.MediaDays,
+		ReceiptsDays: p.ReceiptsDays,
+	}
+	if !p.UpdatedAt.IsZero() {
+		resp.UpdatedAt = p.UpdatedAt.Unix()
+	}
+	return resp
+}
+
+
+type ReportResponse struct {
+	SessionID      string `json:"sessionId" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Messages       int64  `json:"messages" example:"1520"`
+	Media          int64  `json:"media" example:"310"`
+	Receipts       int64  `json:"receipts" example:"4800"`
+	Total          int64  `json:"total" example:"6630"`
+	MessagesBefore int64  `json:"messagesBefore,omitempty" example:"1633219200"`
+	MediaBefore    int64  `json:"mediaBefore,omitempty" example:"1638403200"`
+	ReceiptsBefore int64  `json:"receiptsBefore,omitempty" example:"1640390400"`
+}
+
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+
+func NewReportResponse(r *Report) ReportResponse {
+	return ReportResponse{
+		SessionID:      r.SessionID,
+		Messages:       r.Messages,
+		Media:          r.Media,
+		Receipts:       r.Receipts,
+		Total:          r.Total(),
+		MessagesBefore: unixOrZero(r.Cutoffs.Messages),
+		MediaBefore:    unixOrZero(r.Cutoffs.Media),
+		ReceiptsBefore: unixOrZero(r.Cutoffs.Receipts),
+	}
+}
@@ -0,0 +1,88 @@
+package retention
+
+import (
+	"time"
+
+	"zpmeow/internal/domain/session"
+)
+
+
+// Policy says how many days each kind of stored data is kept. Zero keeps the data forever.
+// A policy without SessionID is the global default; a session policy replaces it entirely.
+type Policy struct {
+	SessionID    string
+	Enabled      bool
+	MessagesDays int
+	MediaDays    int
+	ReceiptsDays int
+	UpdatedAt    time.Time
+}
+
+
+const (
+	SourceGlobal  = "global"
+	SourceSession = "session"
+)
+
+
+func (p *Policy) Source() string {
+	if p.SessionID == "" {
+		return SourceGlobal
+	}
+	return SourceSession
+}
+
+
+func (p *Policy) Validate() error {
+	if p.MessagesDays < 0 || p.MediaDays < 0 || p.ReceiptsDays < 0 {
+		return ErrInvalidRetentionDays
+	}
+	return nil
+}
+
+
+// Cutoffs returns the instants before which data expires; zero means the data never expires.
+func (p *Policy) Cutoffs(now time.Time) Cutoffs {
+	return Cutoffs{
+		Messages: cutoff(now, p.MessagesDays),
+		Media:    cutoff(now, p.MediaDays),
+		Receipts: cutoff(now, p.ReceiptsDays),
+	}
+}
+
+
+type Cutoffs struct {
+	Messages time.Time
+	Media    time.Time
+	Receipts time.Time
+}
+
+
+func cutoff(now time.Time, days int) time.Time {
+	if days <= 0 {
+		return time.Time{}
+	}
+	return now.AddDate(0, 0, -days)
+}
+
+
+// Report counts what a policy removes for one session: messages deleted, media payloads
+// dropped from messages that are kept, and delivery receipts deleted.
+type Report struct {
+	SessionID string
+	Cutoffs   Cutoffs
+	Messages  int64
+	Media     int64
+	Receipts  int64
+}
+
+
+func (r *Report) Total() int64 {
+	return r.Messages + r.Media + r.Receipts
+}
+
+
+var (
+	ErrInvalidRetentionDays = session.NewDomainError("retention days cannot be negative")
+	ErrPolicyNotFound       = session.NewDomainError("retention policy not found")
+)
@@ -0,0 +1,20 @@
+package retention
+
+import (
+	"context"
+	"time"
+)
+
+
+type RetentionRepository interface {
+	GetSessionPolicy(ctx context.Context, sessionID string) (*Policy, error)
+	SaveSessionPolicy(ctx context.Context, policy *Policy) error
+	DeleteSessionPolicy(ctx context.Context, sessionID string) error
+	ListSessionIDs(ctx context.Context) ([]string, error)
+
+
+	CountExpired(ctx context.Context, sessionID string, cutoffs Cutoffs) (*Report, error)
+	PurgeMessages(ctx context.Context, sessionID string, before time.Time, batchSize int) (int64, error)
+	PurgeMedia(ctx context.Context, sessionID string, before time.Time, batchSize int) (int64, error)
+	PurgeReceipts(ctx context.Context, sessionID string, before time.Time, batchSize int) (int64, error)
+}
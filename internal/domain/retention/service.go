@@ -0,0 +1,164 @@
+package retention
+
+import (
+	"context"
+	"time"
+)
+
+
+const DefaultBatchSize = 1000
+
+
+type RetentionService interface {
+	GetPolicy(ctx context.Context, sessionID string) (*Policy, error)
+	SetSessionPolicy(ctx context.Context, policy *Policy) error
+	ClearSessionPolicy(ctx context.Context, sessionID string) error
+
+
+	Preview(ctx context.Context, sessionID string, policy *Policy) (*Report, error)
+	PurgeSession(ctx context.Context, sessionID string) (*Report, error)
+	PurgeAll(ctx context.Context) ([]*Report, error)
+}
+
+
+type RetentionServiceImpl struct {
+	repo      RetentionRepository
+	global    Policy
+	batchSize int
+	now       func() time.Time
+}
+
+
+func NewRetentionService(repo RetentionRepository, global Policy, batchSize int) RetentionService {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	global.SessionID = ""
+
+	return &RetentionServiceImpl{
+		repo:      repo,
+		global:    global,
+		batchSize: batchSize,
+		now:       time.Now,
+	}
+}
+
+
+// GetPolicy returns the session policy, falling back to the global one.
+func (s *RetentionServiceImpl) GetPolicy(ctx context.Context, sessionID string) (*Policy, error) {
+	policy, err := s.repo.GetSessionPolicy(ctx, sessionID)
+	if err == ErrPolicyNotFound {
+		global := s.global
+		return &global, nil
+	}
+	return policy, err
+}
+
+
+func (s *RetentionServiceImpl) SetSessionPolicy(ctx context.Context, policy *Policy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	policy.UpdatedAt = s.now()
+	return s.repo.SaveSessionPolicy(ctx, policy)
+}
+
+
+func (s *RetentionServiceImpl) ClearSessionPolicy(ctx context.Context, sessionID string) error {
+	return s.repo.DeleteSessionPolicy(ctx, sessionID)
+}
+
+
+// Preview reports what the policy would remove right now, whether or not it is enabled.
+// A nil policy previews the session's current policy.
+func (s *RetentionServiceImpl) Preview(ctx context.Context, sessionID string, policy *Policy) (*Report, error) {
+	if policy == nil {
+		var err error
+		if policy, err = s.GetPolicy(ctx, sessionID); err != nil {
+			return nil, err
+		}
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	report, err := s.repo.CountExpired(ctx, sessionID, policy.Cutoffs(s.now()))
+	if err != nil {
+		return nil, err
+	}
+	report.SessionID = sessionID
+	return report, nil
+}
+
+
+// PurgeSession applies the session policy if it is enabled. Messages are deleted before media
+// is dropped so the media count only covers messages that are kept.
+func (s *RetentionServiceImpl) PurgeSession(ctx context.Context, sessionID string) (*Report, error) {
+	policy, err := s.GetPolicy(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{SessionID: sessionID, Cutoffs: policy.Cutoffs(s.now())}
+	if !policy.Enabled {
+		return report, nil
+	}
+
+	if report.Receipts, err = s.purge(ctx, sessionID, report.Cutoffs.Receipts, s.repo.PurgeReceipts); err != nil {
+		return report, err
+	}
+	if report.Messages, err = s.purge(ctx, sessionID, report.Cutoffs.Messages, s.repo.PurgeMessages); err != nil {
+		return report, err
+	}
+	if report.Media, err = s.purge(ctx, sessionID, report.Cutoffs.Media, s.repo.PurgeMedia); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+
+func (s *RetentionServiceImpl) PurgeAll(ctx context.Context) ([]*Report, error) {
+	sessionIDs, err := s.repo.ListSessionIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []*Report
+	for _, sessionID := range sessionIDs {
+		report, err := s.PurgeSession(ctx, sessionID)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+
+type purgeFunc func(ctx context.Context, sessionID string, before time.Time, batchSize int) (int64, error)
+
+
+// purge deletes in batches until a short batch shows nothing expired is left.
+func (s *RetentionServiceImpl) purge(ctx context.Context, sessionID string, before time.Time, fn purgeFunc) (int64, error) {
+	if before.IsZero() {
+		return 0, nil
+	}
+
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		n, err := fn(ctx, sessionID, before, s.batchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < int64(s.batchSize) {
+			return total, nil
+		}
+	}
+}
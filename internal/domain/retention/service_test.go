@@ -0,0 +1,124 @@
+package retention
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+
+type MockRetentionRepository struct {
+	mock.Mock
+}
+
+func (m *MockRetentionRepository) GetSessionPolicy(ctx context.Context, sessionID string) (*Policy, error) {
+	args := m.Called(ctx, sessionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Policy), args.Error(1)
+}
+
+func (m *MockRetentionRepository) SaveSessionPolicy(ctx context.Context, policy *Policy) error {
+	args := m.Called(ctx, policy)
+	return args.Error(0)
+}
+
+func (m *MockRetentionRepository) DeleteSessionPolicy(ctx context.Context, sessionID string) error {
+	args := m.Called(ctx, sessionID)
+	return args.Error(0)
+}
+
+func (m *MockRetentionRepository) ListSessionIDs(ctx context.Context) ([]string, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRetentionRepository) CountExpired(ctx context.Context, sessionID string, cutoffs Cutoffs) (*Report, error) {
+	args := m.Called(ctx, sessionID, cutoffs)
+	return args.Get(0).(*Report), args.Error(1)
+}
+
+func (m *MockRetentionRepository) PurgeMessages(ctx context.Context, sessionID string, before time.Time, batchSize int) (int64, error) {
+	args := m.Called(ctx, sessionID, before, batchSize)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRetentionRepository) PurgeMedia(ctx context.Context, sessionID string, before time.Time, batchSize int) (int64, error) {
+	args := m.Called(ctx, sessionID, before, batchSize)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRetentionRepository) PurgeReceipts(ctx context.Context, sessionID string, before time.Time, batchSize int) (int64, error) {
+	args := m.Called(ctx, sessionID, before, batchSize)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+
+func newTestService(repo *MockRetentionRepository, global Policy, now time.Time) *RetentionServiceImpl {
+	service := NewRetentionService(repo, global, 100).(*RetentionServiceImpl)
+	service.now = func() time.Time { return now }
+	return service
+}
+
+
+func TestGetPolicy_FallsBackToGlobal(t *testing.T) {
+	repo := new(MockRetentionRepository)
+	service := newTestService(repo, Policy{Enabled: true, MessagesDays: 90}, time.Now())
+
+	repo.On("GetSessionPolicy", mock.Anything, "session-1").Return(nil, ErrPolicyNotFound)
+
+	policy, err := service.GetPolicy(context.Background(), "session-1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, SourceGlobal, policy.Source())
+	assert.Equal(t, 90, policy.MessagesDays)
+}
+
+func TestPurgeSession_Batches(t *testing.T) {
+	repo := new(MockRetentionRepository)
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	service := newTestService(repo, Policy{}, now)
+
+	repo.On("GetSessionPolicy", mock.Anything, "session-1").
+		Return(&Policy{SessionID: "session-1", Enabled: true, MessagesDays: 90, MediaDays: 30}, nil)
+	messagesBefore := now.AddDate(0, 0, -90)
+	repo.On("PurgeMessages", mock.Anything, "session-1", messagesBefore, 100).Return(int64(100), nil).Twice()
+	repo.On("PurgeMessages", mock.Anything, "session-1", messagesBefore, 100).Return(int64(7), nil).Once()
+	repo.On("PurgeMedia", mock.Anything, "session-1", now.AddDate(0, 0, -30), 100).Return(int64(12), nil).Once()
+
+	report, err := service.PurgeSession(context.Background(), "session-1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(207), report.Messages)
+	assert.Equal(t, int64(12), report.Media)
+	assert.Equal(t, int64(0), report.Receipts)
+	repo.AssertNotCalled(t, "PurgeReceipts", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	repo.AssertExpectations(t)
+}
+
+func TestPurgeSession_DisabledPolicy(t *testing.T) {
+	repo := new(MockRetentionRepository)
+	service := newTestService(repo, Policy{Enabled: false, MessagesDays: 1}, time.Now())
+
+	repo.On("GetSessionPolicy", mock.Anything, "session-1").Return(nil, ErrPolicyNotFound)
+
+	report, err := service.PurgeSession(context.Background(), "session-1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), report.Total())
+	repo.AssertNotCalled(t, "PurgeMessages", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSetSessionPolicy_NegativeDays(t *testing.T) {
+	repo := new(MockRetentionRepository)
+	service := newTestService(repo, Policy{}, time.Now())
+
+	err := service.SetSessionPolicy(context.Background(), &Policy{SessionID: "session-1", MediaDays: -1})
+
+	assert.Equal(t, ErrInvalidRetentionDays, err)
+	repo.AssertNotCalled(t, "SaveSessionPolicy", mock.Anything, mock.Anything)
+}
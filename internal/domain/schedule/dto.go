@@ -0,0 +1,96 @@
+package schedule
+
+import "time"
+
+
+// UpdateRequest edits a scheduled message; omitted fields are left untouched.
+type UpdateRequest struct {
+	SendAt      *string `json:"sendAt,omitempty" example:"2025-01-31T09:00:00-03:00"`
+	WhenOffline *string `json:"whenOffline,omitempty" example:"skip" enums:"hold,skip"`
+	Text        *string `json:"text,omitempty" example:"Reminder: your appointment is tomorrow at 10am"`
+	Caption     *string `json:"caption,omitempty" example:"Updated caption"`
+}
+
+
+func (r *UpdateRequest) ToChanges() (Changes, error) {
+	changes := Changes{
+		WhenOffline: r.WhenOffline,
+		Text:        r.Text,
+		Caption:     r.Caption,
+	}
+	if r.SendAt != nil {
+		sendAt, err := ParseSendAt(*r.SendAt)
+		if err != nil {
+			return changes, err
+		}
+		changes.SendAt = &sendAt
+	}
+	return changes, nil
+}
+
+
+type ScheduledMessageDTO struct {
+	ID          string `json:"id" example:"9a6c1f0e-1a4b-4f0c-9a57-5f2d7b1c9e10"`
+	SessionID   string `json:"sessionId" example:"550e8400-e29b-41d4-a716-446655440000"`
+	MessageID   string `json:"messageId" example:"3EB0C431C26A1916E07A"`
+	Kind        string `json:"kind" example:"text"`
+	Phone       string `json:"phone" example:"+5511999999999"`
+	Text        string `json:"text,omitempty" example:"Reminder: your appointment is tomorrow"`
+	Caption     string `json:"caption,omitempty"`
+	FileName    string `json:"fileName,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+	SendAt      string `json:"sendAt" example:"2025-01-31T12:00:00Z"`
+	WhenOffline string `json:"whenOffline" example:"hold"`
+	Status      string `json:"status" example:"scheduled"`
+	Attempts    int    `json:"attempts" example:"0"`
+	LastError   string `json:"lastError,omitempty"`
+	CreatedAt   int64  `json:"createdAt" example:"1640995200"`
+	UpdatedAt   int64  `json:"updatedAt" example:"1640995200"`
+	SentAt      int64  `json:"sentAt,omitempty" example:"1641038400"`
+}
+
+
+func NewScheduledMessageDTO(m *ScheduledMessage) ScheduledMessageDTO {
+	dto := ScheduledMessageDTO{
+		ID:          m.ID,
+		SessionID:   m.SessionID,
+		MessageID:   m.MessageID,
+		Kind:        m.Payload.Kind,
+		Phone:       m.Payload.Phone,
+		Text:        m.Payload.Text,
+		Caption:     m.Payload.Caption,
+		FileName:    m.Payload.FileName,
+		MimeType:    m.Payload.MimeType,
+		SendAt:      m.SendAt.UTC().Format(time.RFC3339),
+		WhenOffline: m.WhenOffline,
+		Status:      string(m.Status),
+		Attempts:    m.Attempts,
+		LastError:   m.LastError,
+		CreatedAt:   m.CreatedAt.Unix(),
+		UpdatedAt:   m.UpdatedAt.Unix(),
+	}
+	if !m.SentAt.IsZero() {
+		dto.SentAt = m.SentAt.Unix()
+	}
+	return dto
+}
+
+
+type ListResponse struct {
+	Messages []ScheduledMessageDTO `json:"messages"`
+	Limit    int                   `json:"limit" example:"50"`
+	Offset   int                   `json:"offset" example:"0"`
+}
+
+
+func NewListResponse(messages []*ScheduledMessage, filter ListFilter) ListResponse {
+	dtos := make([]ScheduledMessageDTO, len(messages))
+	for i, m := range messages {
+		dtos[i] = NewScheduledMessageDTO(m)
+	}
+	return ListResponse{
+		Messages: dtos,
+		Limit:    filter.Limit,
+		Offset:   filter.Offset,
+	}
+}
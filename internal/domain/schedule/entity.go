@@ -0,0 +1,506 @@
+package schedule
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"zpmeow/internal/domain/session"
+	"zpmeow/internal/types"
+
+	"github.com/google/uuid"
+)
+
+
+type Status string
+
+
+const (
+	StatusScheduled Status = "scheduled"
+	StatusSending   Status = "sending"
+	StatusSent      Status = "sent"
+	StatusFailed    Status = "failed"
+	StatusSkipped   Status = "skipped"
+	StatusCancelled Status = "cancelled"
+)
+
+
+func (s Status) IsValid() bool {
+	switch s {
+	case StatusScheduled, StatusSending, StatusSent, StatusFailed, StatusSkipped, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+
+// IsFinal reports whether the message left the queue for good.
+func (s Status) IsFinal() bool {
+	return s == StatusSent || s == StatusFailed || s == StatusSkipped || s == StatusCancelled
+}
+
+
+// Offline policies say what happens to a due message while its session is disconnected:
+// hold keeps it queued until the session is back, skip drops it.
+const (
+	OfflineHold = "hold"
+	OfflineSkip = "skip"
+)
+
+
+const (
+	KindText     = "text"
+	KindImage    = "image"
+	KindAudio    = "audio"
+	KindDocument = "document"
+	KindVideo    = "video"
+	KindSticker  = "sticker"
+	KindLocation = "location"
+	KindContact  = "contact"
+	KindButtons  = "buttons"
+	KindList     = "list"
+	KindPoll     = "poll"
+)
+
+
+var kinds = map[string]bool{
+	KindText:     true,
+	KindImage:    true,
+	KindAudio:    true,
+	KindDocument: true,
+	KindVideo:    true,
+	KindSticker:  true,
+	KindLocation: true,
+	KindContact:  true,
+	KindButtons:  true,
+	KindList:     true,
+	KindPoll:     true,
+}
+
+
+// StatusBroadcast is the recipient of status updates, which WhatsApp delivers to the contacts
+// allowed by the status privacy settings.
+const StatusBroadcast = "status@broadcast"
+
+
+// DefaultStatusColor is the background of text status updates sent without one
+const DefaultStatusColor = "#128C7E"
+
+
+// statusFonts are the fonts WhatsApp draws text status updates in
+var statusFonts = map[int]bool{0: true, 1: true, 2: true, 6: true, 7: true, 8: true, 9: true, 10: true}
+
+
+type Reply struct {
+	StanzaID    string `json:"stanzaId"`
+	Participant string `json:"participant,omitempty"`
+}
+
+
+// LinkPreview is the card shown for the link in a text message.
+type LinkPreview struct {
+	MatchedText     string `json:"matchedText"`
+	Title           string `json:"title,omitempty"`
+	Description     string `json:"description,omitempty"`
+	Thumbnail       []byte `json:"thumbnail,omitempty"`
+	ThumbnailWidth  int    `json:"thumbnailWidth,omitempty"`
+	ThumbnailHeight int    `json:"thumbnailHeight,omitempty"`
+}
+
+
+// Payload is a send request after validation, with media already decoded, so it can be
+// delivered later without the original HTTP request. The media file is at MediaPath, which is
+// stored apart from the rest; Media holds the content of media stored before it was kept on
+// disk.
+type Payload struct {
+	Kind            string          `json:"kind"`
+	Phone           string          `json:"phone"`
+	Text            string          `json:"text,omitempty"`
+	Caption         string          `json:"caption,omitempty"`
+	MimeType        string          `json:"mimeType,omitempty"`
+	FileName        string          `json:"fileName,omitempty"`
+	Media           []byte          `json:"-"`
+	MediaPath       string          `json:"-"`
+	ReplyTo         *Reply          `json:"replyTo,omitempty"`
+	LinkPreview     *LinkPreview    `json:"linkPreview,omitempty"`
+	NoLinkPreview   bool            `json:"noLinkPreview,omitempty"`
+	Mentions        []string        `json:"mentions,omitempty"`
+	MentionAll      bool            `json:"mentionAll,omitempty"`
+	ViewOnce        bool            `json:"viewOnce,omitempty"`
+	NoPTT           bool            `json:"noPtt,omitempty"`
+	GifPlayback     bool            `json:"gifPlayback,omitempty"`
+	Latitude        float64         `json:"latitude,omitempty"`
+	Longitude       float64         `json:"longitude,omitempty"`
+	Name            string          `json:"name,omitempty"`
+	Address         string          `json:"address,omitempty"`
+	DisplayName     string          `json:"displayName,omitempty"`
+	VCard           string          `json:"vcard,omitempty"`
+	Buttons         []types.Button  `json:"buttons,omitempty"`
+	ButtonText      string          `json:"buttonText,omitempty"`
+	Sections        []types.Section `json:"sections,omitempty"`
+	Footer          string          `json:"footer,omitempty"`
+	Options         []string        `json:"options,omitempty"`
+	SelectableCount int             `json:"selectableCount,omitempty"`
+	BackgroundColor string          `json:"backgroundColor,omitempty"`
+	Font            int             `json:"font,omitempty"`
+	Audience        []string        `json:"audience,omitempty"`
+}
+
+
+// HasMedia reports whether the payload carries a file.
+func (p *Payload) HasMedia() bool {
+	switch p.Kind {
+	case KindImage, KindAudio, KindDocument, KindVideo, KindSticker:
+		return true
+	}
+	return false
+}
+
+
+// MediaSize returns the size of the payload media, in bytes.
+func (p *Payload) MediaSize() int {
+	if p.MediaPath != "" {
+		if info, err := os.Stat(p.MediaPath); err == nil {
+			return int(info.Size())
+		}
+	}
+	return len(p.Media)
+}
+
+
+// HasCaption reports whether the payload kind supports a caption.
+func (p *Payload) HasCaption() bool {
+	return p.Kind == KindImage || p.Kind == KindDocument || p.Kind == KindVideo
+}
+
+
+// SupportsViewOnce reports whether the payload kind can be sent as view-once.
+func (p *Payload) SupportsViewOnce() bool {
+	return p.Kind == KindImage || p.Kind == KindVideo || p.Kind == KindAudio
+}
+
+
+// HasText reports whether the payload kind carries an editable text body.
+func (p *Payload) HasText() bool {
+	return p.Kind == KindText || p.Kind == KindButtons || p.Kind == KindList
+}
+
+
+func (p *Payload) Validate() error {
+	if !kinds[p.Kind] {
+		return ErrInvalidKind
+	}
+	if strings.TrimSpace(p.Phone) == "" {
+		return ErrInvalidRecipient
+	}
+	if p.HasMedia() && len(p.Media) == 0 && p.MediaPath == "" {
+		return ErrMissingMedia
+	}
+	if p.HasText() && strings.TrimSpace(p.Text) == "" {
+		return ErrEmptyText
+	}
+	return p.ValidateOptions()
+}
+
+
+// IsStatus reports whether the payload is a status update.
+func (p *Payload) IsStatus() bool {
+	return p.Phone == StatusBroadcast
+}
+
+
+// ValidateOptions checks the send options that only apply to some kinds of message.
+func (p *Payload) ValidateOptions() error {
+	if p.ViewOnce && !p.SupportsViewOnce() {
+		return ErrViewOnceNotSupported
+	}
+	if err := p.ValidateStatus(); err != nil {
+		return err
+	}
+	return p.ValidateMentions()
+}
+
+
+// ValidateStatus checks that status updates are texts, images or videos without replies or
+// mentions, and that the background, font and audience options are only used with them.
+func (p *Payload) ValidateStatus() error {
+	if !p.IsStatus() {
+		if p.BackgroundColor != "" || p.Font != 0 || len(p.Audience) > 0 {
+			return ErrStatusOptionsNotStatus
+		}
+		return nil
+	}
+
+	if p.Kind != KindText && p.Kind != KindImage && p.Kind != KindVideo {
+		return ErrStatusKindNotSupported
+	}
+	if p.ReplyTo != nil || len(p.Mentions) > 0 || p.MentionAll || p.ViewOnce {
+		return ErrStatusOptionNotSupported
+	}
+	if p.Kind != KindText && (p.BackgroundColor != "" || p.Font != 0) {
+		return ErrStatusStyleNotText
+	}
+	if p.BackgroundColor != "" {
+		if _, err := ParseStatusColor(p.BackgroundColor); err != nil {
+			return err
+		}
+	}
+	if !statusFonts[p.Font] {
+		return ErrInvalidStatusFont
+	}
+	for _, member := range p.Audience {
+		if strings.TrimSpace(member) == "" {
+			return ErrInvalidStatusAudience
+		}
+	}
+	return nil
+}
+
+
+// ParseStatusColor parses a #RRGGBB or #AARRGGBB color into the ARGB value WhatsApp expects.
+// Colors without alpha are opaque.
+func ParseStatusColor(color string) (uint32, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(color), "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return 0, ErrInvalidStatusColor
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, ErrInvalidStatusColor
+	}
+	if len(hex) == 6 {
+		value |= 0xFF000000
+	}
+	return uint32(value), nil
+}
+
+
+// ValidateMentions checks that mentions are only used where WhatsApp shows them: in texts and
+// captions, and for mentionAll only in groups.
+func (p *Payload) ValidateMentions() error {
+	if len(p.Mentions) == 0 && !p.MentionAll {
+		return nil
+	}
+	if p.Kind != KindText && !p.HasCaption() {
+		return ErrMentionsNotSupported
+	}
+	if p.MentionAll && !strings.HasSuffix(p.Phone, "@g.us") {
+		return ErrMentionAllNotGroup
+	}
+	for _, mention := range p.Mentions {
+		if strings.TrimSpace(mention) == "" {
+			return ErrInvalidMention
+		}
+	}
+	return nil
+}
+
+
+// placeholderPattern matches {{variable}} placeholders in message text.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+
+// texts returns pointers to every user visible text of the payload that may hold placeholders.
+func (p *Payload) texts() []*string {
+	texts := []*string{&p.Text, &p.Caption, &p.Footer, &p.Name, &p.Address, &p.ButtonText}
+	for i := range p.Options {
+		texts = append(texts, &p.Options[i])
+	}
+	for i := range p.Sections {
+		texts = append(texts, &p.Sections[i].Title)
+		for j := range p.Sections[i].Rows {
+			texts = append(texts, &p.Sections[i].Rows[j].Title, &p.Sections[i].Rows[j].Description)
+		}
+	}
+	return texts
+}
+
+
+// Placeholders lists the {{variable}} names used by the payload, in order of first use.
+func (p *Payload) Placeholders() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, text := range p.texts() {
+		for _, m := range placeholderPattern.FindAllStringSubmatch(*text, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				names = append(names, m[1])
+			}
+		}
+	}
+	return names
+}
+
+
+// Render returns a copy of the payload with every placeholder replaced by lookup(name). The
+// payload itself is left untouched; media is shared.
+func (p *Payload) Render(lookup func(name string) string) *Payload {
+	out := *p
+	out.Options = append([]string(nil), p.Options...)
+	out.Sections = make([]types.Section, len(p.Sections))
+	for i, section := range p.Sections {
+		out.Sections[i] = types.Section{Title: section.Title, Rows: append([]types.Row(nil), section.Rows...)}
+	}
+	if p.Sections == nil {
+		out.Sections = nil
+	}
+
+	for _, text := range out.texts() {
+		*text = placeholderPattern.ReplaceAllStringFunc(*text, func(m string) string {
+			return lookup(placeholderPattern.FindStringSubmatch(m)[1])
+		})
+	}
+	return &out
+}
+
+
+// ScheduledMessage is a send waiting in the queue. MessageID is reserved when the message is
+// scheduled so that a send retried after a crash keeps the same WhatsApp message ID.
+type ScheduledMessage struct {
+	ID          string
+	SessionID   string
+	MessageID   string
+	Payload     Payload
+	SendAt      time.Time
+	WhenOffline string
+	Status      Status
+	Attempts    int
+	LastError   string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	SentAt      time.Time
+}
+
+
+func NewScheduledMessage(sessionID, messageID string, payload Payload, sendAt time.Time, whenOffline string) *ScheduledMessage {
+	now := time.Now()
+	if whenOffline == "" {
+		whenOffline = OfflineHold
+	}
+	return &ScheduledMessage{
+		ID:          uuid.New().String(),
+		SessionID:   sessionID,
+		MessageID:   messageID,
+		Payload:     payload,
+		SendAt:      sendAt,
+		WhenOffline: strings.ToLower(whenOffline),
+		Status:      StatusScheduled,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+
+func (m *ScheduledMessage) Validate(now time.Time) error {
+	if strings.TrimSpace(m.SessionID) == "" {
+		return session.ErrInvalidSessionID
+	}
+	if m.WhenOffline != OfflineHold && m.WhenOffline != OfflineSkip {
+		return ErrInvalidOfflinePolicy
+	}
+	if !m.SendAt.After(now) {
+		return ErrSendAtInPast
+	}
+	return m.Payload.Validate()
+}
+
+
+func (m *ScheduledMessage) IsEditable() bool {
+	return m.Status == StatusScheduled
+}
+
+
+func (m *ScheduledMessage) MarkSent(messageID string, now time.Time) {
+	m.Status = StatusSent
+	m.MessageID = messageID
+	m.LastError = ""
+	m.UpdatedAt = now
+	m.SentAt = now
+}
+
+
+func (m *ScheduledMessage) MarkFailed(reason string, now time.Time) {
+	m.Status = StatusFailed
+	m.LastError = reason
+	m.UpdatedAt = now
+}
+
+
+func (m *ScheduledMessage) MarkSkipped(reason string, now time.Time) {
+	m.Status = StatusSkipped
+	m.LastError = reason
+	m.UpdatedAt = now
+}
+
+
+// Hold puts a due message back in the queue. Claiming counted an attempt, which is given back
+// since nothing was sent.
+func (m *ScheduledMessage) Hold(reason string, now time.Time) {
+	if m.Attempts > 0 {
+		m.Attempts--
+	}
+	m.Status = StatusScheduled
+	m.LastError = reason
+	m.UpdatedAt = now
+}
+
+
+// Retry puts a message whose send failed back in the queue for another attempt at sendAt.
+func (m *ScheduledMessage) Retry(reason string, sendAt, now time.Time) {
+	m.Status = StatusScheduled
+	m.LastError = reason
+	m.SendAt = sendAt
+	m.UpdatedAt = now
+}
+
+
+func (m *ScheduledMessage) Cancel(now time.Time) error {
+	if !m.IsEditable() {
+		return ErrNotEditable
+	}
+	m.Status = StatusCancelled
+	m.UpdatedAt = now
+	return nil
+}
+
+
+// ParseSendAt parses an RFC 3339 timestamp; the time zone offset (or Z) is mandatory.
+func ParseSendAt(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, ErrInvalidSendAt
+	}
+	return t, nil
+}
+
+
+var (
+	ErrScheduledNotFound     = session.NewDomainError("scheduled message not found")
+	ErrInvalidSendAt         = session.NewDomainError("sendAt must be an RFC 3339 timestamp with time zone")
+	ErrSendAtInPast          = session.NewDomainError("sendAt must be in the future")
+	ErrInvalidOfflinePolicy  = session.NewDomainError("whenOffline must be hold or skip")
+	ErrInvalidKind           = session.NewDomainError("unsupported scheduled message kind")
+	ErrInvalidRecipient      = session.NewDomainError("recipient phone cannot be empty")
+	ErrMissingMedia          = session.NewDomainError("scheduled media message has no media")
+	ErrEmptyText             = session.NewDomainError("message text cannot be empty")
+	ErrNotEditable           = session.NewDomainError("only messages that are still scheduled can be changed")
+	ErrNothingToEdit         = session.NewDomainError("this change does not apply to the message")
+	ErrInvalidStatusFilter   = session.NewDomainError("invalid scheduled message status")
+	ErrMentionsNotSupported  = session.NewDomainError("mentions are only supported in text messages and captions")
+	ErrMentionAllNotGroup    = session.NewDomainError("mentionAll requires a group recipient")
+	ErrInvalidMention        = session.NewDomainError("mentions cannot be empty")
+	ErrQuotedMessageNotFound = session.NewDomainError("the message to reply to was not found")
+	ErrViewOnceNotSupported  = session.NewDomainError("viewOnce is only supported for images, videos and audio")
+
+	ErrStatusKindNotSupported   = session.NewDomainError("status updates can only be text, image or video")
+	ErrStatusOptionNotSupported = session.NewDomainError("replies, mentions and viewOnce are not supported in status updates")
+	ErrStatusOptionsNotStatus   = session.NewDomainError("backgroundColor, font and audience only apply to status updates")
+	ErrStatusStyleNotText       = session.NewDomainError("backgroundColor and font only apply to text status updates")
+	ErrInvalidStatusColor       = session.NewDomainError("backgroundColor must be a #RRGGBB or #AARRGGBB color")
+	ErrInvalidStatusFont        = session.NewDomainError("unsupported status font")
+	ErrInvalidStatusAudience    = session.NewDomainError("audience entries cannot be empty")
+	ErrStatusAudienceConflict   = session.NewDomainError("audience cannot be used while status privacy is limited to selected contacts")
+	ErrStatusAudienceNotContact = session.NewDomainError("audience members must be saved contacts")
+)
@@ -0,0 +1,33 @@
+package schedule
+
+import (
+	"context"
+	"time"
+)
+
+
+type ListFilter struct {
+	SessionID string
+	Status    Status
+	Limit     int
+	Offset    int
+}
+
+
+type ScheduleRepository interface {
+	Create(ctx context.Context, msg *ScheduledMessage) error
+	GetByID(ctx context.Context, sessionID, id string) (*ScheduledMessage, error)
+	List(ctx context.Context, filter ListFilter) ([]*ScheduledMessage, error)
+
+	// Update saves msg only while the stored row is still in status from and returns
+	// ErrNotEditable otherwise, so edits never race with the scheduler claiming a message.
+	Update(ctx context.Context, msg *ScheduledMessage, from Status) error
+
+	// ClaimDue moves up to limit messages due at now from scheduled to sending and returns them.
+	// Rows claimed by another worker are skipped, and so are messages held for sessions that are
+	// not in connected, so they cannot fill every batch while their session is offline.
+	ClaimDue(ctx context.Context, now time.Time, limit int, connected []string) ([]*ScheduledMessage, error)
+
+	// ReleaseStale puts messages left sending since before back in the queue.
+	ReleaseStale(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,167 @@
+package schedule
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+
+	// MaxAttempts is how many times a send is tried before the message is marked failed.
+	MaxAttempts = 3
+	RetryDelay  = time.Minute
+
+	// StaleAfter is how long a message may stay sending before it is considered abandoned
+	// by a worker that stopped, and is queued again.
+	StaleAfter = 5 * time.Minute
+)
+
+
+// Changes edits a scheduled message; nil fields are left untouched.
+type Changes struct {
+	SendAt      *time.Time
+	WhenOffline *string
+	Text        *string
+	Caption     *string
+}
+
+
+type ScheduleService interface {
+	Schedule(ctx context.Context, msg *ScheduledMessage) error
+	Get(ctx context.Context, sessionID, id string) (*ScheduledMessage, error)
+	List(ctx context.Context, filter *ListFilter) ([]*ScheduledMessage, error)
+	Update(ctx context.Context, sessionID, id string, changes Changes) (*ScheduledMessage, error)
+	Cancel(ctx context.Context, sessionID, id string) (*ScheduledMessage, error)
+
+
+	ClaimDue(ctx context.Context, limit int, connected []string) ([]*ScheduledMessage, error)
+	RecordOutcome(ctx context.Context, msg *ScheduledMessage) error
+	ReleaseStale(ctx context.Context) (int64, error)
+}
+
+
+type ScheduleServiceImpl struct {
+	repo ScheduleRepository
+	now  func() time.Time
+}
+
+
+func NewScheduleService(repo ScheduleRepository) ScheduleService {
+	return &ScheduleServiceImpl{
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+
+func (s *ScheduleServiceImpl) Schedule(ctx context.Context, msg *ScheduledMessage) error {
+	if err := msg.Validate(s.now()); err != nil {
+		return err
+	}
+	return s.repo.Create(ctx, msg)
+}
+
+
+func (s *ScheduleServiceImpl) Get(ctx context.Context, sessionID, id string) (*ScheduledMessage, error) {
+	return s.repo.GetByID(ctx, sessionID, id)
+}
+
+
+// List pages through the messages of a session; filter is normalized in place.
+func (s *ScheduleServiceImpl) List(ctx context.Context, filter *ListFilter) ([]*ScheduledMessage, error) {
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, ErrInvalidStatusFilter
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultPageSize
+	}
+	if filter.Limit > MaxPageSize {
+		filter.Limit = MaxPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	return s.repo.List(ctx, *filter)
+}
+
+
+// Update applies changes to a message that is still waiting to be sent.
+func (s *ScheduleServiceImpl) Update(ctx context.Context, sessionID, id string, changes Changes) (*ScheduledMessage, error) {
+	msg, err := s.repo.GetByID(ctx, sessionID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !msg.IsEditable() {
+		return nil, ErrNotEditable
+	}
+
+	if changes.SendAt != nil {
+		msg.SendAt = *changes.SendAt
+	}
+	if changes.WhenOffline != nil {
+		msg.WhenOffline = strings.ToLower(*changes.WhenOffline)
+	}
+	if changes.Text != nil {
+		if !msg.Payload.HasText() {
+			return nil, ErrNothingToEdit
+		}
+		msg.Payload.Text = *changes.Text
+		// A preview of a link that is no longer in the text is fetched again at send time
+		if preview := msg.Payload.LinkPreview; preview != nil && !strings.Contains(msg.Payload.Text, preview.MatchedText) {
+			msg.Payload.LinkPreview = nil
+		}
+	}
+	if changes.Caption != nil {
+		if !msg.Payload.HasCaption() {
+			return nil, ErrNothingToEdit
+		}
+		msg.Payload.Caption = *changes.Caption
+	}
+
+	now := s.now()
+	if err := msg.Validate(now); err != nil {
+		return nil, err
+	}
+	msg.UpdatedAt = now
+
+	if err := s.repo.Update(ctx, msg, StatusScheduled); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+
+func (s *ScheduleServiceImpl) Cancel(ctx context.Context, sessionID, id string) (*ScheduledMessage, error) {
+	msg, err := s.repo.GetByID(ctx, sessionID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := msg.Cancel(s.now()); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, msg, StatusScheduled); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+
+// ClaimDue claims due messages; messages held for sessions missing from connected stay queued.
+func (s *ScheduleServiceImpl) ClaimDue(ctx context.Context, limit int, connected []string) ([]*ScheduledMessage, error) {
+	return s.repo.ClaimDue(ctx, s.now(), limit, connected)
+}
+
+
+// RecordOutcome saves what happened to a claimed message.
+func (s *ScheduleServiceImpl) RecordOutcome(ctx context.Context, msg *ScheduledMessage) error {
+	return s.repo.Update(ctx, msg, StatusSending)
+}
+
+
+func (s *ScheduleServiceImpl) ReleaseStale(ctx context.Context) (int64, error) {
+	return s.repo.ReleaseStale(ctx, s.now().Add(-StaleAfter))
+}
@@ -0,0 +1,290 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+
+type MockScheduleRepository struct {
+	mock.Mock
+}
+
+func (m *MockScheduleRepository) Create(ctx context.Context, msg *ScheduledMessage) error {
+	args := m.Called(ctx, msg)
+	return args.Error(0)
+}
+
+func (m *MockScheduleRepository) GetByID(ctx context.Context, sessionID, id string) (*ScheduledMessage, error) {
+	args := m.Called(ctx, sessionID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ScheduledMessage), args.Error(1)
+}
+
+func (m *MockScheduleRepository) List(ctx context.Context, filter ListFilter) ([]*ScheduledMessage, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*ScheduledMessage), args.Error(1)
+}
+
+func (m *MockScheduleRepository) Update(ctx context.Context, msg *ScheduledMessage, from Status) error {
+	args := m.Called(ctx, msg, from)
+	return args.Error(0)
+}
+
+func (m *MockScheduleRepository) ClaimDue(ctx context.Context, now time.Time, limit int, connected []string) ([]*ScheduledMessage, error) {
+	args := m.Called(ctx, now, limit, connected)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*ScheduledMessage), args.Error(1)
+}
+
+func (m *MockScheduleRepository) ReleaseStale(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+
+func newTestService(repo ScheduleRepository, now time.Time) *ScheduleServiceImpl {
+	service := NewScheduleService(repo).(*ScheduleServiceImpl)
+	service.now = func() time.Time { return now }
+	return service
+}
+
+
+func textMessage(sendAt time.Time) *ScheduledMessage {
+	return NewScheduledMessage("session-1", "3EB0ABCDEF0123456789", Payload{
+		Kind:  KindText,
+		Phone: "5511999999999",
+		Text:  "hello",
+	}, sendAt, "")
+}
+
+
+func TestSchedule_Validation(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	service := newTestService(new(MockScheduleRepository), now)
+
+	assert.Equal(t, ErrSendAtInPast, service.Schedule(context.Background(), textMessage(now)))
+
+	msg := textMessage(now.Add(time.Hour))
+	msg.WhenOffline = "retry"
+	assert.Equal(t, ErrInvalidOfflinePolicy, service.Schedule(context.Background(), msg))
+
+	msg = textMessage(now.Add(time.Hour))
+	msg.Payload = Payload{Kind: KindImage, Phone: "5511999999999"}
+	assert.Equal(t, ErrMissingMedia, service.Schedule(context.Background(), msg))
+}
+
+func TestSchedule_MentionValidation(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	service := newTestService(new(MockScheduleRepository), now)
+
+	msg := textMessage(now.Add(time.Hour))
+	msg.Payload.MentionAll = true
+	assert.Equal(t, ErrMentionAllNotGroup, service.Schedule(context.Background(), msg))
+
+	msg = textMessage(now.Add(time.Hour))
+	msg.Payload.Mentions = []string{" "}
+	assert.Equal(t, ErrInvalidMention, service.Schedule(context.Background(), msg))
+
+	msg = textMessage(now.Add(time.Hour))
+	msg.Payload = Payload{Kind: KindAudio, Phone: "5511999999999", Media: []byte("ogg"), Mentions: []string{"5511888888888"}}
+	assert.Equal(t, ErrMentionsNotSupported, service.Schedule(context.Background(), msg))
+
+	payload := Payload{Kind: KindImage, Phone: "120363313346913103@g.us", Media: []byte("jpg"), Caption: "hi all", MentionAll: true}
+	assert.NoError(t, payload.Validate())
+}
+
+func TestPayload_ViewOnceValidation(t *testing.T) {
+	payload := Payload{Kind: KindDocument, Phone: "5511999999999", Media: []byte("pdf"), ViewOnce: true}
+	assert.Equal(t, ErrViewOnceNotSupported, payload.Validate())
+
+	for _, kind := range []string{KindImage, KindVideo, KindAudio} {
+		payload = Payload{Kind: kind, Phone: "5511999999999", Media: []byte("data"), ViewOnce: true}
+		assert.NoError(t, payload.Validate(), kind)
+	}
+}
+
+func TestPayload_MediaOnDisk(t *testing.T) {
+	payload := Payload{Kind: KindDocument, Phone: "5511999999999", MediaPath: "/tmp/report.pdf"}
+	assert.NoError(t, payload.Validate())
+
+	payload.MediaPath = ""
+	assert.Equal(t, ErrMissingMedia, payload.Validate())
+}
+
+func TestPayload_StatusValidation(t *testing.T) {
+	payload := Payload{Kind: KindText, Phone: StatusBroadcast, Text: "hello", BackgroundColor: "#FF5733", Font: 2, Audience: []string{"5511999999999"}}
+	assert.NoError(t, payload.Validate())
+
+	payload = Payload{Kind: KindVideo, Phone: StatusBroadcast, Media: []byte("mp4"), Caption: "clip"}
+	assert.NoError(t, payload.Validate())
+
+	payload = Payload{Kind: KindDocument, Phone: StatusBroadcast, Media: []byte("pdf")}
+	assert.Equal(t, ErrStatusKindNotSupported, payload.Validate())
+
+	payload = Payload{Kind: KindText, Phone: StatusBroadcast, Text: "hello", ReplyTo: &Reply{StanzaID: "3EB0C431C26A1916E07A"}}
+	assert.Equal(t, ErrStatusOptionNotSupported, payload.Validate())
+
+	payload = Payload{Kind: KindImage, Phone: StatusBroadcast, Media: []byte("jpg"), Font: 1}
+	assert.Equal(t, ErrStatusStyleNotText, payload.Validate())
+
+	payload = Payload{Kind: KindText, Phone: StatusBroadcast, Text: "hello", BackgroundColor: "green"}
+	assert.Equal(t, ErrInvalidStatusColor, payload.Validate())
+
+	payload = Payload{Kind: KindText, Phone: StatusBroadcast, Text: "hello", Font: 5}
+	assert.Equal(t, ErrInvalidStatusFont, payload.Validate())
+
+	payload = Payload{Kind: KindText, Phone: StatusBroadcast, Text: "hello", Audience: []string{" "}}
+	assert.Equal(t, ErrInvalidStatusAudience, payload.Validate())
+
+	payload = Payload{Kind: KindText, Phone: "5511999999999", Text: "hello", Audience: []string{"5511888888888"}}
+	assert.Equal(t, ErrStatusOptionsNotStatus, payload.Validate())
+}
+
+func TestParseStatusColor(t *testing.T) {
+	color, err := ParseStatusColor("#128C7E")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0xFF128C7E), color)
+
+	color, err = ParseStatusColor("80FFFFFF")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0x80FFFFFF), color)
+
+	_, err = ParseStatusColor("#12345")
+	assert.Equal(t, ErrInvalidStatusColor, err)
+}
+
+func TestSchedule_StoresValidMessage(t *testing.T) {
+	repo := new(MockScheduleRepository)
+	now := time.Unix(1700000000, 0)
+	service := newTestService(repo, now)
+
+	msg := textMessage(now.Add(time.Hour))
+	repo.On("Create", mock.Anything, msg).Return(nil)
+
+	assert.NoError(t, service.Schedule(context.Background(), msg))
+	assert.Equal(t, OfflineHold, msg.WhenOffline)
+	assert.Equal(t, StatusScheduled, msg.Status)
+	repo.AssertExpectations(t)
+}
+
+func TestUpdate_ChangesTextAndSendAt(t *testing.T) {
+	repo := new(MockScheduleRepository)
+	now := time.Unix(1700000000, 0)
+	service := newTestService(repo, now)
+
+	msg := textMessage(now.Add(time.Hour))
+	repo.On("GetByID", mock.Anything, "session-1", msg.ID).Return(msg, nil)
+	repo.On("Update", mock.Anything, msg, StatusScheduled).Return(nil)
+
+	sendAt := now.Add(2 * time.Hour)
+	text := "updated"
+	updated, err := service.Update(context.Background(), "session-1", msg.ID, Changes{SendAt: &sendAt, Text: &text})
+	assert.NoError(t, err)
+	assert.Equal(t, sendAt, updated.SendAt)
+	assert.Equal(t, "updated", updated.Payload.Text)
+	repo.AssertExpectations(t)
+}
+
+func TestUpdate_TextDropsStaleLinkPreview(t *testing.T) {
+	repo := new(MockScheduleRepository)
+	now := time.Unix(1700000000, 0)
+	service := newTestService(repo, now)
+
+	msg := textMessage(now.Add(time.Hour))
+	msg.Payload.Text = "see https://example.com"
+	msg.Payload.LinkPreview = &LinkPreview{MatchedText: "https://example.com", Title: "Example"}
+	repo.On("GetByID", mock.Anything, "session-1", msg.ID).Return(msg, nil)
+	repo.On("Update", mock.Anything, msg, StatusScheduled).Return(nil)
+
+	text := "see https://example.com today"
+	updated, err := service.Update(context.Background(), "session-1", msg.ID, Changes{Text: &text})
+	assert.NoError(t, err)
+	assert.NotNil(t, updated.Payload.LinkPreview, "the link is still in the text")
+
+	text = "see https://example.org instead"
+	updated, err = service.Update(context.Background(), "session-1", msg.ID, Changes{Text: &text})
+	assert.NoError(t, err)
+	assert.Nil(t, updated.Payload.LinkPreview)
+}
+
+func TestUpdate_Rejected(t *testing.T) {
+	repo := new(MockScheduleRepository)
+	now := time.Unix(1700000000, 0)
+	service := newTestService(repo, now)
+
+	sent := textMessage(now.Add(time.Hour))
+	sent.MarkSent("3EB0ABCDEF0123456789", now)
+	repo.On("GetByID", mock.Anything, "session-1", sent.ID).Return(sent, nil)
+
+	text := "late"
+	_, err := service.Update(context.Background(), "session-1", sent.ID, Changes{Text: &text})
+	assert.Equal(t, ErrNotEditable, err)
+
+	msg := textMessage(now.Add(time.Hour))
+	repo.On("GetByID", mock.Anything, "session-1", msg.ID).Return(msg, nil)
+
+	caption := "caption"
+	_, err = service.Update(context.Background(), "session-1", msg.ID, Changes{Caption: &caption})
+	assert.Equal(t, ErrNothingToEdit, err)
+
+	repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCancel(t *testing.T) {
+	repo := new(MockScheduleRepository)
+	now := time.Unix(1700000000, 0)
+	service := newTestService(repo, now)
+
+	msg := textMessage(now.Add(time.Hour))
+	repo.On("GetByID", mock.Anything, "session-1", msg.ID).Return(msg, nil)
+	repo.On("Update", mock.Anything, msg, StatusScheduled).Return(nil)
+
+	cancelled, err := service.Cancel(context.Background(), "session-1", msg.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelled, cancelled.Status)
+
+	_, err = service.Cancel(context.Background(), "session-1", msg.ID)
+	assert.Equal(t, ErrNotEditable, err)
+}
+
+func TestClaimDue_PassesConnectedSessions(t *testing.T) {
+	repo := new(MockScheduleRepository)
+	now := time.Unix(1700000000, 0)
+	service := newTestService(repo, now)
+
+	due := []*ScheduledMessage{textMessage(now)}
+	repo.On("ClaimDue", mock.Anything, now, 50, []string{"session-1"}).Return(due, nil)
+
+	claimed, err := service.ClaimDue(context.Background(), 50, []string{"session-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, due, claimed)
+	repo.AssertExpectations(t)
+}
+
+func TestList_NormalizesFilter(t *testing.T) {
+	repo := new(MockScheduleRepository)
+	service := newTestService(repo, time.Unix(1700000000, 0))
+
+	repo.On("List", mock.Anything, ListFilter{SessionID: "session-1", Limit: MaxPageSize}).Return([]*ScheduledMessage{}, nil)
+
+	filter := &ListFilter{SessionID: "session-1", Limit: 1000, Offset: -5}
+	_, err := service.List(context.Background(), filter)
+	assert.NoError(t, err)
+	assert.Equal(t, MaxPageSize, filter.Limit)
+	assert.Equal(t, 0, filter.Offset)
+
+	_, err = service.List(context.Background(), &ListFilter{SessionID: "session-1", Status: "queued"})
+	assert.Equal(t, ErrInvalidStatusFilter, err)
+}
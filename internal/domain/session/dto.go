@@ -72,6 +72,15 @@ type ProxyResponse struct {
 }
 
 
+// MediaFetchPolicyResponse reports the policy of a session; Unreadable is set when the stored policy
+// is corrupt and remote fetches are refused until a new one is set.
+type MediaFetchPolicyResponse struct {
+	Policy     MediaFetchPolicy `json:"policy"`
+	Unreadable bool             `json:"unreadable,omitempty" example:"false"`
+	Message    string           `json:"message" example:"Media fetch policy updated successfully."`
+}
+
+
 
 
 type SuccessResponse struct {
@@ -1,6 +1,8 @@
 package session
 
 import (
+	"net/netip"
+	"slices"
 	"strings"
 	"time"
 	"zpmeow/internal/types"
@@ -16,6 +18,8 @@ type Session struct {
 	ProxyURL    string
 	WebhookURL  string
 	Events      []string
+	SearchLanguage string
+	MediaFetch  MediaFetchPolicy
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 }
@@ -27,6 +31,7 @@ func NewSession(id, name string) *Session {
 		ID:        id,
 		Name:      strings.TrimSpace(name),
 		Status:    types.StatusDisconnected,
+		SearchLanguage: DefaultSearchLanguage,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
@@ -144,6 +149,36 @@ func (s *Session) IsEventSubscribed(event string) bool {
 	return false
 }
 
+// Search language methods
+func (s *Session) SetSearchLanguage(language string) error {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if !IsSupportedSearchLanguage(language) {
+		return ErrUnsupportedSearchLanguage
+	}
+	s.SearchLanguage = language
+	s.updateTimestamp()
+	return nil
+}
+
+func (s *Session) GetSearchLanguage() string {
+	if s.SearchLanguage == "" {
+		return DefaultSearchLanguage
+	}
+	return s.SearchLanguage
+}
+
+
+// Media fetch policy methods
+func (s *Session) SetMediaFetchPolicy(policy MediaFetchPolicy) error {
+	policy = policy.normalized()
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	s.MediaFetch = policy
+	s.updateTimestamp()
+	return nil
+}
+
 
 func (s *Session) updateTimestamp() {
 	s.UpdatedAt = time.Now()
@@ -203,9 +238,98 @@ var (
 	ErrSessionAlreadyExists      = NewDomainError("session already exists")
 	ErrSessionAlreadyConnected   = NewDomainError("session is already connected")
 	ErrSessionCannotConnect      = NewDomainError("session cannot be connected in current state")
+	ErrUnsupportedSearchLanguage = NewDomainError("search language is not supported")
+	ErrInvalidMediaFetchPolicy   = NewDomainError("media fetch policy is invalid")
 )
 
 
+// MediaFetchPolicy adjusts, for one session, where media and link previews may be fetched
+// from. Each list that is set replaces the server default, except BlockedDomains, which adds to
+// it. AllowedNetworks lists CIDR ranges or addresses that may be fetched even though internal.
+// Unreadable marks a stored policy that could not be decoded; the session fetches nothing until a
+// new policy is set.
+type MediaFetchPolicy struct {
+	AllowedSchemes  []string `json:"allowedSchemes,omitempty" example:"https"`
+	AllowedDomains  []string `json:"allowedDomains,omitempty" example:"cdn.example.com"`
+	BlockedDomains  []string `json:"blockedDomains,omitempty" example:"internal.example.com"`
+	AllowedNetworks []string `json:"allowedNetworks,omitempty" example:"10.20.0.0/16"`
+	Unreadable      bool     `json:"-"`
+}
+
+
+// Validate checks that schemes are http or https, domains are bare host names and networks
+// parse as CIDR ranges or addresses.
+func (p MediaFetchPolicy) Validate() error {
+	for _, scheme := range p.AllowedSchemes {
+		if scheme != "http" && scheme != "https" {
+			return ErrInvalidMediaFetchPolicy
+		}
+	}
+	for _, domain := range append(slices.Clone(p.AllowedDomains), p.BlockedDomains...) {
+		if domain == "" || strings.ContainsAny(domain, "/:@ ") {
+			return ErrInvalidMediaFetchPolicy
+		}
+	}
+	for _, network := range p.AllowedNetworks {
+		if _, err := netip.ParsePrefix(network); err != nil {
+			if _, err := netip.ParseAddr(network); err != nil {
+				return ErrInvalidMediaFetchPolicy
+			}
+		}
+	}
+	return nil
+}
+
+
+func (p MediaFetchPolicy) normalized() MediaFetchPolicy {
+	clean := func(values []string) []string {
+		var out []string
+		for _, value := range values {
+			value = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(value)), ".")
+			if value != "" && !slices.Contains(out, value) {
+				out = append(out, value)
+			}
+		}
+		return out
+	}
+	return MediaFetchPolicy{
+		AllowedSchemes:  clean(p.AllowedSchemes),
+		AllowedDomains:  clean(p.AllowedDomains),
+		BlockedDomains:  clean(p.BlockedDomains),
+		AllowedNetworks: clean(p.AllowedNetworks),
+	}
+}
+
+
+// DefaultSearchLanguage is the text search configuration used when a session has none set.
+const DefaultSearchLanguage = "portuguese"
+
+
+// supportedSearchLanguages lists the built-in PostgreSQL text search configurations.
+var supportedSearchLanguages = []string{
+	"simple", "arabic", "danish", "dutch", "english", "finnish", "french", "german",
+	"greek", "hungarian", "indonesian", "irish", "italian", "lithuanian", "nepali",
+	"norwegian", "portuguese", "romanian", "russian", "spanish", "swedish", "tamil", "turkish",
+}
+
+
+func SupportedSearchLanguages() []string {
+	languages := make([]string, len(supportedSearchLanguages))
+	copy(languages, supportedSearchLanguages)
+	return languages
+}
+
+
+func IsSupportedSearchLanguage(language string) bool {
+	for _, l := range supportedSearchLanguages {
+		if l == language {
+			return true
+		}
+	}
+	return false
+}
+
+
 type DomainError struct {
 	Message string
 }
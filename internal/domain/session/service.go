@@ -28,6 +28,7 @@ type SessionService interface {
 
 	SetProxy(ctx context.Context, id, proxyURL string) error
 	ClearProxy(ctx context.Context, id string) error
+	SetMediaFetchPolicy(ctx context.Context, id string, policy MediaFetchPolicy) (*Session, error)
 
 
 	ConnectOnStartup(ctx context.Context) error
@@ -252,6 +253,22 @@ func (s *SessionServiceImpl) ClearProxy(ctx context.Context, id string) error {
 }
 
 
+func (s *SessionServiceImpl) SetMediaFetchPolicy(ctx context.Context, id string, policy MediaFetchPolicy) (*Session, error) {
+	session, err := s.GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := session.SetMediaFetchPolicy(policy); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+
 
 
 func (s *SessionServiceImpl) ConnectOnStartup(ctx context.Context) error {
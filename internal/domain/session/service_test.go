@@ -260,3 +260,46 @@ func TestCreateSession_InvalidNames(t *testing.T) {
 		})
 	}
 }
+
+func TestSetMediaFetchPolicy(t *testing.T) {
+	mockRepo := new(MockSessionRepository)
+	service := NewSessionService(mockRepo, new(MockWhatsAppService))
+
+	ctx := context.Background()
+	sess := &Session{ID: "test-session-id", Name: "test-session", Status: types.StatusDisconnected}
+	mockRepo.On("GetByID", ctx, sess.ID).Return(sess, nil)
+	mockRepo.On("Update", ctx, sess).Return(nil)
+
+
+	result, err := service.SetMediaFetchPolicy(ctx, sess.ID, MediaFetchPolicy{
+		AllowedSchemes:  []string{"HTTPS", " https "},
+		AllowedDomains:  []string{"CDN.Example.com."},
+		AllowedNetworks: []string{"10.20.0.0/16", "192.168.1.10"},
+	})
+
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https"}, result.MediaFetch.AllowedSchemes)
+	assert.Equal(t, []string{"cdn.example.com"}, result.MediaFetch.AllowedDomains)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSetMediaFetchPolicy_Invalid(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy MediaFetchPolicy
+	}{
+		{"unsupported scheme", MediaFetchPolicy{AllowedSchemes: []string{"file"}}},
+		{"domain with scheme", MediaFetchPolicy{AllowedDomains: []string{"https://example.com"}}},
+		{"domain with port", MediaFetchPolicy{BlockedDomains: []string{"example.com:8080"}}},
+		{"invalid network", MediaFetchPolicy{AllowedNetworks: []string{"10.0.0.0/33"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sess := &Session{ID: "test-session-id"}
+			assert.Equal(t, ErrInvalidMediaFetchPolicy, sess.SetMediaFetchPolicy(tc.policy))
+			assert.Empty(t, sess.MediaFetch)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package template
+
+import "zpmeow/internal/types"
+
+
+// SaveRequest creates a template or replaces its content.
+type SaveRequest struct {
+	Name    string               `json:"name" binding:"required" example:"order-shipped"`
+	Type    string               `json:"type" binding:"required" example:"text" enums:"text,image,video,document,list,poll"`
+	Message types.MessageContent `json:"message" binding:"required"`
+}
+
+
+// TemplateDTO mirrors SaveRequest; media is not returned, only its size.
+type TemplateDTO struct {
+	ID        string               `json:"id" example:"9a6c1f0e-1a4b-4f0c-9a57-5f2d7b1c9e10"`
+	Name      string               `json:"name" example:"order-shipped"`
+	Scope     string               `json:"scope" example:"session" enums:"session,shared"`
+	SessionID string               `json:"sessionId,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Type      string               `json:"type" example:"text"`
+	Variables []string             `json:"variables"`
+	Message   types.MessageContent `json:"message"`
+	MediaSize int                  `json:"mediaSize,omitempty" example:"48213"`
+	CreatedAt int64                `json:"createdAt" example:"1640995200"`
+	UpdatedAt int64                `json:"updatedAt" example:"1640995200"`
+}
+
+
+func NewTemplateDTO(t *Template) TemplateDTO {
+	variables := t.Variables()
+	if variables == nil {
+		variables = []string{}
+	}
+
+	p := &t.Payload
+	return TemplateDTO{
+		ID:        t.ID,
+		Name:      t.Name,
+		Scope:     t.Scope(),
+		SessionID: t.SessionID,
+		Type:      p.Kind,
+		Variables: variables,
+		Message: types.MessageContent{
+			Body:            p.Text,
+			Caption:         p.Caption,
+			MimeType:        p.MimeType,
+			FileName:        p.FileName,
+			Name:            p.Name,
+			ButtonText:      p.ButtonText,
+			Sections:        p.Sections,
+			Footer:          p.Footer,
+			Options:         p.Options,
+			SelectableCount: p.SelectableCount,
+			NoLinkPreview:   p.NoLinkPreview,
+		},
+		MediaSize: t.MediaSize,
+		CreatedAt: t.CreatedAt.Unix(),
+		UpdatedAt: t.UpdatedAt.Unix(),
+	}
+}
+
+
+type ListResponse struct {
+	Templates []TemplateDTO `json:"templates"`
+}
+
+
+func NewListResponse(templates []*Template) ListResponse {
+	dtos := make([]TemplateDTO, len(templates))
+	for i, t := range templates {
+		dtos[i] = NewTemplateDTO(t)
+	}
+	return ListResponse{Templates: dtos}
+}
@@ -0,0 +1,161 @@
+package template
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"zpmeow/internal/domain/schedule"
+	"zpmeow/internal/domain/session"
+
+	"github.com/google/uuid"
+)
+
+
+const (
+	ScopeSession = "session"
+	ScopeShared  = "shared"
+)
+
+
+// PhoneVariable is always available when a template is rendered and holds the recipient number.
+const PhoneVariable = "phone"
+
+
+const MaxNameLength = 100
+
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+
+// kinds are the message types a template can hold.
+var kinds = map[string]bool{
+	schedule.KindText:     true,
+	schedule.KindImage:    true,
+	schedule.KindVideo:    true,
+	schedule.KindDocument: true,
+	schedule.KindList:     true,
+	schedule.KindPoll:     true,
+}
+
+
+// Template is a stored message with {{variable}} placeholders. Templates without a session are
+// shared by all sessions; a session template hides a shared one with the same name.
+type Template struct {
+	ID        string
+	SessionID string
+	Name      string
+	Payload   schedule.Payload
+	MediaSize int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+
+func NewTemplate(sessionID, name string, payload schedule.Payload) *Template {
+	now := time.Now()
+	return &Template{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		Name:      strings.TrimSpace(name),
+		Payload:   payload,
+		MediaSize: payload.MediaSize(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+
+func (t *Template) Validate() error {
+	if len(t.Name) > MaxNameLength || !namePattern.MatchString(t.Name) {
+		return ErrInvalidTemplateName
+	}
+	if !kinds[t.Payload.Kind] {
+		return ErrUnsupportedKind
+	}
+
+	sample := t.Payload
+	sample.Phone = "0"
+	return sample.Validate()
+}
+
+
+func (t *Template) IsShared() bool {
+	return t.SessionID == ""
+}
+
+
+func (t *Template) Scope() string {
+	if t.IsShared() {
+		return ScopeShared
+	}
+	return ScopeSession
+}
+
+
+// IsVisibleTo reports whether the template can be used from scope, which is a session ID or
+// empty for shared templates.
+func (t *Template) IsVisibleTo(scope string) bool {
+	return t.IsShared() || t.SessionID == scope
+}
+
+
+// Replace swaps the content of the template.
+func (t *Template) Replace(name string, payload schedule.Payload, now time.Time) {
+	t.Name = strings.TrimSpace(name)
+	t.Payload = payload
+	t.MediaSize = payload.MediaSize()
+	t.UpdatedAt = now
+}
+
+
+// Variables lists the placeholders of the template, not counting phone.
+func (t *Template) Variables() []string {
+	var names []string
+	for _, name := range t.Payload.Placeholders() {
+		if name != PhoneVariable {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+
+// MissingVariables lists, sorted, the placeholders that vars has no value for.
+func (t *Template) MissingVariables(vars map[string]string) []string {
+	var missing []string
+	for _, name := range t.Variables() {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+
+// Render builds the message for phone from the template.
+func (t *Template) Render(phone string, vars map[string]string) *schedule.Payload {
+	p := t.Payload.Render(func(name string) string {
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		if name == PhoneVariable {
+			return phone
+		}
+		return ""
+	})
+	p.Phone = phone
+	return p
+}
+
+
+var (
+	ErrTemplateNotFound    = session.NewDomainError("template not found")
+	ErrInvalidTemplateName = session.NewDomainError("template name must start with a letter or digit and contain only letters, digits, '.', '_' and '-'")
+	ErrUnsupportedKind     = session.NewDomainError("templates support text, image, video, document, list and poll messages")
+	ErrTemplateNameTaken   = session.NewDomainError("a template with this name already exists")
+	ErrTemplateReadOnly    = session.NewDomainError("shared templates can only be changed through the shared template endpoints")
+	ErrMissingVariables    = session.NewDomainError("template variables are missing")
+)
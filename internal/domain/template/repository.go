@@ -0,0 +1,19 @@
+package template
+
+import "context"
+
+
+// TemplateRepository stores templates; an empty session ID stands for the shared templates.
+type TemplateRepository interface {
+	Create(ctx context.Context, t *Template) error
+	GetByID(ctx context.Context, id string) (*Template, error)
+
+	// GetByName returns the template of the session with that name, falling back to the shared
+	// template with that name.
+	GetByName(ctx context.Context, sessionID, name string) (*Template, error)
+
+	// List returns the templates of the session and the shared ones, without media.
+	List(ctx context.Context, sessionID string) ([]*Template, error)
+	Update(ctx context.Context, t *Template) error
+	Delete(ctx context.Context, sessionID, id string) error
+}
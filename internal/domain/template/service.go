@@ -0,0 +1,109 @@
+package template
+
+import (
+	"context"
+	"time"
+
+	"zpmeow/internal/domain/schedule"
+
+	"github.com/google/uuid"
+)
+
+
+// TemplateService manages templates. scope is a session ID, or empty for shared templates.
+type TemplateService interface {
+	Create(ctx context.Context, t *Template) error
+	Get(ctx context.Context, scope, id string) (*Template, error)
+	List(ctx context.Context, scope string) ([]*Template, error)
+	Replace(ctx context.Context, scope, id, name string, payload schedule.Payload) (*Template, error)
+	Delete(ctx context.Context, scope, id string) error
+
+	// Resolve finds the template a session sends by ID or by name.
+	Resolve(ctx context.Context, sessionID, ref string) (*Template, error)
+}
+
+
+type TemplateServiceImpl struct {
+	repo TemplateRepository
+	now  func() time.Time
+}
+
+
+func NewTemplateService(repo TemplateRepository) TemplateService {
+	return &TemplateServiceImpl{
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+
+func (s *TemplateServiceImpl) Create(ctx context.Context, t *Template) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	return s.repo.Create(ctx, t)
+}
+
+
+func (s *TemplateServiceImpl) Get(ctx context.Context, scope, id string) (*Template, error) {
+	t, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !t.IsVisibleTo(scope) {
+		return nil, ErrTemplateNotFound
+	}
+	return t, nil
+}
+
+
+func (s *TemplateServiceImpl) List(ctx context.Context, scope string) ([]*Template, error) {
+	return s.repo.List(ctx, scope)
+}
+
+
+func (s *TemplateServiceImpl) Replace(ctx context.Context, scope, id, name string, payload schedule.Payload) (*Template, error) {
+	t, err := s.owned(ctx, scope, id)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Replace(name, payload, s.now())
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+
+func (s *TemplateServiceImpl) Delete(ctx context.Context, scope, id string) error {
+	if _, err := s.owned(ctx, scope, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, scope, id)
+}
+
+
+// owned returns a template that belongs to scope itself; shared templates seen from a session
+// are read only.
+func (s *TemplateServiceImpl) owned(ctx context.Context, scope, id string) (*Template, error) {
+	t, err := s.Get(ctx, scope, id)
+	if err != nil {
+		return nil, err
+	}
+	if t.SessionID != scope {
+		return nil, ErrTemplateReadOnly
+	}
+	return t, nil
+}
+
+
+func (s *TemplateServiceImpl) Resolve(ctx context.Context, sessionID, ref string) (*Template, error) {
+	if _, err := uuid.Parse(ref); err == nil {
+		return s.Get(ctx, sessionID, ref)
+	}
+	return s.repo.GetByName(ctx, sessionID, ref)
+}
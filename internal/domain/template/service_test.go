@@ -0,0 +1,146 @@
+package template
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"zpmeow/internal/domain/schedule"
+	"zpmeow/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+
+type MockTemplateRepository struct {
+	mock.Mock
+}
+
+func (m *MockTemplateRepository) Create(ctx context.Context, t *Template) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
+
+func (m *MockTemplateRepository) GetByID(ctx context.Context, id string) (*Template, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Template), args.Error(1)
+}
+
+func (m *MockTemplateRepository) GetByName(ctx context.Context, sessionID, name string) (*Template, error) {
+	args := m.Called(ctx, sessionID, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Template), args.Error(1)
+}
+
+func (m *MockTemplateRepository) List(ctx context.Context, sessionID string) ([]*Template, error) {
+	args := m.Called(ctx, sessionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Template), args.Error(1)
+}
+
+func (m *MockTemplateRepository) Update(ctx context.Context, t *Template) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
+
+func (m *MockTemplateRepository) Delete(ctx context.Context, sessionID, id string) error {
+	args := m.Called(ctx, sessionID, id)
+	return args.Error(0)
+}
+
+
+func newTestService(repo TemplateRepository) *TemplateServiceImpl {
+	service := NewTemplateService(repo).(*TemplateServiceImpl)
+	service.now = func() time.Time { return time.Unix(1700000000, 0) }
+	return service
+}
+
+
+func textTemplate(sessionID, text string) *Template {
+	return NewTemplate(sessionID, "order-shipped", schedule.Payload{Kind: schedule.KindText, Text: text})
+}
+
+
+func TestCreate_Validation(t *testing.T) {
+	service := newTestService(new(MockTemplateRepository))
+
+	tmpl := textTemplate("session-1", "hello")
+	tmpl.Name = "bad name"
+	assert.Equal(t, ErrInvalidTemplateName, service.Create(context.Background(), tmpl))
+
+	tmpl = NewTemplate("session-1", "contact", schedule.Payload{Kind: schedule.KindContact, DisplayName: "A", VCard: "B"})
+	assert.Equal(t, ErrUnsupportedKind, service.Create(context.Background(), tmpl))
+
+	tmpl = NewTemplate("session-1", "banner", schedule.Payload{Kind: schedule.KindImage, Caption: "{{name}}"})
+	assert.Equal(t, schedule.ErrMissingMedia, service.Create(context.Background(), tmpl))
+}
+
+func TestRender_FillsVariables(t *testing.T) {
+	tmpl := NewTemplate("", "menu", schedule.Payload{
+		Kind:       schedule.KindList,
+		Text:       "Hi {{name}}",
+		ButtonText: "Open",
+		Sections:   []types.Section{{Title: "{{store}}", Rows: []types.Row{{Title: "Call {{phone}}", RowID: "1"}}}},
+	})
+
+	assert.Equal(t, []string{"name", "store"}, tmpl.Variables())
+	assert.Equal(t, []string{"store"}, tmpl.MissingVariables(map[string]string{"name": "Alice"}))
+
+	p := tmpl.Render("5511999999999", map[string]string{"name": "Alice", "store": "Downtown"})
+	assert.Equal(t, "5511999999999", p.Phone)
+	assert.Equal(t, "Hi Alice", p.Text)
+	assert.Equal(t, "Downtown", p.Sections[0].Title)
+	assert.Equal(t, "Call 5511999999999", p.Sections[0].Rows[0].Title)
+	assert.Equal(t, "{{store}}", tmpl.Payload.Sections[0].Title)
+}
+
+func TestResolve_ByIDOrName(t *testing.T) {
+	repo := new(MockTemplateRepository)
+	service := newTestService(repo)
+
+	shared := textTemplate("", "hello")
+	other := textTemplate("session-2", "hello")
+	repo.On("GetByID", mock.Anything, shared.ID).Return(shared, nil)
+	repo.On("GetByID", mock.Anything, other.ID).Return(other, nil)
+	repo.On("GetByName", mock.Anything, "session-1", "order-shipped").Return(shared, nil)
+
+	found, err := service.Resolve(context.Background(), "session-1", shared.ID)
+	require.NoError(t, err)
+	assert.Equal(t, shared, found)
+
+	_, err = service.Resolve(context.Background(), "session-1", other.ID)
+	assert.Equal(t, ErrTemplateNotFound, err)
+
+	found, err = service.Resolve(context.Background(), "session-1", "order-shipped")
+	require.NoError(t, err)
+	assert.Equal(t, shared, found)
+}
+
+func TestReplace_SharedIsReadOnlyFromSession(t *testing.T) {
+	repo := new(MockTemplateRepository)
+	service := newTestService(repo)
+
+	shared := textTemplate("", "hello")
+	repo.On("GetByID", mock.Anything, shared.ID).Return(shared, nil)
+	repo.On("Update", mock.Anything, shared).Return(nil)
+
+	payload := schedule.Payload{Kind: schedule.KindText, Text: "bye"}
+	_, err := service.Replace(context.Background(), "session-1", shared.ID, "farewell", payload)
+	assert.Equal(t, ErrTemplateReadOnly, err)
+	assert.Equal(t, ErrTemplateReadOnly, service.Delete(context.Background(), "session-1", shared.ID))
+
+	updated, err := service.Replace(context.Background(), "", shared.ID, "farewell", payload)
+	require.NoError(t, err)
+	assert.Equal(t, "farewell", updated.Name)
+	assert.Equal(t, "bye", updated.Payload.Text)
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,392 @@
+package broadcaster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"zpmeow/internal/domain/broadcast"
+	"zpmeow/internal/domain/schedule"
+	"zpmeow/internal/infra/logger"
+	"zpmeow/internal/infra/meow"
+
+	"go.mau.fi/whatsmeow"
+)
+
+// EventBroadcast is the webhook event reporting that a broadcast job finished
+const EventBroadcast = "broadcast"
+
+// recipientBatch bounds how many recipients are loaded at a time
+const recipientBatch = 100
+
+// offlineRetry is how long a job waits before checking a disconnected session again
+var offlineRetry = 30 * time.Second
+
+const errSessionOffline = "waiting for session to connect"
+
+// Sender delivers broadcast messages and reports job outcomes
+type Sender interface {
+	IsClientConnected(sessionID string) bool
+	SendPayload(ctx context.Context, sessionID string, p *schedule.Payload) (*whatsmeow.SendResponse, error)
+	GenerateMessageID(sessionID string) string
+	ResolveLinkPreview(ctx context.Context, sessionID string, p *schedule.Payload)
+	NotifyWebhook(sessionID, eventType string, data interface{})
+}
+
+// Broadcaster runs broadcast jobs in the background. Jobs of the same session share one pacer,
+// so running several broadcasts at once does not raise the send rate of a session. The pacer is
+// dropped once the session has no running jobs.
+type Broadcaster struct {
+	broadcastService broadcast.BroadcastService
+	sender           Sender
+	interval         time.Duration
+	logger           logger.Logger
+
+	mu      sync.Mutex
+	running map[string]*run
+	pacers  map[string]*pacer
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+type run struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewBroadcaster creates a broadcaster sending at most perMinute messages per session
+func NewBroadcaster(broadcastService broadcast.BroadcastService, sender Sender, perMinute int) *Broadcaster {
+	if perMinute <= 0 {
+		perMinute = 20
+	}
+
+	return &Broadcaster{
+		broadcastService: broadcastService,
+		sender:           sender,
+		interval:         time.Minute / time.Duration(perMinute),
+		logger:           logger.GetLogger().Sub("broadcaster"),
+		running:          make(map[string]*run),
+		pacers:           make(map[string]*pacer),
+	}
+}
+
+// Start resumes the jobs that were running when the server stopped
+func (b *Broadcaster) Start(ctx context.Context) {
+	jobs, err := b.broadcastService.ActiveJobs(ctx)
+	if err != nil {
+		b.logger.Errorf("Failed to load running broadcast jobs: %v", err)
+		return
+	}
+
+	for _, job := range jobs {
+		b.launch(job)
+	}
+	if len(jobs) > 0 {
+		b.logger.Infof("Resumed %d broadcast jobs", len(jobs))
+	}
+}
+
+// Stop halts all jobs after their current send and waits for them. Jobs stay running in the
+// database and are resumed by the next Start.
+func (b *Broadcaster) Stop() {
+	b.mu.Lock()
+	b.closed = true
+	for _, r := range b.running {
+		r.cancel()
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
+
+// Create stores a job and starts sending it. The link preview of a text job is fetched here,
+// once for all recipients.
+func (b *Broadcaster) Create(ctx context.Context, job *broadcast.Job, recipients []*broadcast.Recipient) error {
+	b.sender.ResolveLinkPreview(ctx, job.SessionID, &job.Payload)
+	if err := b.broadcastService.Create(ctx, job, recipients); err != nil {
+		return err
+	}
+
+	b.launch(job)
+	return nil
+}
+
+// Pause stops sending a job after the message in flight
+func (b *Broadcaster) Pause(ctx context.Context, sessionID, id string) (*broadcast.Job, error) {
+	b.halt(id)
+
+	job, err := b.broadcastService.Pause(ctx, sessionID, id)
+	if err != nil {
+		b.relaunch(ctx, sessionID, id)
+		return nil, err
+	}
+	return job, nil
+}
+
+// Resume continues a paused job with the recipients that were not sent yet
+func (b *Broadcaster) Resume(ctx context.Context, sessionID, id string) (*broadcast.Job, error) {
+	job, err := b.broadcastService.Resume(ctx, sessionID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	b.launch(job)
+	return job, nil
+}
+
+// Cancel stops a job for good
+func (b *Broadcaster) Cancel(ctx context.Context, sessionID, id string) (*broadcast.Job, error) {
+	b.halt(id)
+
+	job, err := b.broadcastService.Cancel(ctx, sessionID, id)
+	if err != nil {
+		b.relaunch(ctx, sessionID, id)
+		return nil, err
+	}
+
+	b.logger.Infof("Cancelled broadcast %s of session %s", id, sessionID)
+	return job, nil
+}
+
+func (b *Broadcaster) launch(job *broadcast.Job) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed || b.running[job.ID] != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &run{cancel: cancel, done: make(chan struct{})}
+	b.running[job.ID] = r
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer close(r.done)
+		defer b.forget(job.ID, r)
+
+		b.run(ctx, job)
+	}()
+}
+
+// relaunch restarts a job that was halted for a state change that did not happen
+func (b *Broadcaster) relaunch(ctx context.Context, sessionID, id string) {
+	job, err := b.broadcastService.Get(ctx, sessionID, id)
+	if err == nil && job.Status == broadcast.StatusRunning {
+		b.launch(job)
+	}
+}
+
+// halt stops the worker of a job, if any, and waits for it to exit
+func (b *Broadcaster) halt(id string) {
+	b.mu.Lock()
+	r := b.running[id]
+	b.mu.Unlock()
+
+	if r != nil {
+		r.cancel()
+		<-r.done
+	}
+}
+
+func (b *Broadcaster) forget(id string, r *run) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r.cancel()
+	if b.running[id] == r {
+		delete(b.running, id)
+	}
+}
+
+// acquirePacer returns the pacer of a session for a job starting to send
+func (b *Broadcaster) acquirePacer(sessionID string) *pacer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p := b.pacers[sessionID]
+	if p == nil {
+		p = &pacer{}
+		b.pacers[sessionID] = p
+	}
+	p.jobs++
+	return p
+}
+
+// releasePacer is called when a job stops sending
+func (b *Broadcaster) releasePacer(sessionID string, p *pacer) {
+	b.mu.Lock()
+	p.jobs--
+	b.mu.Unlock()
+
+	b.dropIdlePacer(sessionID, p)
+}
+
+// dropIdlePacer forgets the pacer of a session without running jobs. It waits for the last
+// reserved slot to pass first, so a job started right after still keeps the pace.
+func (b *Broadcaster) dropIdlePacer(sessionID string, p *pacer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if p.jobs > 0 || b.pacers[sessionID] != p {
+		return
+	}
+	if wait := time.Until(p.nextSlot()); wait > 0 {
+		time.AfterFunc(wait, func() { b.dropIdlePacer(sessionID, p) })
+		return
+	}
+	delete(b.pacers, sessionID)
+}
+
+func (b *Broadcaster) run(ctx context.Context, job *broadcast.Job) {
+	b.logger.Infof("Sending broadcast %s of session %s", job.ID, job.SessionID)
+	pace := b.acquirePacer(job.SessionID)
+	defer b.releasePacer(job.SessionID, pace)
+
+	for ctx.Err() == nil {
+		if !b.sender.IsClientConnected(job.SessionID) {
+			if !b.recordError(job, errSessionOffline) {
+				return
+			}
+			sleep(ctx, offlineRetry)
+			continue
+		}
+		if job.LastError != "" && !b.recordError(job, "") {
+			return
+		}
+
+		recipients, err := b.broadcastService.NextRecipients(ctx, job.ID, recipientBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				b.logger.Errorf("Failed to load recipients of broadcast %s: %v", job.ID, err)
+				sleep(ctx, offlineRetry)
+			}
+			continue
+		}
+
+		if len(recipients) == 0 {
+			b.finish(job)
+			return
+		}
+
+		for _, r := range recipients {
+			if !pace.wait(ctx, b.interval) {
+				return
+			}
+			if !b.sender.IsClientConnected(job.SessionID) {
+				break
+			}
+			b.send(job, r)
+		}
+	}
+}
+
+// send delivers one message. It uses its own context so a pause does not leave a recipient
+// half way through.
+func (b *Broadcaster) send(job *broadcast.Job, r *broadcast.Recipient) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if r.MessageID == "" {
+		r.MarkSending(b.sender.GenerateMessageID(job.SessionID), time.Now())
+		if err := b.broadcastService.RecordRecipient(ctx, r); err != nil {
+			b.logger.Errorf("Failed to update recipient %d of broadcast %s: %v", r.Position, job.ID, err)
+			return
+		}
+	}
+
+	resp, err := b.sender.SendPayload(meow.WithMessageID(ctx, r.MessageID), job.SessionID, broadcast.Render(&job.Payload, r))
+	if err != nil {
+		b.logger.Warnf("Broadcast %s: send to %s failed: %v", job.ID, r.Phone, err)
+		r.MarkFailed(err.Error(), time.Now())
+	} else {
+		r.MarkSent(string(resp.ID), time.Now())
+	}
+
+	if err := b.broadcastService.RecordRecipient(ctx, r); err != nil {
+		b.logger.Errorf("Failed to update recipient %d of broadcast %s: %v", r.Position, job.ID, err)
+	}
+}
+
+// recordError saves why a job is stalled and reports whether the job is still running
+func (b *Broadcaster) recordError(job *broadcast.Job, reason string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := b.broadcastService.RecordError(ctx, job, reason)
+	if err == broadcast.ErrInvalidTransition {
+		return false
+	}
+	if err != nil {
+		b.logger.Errorf("Failed to update broadcast %s: %v", job.ID, err)
+	}
+	return true
+}
+
+// finish completes a job whose recipients were all processed and reports it over webhook
+func (b *Broadcaster) finish(job *broadcast.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := b.broadcastService.Complete(ctx, job); err != nil {
+		if err != broadcast.ErrInvalidTransition {
+			b.logger.Errorf("Failed to complete broadcast %s: %v", job.ID, err)
+		}
+		return
+	}
+
+	if done, err := b.broadcastService.Get(ctx, job.SessionID, job.ID); err == nil {
+		job = done
+	}
+
+	b.logger.Infof("Broadcast %s of session %s completed: %d sent, %d failed",
+		job.ID, job.SessionID, job.Progress.Sent, job.Progress.Failed)
+	b.sender.NotifyWebhook(job.SessionID, EventBroadcast, broadcast.NewJobDTO(job))
+}
+
+// pacer spaces the sends of one session
+type pacer struct {
+	mu   sync.Mutex
+	next time.Time
+
+	// jobs counts the running jobs of the session, guarded by Broadcaster.mu
+	jobs int
+}
+
+// wait reserves the next send slot and sleeps until it; it returns false if ctx ends first
+func (p *pacer) wait(ctx context.Context, interval time.Duration) bool {
+	p.mu.Lock()
+	now := time.Now()
+	at := p.next
+	if at.Before(now) {
+		at = now
+	}
+	p.next = at.Add(interval)
+	p.mu.Unlock()
+
+	return sleep(ctx, time.Until(at))
+}
+
+// nextSlot is the earliest time the next send may happen
+func (p *pacer) nextSlot() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.next
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
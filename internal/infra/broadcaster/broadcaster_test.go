@@ -0,0 +1,463 @@
+package broadcaster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"zpmeow/internal/config"
+	"zpmeow/internal/domain/broadcast"
+	"zpmeow/internal/domain/schedule"
+	"zpmeow/internal/infra/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mau.fi/whatsmeow"
+	waTypes "go.mau.fi/whatsmeow/types"
+)
+
+// memoryRepository keeps jobs and recipients in memory and changes jobs like the Postgres
+// repository: only while the stored status is still the expected one
+type memoryRepository struct {
+	broadcast.BroadcastRepository
+
+	mu         sync.Mutex
+	jobs       map[string]broadcast.Job
+	recipients map[string][]broadcast.Recipient
+	// failUpdate, when set, is returned by the next UpdateJob
+	failUpdate error
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		jobs:       map[string]broadcast.Job{},
+		recipients: map[string][]broadcast.Recipient{},
+	}
+}
+
+func (m *memoryRepository) CreateJob(ctx context.Context, job *broadcast.Job, recipients []*broadcast.Recipient) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.jobs[job.ID] = *job
+	for _, r := range recipients {
+		m.recipients[job.ID] = append(m.recipients[job.ID], *r)
+	}
+	return nil
+}
+
+func (m *memoryRepository) GetJob(ctx context.Context, sessionID, id string) (*broadcast.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok || job.SessionID != sessionID {
+		return nil, broadcast.ErrJobNotFound
+	}
+
+	job.Progress = broadcast.Progress{Total: len(m.recipients[id])}
+	for _, r := range m.recipients[id] {
+		switch r.Status {
+		case broadcast.RecipientPending, broadcast.RecipientSending:
+			job.Progress.Pending++
+		case broadcast.RecipientSent:
+			job.Progress.Sent++
+		case broadcast.RecipientFailed:
+			job.Progress.Failed++
+		case broadcast.RecipientCancelled:
+			job.Progress.Cancelled++
+		}
+	}
+	return &job, nil
+}
+
+func (m *memoryRepository) UpdateJob(ctx context.Context, job *broadcast.Job, from broadcast.Status) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.failUpdate; err != nil {
+		m.failUpdate = nil
+		return err
+	}
+	if m.jobs[job.ID].Status != from {
+		return broadcast.ErrInvalidTransition
+	}
+	m.jobs[job.ID] = *job
+	return nil
+}
+
+func (m *memoryRepository) ActiveJobs(ctx context.Context) ([]*broadcast.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var jobs []*broadcast.Job
+	for _, job := range m.jobs {
+		if job.Status == broadcast.StatusRunning {
+			jobs = append(jobs, &job)
+		}
+	}
+	return jobs, nil
+}
+
+func (m *memoryRepository) NextRecipients(ctx context.Context, jobID string, limit int) ([]*broadcast.Recipient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var next []*broadcast.Recipient
+	for _, r := range m.recipients[jobID] {
+		if len(next) == limit {
+			break
+		}
+		if r.Status == broadcast.RecipientPending || r.Status == broadcast.RecipientSending {
+			next = append(next, &r)
+		}
+	}
+	return next, nil
+}
+
+func (m *memoryRepository) UpdateRecipient(ctx context.Context, r *broadcast.Recipient) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.recipients[r.JobID][r.Position] = *r
+	return nil
+}
+
+func (m *memoryRepository) CancelPending(ctx context.Context, jobID string, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	for i, r := range m.recipients[jobID] {
+		if r.Status == broadcast.RecipientPending || r.Status == broadcast.RecipientSending {
+			m.recipients[jobID][i].Status = broadcast.RecipientCancelled
+			n++
+		}
+	}
+	return n, nil
+}
+
+// fakeSender records when it sent to whom and the webhooks it delivered
+type fakeSender struct {
+	mu        sync.Mutex
+	offline   bool
+	sentAt    []time.Time
+	sent      []string
+	previews  []*schedule.LinkPreview
+	webhooks  []broadcast.JobDTO
+	messageID int
+	resolved  int
+}
+
+func (f *fakeSender) IsClientConnected(sessionID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.offline
+}
+
+func (f *fakeSender) setOffline(offline bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.offline = offline
+}
+
+func (f *fakeSender) SendPayload(ctx context.Context, sessionID string, p *schedule.Payload) (*whatsmeow.SendResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sentAt = append(f.sentAt, time.Now())
+	f.sent = append(f.sent, p.Phone)
+	f.previews = append(f.previews, p.LinkPreview)
+	return &whatsmeow.SendResponse{ID: waTypes.MessageID(fmt.Sprintf("3EB0%016d", len(f.sent)))}, nil
+}
+
+func (f *fakeSender) GenerateMessageID(sessionID string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messageID++
+	return fmt.Sprintf("3EB1%016d", f.messageID)
+}
+
+func (f *fakeSender) ResolveLinkPreview(ctx context.Context, sessionID string, p *schedule.Payload) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resolved++
+	p.LinkPreview = &schedule.LinkPreview{MatchedText: "https://example.com", Title: "Example"}
+}
+
+func (f *fakeSender) NotifyWebhook(sessionID, eventType string, data interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if eventType == EventBroadcast {
+		f.webhooks = append(f.webhooks, data.(broadcast.JobDTO))
+	}
+}
+
+func (f *fakeSender) sentCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.sent)
+}
+
+func newTestBroadcaster(t *testing.T, interval time.Duration) (*Broadcaster, *memoryRepository, *fakeSender) {
+	logger.SetLogger(logger.Initialize(&config.LoggerConfig{Level: "fatal", Format: "console"}))
+
+	repo := newMemoryRepository()
+	sender := &fakeSender{}
+	b := NewBroadcaster(broadcast.NewBroadcastService(repo), sender, 20)
+	b.interval = interval
+	t.Cleanup(b.Stop)
+	return b, repo, sender
+}
+
+// createJob starts a broadcast of a session to count recipients
+func createJob(t *testing.T, b *Broadcaster, sessionID string, count int) *broadcast.Job {
+	var phones []string
+	for i := 0; i < count; i++ {
+		phones = append(phones, fmt.Sprintf("55119%08d", i))
+	}
+	recipients, _, err := broadcast.ParseRecipients(phones, "")
+	require.NoError(t, err)
+
+	job := broadcast.NewJob(sessionID, schedule.Payload{Kind: schedule.KindText, Text: "hello {{phone}}"}, nil)
+	require.NoError(t, b.Create(context.Background(), job, recipients))
+	return job
+}
+
+func getJob(t *testing.T, repo *memoryRepository, job *broadcast.Job) *broadcast.Job {
+	stored, err := repo.GetJob(context.Background(), job.SessionID, job.ID)
+	require.NoError(t, err)
+	return stored
+}
+
+func waitStatus(t *testing.T, repo *memoryRepository, job *broadcast.Job, status broadcast.Status) *broadcast.Job {
+	var stored *broadcast.Job
+	require.Eventually(t, func() bool {
+		stored = getJob(t, repo, job)
+		return stored.Status == status
+	}, 5*time.Second, 5*time.Millisecond)
+	return stored
+}
+
+func isRunning(b *Broadcaster, id string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.running[id] != nil
+}
+
+func pacerCount(b *Broadcaster) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pacers)
+}
+
+func TestBroadcaster_JobsOfASessionSharePacer(t *testing.T) {
+	interval := 20 * time.Millisecond
+	b, repo, sender := newTestBroadcaster(t, interval)
+
+	start := time.Now()
+	first := createJob(t, b, "session-1", 3)
+	second := createJob(t, b, "session-1", 3)
+
+	waitStatus(t, repo, first, broadcast.StatusCompleted)
+	waitStatus(t, repo, second, broadcast.StatusCompleted)
+
+	sender.mu.Lock()
+	sentAt := append([]time.Time(nil), sender.sentAt...)
+	sender.mu.Unlock()
+	require.Len(t, sentAt, 6)
+
+	// Each send takes the next slot of the session, whichever job it belongs to
+	sort.Slice(sentAt, func(i, j int) bool { return sentAt[i].Before(sentAt[j]) })
+	for i, at := range sentAt {
+		assert.GreaterOrEqual(t, at.Sub(start), time.Duration(i)*interval, "send %d", i)
+	}
+
+	// The pacer is dropped once the last reserved slot has passed
+	require.Eventually(t, func() bool { return pacerCount(b) == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestBroadcaster_PacerKeptWhileJobsRun(t *testing.T) {
+	b, repo, _ := newTestBroadcaster(t, 20*time.Millisecond)
+
+	short := createJob(t, b, "session-1", 1)
+	long := createJob(t, b, "session-1", 10)
+	other := createJob(t, b, "session-2", 1)
+
+	waitStatus(t, repo, short, broadcast.StatusCompleted)
+	waitStatus(t, repo, other, broadcast.StatusCompleted)
+	require.Eventually(t, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.pacers["session-2"] == nil
+	}, time.Second, 5*time.Millisecond)
+
+	b.mu.Lock()
+	p := b.pacers["session-1"]
+	b.mu.Unlock()
+	require.NotNil(t, p, "the pacer stays while a job of the session runs")
+
+	waitStatus(t, repo, long, broadcast.StatusCompleted)
+	require.Eventually(t, func() bool { return pacerCount(b) == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestBroadcaster_FinishNotifiesWebhook(t *testing.T) {
+	b, repo, sender := newTestBroadcaster(t, time.Millisecond)
+
+	job := createJob(t, b, "session-1", 3)
+	done := waitStatus(t, repo, job, broadcast.StatusCompleted)
+	assert.False(t, done.FinishedAt.IsZero())
+	assert.Equal(t, broadcast.Progress{Total: 3, Sent: 3}, done.Progress)
+
+	require.Eventually(t, func() bool { return !isRunning(b, job.ID) }, time.Second, 5*time.Millisecond)
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	assert.Equal(t, []string{"5511900000000", "5511900000001", "5511900000002"}, sender.sent)
+	require.Len(t, sender.webhooks, 1)
+	assert.Equal(t, job.ID, sender.webhooks[0].ID)
+	assert.Equal(t, "completed", sender.webhooks[0].Status)
+	assert.Equal(t, 3, sender.webhooks[0].Progress.Sent)
+
+	for _, r := range repo.recipients[job.ID] {
+		assert.Equal(t, broadcast.RecipientSent, r.Status)
+		assert.NotEmpty(t, r.MessageID)
+	}
+}
+
+func TestBroadcaster_LinkPreviewFetchedOnce(t *testing.T) {
+	b, repo, sender := newTestBroadcaster(t, time.Millisecond)
+
+	job := createJob(t, b, "session-1", 3)
+	waitStatus(t, repo, job, broadcast.StatusCompleted)
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	assert.Equal(t, 1, sender.resolved)
+	require.Len(t, sender.previews, 3)
+	for _, preview := range sender.previews {
+		require.NotNil(t, preview, "every send reuses the preview stored with the job")
+		assert.Equal(t, "Example", preview.Title)
+	}
+}
+
+func TestBroadcaster_PauseAndResume(t *testing.T) {
+	b, repo, sender := newTestBroadcaster(t, 20*time.Millisecond)
+
+	job := createJob(t, b, "session-1", 20)
+	require.Eventually(t, func() bool { return sender.sentCount() > 0 }, time.Second, time.Millisecond)
+
+	paused, err := b.Pause(context.Background(), job.SessionID, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, broadcast.StatusPaused, paused.Status)
+	assert.False(t, isRunning(b, job.ID), "Pause waits for the worker to exit")
+
+	sent := sender.sentCount()
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, sent, sender.sentCount(), "nothing is sent while paused")
+
+	_, err = b.Pause(context.Background(), job.SessionID, job.ID)
+	assert.ErrorIs(t, err, broadcast.ErrInvalidTransition)
+	assert.False(t, isRunning(b, job.ID), "a paused job is not relaunched")
+
+	b.interval = time.Millisecond
+	_, err = b.Resume(context.Background(), job.SessionID, job.ID)
+	require.NoError(t, err)
+
+	done := waitStatus(t, repo, job, broadcast.StatusCompleted)
+	assert.Equal(t, 20, done.Progress.Sent)
+	assert.Equal(t, 20, sender.sentCount(), "every recipient is sent exactly once")
+}
+
+func TestBroadcaster_FailedPauseRelaunches(t *testing.T) {
+	b, repo, sender := newTestBroadcaster(t, 10*time.Millisecond)
+
+	job := createJob(t, b, "session-1", 10)
+	require.Eventually(t, func() bool { return sender.sentCount() > 0 }, time.Second, time.Millisecond)
+
+	repo.mu.Lock()
+	repo.failUpdate = errors.New("database is down")
+	repo.mu.Unlock()
+
+	_, err := b.Pause(context.Background(), job.SessionID, job.ID)
+	assert.EqualError(t, err, "database is down")
+	assert.True(t, isRunning(b, job.ID), "the job is still running, so its worker is relaunched")
+
+	done := waitStatus(t, repo, job, broadcast.StatusCompleted)
+	assert.Equal(t, 10, done.Progress.Sent)
+	assert.Equal(t, 10, sender.sentCount())
+}
+
+func TestBroadcaster_Cancel(t *testing.T) {
+	b, repo, sender := newTestBroadcaster(t, 20*time.Millisecond)
+
+	job := createJob(t, b, "session-1", 20)
+	require.Eventually(t, func() bool { return sender.sentCount() > 0 }, time.Second, time.Millisecond)
+
+	cancelled, err := b.Cancel(context.Background(), job.SessionID, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, broadcast.StatusCancelled, cancelled.Status)
+	assert.False(t, isRunning(b, job.ID))
+
+	sent := sender.sentCount()
+	assert.Equal(t, broadcast.Progress{Total: 20, Sent: sent, Cancelled: 20 - sent}, cancelled.Progress)
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, sent, sender.sentCount(), "nothing is sent after Cancel returns")
+
+	_, err = b.Cancel(context.Background(), job.SessionID, job.ID)
+	assert.ErrorIs(t, err, broadcast.ErrInvalidTransition)
+	assert.False(t, isRunning(b, job.ID), "a cancelled job is not relaunched")
+	assert.Equal(t, broadcast.StatusCancelled, getJob(t, repo, job).Status)
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	assert.Empty(t, sender.webhooks, "only completed jobs are reported")
+}
+
+func TestBroadcaster_WaitsForSessionToConnect(t *testing.T) {
+	retry := offlineRetry
+	offlineRetry = 5 * time.Millisecond
+	t.Cleanup(func() { offlineRetry = retry })
+
+	b, repo, sender := newTestBroadcaster(t, time.Millisecond)
+	sender.setOffline(true)
+
+	job := createJob(t, b, "session-1", 2)
+	require.Eventually(t, func() bool {
+		return getJob(t, repo, job).LastError == errSessionOffline
+	}, time.Second, time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Zero(t, sender.sentCount(), "nothing is sent while the session is offline")
+	assert.Equal(t, broadcast.StatusRunning, getJob(t, repo, job).Status)
+
+	sender.setOffline(false)
+	done := waitStatus(t, repo, job, broadcast.StatusCompleted)
+	assert.Empty(t, done.LastError)
+	assert.Equal(t, 2, done.Progress.Sent)
+}
+
+func TestBroadcaster_StopAndStart(t *testing.T) {
+	b, repo, sender := newTestBroadcaster(t, 20*time.Millisecond)
+
+	job := createJob(t, b, "session-1", 10)
+	require.Eventually(t, func() bool { return sender.sentCount() > 0 }, time.Second, time.Millisecond)
+
+	b.Stop()
+	assert.False(t, isRunning(b, job.ID))
+	assert.Equal(t, broadcast.StatusRunning, getJob(t, repo, job).Status, "stopped jobs are resumed by the next Start")
+
+	// A new broadcaster picks up the running jobs of the repository
+	resumed := NewBroadcaster(broadcast.NewBroadcastService(repo), sender, 20)
+	resumed.interval = time.Millisecond
+	defer resumed.Stop()
+	resumed.Start(context.Background())
+
+	waitStatus(t, repo, job, broadcast.StatusCompleted)
+	assert.Equal(t, 10, sender.sentCount())
+}
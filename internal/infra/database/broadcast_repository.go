@@ -0,0 +1,353 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"zpmeow/internal/domain/broadcast"
+
+	"github.com/jmoiron/sqlx"
+)
+
+
+type PostgresBroadcastRepository struct {
+	db *sqlx.DB
+}
+
+
+func NewPostgresBroadcastRepository(db *sqlx.DB) broadcast.BroadcastRepository {
+	return &PostgresBroadcastRepository{db: db}
+}
+
+
+// recipientInsertBatch keeps one insert statement well below the PostgreSQL parameter limit.
+const recipientInsertBatch = 1000
+
+
+// broadcastJobSelect reads jobs with their progress counted from the recipients; %s is the
+// media column, which job lists leave out.
+const broadcastJobSelect = `
+	SELECT j.id, j.session_id, j.kind, j.payload, %s AS media, j.media_path, j.variables, j.status, j.last_error,
+		j.created_at, j.updated_at, j.finished_at,
+		COUNT(r.position) AS total,
+		COUNT(r.position) FILTER (WHERE r.status IN ('pending', 'sending')) AS pending,
+		COUNT(r.position) FILTER (WHERE r.status = 'sent') AS sent,
+		COUNT(r.position) FILTER (WHERE r.status = 'failed') AS failed,
+		COUNT(r.position) FILTER (WHERE r.status = 'cancelled') AS cancelled
+	FROM broadcast_jobs j
+	LEFT JOIN broadcast_recipients r ON r.job_id = j.id
+`
+
+
+type broadcastJobModel struct {
+	ID         string       `db:"id"`
+	SessionID  string       `db:"session_id"`
+	Kind       string       `db:"kind"`
+	Payload    string       `db:"payload"`
+	Media      []byte       `db:"media"`
+	MediaPath  string       `db:"media_path"`
+	Variables  string       `db:"variables"`
+	Status     string       `db:"status"`
+	LastError  string       `db:"last_error"`
+	CreatedAt  time.Time    `db:"created_at"`
+	UpdatedAt  time.Time    `db:"updated_at"`
+	FinishedAt sql.NullTime `db:"finished_at"`
+	Total      int          `db:"total"`
+	Pending    int          `db:"pending"`
+	Sent       int          `db:"sent"`
+	Failed     int          `db:"failed"`
+	Cancelled  int          `db:"cancelled"`
+}
+
+
+func (m *broadcastJobModel) toEntity() (*broadcast.Job, error) {
+	job := &broadcast.Job{
+		ID:         m.ID,
+		SessionID:  m.SessionID,
+		Status:     broadcast.Status(m.Status),
+		LastError:  m.LastError,
+		CreatedAt:  m.CreatedAt,
+		UpdatedAt:  m.UpdatedAt,
+		FinishedAt: m.FinishedAt.Time,
+		Progress: broadcast.Progress{
+			Total:     m.Total,
+			Pending:   m.Pending,
+			Sent:      m.Sent,
+			Failed:    m.Failed,
+			Cancelled: m.Cancelled,
+		},
+	}
+
+	if err := json.Unmarshal([]byte(m.Payload), &job.Payload); err != nil {
+		return nil, fmt.Errorf("failed to decode broadcast payload of %s: %w", m.ID, err)
+	}
+	if err := json.Unmarshal([]byte(m.Variables), &job.Variables); err != nil {
+		return nil, fmt.Errorf("failed to decode broadcast variables of %s: %w", m.ID, err)
+	}
+	job.Payload.Media, job.Payload.MediaPath = m.Media, m.MediaPath
+
+	return job, nil
+}
+
+
+func toBroadcastJobs(models []broadcastJobModel) ([]*broadcast.Job, error) {
+	jobs := make([]*broadcast.Job, 0, len(models))
+	for i := range models {
+		job, err := models[i].toEntity()
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+
+type broadcastRecipientModel struct {
+	JobID     string       `db:"job_id"`
+	Position  int          `db:"position"`
+	Phone     string       `db:"phone"`
+	Variables string       `db:"variables"`
+	Status    string       `db:"status"`
+	MessageID string       `db:"message_id"`
+	Error     string       `db:"error"`
+	UpdatedAt time.Time    `db:"updated_at"`
+	SentAt    sql.NullTime `db:"sent_at"`
+}
+
+
+func newBroadcastRecipientModel(r *broadcast.Recipient) (*broadcastRecipientModel, error) {
+	variables, err := json.Marshal(r.Variables)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode recipient variables: %w", err)
+	}
+
+	return &broadcastRecipientModel{
+		JobID:     r.JobID,
+		Position:  r.Position,
+		Phone:     r.Phone,
+		Variables: string(variables),
+		Status:    string(r.Status),
+		MessageID: r.MessageID,
+		Error:     r.Error,
+		UpdatedAt: r.UpdatedAt,
+		SentAt:    nullTime(r.SentAt),
+	}, nil
+}
+
+
+func (m *broadcastRecipientModel) toEntity() (*broadcast.Recipient, error) {
+	r := &broadcast.Recipient{
+		JobID:     m.JobID,
+		Position:  m.Position,
+		Phone:     m.Phone,
+		Status:    broadcast.RecipientStatus(m.Status),
+		MessageID: m.MessageID,
+		Error:     m.Error,
+		UpdatedAt: m.UpdatedAt,
+		SentAt:    m.SentAt.Time,
+	}
+
+	if err := json.Unmarshal([]byte(m.Variables), &r.Variables); err != nil {
+		return nil, fmt.Errorf("failed to decode variables of recipient %d of %s: %w", m.Position, m.JobID, err)
+	}
+	return r, nil
+}
+
+
+func toBroadcastRecipients(models []broadcastRecipientModel) ([]*broadcast.Recipient, error) {
+	recipients := make([]*broadcast.Recipient, 0, len(models))
+	for i := range models {
+		r, err := models[i].toEntity()
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+
+func (r *PostgresBroadcastRepository) CreateJob(ctx context.Context, job *broadcast.Job, recipients []*broadcast.Recipient) error {
+	payload, err := json.Marshal(job.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode broadcast payload: %w", err)
+	}
+	variables, err := json.Marshal(job.Variables)
+	if err != nil {
+		return fmt.Errorf("failed to encode broadcast variables: %w", err)
+	}
+
+	models := make([]*broadcastRecipientModel, len(recipients))
+	for i, recipient := range recipients {
+		if models[i], err = newBroadcastRecipientModel(recipient); err != nil {
+			return err
+		}
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO broadcast_jobs (id, session_id, kind, payload, media, media_path, variables, status, last_error,
+			created_at, updated_at, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, job.ID, job.SessionID, job.Payload.Kind, string(payload), job.Payload.Media, job.Payload.MediaPath, string(variables),
+		string(job.Status), job.LastError, job.CreatedAt, job.UpdatedAt, nullTime(job.FinishedAt))
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO broadcast_recipients (job_id, position, phone, variables, status, message_id, error, updated_at, sent_at)
+		VALUES (:job_id, :position, :phone, :variables, :status, :message_id, :error, :updated_at, :sent_at)
+	`
+	for start := 0; start < len(models); start += recipientInsertBatch {
+		end := start + recipientInsertBatch
+		if end > len(models) {
+			end = len(models)
+		}
+		if _, err := tx.NamedExecContext(ctx, query, models[start:end]); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+
+func (r *PostgresBroadcastRepository) GetJob(ctx context.Context, sessionID, id string) (*broadcast.Job, error) {
+	var model broadcastJobModel
+
+	query := fmt.Sprintf(broadcastJobSelect, "j.media") + ` WHERE j.session_id = $1 AND j.id = $2 GROUP BY j.id`
+	if err := r.db.GetContext(ctx, &model, query, sessionID, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, broadcast.ErrJobNotFound
+		}
+		return nil, err
+	}
+
+	return model.toEntity()
+}
+
+
+func (r *PostgresBroadcastRepository) ListJobs(ctx context.Context, filter broadcast.JobFilter) ([]*broadcast.Job, error) {
+	query := fmt.Sprintf(broadcastJobSelect, "NULL::bytea") +
+		` WHERE j.session_id = $1 GROUP BY j.id ORDER BY j.created_at DESC, j.id LIMIT $2 OFFSET $3`
+
+	var models []broadcastJobModel
+	if err := r.db.SelectContext(ctx, &models, query, filter.SessionID, filter.Limit, filter.Offset); err != nil {
+		return nil, err
+	}
+
+	return toBroadcastJobs(models)
+}
+
+
+func (r *PostgresBroadcastRepository) UpdateJob(ctx context.Context, job *broadcast.Job, from broadcast.Status) error {
+	query := `
+		UPDATE broadcast_jobs SET status = $3, last_error = $4, updated_at = $5, finished_at = $6
+		WHERE session_id = $1 AND id = $2 AND status = $7
+	`
+
+	result, err := r.db.ExecContext(ctx, query,
+		job.SessionID, job.ID, string(job.Status), job.LastError, job.UpdatedAt, nullTime(job.FinishedAt), string(from))
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return broadcast.ErrInvalidTransition
+	}
+	return nil
+}
+
+
+func (r *PostgresBroadcastRepository) ActiveJobs(ctx context.Context) ([]*broadcast.Job, error) {
+	query := fmt.Sprintf(broadcastJobSelect, "j.media") + ` WHERE j.status = $1 GROUP BY j.id ORDER BY j.created_at`
+
+	var models []broadcastJobModel
+	if err := r.db.SelectContext(ctx, &models, query, string(broadcast.StatusRunning)); err != nil {
+		return nil, err
+	}
+
+	return toBroadcastJobs(models)
+}
+
+
+func (r *PostgresBroadcastRepository) ListRecipients(ctx context.Context, filter broadcast.RecipientFilter) ([]*broadcast.Recipient, error) {
+	args := []interface{}{filter.JobID}
+	where := "job_id = $1"
+	if filter.Status != "" {
+		args = append(args, string(filter.Status))
+		where += " AND status = $2"
+	}
+	args = append(args, filter.Limit, filter.Offset)
+
+	query := fmt.Sprintf(`
+		SELECT job_id, position, phone, variables, status, message_id, error, updated_at, sent_at
+		FROM broadcast_recipients WHERE %s ORDER BY position LIMIT $%d OFFSET $%d
+	`, where, len(args)-1, len(args))
+
+	var models []broadcastRecipientModel
+	if err := r.db.SelectContext(ctx, &models, query, args...); err != nil {
+		return nil, err
+	}
+
+	return toBroadcastRecipients(models)
+}
+
+
+func (r *PostgresBroadcastRepository) NextRecipients(ctx context.Context, jobID string, limit int) ([]*broadcast.Recipient, error) {
+	query := `
+		SELECT job_id, position, phone, variables, status, message_id, error, updated_at, sent_at
+		FROM broadcast_recipients
+		WHERE job_id = $1 AND status IN ($2, $3)
+		ORDER BY position
+		LIMIT $4
+	`
+
+	var models []broadcastRecipientModel
+	err := r.db.SelectContext(ctx, &models, query,
+		jobID, string(broadcast.RecipientPending), string(broadcast.RecipientSending), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return toBroadcastRecipients(models)
+}
+
+
+func (r *PostgresBroadcastRepository) UpdateRecipient(ctx context.Context, recipient *broadcast.Recipient) error {
+	query := `
+		UPDATE broadcast_recipients SET status = $3, message_id = $4, error = $5, updated_at = $6, sent_at = $7
+		WHERE job_id = $1 AND position = $2
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		recipient.JobID, recipient.Position, string(recipient.Status), recipient.MessageID, recipient.Error,
+		recipient.UpdatedAt, nullTime(recipient.SentAt))
+	return err
+}
+
+
+func (r *PostgresBroadcastRepository) CancelPending(ctx context.Context, jobID string, now time.Time) (int64, error) {
+	query := `UPDATE broadcast_recipients SET status = $2, updated_at = $3 WHERE job_id = $1 AND status IN ($4, $5)`
+
+	result, err := r.db.ExecContext(ctx, query, jobID, string(broadcast.RecipientCancelled), now,
+		string(broadcast.RecipientPending), string(broadcast.RecipientSending))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
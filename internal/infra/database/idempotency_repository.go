@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"zpmeow/internal/domain/idempotency"
+
+	"github.com/jmoiron/sqlx"
+)
+
+
+type PostgresIdempotencyRepository struct {
+	db *sqlx.DB
+}
+
+
+func NewPostgresIdempotencyRepository(db *sqlx.DB) idempotency.IdempotencyRepository {
+	return &PostgresIdempotencyRepository{db: db}
+}
+
+
+type idempotencyRecordModel struct {
+	SessionID   string    `db:"session_id"`
+	Key         string    `db:"idempotency_key"`
+	RequestHash string    `db:"request_hash"`
+	Status      string    `db:"status"`
+	Response    []byte    `db:"response"`
+	CreatedAt   time.Time `db:"created_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+
+// Reserve inserts the record, or takes over a key whose record expired or was left pending
+// by a send that never finished. The conditional upsert keeps concurrent retries from both
+// winning the key.
+func (r *PostgresIdempotencyRepository) Reserve(ctx context.Context, record *idempotency.Record, staleBefore time.Time) (*idempotency.Record, error) {
+	query := `
+		INSERT INTO send_idempotency (session_id, idempotency_key, request_hash, status, response, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, NULL, $5, $6)
+		ON CONFLICT (session_id, idempotency_key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			status = EXCLUDED.status,
+			response = NULL,
+			created_at = EXCLUDED.created_at,
+			expires_at = EXCLUDED.expires_at
+		WHERE send_idempotency.expires_at <= EXCLUDED.created_at
+			OR (send_idempotency.status = $7 AND send_idempotency.created_at < $8)
+		RETURNING session_id
+	`
+
+	var reserved string
+	err := r.db.GetContext(ctx, &reserved, query,
+		record.SessionID, record.Key, record.RequestHash, string(record.Status),
+		record.CreatedAt, record.ExpiresAt, string(idempotency.StatusPending), staleBefore)
+	if err == nil {
+		return nil, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var model idempotencyRecordModel
+	err = r.db.GetContext(ctx, &model, `
+		SELECT session_id, idempotency_key, request_hash, status, response, created_at, expires_at
+		FROM send_idempotency WHERE session_id = $1 AND idempotency_key = $2
+	`, record.SessionID, record.Key)
+	if err == sql.ErrNoRows {
+		// The holder released the key between both statements; report it as still busy.
+		return nil, idempotency.ErrRequestInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &idempotency.Record{
+		SessionID:   model.SessionID,
+		Key:         model.Key,
+		RequestHash: model.RequestHash,
+		Status:      idempotency.Status(model.Status),
+		Response:    model.Response,
+		CreatedAt:   model.CreatedAt,
+		ExpiresAt:   model.ExpiresAt,
+	}, nil
+}
+
+
+func (r *PostgresIdempotencyRepository) Complete(ctx context.Context, sessionID, key string, response []byte) error {
+	query := `UPDATE send_idempotency SET status = $3, response = $4 WHERE session_id = $1 AND idempotency_key = $2`
+	_, err := r.db.ExecContext(ctx, query, sessionID, key, string(idempotency.StatusCompleted), response)
+	return err
+}
+
+
+func (r *PostgresIdempotencyRepository) Delete(ctx context.Context, sessionID, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM send_idempotency WHERE session_id = $1 AND idempotency_key = $2`, sessionID, key)
+	return err
+}
+
+
+func (r *PostgresIdempotencyRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM send_idempotency WHERE expires_at <= $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
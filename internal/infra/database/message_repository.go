@@ -0,0 +1,723 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"zpmeow/internal/domain/message"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+
+type PostgresMessageRepository struct {
+	db *sqlx.DB
+}
+
+
+func NewPostgresMessageRepository(db *sqlx.DB) message.MessageRepository {
+	return &PostgresMessageRepository{db: db}
+}
+
+
+type messageModel struct {
+	SessionID         string    `db:"session_id"`
+	ID                string    `db:"id"`
+	ChatJID           string    `db:"chat_jid"`
+	SenderJID         string    `db:"sender_jid"`
+	PushName          string    `db:"push_name"`
+	FromMe            bool      `db:"from_me"`
+	IsGroup           bool      `db:"is_group"`
+	Type              string    `db:"message_type"`
+	Text              string    `db:"text"`
+	Caption           string    `db:"caption"`
+	MimeType          string    `db:"mime_type"`
+	FileName          string    `db:"file_name"`
+	FileLength        int64     `db:"file_length"`
+	HasMedia          bool      `db:"has_media"`
+	QuotedID          string    `db:"quoted_id"`
+	QuotedParticipant string    `db:"quoted_participant"`
+	ViewOnce          bool      `db:"view_once"`
+	Raw               []byte    `db:"raw"`
+	Timestamp         time.Time `db:"timestamp"`
+	CreatedAt         time.Time `db:"created_at"`
+}
+
+
+// messageSearchDocument builds the weighted search vector of a message row for the given text search configuration.
+const messageSearchDocument = `setweight(to_tsvector(%[1]s, %[2]stext || ' ' || %[2]scaption), 'A') ||
+	setweight(to_tsvector(%[1]s, regexp_replace(%[2]sfile_name, '[._-]+', ' ', 'g')), 'B')`
+
+
+const messageColumns = `session_id, id, chat_jid, sender_jid, push_name, from_me, is_group, message_type,
+	text, caption, mime_type, file_name, file_length, has_media, quoted_id, quoted_participant,
+	view_once, raw, timestamp, created_at`
+
+
+func (m *messageModel) toEntity() *message.Message {
+	return &message.Message{
+		ID:                m.ID,
+		SessionID:         m.SessionID,
+		ChatJID:           m.ChatJID,
+		SenderJID:         m.SenderJID,
+		PushName:          m.PushName,
+		FromMe:            m.FromMe,
+		IsGroup:           m.IsGroup,
+		Type:              m.Type,
+		Text:              m.Text,
+		Caption:           m.Caption,
+		MimeType:          m.MimeType,
+		FileName:          m.FileName,
+		FileLength:        uint64(m.FileLength),
+		QuotedID:          m.QuotedID,
+		QuotedParticipant: m.QuotedParticipant,
+		ViewOnce:          m.ViewOnce,
+		Raw:               m.Raw,
+		Timestamp:         m.Timestamp,
+		CreatedAt:         m.CreatedAt,
+	}
+}
+
+
+func fromMessageEntity(msg *message.Message) *messageModel {
+	createdAt := msg.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	return &messageModel{
+		SessionID:         msg.SessionID,
+		ID:                msg.ID,
+		ChatJID:           msg.ChatJID,
+		SenderJID:         msg.SenderJID,
+		PushName:          msg.PushName,
+		FromMe:            msg.FromMe,
+		IsGroup:           msg.IsGroup,
+		Type:              msg.Type,
+		Text:              msg.Text,
+		Caption:           msg.Caption,
+		MimeType:          msg.MimeType,
+		FileName:          msg.FileName,
+		FileLength:        int64(msg.FileLength),
+		HasMedia:          msg.HasMedia(),
+		QuotedID:          msg.QuotedID,
+		QuotedParticipant: msg.QuotedParticipant,
+		ViewOnce:          msg.ViewOnce,
+		Raw:               msg.Raw,
+		Timestamp:         msg.Timestamp,
+		CreatedAt:         createdAt,
+	}
+}
+
+
+func (r *PostgresMessageRepository) Save(ctx context.Context, msg *message.Message) error {
+	model := fromMessageEntity(msg)
+
+	language := `COALESCE((SELECT search_language FROM sessions WHERE id = :session_id), 'portuguese')`
+
+	query := `
+		INSERT INTO messages (` + messageColumns + `, search_language, search_vector)
+		VALUES (:session_id, :id, :chat_jid, :sender_jid, :push_name, :from_me, :is_group, :message_type,
+			:text, :caption, :mime_type, :file_name, :file_length, :has_media, :quoted_id, :quoted_participant,
+			:view_once, :raw, :timestamp, :created_at, ` + language + `,
+			` + fmt.Sprintf(messageSearchDocument, "CAST("+language+" AS regconfig)", ":") + `)
+		ON CONFLICT (session_id, id) DO UPDATE SET
+			message_type = EXCLUDED.message_type,
+			text = EXCLUDED.text,
+			caption = EXCLUDED.caption,
+			mime_type = EXCLUDED.mime_type,
+			file_name = EXCLUDED.file_name,
+			file_length = EXCLUDED.file_length,
+			has_media = EXCLUDED.has_media,
+			quoted_id = EXCLUDED.quoted_id,
+			quoted_participant = EXCLUDED.quoted_participant,
+			view_once = EXCLUDED.view_once,
+			raw = EXCLUDED.raw,
+			search_language = EXCLUDED.search_language,
+			search_vector = EXCLUDED.search_vector
+	`
+
+	_, err := r.db.NamedExecContext(ctx, query, model)
+	return err
+}
+
+
+func (r *PostgresMessageRepository) GetByID(ctx context.Context, sessionID, id string) (*message.Message, error) {
+	var model messageModel
+
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE session_id = $1 AND id = $2`
+
+	err := r.db.GetContext(ctx, &model, query, sessionID, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, message.ErrMessageNotFound
+		}
+		return nil, err
+	}
+
+	return model.toEntity(), nil
+}
+
+
+func (r *PostgresMessageRepository) List(ctx context.Context, filter message.ListFilter) ([]*message.Message, error) {
+	where, args := buildMessageFilter(filter)
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(`SELECT %s FROM messages WHERE %s ORDER BY timestamp DESC, id DESC LIMIT $%d`,
+		messageColumns, strings.Join(where, " AND "), len(args))
+
+	var models []messageModel
+	if err := r.db.SelectContext(ctx, &models, query, args...); err != nil {
+		return nil, err
+	}
+
+	messages := make([]*message.Message, len(models))
+	for i := range models {
+		messages[i] = models[i].toEntity()
+	}
+
+	return messages, nil
+}
+
+
+func buildMessageFilter(filter message.ListFilter) ([]string, []interface{}) {
+	var where []string
+	var args []interface{}
+
+	add := func(clause string, values ...interface{}) {
+		placeholders := make([]interface{}, len(values))
+		for i, v := range values {
+			args = append(args, v)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		where = append(where, fmt.Sprintf(clause, placeholders...))
+	}
+
+	add("session_id = %s", filter.SessionID)
+	add("chat_jid = %s", filter.ChatJID)
+
+	switch filter.Direction {
+	case message.DirectionIncoming:
+		add("from_me = %s", false)
+	case message.DirectionOutgoing:
+		add("from_me = %s", true)
+	}
+
+	if len(filter.Types) > 0 {
+		placeholders := make([]string, len(filter.Types))
+		for i, t := range filter.Types {
+			args = append(args, t)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		where = append(where, "message_type IN ("+strings.Join(placeholders, ", ")+")")
+	}
+
+	if filter.SenderJID != "" {
+		add("sender_jid = %s", filter.SenderJID)
+	}
+	if !filter.Since.IsZero() {
+		add("timestamp >= %s", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		add("timestamp <= %s", filter.Until)
+	}
+	if filter.HasMedia != nil {
+		add("has_media = %s", *filter.HasMedia)
+	}
+	if filter.Cursor != nil {
+		add("(timestamp, id) < (%s, %s)", filter.Cursor.Timestamp, filter.Cursor.ID)
+	}
+
+	return where, args
+}
+
+
+// Search snippets mark matches with control characters, stripped from the text beforehand, so
+// the text can be HTML-escaped before the markers become <mark> tags.
+const (
+	snippetStartSel = "\x02"
+	snippetStopSel  = "\x03"
+)
+
+
+var snippetMarks = strings.NewReplacer(snippetStartSel, "<mark>", snippetStopSel, "</mark>")
+
+
+// highlightSnippet escapes a ts_headline snippet, keeping only its highlight marks as HTML.
+func highlightSnippet(snippet string) string {
+	return snippetMarks.Replace(html.EscapeString(snippet))
+}
+
+
+type searchHitModel struct {
+	messageModel
+	Snippet string  `db:"snippet"`
+	Rank    float64 `db:"rank"`
+}
+
+
+func (r *PostgresMessageRepository) Search(ctx context.Context, query message.SearchQuery) ([]*message.SearchHit, error) {
+	args := []interface{}{query.SessionID, query.Language, query.Query}
+	where := []string{"session_id = $1", "search_vector @@ websearch_to_tsquery($2::regconfig, $3)"}
+
+	add := func(clause string, value interface{}) {
+		args = append(args, value)
+		where = append(where, fmt.Sprintf(clause, len(args)))
+	}
+
+	if query.ChatJID != "" {
+		add("chat_jid = $%d", query.ChatJID)
+	}
+	if len(query.Types) > 0 {
+		add("message_type = ANY($%d)", pq.Array(query.Types))
+	}
+	if !query.Since.IsZero() {
+		add("timestamp >= $%d", query.Since)
+	}
+	if !query.Until.IsZero() {
+		add("timestamp <= $%d", query.Until)
+	}
+
+	args = append(args, query.Limit, query.Offset)
+	sqlQuery := fmt.Sprintf(`
+		SELECT %s,
+			ts_headline($2::regconfig, translate(trim(text || ' ' || caption || ' ' || file_name), chr(2) || chr(3), ''),
+				websearch_to_tsquery($2::regconfig, $3),
+				'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxWords=30, MinWords=10, MaxFragments=2') AS snippet,
+			ts_rank(search_vector, websearch_to_tsquery($2::regconfig, $3)) AS rank
+		FROM messages
+		WHERE %s
+		ORDER BY rank DESC, timestamp DESC, id DESC
+		LIMIT $%d OFFSET $%d`,
+		messageColumns, strings.Join(where, " AND "), len(args)-1, len(args))
+
+	var models []searchHitModel
+	if err := r.db.SelectContext(ctx, &models, sqlQuery, args...); err != nil {
+		return nil, err
+	}
+
+	hits := make([]*message.SearchHit, len(models))
+	for i := range models {
+		hits[i] = &message.SearchHit{
+			Message: models[i].toEntity(),
+			Snippet: highlightSnippet(models[i].Snippet),
+			Rank:    models[i].Rank,
+		}
+	}
+
+	return hits, nil
+}
+
+
+// ListAround returns up to size messages on each side of the anchor, both in chronological order.
+func (r *PostgresMessageRepository) ListAround(ctx context.Context, sessionID, chatJID string, anchor message.Cursor, size int) ([]*message.Message, []*message.Message, error) {
+	beforeQuery := `SELECT ` + messageColumns + ` FROM messages
+		WHERE session_id = $1 AND chat_jid = $2 AND (timestamp, id) < ($3, $4)
+		ORDER BY timestamp DESC, id DESC LIMIT $5`
+
+	afterQuery := `SELECT ` + messageColumns + ` FROM messages
+		WHERE session_id = $1 AND chat_jid = $2 AND (timestamp, id) > ($3, $4)
+		ORDER BY timestamp ASC, id ASC LIMIT $5`
+
+	var beforeModels, afterModels []messageModel
+	if err := r.db.SelectContext(ctx, &beforeModels, beforeQuery, sessionID, chatJID, anchor.Timestamp, anchor.ID, size); err != nil {
+		return nil, nil, err
+	}
+	if err := r.db.SelectContext(ctx, &afterModels, afterQuery, sessionID, chatJID, anchor.Timestamp, anchor.ID, size); err != nil {
+		return nil, nil, err
+	}
+
+	before := make([]*message.Message, len(beforeModels))
+	for i := range beforeModels {
+		before[len(beforeModels)-1-i] = beforeModels[i].toEntity()
+	}
+
+	after := make([]*message.Message, len(afterModels))
+	for i := range afterModels {
+		after[i] = afterModels[i].toEntity()
+	}
+
+	return before, after, nil
+}
+
+
+// Reindex rebuilds the search vectors of up to batchSize messages of a session not yet indexed with
+// language, returning how many were rebuilt.
+func (r *PostgresMessageRepository) Reindex(ctx context.Context, sessionID, language string, batchSize int) (int64, error) {
+	query := `
+		UPDATE messages SET search_language = $2, search_vector = ` + fmt.Sprintf(messageSearchDocument, "$2::regconfig", "") + `
+		WHERE session_id = $1 AND id IN (
+			SELECT id FROM messages WHERE session_id = $1 AND search_language <> $2 LIMIT $3
+		)`
+
+	result, err := r.db.ExecContext(ctx, query, sessionID, language, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+
+// CountUnindexed counts the messages of a session not yet indexed with language.
+func (r *PostgresMessageRepository) CountUnindexed(ctx context.Context, sessionID, language string) (int64, error) {
+	var count int64
+	err := r.db.GetContext(ctx, &count,
+		`SELECT COUNT(*) FROM messages WHERE session_id = $1 AND search_language <> $2`, sessionID, language)
+	return count, err
+}
+
+
+type receiptModel struct {
+	SessionID   string    `db:"session_id"`
+	MessageID   string    `db:"message_id"`
+	ChatJID     string    `db:"chat_jid"`
+	Participant string    `db:"participant_jid"`
+	Status      string    `db:"status"`
+	Timestamp   time.Time `db:"timestamp"`
+}
+
+
+// SaveReceipt only records receipts of stored outgoing messages; repeated receipts keep the first timestamp.
+func (r *PostgresMessageRepository) SaveReceipt(ctx context.Context, receipt *message.Receipt) (bool, error) {
+	query := `
+		INSERT INTO message_receipts (session_id, message_id, chat_jid, participant_jid, status, timestamp)
+		SELECT $1, $2, $3, $4, $5, $6
+		WHERE EXISTS (SELECT 1 FROM messages WHERE session_id = $1 AND id = $2 AND from_me)
+		ON CONFLICT (session_id, message_id, participant_jid, status) DO NOTHING
+	`
+
+	result, err := r.db.ExecContext(ctx, query,
+		receipt.SessionID, receipt.MessageID, receipt.ChatJID, receipt.Participant, string(receipt.Status), receipt.Timestamp)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+
+func (r *PostgresMessageRepository) ListReceipts(ctx context.Context, sessionID, messageID string) ([]*message.Receipt, error) {
+	query := `
+		SELECT session_id, message_id, chat_jid, participant_jid, status, timestamp
+		FROM message_receipts
+		WHERE session_id = $1 AND message_id = $2
+		ORDER BY timestamp ASC, created_at ASC
+	`
+
+	var models []receiptModel
+	if err := r.db.SelectContext(ctx, &models, query, sessionID, messageID); err != nil {
+		return nil, err
+	}
+
+	receipts := make([]*message.Receipt, len(models))
+	for i, m := range models {
+		receipts[i] = &message.Receipt{
+			SessionID:   m.SessionID,
+			MessageID:   m.MessageID,
+			ChatJID:     m.ChatJID,
+			Participant: m.Participant,
+			Status:      message.Status(m.Status),
+			Timestamp:   m.Timestamp,
+		}
+	}
+
+	return receipts, nil
+}
+
+
+func (r *PostgresMessageRepository) GetOldestInChat(ctx context.Context, sessionID, chatJID string, before time.Time) (*message.Message, error) {
+	args := []interface{}{sessionID, chatJID}
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE session_id = $1 AND chat_jid = $2`
+	if !before.IsZero() {
+		args = append(args, before)
+		query += ` AND timestamp < $3`
+	}
+	query += ` ORDER BY timestamp ASC, id ASC LIMIT 1`
+
+	var model messageModel
+	if err := r.db.GetContext(ctx, &model, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, message.ErrMessageNotFound
+		}
+		return nil, err
+	}
+
+	return model.toEntity(), nil
+}
+
+
+type historySyncJobModel struct {
+	ID             string       `db:"id"`
+	SessionID      string       `db:"session_id"`
+	ChatJID        string       `db:"chat_jid"`
+	AnchorID       string       `db:"anchor_id"`
+	Count          int          `db:"count"`
+	Status         string       `db:"status"`
+	Progress       int          `db:"progress"`
+	Conversations  int          `db:"conversations"`
+	MessagesStored int          `db:"messages_stored"`
+	Error          string       `db:"error"`
+	CreatedAt      time.Time    `db:"created_at"`
+	UpdatedAt      time.Time    `db:"updated_at"`
+	CompletedAt    sql.NullTime `db:"completed_at"`
+}
+
+
+const historySyncJobColumns = `id, session_id, chat_jid, anchor_id, count, status, progress, conversations,
+	messages_stored, error, created_at, updated_at, completed_at`
+
+
+func (m *historySyncJobModel) toEntity() *message.HistorySyncJob {
+	return &message.HistorySyncJob{
+		ID:             m.ID,
+		SessionID:      m.SessionID,
+		ChatJID:        m.ChatJID,
+		AnchorID:       m.AnchorID,
+		Count:          m.Count,
+		Status:         message.JobStatus(m.Status),
+		Progress:       m.Progress,
+		Conversations:  m.Conversations,
+		MessagesStored: m.MessagesStored,
+		Error:          m.Error,
+		CreatedAt:      m.CreatedAt,
+		UpdatedAt:      m.UpdatedAt,
+		CompletedAt:    m.CompletedAt.Time,
+	}
+}
+
+
+func (r *PostgresMessageRepository) SaveHistorySyncJob(ctx context.Context, job *message.HistorySyncJob) error {
+	model := historySyncJobModel{
+		ID:             job.ID,
+		SessionID:      job.SessionID,
+		ChatJID:        job.ChatJID,
+		AnchorID:       job.AnchorID,
+		Count:          job.Count,
+		Status:         string(job.Status),
+		Progress:       job.Progress,
+		Conversations:  job.Conversations,
+		MessagesStored: job.MessagesStored,
+		Error:          job.Error,
+		CreatedAt:      job.CreatedAt,
+		UpdatedAt:      job.UpdatedAt,
+		CompletedAt:    sql.NullTime{Time: job.CompletedAt, Valid: !job.CompletedAt.IsZero()},
+	}
+
+	query := `
+		INSERT INTO history_sync_jobs (` + historySyncJobColumns + `)
+		VALUES (:id, :session_id, :chat_jid, :anchor_id, :count, :status, :progress, :conversations,
+			:messages_stored, :error, :created_at, :updated_at, :completed_at)
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status,
+			progress = EXCLUDED.progress,
+			conversations = EXCLUDED.conversations,
+			messages_stored = EXCLUDED.messages_stored,
+			error = EXCLUDED.error,
+			updated_at = EXCLUDED.updated_at,
+			completed_at = EXCLUDED.completed_at
+	`
+
+	_, err := r.db.NamedExecContext(ctx, query, model)
+	return err
+}
+
+
+func (r *PostgresMessageRepository) GetHistorySyncJob(ctx context.Context, sessionID, id string) (*message.HistorySyncJob, error) {
+	query := `SELECT ` + historySyncJobColumns + ` FROM history_sync_jobs WHERE session_id = $1 AND id = $2`
+	return r.getHistorySyncJob(ctx, query, sessionID, id)
+}
+
+
+// FindActiveHistorySyncJob returns the oldest unfinished job of a chat; responses are matched to requests in order.
+func (r *PostgresMessageRepository) FindActiveHistorySyncJob(ctx context.Context, sessionID, chatJID string) (*message.HistorySyncJob, error) {
+	query := `SELECT ` + historySyncJobColumns + ` FROM history_sync_jobs
+		WHERE session_id = $1 AND chat_jid = $2 AND status IN ('pending', 'running')
+		ORDER BY created_at ASC LIMIT 1`
+	return r.getHistorySyncJob(ctx, query, sessionID, chatJID)
+}
+
+
+func (r *PostgresMessageRepository) getHistorySyncJob(ctx context.Context, query string, args ...interface{}) (*message.HistorySyncJob, error) {
+	var model historySyncJobModel
+	if err := r.db.GetContext(ctx, &model, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, message.ErrJobNotFound
+		}
+		return nil, err
+	}
+	return model.toEntity(), nil
+}
+
+
+// ListChronological pages oldest first through a chat, or through every chat when chatJID is empty.
+func (r *PostgresMessageRepository) ListChronological(ctx context.Context, sessionID, chatJID string, after *message.Cursor, limit int) ([]*message.Message, error) {
+	args := []interface{}{sessionID}
+	where := []string{"session_id = $1"}
+
+	if chatJID != "" {
+		args = append(args, chatJID)
+		where = append(where, fmt.Sprintf("chat_jid = $%d", len(args)))
+	}
+	if after != nil {
+		args = append(args, after.Timestamp, after.ID)
+		where = append(where, fmt.Sprintf("(timestamp, id) > ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	args = append(args, limit)
+	query := fmt.Sprintf(`SELECT %s FROM messages WHERE %s ORDER BY timestamp ASC, id ASC LIMIT $%d`,
+		messageColumns, strings.Join(where, " AND "), len(args))
+
+	var models []messageModel
+	if err := r.db.SelectContext(ctx, &models, query, args...); err != nil {
+		return nil, err
+	}
+
+	messages := make([]*message.Message, len(models))
+	for i := range models {
+		messages[i] = models[i].toEntity()
+	}
+
+	return messages, nil
+}
+
+
+type exportJobModel struct {
+	ID               string       `db:"id"`
+	SessionID        string       `db:"session_id"`
+	ChatJID          string       `db:"chat_jid"`
+	Format           string       `db:"format"`
+	MediaMode        string       `db:"media_mode"`
+	Status           string       `db:"status"`
+	MessagesExported int          `db:"messages_exported"`
+	FilePath         string       `db:"file_path"`
+	FileSize         int64        `db:"file_size"`
+	Error            string       `db:"error"`
+	CreatedAt        time.Time    `db:"created_at"`
+	UpdatedAt        time.Time    `db:"updated_at"`
+	CompletedAt      sql.NullTime `db:"completed_at"`
+}
+
+
+func (r *PostgresMessageRepository) SaveExportJob(ctx context.Context, job *message.ExportJob) error {
+	model := exportJobModel{
+		ID:               job.ID,
+		SessionID:        job.SessionID,
+		ChatJID:          job.ChatJID,
+		Format:           job.Format,
+		MediaMode:        job.MediaMode,
+		Status:           string(job.Status),
+		MessagesExported: job.MessagesExported,
+		FilePath:         job.FilePath,
+		FileSize:         job.FileSize,
+		Error:            job.Error,
+		CreatedAt:        job.CreatedAt,
+		UpdatedAt:        job.UpdatedAt,
+		CompletedAt:      sql.NullTime{Time: job.CompletedAt, Valid: !job.CompletedAt.IsZero()},
+	}
+
+	query := `
+		INSERT INTO export_jobs (id, session_id, chat_jid, format, media_mode, status, messages_exported,
+			file_path, file_size, error, created_at, updated_at, completed_at)
+		VALUES (:id, :session_id, :chat_jid, :format, :media_mode, :status, :messages_exported,
+			:file_path, :file_size, :error, :created_at, :updated_at, :completed_at)
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status,
+			messages_exported = EXCLUDED.messages_exported,
+			file_path = EXCLUDED.file_path,
+			file_size = EXCLUDED.file_size,
+			error = EXCLUDED.error,
+			updated_at = EXCLUDED.updated_at,
+			completed_at = EXCLUDED.completed_at
+	`
+
+	_, err := r.db.NamedExecContext(ctx, query, model)
+	return err
+}
+
+
+const exportJobColumns = `id, session_id, chat_jid, format, media_mode, status, messages_exported,
+	file_path, file_size, error, created_at, updated_at, completed_at`
+
+
+func (r *PostgresMessageRepository) GetExportJob(ctx context.Context, sessionID, id string) (*message.ExportJob, error) {
+	var model exportJobModel
+
+	query := `SELECT ` + exportJobColumns + ` FROM export_jobs WHERE session_id = $1 AND id = $2`
+
+	if err := r.db.GetContext(ctx, &model, query, sessionID, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, message.ErrJobNotFound
+		}
+		return nil, err
+	}
+
+	return toExportJobEntity(model), nil
+}
+
+
+func (r *PostgresMessageRepository) ListUnfinishedExportJobs(ctx context.Context) ([]*message.ExportJob, error) {
+	query := `SELECT ` + exportJobColumns + ` FROM export_jobs WHERE status IN ($1, $2) ORDER BY created_at`
+
+	return r.selectExportJobs(ctx, query, message.JobStatusPending, message.JobStatusRunning)
+}
+
+
+func (r *PostgresMessageRepository) ListExportJobsFinishedBefore(ctx context.Context, before time.Time, limit int) ([]*message.ExportJob, error) {
+	query := `
+		SELECT ` + exportJobColumns + ` FROM export_jobs
+		WHERE status IN ($1, $2) AND completed_at < $3
+		ORDER BY completed_at
+		LIMIT $4
+	`
+
+	return r.selectExportJobs(ctx, query, message.JobStatusCompleted, message.JobStatusFailed, before, limit)
+}
+
+
+func (r *PostgresMessageRepository) DeleteExportJob(ctx context.Context, sessionID, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM export_jobs WHERE session_id = $1 AND id = $2`, sessionID, id)
+	return err
+}
+
+
+func (r *PostgresMessageRepository) selectExportJobs(ctx context.Context, query string, args ...interface{}) ([]*message.ExportJob, error) {
+	var models []exportJobModel
+	if err := r.db.SelectContext(ctx, &models, query, args...); err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*message.ExportJob, 0, len(models))
+	for _, model := range models {
+		jobs = append(jobs, toExportJobEntity(model))
+	}
+	return jobs, nil
+}
+
+
+func toExportJobEntity(model exportJobModel) *message.ExportJob {
+	return &message.ExportJob{
+		ID:               model.ID,
+		SessionID:        model.SessionID,
+		ChatJID:          model.ChatJID,
+		Format:           model.Format,
+		MediaMode:        model.MediaMode,
+		Status:           message.JobStatus(model.Status),
+		MessagesExported: model.MessagesExported,
+		FilePath:         model.FilePath,
+		FileSize:         model.FileSize,
+		Error:            model.Error,
+		CreatedAt:        model.CreatedAt,
+		UpdatedAt:        model.UpdatedAt,
+		CompletedAt:      model.CompletedAt.Time,
+	}
+}
@@ -0,0 +1,26 @@
+-- Store received and sent messages for history queries
+CREATE TABLE IF NOT EXISTS messages (
+    session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    id TEXT NOT NULL,
+    chat_jid TEXT NOT NULL,
+    sender_jid TEXT NOT NULL DEFAULT '',
+    push_name TEXT NOT NULL DEFAULT '',
+    from_me BOOLEAN NOT NULL DEFAULT FALSE,
+    is_group BOOLEAN NOT NULL DEFAULT FALSE,
+    message_type TEXT NOT NULL,
+    text TEXT NOT NULL DEFAULT '',
+    caption TEXT NOT NULL DEFAULT '',
+    mime_type TEXT NOT NULL DEFAULT '',
+    file_name TEXT NOT NULL DEFAULT '',
+    file_length BIGINT NOT NULL DEFAULT 0,
+    has_media BOOLEAN NOT NULL DEFAULT FALSE,
+    quoted_id TEXT NOT NULL DEFAULT '',
+    quoted_participant TEXT NOT NULL DEFAULT '',
+    raw BYTEA,
+    timestamp TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (session_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_chat_timeline ON messages (session_id, chat_jid, timestamp DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_messages_sender ON messages (session_id, sender_jid);
@@ -0,0 +1,4 @@
+-- Remove message full-text search
+DROP INDEX IF EXISTS idx_messages_search;
+ALTER TABLE messages DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE sessions DROP COLUMN IF EXISTS search_language;
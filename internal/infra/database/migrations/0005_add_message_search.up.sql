@@ -0,0 +1,13 @@
+-- Per-session text search configuration, Portuguese by default
+ALTER TABLE sessions ADD COLUMN search_language TEXT NOT NULL DEFAULT 'portuguese';
+
+-- Full-text search over message bodies, captions and document file names
+ALTER TABLE messages ADD COLUMN search_vector TSVECTOR;
+
+UPDATE messages m SET search_vector =
+    setweight(to_tsvector(s.search_language::regconfig, m.text || ' ' || m.caption), 'A') ||
+    setweight(to_tsvector(s.search_language::regconfig, regexp_replace(m.file_name, '[._-]+', ' ', 'g')), 'B')
+FROM sessions s
+WHERE s.id = m.session_id;
+
+CREATE INDEX IF NOT EXISTS idx_messages_search ON messages USING GIN (search_vector);
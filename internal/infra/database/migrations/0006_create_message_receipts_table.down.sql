@@ -0,0 +1,2 @@
+-- Drop message delivery status timeline
+DROP TABLE IF EXISTS message_receipts;
@@ -0,0 +1,12 @@
+-- Delivery status timeline of outgoing messages, one row per participant and status
+CREATE TABLE IF NOT EXISTS message_receipts (
+    session_id TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    chat_jid TEXT NOT NULL,
+    participant_jid TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (session_id, message_id, participant_jid, status),
+    FOREIGN KEY (session_id, message_id) REFERENCES messages(session_id, id) ON DELETE CASCADE
+);
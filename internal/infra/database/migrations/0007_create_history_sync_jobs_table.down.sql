@@ -0,0 +1,2 @@
+-- Drop history sync jobs
+DROP TABLE IF EXISTS history_sync_jobs;
@@ -0,0 +1,19 @@
+-- Track on-demand history sync requests
+CREATE TABLE IF NOT EXISTS history_sync_jobs (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    chat_jid TEXT NOT NULL,
+    anchor_id TEXT NOT NULL DEFAULT '',
+    count INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    progress INTEGER NOT NULL DEFAULT 0,
+    conversations INTEGER NOT NULL DEFAULT 0,
+    messages_stored INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_history_sync_jobs_active ON history_sync_jobs (session_id, chat_jid, created_at)
+    WHERE status IN ('pending', 'running');
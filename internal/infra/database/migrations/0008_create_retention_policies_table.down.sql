@@ -0,0 +1,3 @@
+-- Drop retention policies
+DROP INDEX IF EXISTS idx_message_receipts_timestamp;
+DROP TABLE IF EXISTS retention_policies;
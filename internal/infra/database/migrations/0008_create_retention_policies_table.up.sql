@@ -0,0 +1,11 @@
+-- Per-session retention overrides; sessions without a row use the global policy
+CREATE TABLE IF NOT EXISTS retention_policies (
+    session_id TEXT PRIMARY KEY REFERENCES sessions(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    messages_days INTEGER NOT NULL DEFAULT 0,
+    media_days INTEGER NOT NULL DEFAULT 0,
+    receipts_days INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_receipts_timestamp ON message_receipts (session_id, timestamp);
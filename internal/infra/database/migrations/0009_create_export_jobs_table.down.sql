@@ -0,0 +1,3 @@
+-- Drop transcript exports
+DROP INDEX IF EXISTS idx_messages_session_timeline;
+DROP TABLE IF EXISTS export_jobs;
@@ -0,0 +1,18 @@
+-- Track asynchronous transcript exports
+CREATE TABLE IF NOT EXISTS export_jobs (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    chat_jid TEXT NOT NULL DEFAULT '',
+    format TEXT NOT NULL,
+    media_mode TEXT NOT NULL,
+    status TEXT NOT NULL,
+    messages_exported INTEGER NOT NULL DEFAULT 0,
+    file_path TEXT NOT NULL DEFAULT '',
+    file_size BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_session_timeline ON messages (session_id, timestamp, id);
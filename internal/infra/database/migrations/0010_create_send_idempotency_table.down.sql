@@ -0,0 +1,2 @@
+-- Drop send idempotency keys
+DROP TABLE IF EXISTS send_idempotency;
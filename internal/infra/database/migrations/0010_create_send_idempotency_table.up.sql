@@ -0,0 +1,13 @@
+-- Remember sends made under an idempotency key so retried requests replay the first response
+CREATE TABLE IF NOT EXISTS send_idempotency (
+    session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    idempotency_key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    status TEXT NOT NULL,
+    response BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (session_id, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_send_idempotency_expires_at ON send_idempotency (expires_at);
@@ -0,0 +1,2 @@
+-- Drop the scheduled message queue
+DROP TABLE IF EXISTS scheduled_messages;
@@ -0,0 +1,3 @@
+-- Drop broadcast jobs and their recipients
+DROP TABLE IF EXISTS broadcast_recipients;
+DROP TABLE IF EXISTS broadcast_jobs;
@@ -0,0 +1,33 @@
+-- Bulk sends of one message template to many recipients
+CREATE TABLE IF NOT EXISTS broadcast_jobs (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    media BYTEA,
+    variables JSONB NOT NULL DEFAULT '[]',
+    status TEXT NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_broadcast_jobs_session ON broadcast_jobs (session_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_broadcast_jobs_running ON broadcast_jobs (created_at) WHERE status = 'running';
+
+-- Per-recipient results of a broadcast, in list order
+CREATE TABLE IF NOT EXISTS broadcast_recipients (
+    job_id TEXT NOT NULL REFERENCES broadcast_jobs(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    phone TEXT NOT NULL,
+    variables JSONB NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL,
+    message_id TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMPTZ,
+    PRIMARY KEY (job_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_broadcast_recipients_open ON broadcast_recipients (job_id, position) WHERE status IN ('pending', 'sending');
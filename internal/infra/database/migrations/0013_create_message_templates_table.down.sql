@@ -0,0 +1,2 @@
+-- Drop message templates
+DROP TABLE IF EXISTS message_templates;
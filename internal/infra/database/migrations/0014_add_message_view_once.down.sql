@@ -0,0 +1,2 @@
+-- Remove the view-once flag from messages
+ALTER TABLE messages DROP COLUMN IF EXISTS view_once;
@@ -0,0 +1,2 @@
+-- Flag messages that can only be viewed once
+ALTER TABLE messages ADD COLUMN view_once BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+-- Remove the per-session media fetch policy
+ALTER TABLE sessions DROP COLUMN IF EXISTS media_fetch_policy;
@@ -0,0 +1,2 @@
+-- Per-session policy for fetching remote media and link previews
+ALTER TABLE sessions ADD COLUMN media_fetch_policy JSONB NOT NULL DEFAULT '{}'::jsonb;
@@ -0,0 +1,2 @@
+-- Remove the per-message search language
+ALTER TABLE messages DROP COLUMN IF EXISTS search_language;
@@ -0,0 +1,6 @@
+-- Text search configuration each message was indexed with, so a language change can reindex in batches
+ALTER TABLE messages ADD COLUMN search_language TEXT NOT NULL DEFAULT '';
+
+UPDATE messages m SET search_language = s.search_language
+FROM sessions s
+WHERE s.id = m.session_id;
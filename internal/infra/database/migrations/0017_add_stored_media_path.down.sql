@@ -0,0 +1,5 @@
+-- Remove the paths of stored media
+ALTER TABLE message_templates DROP COLUMN IF EXISTS media_size;
+ALTER TABLE message_templates DROP COLUMN IF EXISTS media_path;
+ALTER TABLE broadcast_jobs DROP COLUMN IF EXISTS media_path;
+ALTER TABLE scheduled_messages DROP COLUMN IF EXISTS media_path;
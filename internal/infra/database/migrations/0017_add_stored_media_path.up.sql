@@ -0,0 +1,9 @@
+-- Media of scheduled messages, broadcasts and templates is kept in the media directory; the
+-- media column only holds media stored before
+ALTER TABLE scheduled_messages ADD COLUMN media_path TEXT NOT NULL DEFAULT '';
+ALTER TABLE broadcast_jobs ADD COLUMN media_path TEXT NOT NULL DEFAULT '';
+ALTER TABLE message_templates ADD COLUMN media_path TEXT NOT NULL DEFAULT '';
+
+-- Size of the template media, which is no longer read from the media column
+ALTER TABLE message_templates ADD COLUMN media_size BIGINT NOT NULL DEFAULT 0;
+UPDATE message_templates SET media_size = octet_length(media) WHERE media IS NOT NULL;
@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -38,6 +39,8 @@ type sessionModel struct {
 	ProxyURL      string    `db:"proxy_url"`
 	WebhookURL    string    `db:"webhook_url"`
 	WebhookEvents string    `db:"webhook_events"`
+	SearchLanguage string   `db:"search_language"`
+	MediaFetch    string    `db:"media_fetch_policy"`
 	CreatedAt     time.Time `db:"created_at"`
 	UpdatedAt     time.Time `db:"updated_at"`
 }
@@ -50,6 +53,14 @@ func (m *sessionModel) toEntity() *session.Session {
 		events = strings.Split(m.WebhookEvents, ",")
 	}
 
+	// A corrupt policy must not fall back to the defaults, which would drop its blocked domains
+	var mediaFetch session.MediaFetchPolicy
+	if m.MediaFetch != "" {
+		if err := json.Unmarshal([]byte(m.MediaFetch), &mediaFetch); err != nil {
+			mediaFetch = session.MediaFetchPolicy{Unreadable: true}
+		}
+	}
+
 	return &session.Session{
 		ID:            m.ID,
 		Name:          m.Name,
@@ -59,6 +70,8 @@ func (m *sessionModel) toEntity() *session.Session {
 		ProxyURL:      m.ProxyURL,
 		WebhookURL:    m.WebhookURL,
 		Events:        events,
+		SearchLanguage: m.SearchLanguage,
+		MediaFetch:    mediaFetch,
 		CreatedAt:     m.CreatedAt,
 		UpdatedAt:     m.UpdatedAt,
 	}
@@ -72,6 +85,15 @@ func fromEntity(s *session.Session) *sessionModel {
 		eventsStr = strings.Join(s.Events, ",")
 	}
 
+	// An unreadable policy is left as stored, see storedMediaFetch
+	var mediaFetch []byte
+	if !s.MediaFetch.Unreadable {
+		var err error
+		if mediaFetch, err = json.Marshal(s.MediaFetch); err != nil {
+			mediaFetch = []byte("{}")
+		}
+	}
+
 	return &sessionModel{
 		ID:            s.ID,
 		Name:          s.Name,
@@ -81,23 +103,32 @@ func fromEntity(s *session.Session) *sessionModel {
 		ProxyURL:      s.ProxyURL,
 		WebhookURL:    s.WebhookURL,
 		WebhookEvents: eventsStr,
+		SearchLanguage: s.GetSearchLanguage(),
+		MediaFetch:    string(mediaFetch),
 		CreatedAt:     s.CreatedAt,
 		UpdatedAt:     s.UpdatedAt,
 	}
 }
 
 
+// storedMediaFetch is the media fetch policy to write, or NULL to keep the stored one when it could
+// not be read.
+func storedMediaFetch(model *sessionModel) sql.NullString {
+	return sql.NullString{String: model.MediaFetch, Valid: model.MediaFetch != ""}
+}
+
+
 func (r *PostgresSessionRepository) Create(ctx context.Context, sess *session.Session) error {
 	model := fromEntity(sess)
 
 	query := `
-		INSERT INTO sessions (id, name, device_jid, status, qr_code, proxy_url, webhook_url, webhook_events, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO sessions (id, name, device_jid, status, qr_code, proxy_url, webhook_url, webhook_events, search_language, media_fetch_policy, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE($10::jsonb, '{}'), $11, $12)
 	`
 
 	_, err := r.db.ExecContext(ctx, query,
 		model.ID, model.Name, model.WhatsAppJID, model.Status,
-		model.QRCode, model.ProxyURL, model.WebhookURL, model.WebhookEvents, model.CreatedAt, model.UpdatedAt)
+		model.QRCode, model.ProxyURL, model.WebhookURL, model.WebhookEvents, model.SearchLanguage, storedMediaFetch(model), model.CreatedAt, model.UpdatedAt)
 
 	return err
 }
@@ -107,8 +138,8 @@ func (r *PostgresSessionRepository) Save(ctx context.Context, sess *session.Sess
 	model := fromEntity(sess)
 	
 	query := `
-		INSERT INTO sessions (id, name, device_jid, status, qr_code, proxy_url, webhook_url, webhook_events, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO sessions (id, name, device_jid, status, qr_code, proxy_url, webhook_url, webhook_events, search_language, media_fetch_policy, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE($10::jsonb, '{}'), $11, $12)
 		ON CONFLICT (id) DO UPDATE SET
 			name = EXCLUDED.name,
 			device_jid = EXCLUDED.device_jid,
@@ -117,12 +148,14 @@ func (r *PostgresSessionRepository) Save(ctx context.Context, sess *session.Sess
 			proxy_url = EXCLUDED.proxy_url,
 			webhook_url = EXCLUDED.webhook_url,
 			webhook_events = EXCLUDED.webhook_events,
+			search_language = EXCLUDED.search_language,
+			media_fetch_policy = COALESCE($10::jsonb, sessions.media_fetch_policy),
 			updated_at = EXCLUDED.updated_at
 	`
 
 	_, err := r.db.ExecContext(ctx, query,
 		model.ID, model.Name, model.WhatsAppJID, model.Status,
-		model.QRCode, model.ProxyURL, model.WebhookURL, model.WebhookEvents, model.CreatedAt, model.UpdatedAt)
+		model.QRCode, model.ProxyURL, model.WebhookURL, model.WebhookEvents, model.SearchLanguage, storedMediaFetch(model), model.CreatedAt, model.UpdatedAt)
 	
 	return err
 }
@@ -135,6 +168,8 @@ func (r *PostgresSessionRepository) GetByID(ctx context.Context, id string) (*se
 		SELECT id, name, COALESCE(device_jid, '') as device_jid, status,
 			   COALESCE(qr_code, '') as qr_code, COALESCE(proxy_url, '') as proxy_url,
 			   COALESCE(webhook_url, '') as webhook_url, COALESCE(webhook_events, '') as webhook_events,
+			   COALESCE(search_language, 'portuguese') as search_language,
+			   COALESCE(media_fetch_policy, '{}') as media_fetch_policy,
 			   created_at, updated_at
 		FROM sessions WHERE id = $1
 	`
@@ -158,6 +193,8 @@ func (r *PostgresSessionRepository) GetByName(ctx context.Context, name string)
 		SELECT id, name, COALESCE(device_jid, '') as device_jid, status,
 			   COALESCE(qr_code, '') as qr_code, COALESCE(proxy_url, '') as proxy_url,
 			   COALESCE(webhook_url, '') as webhook_url, COALESCE(webhook_events, '') as webhook_events,
+			   COALESCE(search_language, 'portuguese') as search_language,
+			   COALESCE(media_fetch_policy, '{}') as media_fetch_policy,
 			   created_at, updated_at
 		FROM sessions WHERE name = $1
 	`
@@ -181,6 +218,8 @@ func (r *PostgresSessionRepository) GetAll(ctx context.Context) ([]*session.Sess
 		SELECT id, name, COALESCE(device_jid, '') as device_jid, status,
 			   COALESCE(qr_code, '') as qr_code, COALESCE(proxy_url, '') as proxy_url,
 			   COALESCE(webhook_url, '') as webhook_url, COALESCE(webhook_events, '') as webhook_events,
+			   COALESCE(search_language, 'portuguese') as search_language,
+			   COALESCE(media_fetch_policy, '{}') as media_fetch_policy,
 			   created_at, updated_at
 		FROM sessions ORDER BY created_at DESC
 	`
@@ -205,13 +244,14 @@ func (r *PostgresSessionRepository) Update(ctx context.Context, sess *session.Se
 	query := `
 		UPDATE sessions SET
 			name = $2, device_jid = $3, status = $4,
-			qr_code = $5, proxy_url = $6, webhook_url = $7, webhook_events = $8, updated_at = $9
+			qr_code = $5, proxy_url = $6, webhook_url = $7, webhook_events = $8,
+			search_language = $9, media_fetch_policy = COALESCE($10::jsonb, media_fetch_policy), updated_at = $11
 		WHERE id = $1
 	`
 
 	result, err := r.db.ExecContext(ctx, query,
 		model.ID, model.Name, model.WhatsAppJID, model.Status,
-		model.QRCode, model.ProxyURL, model.WebhookURL, model.WebhookEvents, model.UpdatedAt)
+		model.QRCode, model.ProxyURL, model.WebhookURL, model.WebhookEvents, model.SearchLanguage, storedMediaFetch(model), model.UpdatedAt)
 	
 	if err != nil {
 		return err
@@ -271,6 +311,8 @@ func (r *PostgresSessionRepository) FindByName(ctx context.Context, name string)
 		SELECT id, name, COALESCE(device_jid, '') as device_jid, status,
 			   COALESCE(qr_code, '') as qr_code, COALESCE(proxy_url, '') as proxy_url,
 			   COALESCE(webhook_url, '') as webhook_url, COALESCE(webhook_events, '') as webhook_events,
+			   COALESCE(search_language, 'portuguese') as search_language,
+			   COALESCE(media_fetch_policy, '{}') as media_fetch_policy,
 			   created_at, updated_at
 		FROM sessions WHERE name ILIKE $1 ORDER BY created_at DESC
 	`
@@ -296,6 +338,8 @@ func (r *PostgresSessionRepository) GetByStatus(ctx context.Context, status type
 		SELECT id, name, COALESCE(device_jid, '') as device_jid, status,
 			   COALESCE(qr_code, '') as qr_code, COALESCE(proxy_url, '') as proxy_url,
 			   COALESCE(webhook_url, '') as webhook_url, COALESCE(webhook_events, '') as webhook_events,
+			   COALESCE(search_language, 'portuguese') as search_language,
+			   COALESCE(media_fetch_policy, '{}') as media_fetch_policy,
 			   created_at, updated_at
 		FROM sessions WHERE status = $1 ORDER BY created_at DESC
 	`
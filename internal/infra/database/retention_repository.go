@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"zpmeow/internal/domain/retention"
+
+	"github.com/jmoiron/sqlx"
+)
+
+
+type PostgresRetentionRepository struct {
+	db *sqlx.DB
+}
+
+
+func NewPostgresRetentionRepository(db *sqlx.DB) retention.RetentionRepository {
+	return &PostgresRetentionRepository{db: db}
+}
+
+
+type retentionPolicyModel struct {
+	SessionID    string    `db:"session_id"`
+	Enabled      bool      `db:"enabled"`
+	MessagesDays int       `db:"messages_days"`
+	MediaDays    int       `db:"media_days"`
+	ReceiptsDays int       `db:"receipts_days"`
+	UpdatedAt    time.Time `db:"updated_at"`
+}
+
+
+func (r *PostgresRetentionRepository) GetSessionPolicy(ctx context.Context, sessionID string) (*retention.Policy, error) {
+	var model retentionPolicyModel
+
+	query := `
+		SELECT session_id, enabled, messages_days, media_days, receipts_days, updated_at
+		FROM retention_policies WHERE session_id = $1
+	`
+
+	if err := r.db.GetContext(ctx, &model, query, sessionID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, retention.ErrPolicyNotFound
+		}
+		return nil, err
+	}
+
+	return &retention.Policy{
+		SessionID:    model.SessionID,
+		Enabled:      model.Enabled,
+		MessagesDays: model.MessagesDays,
+		MediaDays:    model.MediaDays,
+		ReceiptsDays: model.ReceiptsDays,
+		UpdatedAt:    model.UpdatedAt,
+	}, nil
+}
+
+
+func (r *PostgresRetentionRepository) SaveSessionPolicy(ctx context.Context, policy *retention.Policy) error {
+	query := `
+		INSERT INTO retention_policies (session_id, enabled, messages_days, media_days, receipts_days, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (session_id) DO UPDATE SET
+			enabled = EXCLUDED.enabled,
+			messages_days = EXCLUDED.messages_days,
+			media_days = EXCLUDED.media_days,
+			receipts_days = EXCLUDED.receipts_days,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		policy.SessionID, policy.Enabled, policy.MessagesDays, policy.MediaDays, policy.ReceiptsDays, policy.UpdatedAt)
+	return err
+}
+
+
+func (r *PostgresRetentionRepository) DeleteSessionPolicy(ctx context.Context, sessionID string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM retention_policies WHERE session_id = $1`, sessionID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return retention.ErrPolicyNotFound
+	}
+
+	return nil
+}
+
+
+func (r *PostgresRetentionRepository) ListSessionIDs(ctx context.Context) ([]string, error) {
+	var ids []string
+	err := r.db.SelectContext(ctx, &ids, `SELECT id FROM sessions ORDER BY created_at`)
+	return ids, err
+}
+
+
+// CountExpired mirrors the purge queries; media is only counted on messages that outlive the message cutoff.
+func (r *PostgresRetentionRepository) CountExpired(ctx context.Context, sessionID string, cutoffs retention.Cutoffs) (*retention.Report, error) {
+	report := &retention.Report{SessionID: sessionID, Cutoffs: cutoffs}
+
+	if !cutoffs.Messages.IsZero() {
+		query := `SELECT COUNT(*) FROM messages WHERE session_id = $1 AND timestamp < $2`
+		if err := r.db.GetContext(ctx, &report.Messages, query, sessionID, cutoffs.Messages); err != nil {
+			return nil, err
+		}
+	}
+
+	if !cutoffs.Media.IsZero() {
+		query := `SELECT COUNT(*) FROM messages
+			WHERE session_id = $1 AND has_media AND raw IS NOT NULL AND timestamp < $2
+			AND ($3::timestamptz IS NULL OR timestamp >= $3)`
+		if err := r.db.GetContext(ctx, &report.Media, query, sessionID, cutoffs.Media, nullTime(cutoffs.Messages)); err != nil {
+			return nil, err
+		}
+	}
+
+	if !cutoffs.Receipts.IsZero() {
+		query := `SELECT COUNT(*) FROM message_receipts WHERE session_id = $1 AND timestamp < $2`
+		if err := r.db.GetContext(ctx, &report.Receipts, query, sessionID, cutoffs.Receipts); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+
+func (r *PostgresRetentionRepository) PurgeMessages(ctx context.Context, sessionID string, before time.Time, batchSize int) (int64, error) {
+	query := `
+		DELETE FROM messages WHERE session_id = $1 AND id IN (
+			SELECT id FROM messages WHERE session_id = $1 AND timestamp < $2 LIMIT $3
+		)
+	`
+	return r.execCount(ctx, query, sessionID, before, batchSize)
+}
+
+
+// PurgeMedia drops the raw payload, which holds the media keys, and keeps the message itself.
+func (r *PostgresRetentionRepository) PurgeMedia(ctx context.Context, sessionID string, before time.Time, batchSize int) (int64, error) {
+	query := `
+		UPDATE messages SET raw = NULL WHERE session_id = $1 AND id IN (
+			SELECT id FROM messages
+			WHERE session_id = $1 AND has_media AND raw IS NOT NULL AND timestamp < $2
+			LIMIT $3
+		)
+	`
+	return r.execCount(ctx, query, sessionID, before, batchSize)
+}
+
+
+func (r *PostgresRetentionRepository) PurgeReceipts(ctx context.Context, sessionID string, before time.Time, batchSize int) (int64, error) {
+	query := `
+		DELETE FROM message_receipts WHERE ctid IN (
+			SELECT ctid FROM message_receipts WHERE session_id = $1 AND timestamp < $2 LIMIT $3
+		)
+	`
+	return r.execCount(ctx, query, sessionID, before, batchSize)
+}
+
+
+func (r *PostgresRetentionRepository) execCount(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"zpmeow/internal/domain/schedule"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+
+type PostgresScheduleRepository struct {
+	db *sqlx.DB
+}
+
+
+func NewPostgresScheduleRepository(db *sqlx.DB) schedule.ScheduleRepository {
+	return &PostgresScheduleRepository{db: db}
+}
+
+
+const scheduledMessageColumns = `id, session_id, message_id, kind, payload, media, media_path, send_at, when_offline,
+	status, attempts, last_error, created_at, updated_at, sent_at`
+
+
+type scheduledMessageModel struct {
+	ID          string       `db:"id"`
+	SessionID   string       `db:"session_id"`
+	MessageID   string       `db:"message_id"`
+	Kind        string       `db:"kind"`
+	Payload     string       `db:"payload"`
+	Media       []byte       `db:"media"`
+	MediaPath   string       `db:"media_path"`
+	SendAt      time.Time    `db:"send_at"`
+	WhenOffline string       `db:"when_offline"`
+	Status      string       `db:"status"`
+	Attempts    int          `db:"attempts"`
+	LastError   string       `db:"last_error"`
+	CreatedAt   time.Time    `db:"created_at"`
+	UpdatedAt   time.Time    `db:"updated_at"`
+	SentAt      sql.NullTime `db:"sent_at"`
+}
+
+
+func newScheduledMessageModel(msg *schedule.ScheduledMessage) (*scheduledMessageModel, error) {
+	payload, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode scheduled payload: %w", err)
+	}
+
+	return &scheduledMessageModel{
+		ID:          msg.ID,
+		SessionID:   msg.SessionID,
+		MessageID:   msg.MessageID,
+		Kind:        msg.Payload.Kind,
+		Payload:     string(payload),
+		Media:       msg.Payload.Media,
+		MediaPath:   msg.Payload.MediaPath,
+		SendAt:      msg.SendAt,
+		WhenOffline: msg.WhenOffline,
+		Status:      string(msg.Status),
+		Attempts:    msg.Attempts,
+		LastError:   msg.LastError,
+		CreatedAt:   msg.CreatedAt,
+		UpdatedAt:   msg.UpdatedAt,
+		SentAt:      nullTime(msg.SentAt),
+	}, nil
+}
+
+
+func (m *scheduledMessageModel) toEntity() (*schedule.ScheduledMessage, error) {
+	msg := &schedule.ScheduledMessage{
+		ID:          m.ID,
+		SessionID:   m.SessionID,
+		MessageID:   m.MessageID,
+		SendAt:      m.SendAt,
+		WhenOffline: m.WhenOffline,
+		Status:      schedule.Status(m.Status),
+		Attempts:    m.Attempts,
+		LastError:   m.LastError,
+		CreatedAt:   m.CreatedAt,
+		UpdatedAt:   m.UpdatedAt,
+		SentAt:      m.SentAt.Time,
+	}
+
+	if err := json.Unmarshal([]byte(m.Payload), &msg.Payload); err != nil {
+		return nil, fmt.Errorf("failed to decode scheduled payload of %s: %w", m.ID, err)
+	}
+	msg.Payload.Media, msg.Payload.MediaPath = m.Media, m.MediaPath
+
+	return msg, nil
+}
+
+
+func toScheduledMessages(models []scheduledMessageModel) ([]*schedule.ScheduledMessage, error) {
+	messages := make([]*schedule.ScheduledMessage, 0, len(models))
+	for i := range models {
+		msg, err := models[i].toEntity()
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+
+func (r *PostgresScheduleRepository) Create(ctx context.Context, msg *schedule.ScheduledMessage) error {
+	model, err := newScheduledMessageModel(msg)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO scheduled_messages (id, session_id, message_id, kind, payload, media, media_path, send_at, when_offline,
+			status, attempts, last_error, created_at, updated_at, sent_at)
+		VALUES (:id, :session_id, :message_id, :kind, :payload, :media, :media_path, :send_at, :when_offline,
+			:status, :attempts, :last_error, :created_at, :updated_at, :sent_at)
+	`
+
+	_, err = r.db.NamedExecContext(ctx, query, model)
+	return err
+}
+
+
+func (r *PostgresScheduleRepository) GetByID(ctx context.Context, sessionID, id string) (*schedule.ScheduledMessage, error) {
+	var model scheduledMessageModel
+
+	query := fmt.Sprintf(`SELECT %s FROM scheduled_messages WHERE session_id = $1 AND id = $2`, scheduledMessageColumns)
+	if err := r.db.GetContext(ctx, &model, query, sessionID, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, schedule.ErrScheduledNotFound
+		}
+		return nil, err
+	}
+
+	return model.toEntity()
+}
+
+
+func (r *PostgresScheduleRepository) List(ctx context.Context, filter schedule.ListFilter) ([]*schedule.ScheduledMessage, error) {
+	args := []interface{}{filter.SessionID}
+	where := "session_id = $1"
+	if filter.Status != "" {
+		args = append(args, string(filter.Status))
+		where += " AND status = $2"
+	}
+	args = append(args, filter.Limit, filter.Offset)
+
+	query := fmt.Sprintf(`SELECT %s FROM scheduled_messages WHERE %s ORDER BY send_at ASC, id ASC LIMIT $%d OFFSET $%d`,
+		scheduledMessageColumns, where, len(args)-1, len(args))
+
+	var models []scheduledMessageModel
+	if err := r.db.SelectContext(ctx, &models, query, args...); err != nil {
+		return nil, err
+	}
+
+	return toScheduledMessages(models)
+}
+
+
+func (r *PostgresScheduleRepository) Update(ctx context.Context, msg *schedule.ScheduledMessage, from schedule.Status) error {
+	model, err := newScheduledMessageModel(msg)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE scheduled_messages SET
+			message_id = $3, payload = $4, send_at = $5, when_offline = $6, status = $7,
+			attempts = $8, last_error = $9, updated_at = $10, sent_at = $11
+		WHERE session_id = $1 AND id = $2 AND status = $12
+	`
+
+	result, err := r.db.ExecContext(ctx, query,
+		model.SessionID, model.ID, model.MessageID, model.Payload, model.SendAt, model.WhenOffline, model.Status,
+		model.Attempts, model.LastError, model.UpdatedAt, model.SentAt, string(from))
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return schedule.ErrNotEditable
+	}
+	return nil
+}
+
+
+func (r *PostgresScheduleRepository) ClaimDue(ctx context.Context, now time.Time, limit int, connected []string) ([]*schedule.ScheduledMessage, error) {
+	query := fmt.Sprintf(`
+		UPDATE scheduled_messages SET status = $1, attempts = attempts + 1, updated_at = $2
+		WHERE id IN (
+			SELECT id FROM scheduled_messages
+			WHERE status = $3 AND send_at <= $2
+				AND (when_offline <> $5 OR session_id = ANY($6))
+			ORDER BY send_at ASC
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING %s
+	`, scheduledMessageColumns)
+
+	var models []scheduledMessageModel
+	err := r.db.SelectContext(ctx, &models, query,
+		string(schedule.StatusSending), now, string(schedule.StatusScheduled), limit,
+		schedule.OfflineHold, pq.Array(connected))
+	if err != nil {
+		return nil, err
+	}
+
+	return toScheduledMessages(models)
+}
+
+
+func (r *PostgresScheduleRepository) ReleaseStale(ctx context.Context, before time.Time) (int64, error) {
+	query := `UPDATE scheduled_messages SET status = $1, updated_at = NOW() WHERE status = $2 AND updated_at < $3`
+
+	result, err := r.db.ExecContext(ctx, query, string(schedule.StatusScheduled), string(schedule.StatusSending), before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+
+// PostgresStoredMediaRepository finds the stored media files still needed by a message.
+type PostgresStoredMediaRepository struct {
+	db *sqlx.DB
+}
+
+
+func NewPostgresStoredMediaRepository(db *sqlx.DB) *PostgresStoredMediaRepository {
+	return &PostgresStoredMediaRepository{db: db}
+}
+
+
+// ReferencedMedia lists the media paths of scheduled messages not sent yet, of broadcasts not
+// finished and of templates.
+func (r *PostgresStoredMediaRepository) ReferencedMedia(ctx context.Context) ([]string, error) {
+	query := `
+		SELECT media_path FROM scheduled_messages WHERE media_path <> '' AND status IN ('scheduled', 'sending')
+		UNION
+		SELECT media_path FROM broadcast_jobs WHERE media_path <> '' AND status IN ('running', 'paused')
+		UNION
+		SELECT media_path FROM message_templates WHERE media_path <> ''
+	`
+
+	var paths []string
+	if err := r.db.SelectContext(ctx, &paths, query); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
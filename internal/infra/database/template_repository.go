@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"zpmeow/internal/domain/template"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+
+type PostgresTemplateRepository struct {
+	db *sqlx.DB
+}
+
+
+func NewPostgresTemplateRepository(db *sqlx.DB) template.TemplateRepository {
+	return &PostgresTemplateRepository{db: db}
+}
+
+
+// templateSelect reads templates; media is the media column expression, which lists leave out.
+func templateSelect(media string) string {
+	return fmt.Sprintf(`SELECT id, session_id, name, payload, %s AS media, media_path, media_size,
+		created_at, updated_at FROM message_templates`, media)
+}
+
+
+type templateModel struct {
+	ID        string         `db:"id"`
+	SessionID sql.NullString `db:"session_id"`
+	Name      string         `db:"name"`
+	Payload   string         `db:"payload"`
+	Media     []byte         `db:"media"`
+	MediaPath string         `db:"media_path"`
+	MediaSize int            `db:"media_size"`
+	CreatedAt time.Time      `db:"created_at"`
+	UpdatedAt time.Time      `db:"updated_at"`
+}
+
+
+func (m *templateModel) toEntity() (*template.Template, error) {
+	t := &template.Template{
+		ID:        m.ID,
+		SessionID: m.SessionID.String,
+		Name:      m.Name,
+		MediaSize: m.MediaSize,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+
+	if err := json.Unmarshal([]byte(m.Payload), &t.Payload); err != nil {
+		return nil, fmt.Errorf("failed to decode template payload of %s: %w", m.ID, err)
+	}
+	t.Payload.Media, t.Payload.MediaPath = m.Media, m.MediaPath
+
+	return t, nil
+}
+
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+
+// isUniqueViolation reports whether err is a PostgreSQL unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+
+func (r *PostgresTemplateRepository) Create(ctx context.Context, t *template.Template) error {
+	payload, err := json.Marshal(t.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode template payload: %w", err)
+	}
+
+	query := `
+		INSERT INTO message_templates (id, session_id, name, kind, payload, media, media_path, media_size,
+			created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	_, err = r.db.ExecContext(ctx, query, t.ID, nullString(t.SessionID), t.Name, t.Payload.Kind, string(payload),
+		t.Payload.Media, t.Payload.MediaPath, t.MediaSize, t.CreatedAt, t.UpdatedAt)
+	if isUniqueViolation(err) {
+		return template.ErrTemplateNameTaken
+	}
+	return err
+}
+
+
+func (r *PostgresTemplateRepository) GetByID(ctx context.Context, id string) (*template.Template, error) {
+	query := templateSelect("media") + ` WHERE id = $1`
+	return r.get(ctx, query, id)
+}
+
+
+func (r *PostgresTemplateRepository) GetByName(ctx context.Context, sessionID, name string) (*template.Template, error) {
+	query := templateSelect("media") + `
+		WHERE name = $2 AND (session_id = $1 OR session_id IS NULL)
+		ORDER BY session_id NULLS LAST
+		LIMIT 1
+	`
+	return r.get(ctx, query, sessionID, name)
+}
+
+
+func (r *PostgresTemplateRepository) get(ctx context.Context, query string, args ...interface{}) (*template.Template, error) {
+	var model templateModel
+	if err := r.db.GetContext(ctx, &model, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, template.ErrTemplateNotFound
+		}
+		return nil, err
+	}
+	return model.toEntity()
+}
+
+
+func (r *PostgresTemplateRepository) List(ctx context.Context, sessionID string) ([]*template.Template, error) {
+	query := templateSelect("NULL::bytea") + `
+		WHERE session_id IS NULL OR session_id = $1
+		ORDER BY name, session_id NULLS LAST
+	`
+
+	var models []templateModel
+	if err := r.db.SelectContext(ctx, &models, query, nullString(sessionID)); err != nil {
+		return nil, err
+	}
+
+	templates := make([]*template.Template, 0, len(models))
+	for i := range models {
+		t, err := models[i].toEntity()
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+
+func (r *PostgresTemplateRepository) Update(ctx context.Context, t *template.Template) error {
+	payload, err := json.Marshal(t.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode template payload: %w", err)
+	}
+
+	query := `
+		UPDATE message_templates SET name = $2, kind = $3, payload = $4, media = $5, media_path = $6,
+			media_size = $7, updated_at = $8
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, t.ID, t.Name, t.Payload.Kind, string(payload), t.Payload.Media,
+		t.Payload.MediaPath, t.MediaSize, t.UpdatedAt)
+	if isUniqueViolation(err) {
+		return template.ErrTemplateNameTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return template.ErrTemplateNotFound
+	}
+	return nil
+}
+
+
+func (r *PostgresTemplateRepository) Delete(ctx context.Context, sessionID, id string) error {
+	query := `DELETE FROM message_templates WHERE id = $1 AND session_id IS NOT DISTINCT FROM $2`
+
+	result, err := r.db.ExecContext(ctx, query, id, nullString(sessionID))
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return template.ErrTemplateNotFound
+	}
+	return nil
+}
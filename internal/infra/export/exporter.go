@@ -0,0 +1,254 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"zpmeow/internal/domain/message"
+	"zpmeow/internal/infra/logger"
+)
+
+// maxEmbedSize is the largest media file inlined in an export; bigger files are linked instead
+const maxEmbedSize = 16 << 20
+
+// purgeBatchSize is how many expired exports are loaded at a time while purging
+const purgeBatchSize = 100
+
+var mimeTypePattern = regexp.MustCompile(`^[a-z]+/[a-z0-9.+-]+$`)
+
+// MediaDownloader fetches the media of a stored message
+type MediaDownloader interface {
+	DownloadMedia(ctx context.Context, sessionID, messageID string) ([]byte, string, error)
+}
+
+// Record is one exported message: the stored message plus how its media is included
+type Record struct {
+	message.MessageDTO
+	MediaURL  string `json:"mediaUrl,omitempty"`
+	MediaData string `json:"mediaData,omitempty"`
+}
+
+// Exporter writes chat transcripts to files in the background
+type Exporter struct {
+	messageService message.MessageService
+	media          MediaDownloader
+	dir            string
+	ttl            time.Duration
+	logger         logger.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewExporter creates an exporter writing files below dir. Finished exports are kept for ttl;
+// zero or less keeps them forever.
+func NewExporter(messageService message.MessageService, media MediaDownloader, dir string, ttl time.Duration) *Exporter {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Exporter{
+		messageService: messageService,
+		media:          media,
+		dir:            dir,
+		ttl:            ttl,
+		logger:         logger.GetLogger().Sub("export"),
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// Start stores the job and writes the export in the background
+func (e *Exporter) Start(ctx context.Context, job *message.ExportJob) error {
+	if err := e.messageService.CreateExportJob(ctx, job); err != nil {
+		return err
+	}
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		e.run(e.ctx, job)
+	}()
+
+	return nil
+}
+
+// Stop interrupts the running exports and waits for them to be marked as failed
+func (e *Exporter) Stop() {
+	e.cancel()
+	e.wg.Wait()
+}
+
+// Recover fails the jobs left pending or running by a previous process and removes their partial
+// files. It must run before new exports are started.
+func (e *Exporter) Recover(ctx context.Context) (int, error) {
+	jobs, err := e.messageService.ListUnfinishedExportJobs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, job := range jobs {
+		e.removeFile(e.path(job))
+		job.Fail("export interrupted by a server restart")
+		if err := e.messageService.UpdateExportJob(ctx, job); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(jobs), nil
+}
+
+// PurgeExpired deletes the files and jobs of exports that finished longer than the TTL ago
+func (e *Exporter) PurgeExpired(ctx context.Context) (int, error) {
+	if e.ttl <= 0 {
+		return 0, nil
+	}
+
+	before := time.Now().Add(-e.ttl)
+	purged := 0
+	for {
+		jobs, err := e.messageService.ListExpiredExportJobs(ctx, before, purgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+
+		for _, job := range jobs {
+			e.removeFile(e.path(job))
+			if err := e.messageService.DeleteExportJob(ctx, job.SessionID, job.ID); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+
+		if len(jobs) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+func (e *Exporter) run(ctx context.Context, job *message.ExportJob) {
+	// Job updates outlive a shutdown so an interrupted export is recorded as failed
+	updateCtx := context.WithoutCancel(ctx)
+
+	job.MarkRunning()
+	if err := e.messageService.UpdateExportJob(updateCtx, job); err != nil {
+		e.logger.Errorf("Failed to update export job %s: %v", job.ID, err)
+	}
+
+	path, size, count, err := e.write(ctx, job)
+	if err != nil {
+		e.logger.Errorf("Export %s of session %s failed: %v", job.ID, job.SessionID, err)
+		if path != "" {
+			e.removeFile(path)
+		}
+		if ctx.Err() != nil {
+			job.Fail("export interrupted by server shutdown")
+		} else {
+			job.Fail(err.Error())
+		}
+	} else {
+		e.logger.Infof("Export %s of session %s finished: %d messages, %d bytes", job.ID, job.SessionID, count, size)
+		job.Complete(path, size, count)
+	}
+
+	if err := e.messageService.UpdateExportJob(updateCtx, job); err != nil {
+		e.logger.Errorf("Failed to update export job %s: %v", job.ID, err)
+	}
+}
+
+// path is where the export file of a job is written
+func (e *Exporter) path(job *message.ExportJob) string {
+	return filepath.Join(e.dir, job.SessionID, job.ID+job.FileExtension())
+}
+
+func (e *Exporter) removeFile(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		e.logger.Warnf("Failed to remove export file %s: %v", path, err)
+	}
+}
+
+func (e *Exporter) write(ctx context.Context, job *message.ExportJob) (string, int64, int, error) {
+	dir := filepath.Join(e.dir, job.SessionID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", 0, 0, fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	path := e.path(job)
+	file, err := os.Create(path)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer file.Close()
+
+	buf := bufio.NewWriter(file)
+	w := newWriter(job.Format, buf)
+
+	if err := w.begin(job); err != nil {
+		return path, 0, 0, err
+	}
+
+	count, err := e.messageService.EachMessage(ctx, job.SessionID, job.ChatJID, func(m *message.Message) error {
+		return w.write(e.record(ctx, job, m))
+	})
+	if err != nil {
+		return path, 0, count, err
+	}
+
+	if err := w.end(count); err != nil {
+		return path, 0, count, err
+	}
+	if err := buf.Flush(); err != nil {
+		return path, 0, count, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return path, 0, count, err
+	}
+
+	return path, info.Size(), count, nil
+}
+
+// record builds the exported form of a message. Media that cannot be embedded falls back to a link.
+func (e *Exporter) record(ctx context.Context, job *message.ExportJob, m *message.Message) *Record {
+	rec := &Record{MessageDTO: message.NewMessageDTO(m)}
+	if !m.HasMedia() || job.MediaMode == message.MediaModeNone {
+		return rec
+	}
+
+	rec.MediaURL = MediaURL(m.SessionID, m.ID)
+	if job.MediaMode != message.MediaModeEmbed || m.FileLength > maxEmbedSize || len(m.Raw) == 0 {
+		return rec
+	}
+
+	data, mimeType, err := e.media.DownloadMedia(ctx, m.SessionID, m.ID)
+	if err != nil {
+		e.logger.Warnf("Export %s: linking media of message %s instead of embedding it: %v", job.ID, m.ID, err)
+		return rec
+	}
+
+	rec.MediaData = DataURI(mimeType, data)
+	return rec
+}
+
+// MediaURL is the API path serving the media of a stored message
+func MediaURL(sessionID, messageID string) string {
+	return "/session/" + sessionID + "/messages/" + messageID + "/media"
+}
+
+// DataURI encodes data as a base64 data URI. Mime parameters are dropped and malformed
+// types become application/octet-stream, so the result is safe to place in an HTML attribute.
+func DataURI(mimeType string, data []byte) string {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if !mimeTypePattern.MatchString(mimeType) {
+		mimeType = "application/octet-stream"
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
@@ -0,0 +1,124 @@
+package export
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"zpmeow/internal/config"
+	"zpmeow/internal/domain/message"
+	"zpmeow/internal/infra/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMessageService implements the export job calls of the message service; anything else panics
+type fakeMessageService struct {
+	message.MessageService
+
+	mu         sync.Mutex
+	unfinished []*message.ExportJob
+	expired    []*message.ExportJob
+	updated    []message.JobStatus
+	deleted    []string
+	started    chan struct{}
+}
+
+func (f *fakeMessageService) CreateExportJob(ctx context.Context, job *message.ExportJob) error {
+	return nil
+}
+
+func (f *fakeMessageService) UpdateExportJob(ctx context.Context, job *message.ExportJob) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updated = append(f.updated, job.Status)
+	return nil
+}
+
+func (f *fakeMessageService) ListUnfinishedExportJobs(ctx context.Context) ([]*message.ExportJob, error) {
+	return f.unfinished, nil
+}
+
+func (f *fakeMessageService) ListExpiredExportJobs(ctx context.Context, before time.Time, limit int) ([]*message.ExportJob, error) {
+	jobs := f.expired
+	f.expired = nil
+	return jobs, nil
+}
+
+func (f *fakeMessageService) DeleteExportJob(ctx context.Context, sessionID, id string) error {
+	f.deleted = append(f.deleted, id)
+	return nil
+}
+
+// EachMessage blocks until the export is cancelled
+func (f *fakeMessageService) EachMessage(ctx context.Context, sessionID, chatJID string, fn func(*message.Message) error) (int, error) {
+	close(f.started)
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func newTestExporter(t *testing.T, service message.MessageService, ttl time.Duration) *Exporter {
+	logger.SetLogger(logger.Initialize(&config.LoggerConfig{Level: "fatal", Format: "console"}))
+	return NewExporter(service, nil, t.TempDir(), ttl)
+}
+
+func writeExportFile(t *testing.T, e *Exporter, job *message.ExportJob) string {
+	path := e.path(job)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte("partial"), 0o644))
+	return path
+}
+
+func TestExporter_RecoverFailsUnfinishedJobs(t *testing.T) {
+	job := message.NewExportJob("session-1", "", message.ExportFormatJSONL, "")
+	job.MarkRunning()
+	service := &fakeMessageService{unfinished: []*message.ExportJob{job}}
+	e := newTestExporter(t, service, 0)
+	path := writeExportFile(t, e, job)
+
+	failed, err := e.Recover(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, message.JobStatusFailed, job.Status)
+	assert.Equal(t, []message.JobStatus{message.JobStatusFailed}, service.updated)
+	assert.NoFileExists(t, path)
+}
+
+func TestExporter_PurgeExpired(t *testing.T) {
+	completed := message.NewExportJob("session-1", "", message.ExportFormatCSV, "")
+	failed := message.NewExportJob("session-1", "", message.ExportFormatHTML, "")
+	service := &fakeMessageService{expired: []*message.ExportJob{completed, failed}}
+
+	e := newTestExporter(t, service, 0)
+	purged, err := e.PurgeExpired(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, purged, "a zero TTL keeps exports forever")
+
+	e = newTestExporter(t, service, time.Hour)
+	path := writeExportFile(t, e, completed)
+
+	purged, err = e.PurgeExpired(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, purged)
+	assert.Equal(t, []string{completed.ID, failed.ID}, service.deleted)
+	assert.NoFileExists(t, path)
+}
+
+func TestExporter_StopFailsRunningExport(t *testing.T) {
+	service := &fakeMessageService{started: make(chan struct{})}
+	e := newTestExporter(t, service, 0)
+
+	job := message.NewExportJob("session-1", "", message.ExportFormatJSONL, "")
+	require.NoError(t, e.Start(context.Background(), job))
+	<-service.started
+
+	e.Stop()
+	assert.Equal(t, message.JobStatusFailed, job.Status)
+	assert.Equal(t, "export interrupted by server shutdown", job.Error)
+	assert.Equal(t, []message.JobStatus{message.JobStatusRunning, message.JobStatusFailed}, service.updated)
+	assert.NoFileExists(t, e.path(job))
+}
@@ -0,0 +1,201 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"html/template"
+	"io"
+	"strconv"
+	"time"
+
+	"zpmeow/internal/domain/message"
+)
+
+// writer streams records in one export format
+type writer interface {
+	begin(job *message.ExportJob) error
+	write(rec *Record) error
+	end(count int) error
+}
+
+func newWriter(format string, out io.Writer) writer {
+	switch format {
+	case message.ExportFormatCSV:
+		return &csvWriter{w: csv.NewWriter(out)}
+	case message.ExportFormatHTML:
+		return &htmlWriter{out: out}
+	default:
+		return &jsonlWriter{enc: json.NewEncoder(out)}
+	}
+}
+
+// jsonlWriter writes one JSON object per line
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func (w *jsonlWriter) begin(*message.ExportJob) error { return nil }
+
+func (w *jsonlWriter) write(rec *Record) error { return w.enc.Encode(rec) }
+
+func (w *jsonlWriter) end(int) error { return nil }
+
+var csvHeader = []string{
+	"id", "timestamp", "chat", "sender", "push_name", "direction", "type",
+	"text", "caption", "mime_type", "file_name", "quoted_id", "media",
+}
+
+// csvWriter writes one row per message; the media column holds the link or the data URI
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (w *csvWriter) begin(*message.ExportJob) error {
+	return w.w.Write(csvHeader)
+}
+
+func (w *csvWriter) write(rec *Record) error {
+	var mimeType, fileName, quotedID string
+	if rec.Media != nil {
+		mimeType = rec.Media.MimeType
+		fileName = rec.Media.FileName
+	}
+	if rec.Quoted != nil {
+		quotedID = rec.Quoted.ID
+	}
+
+	media := rec.MediaURL
+	if rec.MediaData != "" {
+		media = rec.MediaData
+	}
+
+	return w.w.Write([]string{
+		rec.ID,
+		time.Unix(rec.Timestamp, 0).UTC().Format(time.RFC3339),
+		rec.Chat,
+		rec.Sender,
+		rec.PushName,
+		rec.Direction,
+		rec.Type,
+		rec.Text,
+		rec.Caption,
+		mimeType,
+		fileName,
+		quotedID,
+		media,
+	})
+}
+
+func (w *csvWriter) end(int) error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// htmlWriter writes a self-contained transcript page; all values go through html/template escaping
+type htmlWriter struct {
+	out io.Writer
+}
+
+type htmlHeader struct {
+	Title       string
+	SessionID   string
+	Chat        string
+	GeneratedAt string
+}
+
+type htmlMessage struct {
+	*Record
+	Time   string
+	Author string
+	Src    template.URL
+}
+
+func (w *htmlWriter) begin(job *message.ExportJob) error {
+	title := "Session " + job.SessionID
+	if job.ChatJID != "" {
+		title = "Chat " + job.ChatJID
+	}
+
+	return htmlTemplates.ExecuteTemplate(w.out, "header", htmlHeader{
+		Title:       title,
+		SessionID:   job.SessionID,
+		Chat:        job.ChatJID,
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+func (w *htmlWriter) write(rec *Record) error {
+	author := rec.PushName
+	if rec.FromMe {
+		author = "You"
+	} else if author == "" {
+		author = rec.Sender
+	}
+
+	msg := htmlMessage{
+		Record: rec,
+		Time:   time.Unix(rec.Timestamp, 0).UTC().Format("2006-01-02 15:04:05"),
+		Author: author,
+	}
+
+	// Both values are built by the exporter: a relative API path or a data URI with a sanitized mime type.
+	if rec.MediaData != "" {
+		msg.Src = template.URL(rec.MediaData)
+	} else if rec.MediaURL != "" {
+		msg.Src = template.URL(rec.MediaURL)
+	}
+
+	return htmlTemplates.ExecuteTemplate(w.out, "message", msg)
+}
+
+func (w *htmlWriter) end(count int) error {
+	return htmlTemplates.ExecuteTemplate(w.out, "footer", strconv.Itoa(count))
+}
+
+var htmlTemplates = template.Must(template.New("transcript").Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body{font-family:-apple-system,Segoe UI,Roboto,sans-serif;background:#efeae2;margin:0;padding:24px}
+header{max-width:860px;margin:0 auto 16px;color:#54656f;font-size:13px}
+h1{font-size:18px;color:#111b21;margin:0 0 4px}
+.message{max-width:640px;margin:6px 0;padding:8px 12px;border-radius:8px;background:#fff;box-shadow:0 1px 1px rgba(0,0,0,.1)}
+.outgoing{margin-left:auto;background:#d9fdd3}
+main{max-width:860px;margin:0 auto;display:flex;flex-direction:column}
+.meta{font-size:12px;color:#667781;margin-bottom:4px}
+.text{white-space:pre-wrap;word-wrap:break-word}
+.quote{font-size:12px;color:#667781;border-left:3px solid #06cf9c;padding-left:6px;margin-bottom:4px}
+img,video{max-width:100%;border-radius:6px}
+footer{max-width:860px;margin:16px auto 0;color:#54656f;font-size:13px}
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<div>Session {{.SessionID}}{{if .Chat}} &middot; {{.Chat}}{{end}} &middot; exported {{.GeneratedAt}} UTC</div>
+</header>
+<main>
+{{end}}
+
+{{define "message"}}<div class="message {{.Direction}}" id="{{.ID}}">
+<div class="meta">{{.Author}}{{if .IsGroup}} &middot; {{.Chat}}{{end}} &middot; {{.Time}}</div>
+{{if .Quoted}}<div class="quote">In reply to <a href="#{{.Quoted.ID}}">{{.Quoted.ID}}</a></div>{{end}}
+{{if .Src}}{{if or (eq .Type "image") (eq .Type "sticker")}}<img src="{{.Src}}" alt="{{.Type}}">
+{{else if eq .Type "video"}}<video src="{{.Src}}" controls></video>
+{{else if eq .Type "audio"}}<audio src="{{.Src}}" controls></audio>
+{{else}}<a href="{{.Src}}" download="{{if .Media}}{{.Media.FileName}}{{end}}">{{if .Media}}{{if .Media.FileName}}{{.Media.FileName}}{{else}}Download {{.Type}}{{end}}{{else}}Download {{.Type}}{{end}}</a>
+{{end}}{{else if .HasMedia}}<div class="meta">[{{.Type}}]</div>
+{{end}}{{if .Text}}<div class="text">{{.Text}}</div>
+{{end}}{{if .Caption}}<div class="text">{{.Caption}}</div>
+{{end}}</div>
+{{end}}
+
+{{define "footer"}}</main>
+<footer>{{.}} messages</footer>
+</body>
+</html>
+{{end}}
+`))
@@ -0,0 +1,88 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"zpmeow/internal/domain/message"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportRecords() []*Record {
+	text := &message.Message{
+		ID:        "MSG1",
+		SessionID: "session-1",
+		ChatJID:   "5511999999999@s.whatsapp.net",
+		SenderJID: "5511999999999@s.whatsapp.net",
+		PushName:  "Alice",
+		Type:      message.TypeText,
+		Text:      `<script>alert("x")</script>`,
+		Timestamp: time.Unix(1700000000, 0),
+	}
+	image := &message.Message{
+		ID:        "MSG2",
+		SessionID: "session-1",
+		ChatJID:   "5511999999999@s.whatsapp.net",
+		FromMe:    true,
+		Type:      message.TypeImage,
+		Caption:   "photo",
+		MimeType:  "image/jpeg",
+		Timestamp: time.Unix(1700000060, 0),
+	}
+
+	return []*Record{
+		{MessageDTO: message.NewMessageDTO(text)},
+		{MessageDTO: message.NewMessageDTO(image), MediaData: DataURI("image/jpeg", []byte{0xff, 0xd8})},
+	}
+}
+
+func writeAll(t *testing.T, format string) string {
+	var out bytes.Buffer
+	job := message.NewExportJob("session-1", "5511999999999@s.whatsapp.net", format, message.MediaModeEmbed)
+	w := newWriter(job.Format, &out)
+
+	require.NoError(t, w.begin(job))
+	for _, rec := range exportRecords() {
+		require.NoError(t, w.write(rec))
+	}
+	require.NoError(t, w.end(2))
+
+	return out.String()
+}
+
+func TestHTMLWriter_EscapesTextAndEmbedsMedia(t *testing.T) {
+	out := writeAll(t, message.ExportFormatHTML)
+
+	assert.NotContains(t, out, "<script>alert")
+	assert.Contains(t, out, "&lt;script&gt;")
+	assert.Contains(t, out, `<img src="data:image/jpeg;base64,/9g="`)
+	assert.Contains(t, out, "2 messages")
+}
+
+func TestCSVWriter_Rows(t *testing.T) {
+	rows, err := csv.NewReader(strings.NewReader(writeAll(t, message.ExportFormatCSV))).ReadAll()
+	require.NoError(t, err)
+
+	require.Len(t, rows, 3)
+	assert.Equal(t, csvHeader, rows[0])
+	assert.Equal(t, "2023-11-14T22:13:20Z", rows[1][1])
+	assert.Equal(t, "outgoing", rows[2][5])
+	assert.Equal(t, "data:image/jpeg;base64,/9g=", rows[2][12])
+}
+
+func TestJSONLWriter_OneLinePerMessage(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(writeAll(t, message.ExportFormatJSONL)), "\n")
+
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[1], `"mediaData":"data:image/jpeg;base64,/9g="`)
+}
+
+func TestDataURI_SanitizesMimeType(t *testing.T) {
+	assert.Equal(t, "data:audio/ogg;base64,AA==", DataURI("audio/ogg; codecs=opus", []byte{0}))
+	assert.Equal(t, "data:application/octet-stream;base64,AA==", DataURI(`text/html"><x`, []byte{0}))
+}
@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"zpmeow/internal/domain/broadcast"
+	"zpmeow/internal/domain/session"
+	"zpmeow/internal/infra/broadcaster"
+	"zpmeow/internal/infra/logger"
+	"zpmeow/internal/infra/meow"
+	"zpmeow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BroadcastHandler starts bulk sends and controls their progress
+type BroadcastHandler struct {
+	sessionService   session.SessionService
+	broadcastService broadcast.BroadcastService
+	broadcaster      *broadcaster.Broadcaster
+	logger           logger.Logger
+}
+
+// NewBroadcastHandler creates a new broadcast handler
+func NewBroadcastHandler(sessionService session.SessionService, broadcastService broadcast.BroadcastService, b *broadcaster.Broadcaster) *BroadcastHandler {
+	return &BroadcastHandler{
+		sessionService:   sessionService,
+		broadcastService: broadcastService,
+		broadcaster:      b,
+		logger:           logger.GetLogger().Sub("broadcast-handler"),
+	}
+}
+
+// resolveSession resolves the session from the path parameter, accepting either ID or name
+func (h *BroadcastHandler) resolveSession(c *gin.Context) (*session.Session, bool) {
+	sessionID := c.Param("sessionId")
+	if sessionID == "" {
+		utils.RespondWithError(c, http.StatusBadRequest, "Session ID is required")
+		return nil, false
+	}
+
+	sess, err := h.sessionService.GetSession(c.Request.Context(), sessionID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Session not found", err.Error())
+		return nil, false
+	}
+
+	// Media URLs in the broadcast are fetched under the session's policy
+	c.Request = c.Request.WithContext(utils.WithFetchPolicy(c.Request.Context(), meow.SessionFetchPolicy(sess)))
+	return sess, true
+}
+
+func (h *BroadcastHandler) handleDomainError(c *gin.Context, err error, defaultMessage string) {
+	statusCode, msg := MapDomainError(err)
+	if statusCode == http.StatusInternalServerError {
+		h.logger.Errorf("%s: %v", defaultMessage, err)
+		utils.RespondWithError(c, statusCode, defaultMessage, err.Error())
+		return
+	}
+	utils.RespondWithError(c, statusCode, msg)
+}
+
+// pagination reads the limit and offset query parameters
+func pagination(c *gin.Context) (int, int, bool) {
+	var limit, offset int
+	var err error
+
+	if value := c.Query("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid limit")
+			return 0, 0, false
+		}
+	}
+	if value := c.Query("offset"); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid offset")
+			return 0, 0, false
+		}
+	}
+	return limit, offset, true
+}
+
+// @Summary Start broadcast
+// @Description Sends one message to many recipients in the background, paced by the session send rate. Recipients come from the recipients list and/or a CSV with a phone column; the other CSV columns fill {{placeholders}} in the message texts, and {{phone}} is always available. Any message type supported by the send endpoints can be broadcast.
+// @Tags broadcast
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param request body broadcast.CreateRequest true "Broadcast request"
+// @Success 202 {object} broadcast.JobDTO
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/broadcast [post]
+func (h *BroadcastHandler) CreateBroadcast(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	var req broadcast.CreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	recipients, variables, err := broadcast.ParseRecipients(req.Recipients, req.VariablesCSV)
+	if err != nil {
+		h.handleDomainError(c, err, "Invalid recipients")
+		return
+	}
+	for _, r := range recipients {
+		if !utils.IsValidPhoneNumber(r.Phone) {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid phone number format", r.Phone)
+			return
+		}
+	}
+
+	payload, err := buildPayload(c.Request.Context(), req.Type, &req.Message)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid message", err.Error())
+		return
+	}
+
+	job := broadcast.NewJob(sess.ID, *payload, variables)
+	if err := h.broadcaster.Create(c.Request.Context(), job, recipients); err != nil {
+		h.handleDomainError(c, err, "Failed to start broadcast")
+		return
+	}
+
+	h.logger.Infof("Started %s broadcast %s of session %s to %d recipients", payload.Kind, job.ID, sess.ID, len(recipients))
+
+	utils.RespondWithJSON(c, http.StatusAccepted, broadcast.NewJobDTO(job))
+}
+
+// @Summary List broadcasts
+// @Description Lists the broadcast jobs of a session, newest first
+// @Tags broadcast
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param limit query int false "Page size (default 100, max 1000)"
+// @Param offset query int false "Number of jobs to skip"
+// @Success 200 {object} broadcast.JobListResponse
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/broadcast [get]
+func (h *BroadcastHandler) ListBroadcasts(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	limit, offset, ok := pagination(c)
+	if !ok {
+		return
+	}
+
+	filter := broadcast.JobFilter{SessionID: sess.ID, Limit: limit, Offset: offset}
+	jobs, err := h.broadcastService.List(c.Request.Context(), &filter)
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to list broadcasts")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, broadcast.NewJobListResponse(jobs, filter))
+}
+
+// @Summary Get broadcast
+// @Description Returns a broadcast job and its progress
+// @Tags broadcast
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param id path string true "Broadcast job ID"
+// @Success 200 {object} broadcast.JobDTO
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/broadcast/{id} [get]
+func (h *BroadcastHandler) GetBroadcast(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.broadcastService.Get(c.Request.Context(), sess.ID, c.Param("id"))
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to get broadcast")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, broadcast.NewJobDTO(job))
+}
+
+// @Summary List broadcast recipients
+// @Description Returns the per-recipient results of a broadcast in list order
+// @Tags broadcast
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param id path string true "Broadcast job ID"
+// @Param status query string false "Filter by status (pending, sending, sent, failed, cancelled)"
+// @Param limit query int false "Page size (default 100, max 1000)"
+// @Param offset query int false "Number of recipients to skip"
+// @Success 200 {object} broadcast.RecipientListResponse
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/broadcast/{id}/recipients [get]
+func (h *BroadcastHandler) ListRecipients(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	limit, offset, ok := pagination(c)
+	if !ok {
+		return
+	}
+
+	filter := broadcast.RecipientFilter{
+		JobID:  c.Param("id"),
+		Status: broadcast.RecipientStatus(c.Query("status")),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	recipients, err := h.broadcastService.Recipients(c.Request.Context(), sess.ID, &filter)
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to list broadcast recipients")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, broadcast.NewRecipientListResponse(recipients, filter))
+}
+
+// @Summary Pause broadcast
+// @Description Stops sending after the message in flight; resume continues with the remaining recipients
+// @Tags broadcast
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param id path string true "Broadcast job ID"
+// @Success 200 {object} broadcast.JobDTO
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/broadcast/{id}/pause [post]
+func (h *BroadcastHandler) PauseBroadcast(c *gin.Context) {
+	h.control(c, "pause", h.broadcaster.Pause)
+}
+
+// @Summary Resume broadcast
+// @Description Continues a paused broadcast with the recipients that were not sent yet
+// @Tags broadcast
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param id path string true "Broadcast job ID"
+// @Success 200 {object} broadcast.JobDTO
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/broadcast/{id}/resume [post]
+func (h *BroadcastHandler) ResumeBroadcast(c *gin.Context) {
+	h.control(c, "resume", h.broadcaster.Resume)
+}
+
+// @Summary Cancel broadcast
+// @Description Stops a running or paused broadcast for good; recipients not sent yet are marked cancelled
+// @Tags broadcast
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param id path string true "Broadcast job ID"
+// @Success 200 {object} broadcast.JobDTO
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/broadcast/{id}/cancel [post]
+func (h *BroadcastHandler) CancelBroadcast(c *gin.Context) {
+	h.control(c, "cancel", h.broadcaster.Cancel)
+}
+
+func (h *BroadcastHandler) control(c *gin.Context, action string, fn func(ctx context.Context, sessionID, id string) (*broadcast.Job, error)) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	job, err := fn(c.Request.Context(), sess.ID, c.Param("id"))
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to "+action+" broadcast")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, broadcast.NewJobDTO(job))
+}
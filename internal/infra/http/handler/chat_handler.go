@@ -329,6 +329,67 @@ func (h *ChatHandler) Edit(c *gin.Context) {
 }
 
 
+// @Summary Forward a message
+// @Description Forward a stored message to one or more chats, marked as forwarded. Media older than two weeks is uploaded again.
+// @Tags chat
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param request body types.ChatForwardRequest true "Forward request"
+// @Success 200 {object} utils.SuccessResponse{data=types.ChatForwardResponse}
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 410 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/chat/forward [post]
+func (h *ChatHandler) Forward(c *gin.Context) {
+
+	sessionID, ok := h.resolveSessionID(c)
+	if !ok {
+		return
+	}
+
+
+	var req types.ChatForwardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+
+	for _, to := range req.To {
+		if !utils.IsValidPhoneNumber(to) {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid phone number format", to)
+			return
+		}
+	}
+
+
+	h.logger.Infof("Forwarding message %s to %d chats from session %s", req.MessageID, len(req.To), sessionID)
+
+	results, err := h.meowService.ForwardMessage(c.Request.Context(), sessionID, req.MessageID, req.To)
+	if err != nil {
+		statusCode, msg := MapDomainError(err)
+		if statusCode == http.StatusInternalServerError {
+			h.logger.Errorf("Failed to forward message: %v", err)
+			utils.RespondWithError(c, statusCode, "Failed to forward message", err.Error())
+			return
+		}
+		utils.RespondWithError(c, statusCode, msg)
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, utils.SuccessResponse{
+		Success: true,
+		Message: "Message forwarded",
+		Data: types.ChatForwardResponse{
+			MessageID: req.MessageID,
+			Results:   results,
+		},
+	})
+}
+
+
 // @Summary Download image media
 // @Description Download image media from a message
 // @Tags chat
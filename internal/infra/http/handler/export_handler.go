@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"zpmeow/internal/domain/message"
+	"zpmeow/internal/domain/session"
+	"zpmeow/internal/infra/export"
+	"zpmeow/internal/infra/logger"
+	"zpmeow/internal/infra/meow"
+	"zpmeow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportHandler starts transcript exports and serves the finished files
+type ExportHandler struct {
+	sessionService session.SessionService
+	messageService message.MessageService
+	exporter       *export.Exporter
+	logger         logger.Logger
+}
+
+// NewExportHandler creates a new export handler
+func NewExportHandler(sessionService session.SessionService, messageService message.MessageService, exporter *export.Exporter) *ExportHandler {
+	return &ExportHandler{
+		sessionService: sessionService,
+		messageService: messageService,
+		exporter:       exporter,
+		logger:         logger.GetLogger().Sub("export-handler"),
+	}
+}
+
+// resolveSession resolves the session from the path parameter, accepting either ID or name
+func (h *ExportHandler) resolveSession(c *gin.Context) (*session.Session, bool) {
+	sessionID := c.Param("sessionId")
+	if sessionID == "" {
+		utils.RespondWithError(c, http.StatusBadRequest, "Session ID is required")
+		return nil, false
+	}
+
+	sess, err := h.sessionService.GetSession(c.Request.Context(), sessionID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Session not found", err.Error())
+		return nil, false
+	}
+
+	return sess, true
+}
+
+func (h *ExportHandler) handleDomainError(c *gin.Context, err error, defaultMessage string) {
+	statusCode, msg := MapDomainError(err)
+	if statusCode == http.StatusInternalServerError {
+		h.logger.Errorf("%s: %v", defaultMessage, err)
+		utils.RespondWithError(c, statusCode, defaultMessage, err.Error())
+		return
+	}
+	utils.RespondWithError(c, statusCode, msg)
+}
+
+// @Summary Export chat transcript
+// @Description Starts an asynchronous export of one chat, or of the whole session when chat is omitted, as JSON Lines, CSV or a self-contained HTML page. Media is linked (default), embedded as data URIs or left out. Poll the job and download the file from downloadUrl once it is completed.
+// @Tags exports
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param request body message.ExportRequest true "Export request"
+// @Success 202 {object} message.ExportJobDTO
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/exports [post]
+func (h *ExportHandler) CreateExport(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	var req message.ExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	var chatJID string
+	if chat := strings.TrimSpace(req.Chat); chat != "" {
+		jid, err := meow.JID.ParseJID(chat)
+		if err != nil {
+			h.handleDomainError(c, message.ErrInvalidChatJID, "Invalid chat")
+			return
+		}
+		chatJID = jid.ToNonAD().String()
+	}
+
+	job := message.NewExportJob(sess.ID, chatJID, req.Format, req.Media)
+	if err := h.exporter.Start(c.Request.Context(), job); err != nil {
+		h.handleDomainError(c, err, "Failed to start export")
+		return
+	}
+
+	h.logger.Infof("Started %s export %s of session %s (chat=%q media=%s)", job.Format, job.ID, sess.ID, chatJID, job.MediaMode)
+
+	utils.RespondWithJSON(c, http.StatusAccepted, message.NewExportJobDTO(job))
+}
+
+// @Summary Get export job
+// @Description Returns the progress of a transcript export
+// @Tags exports
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param jobId path string true "Export job ID"
+// @Success 200 {object} message.ExportJobDTO
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/exports/{jobId} [get]
+func (h *ExportHandler) GetExport(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.messageService.GetExportJob(c.Request.Context(), sess.ID, c.Param("jobId"))
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to get export job")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, message.NewExportJobDTO(job))
+}
+
+// @Summary Download export
+// @Description Downloads the file of a completed transcript export
+// @Tags exports
+// @Produce application/octet-stream
+// @Param sessionId path string true "Session ID or Name"
+// @Param jobId path string true "Export job ID"
+// @Success 200 {file} file
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/exports/{jobId}/download [get]
+func (h *ExportHandler) DownloadExport(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.messageService.GetExportJob(c.Request.Context(), sess.ID, c.Param("jobId"))
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to get export job")
+		return
+	}
+
+	if job.Status != message.JobStatusCompleted {
+		h.handleDomainError(c, message.ErrExportNotReady, "Export is not finished yet")
+		return
+	}
+
+	if _, err := os.Stat(job.FilePath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			utils.RespondWithError(c, http.StatusNotFound, "Export file not found")
+			return
+		}
+		h.handleDomainError(c, err, "Failed to read export file")
+		return
+	}
+
+	c.FileAttachment(job.FilePath, exportFileName(job))
+}
+
+// exportFileName names the download after the exported chat, or the session for full exports
+func exportFileName(job *message.ExportJob) string {
+	name := "session-" + job.SessionID
+	if job.ChatJID != "" {
+		name = "chat-" + strings.SplitN(job.ChatJID, "@", 2)[0]
+	}
+	return name + "-" + job.CreatedAt.UTC().Format("20060102-150405") + job.FileExtension()
+}
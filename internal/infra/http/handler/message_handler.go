@@ -0,0 +1,386 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"zpmeow/internal/domain/message"
+	"zpmeow/internal/domain/session"
+	"zpmeow/internal/infra/logger"
+	"zpmeow/internal/infra/meow"
+	"zpmeow/internal/infra/reindex"
+	"zpmeow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MessageHandler serves the stored message history
+type MessageHandler struct {
+	sessionService session.SessionService
+	messageService message.MessageService
+	meowService    *meow.MeowServiceImpl
+	reindexer      *reindex.Reindexer
+	logger         logger.Logger
+}
+
+// NewMessageHandler creates a new message handler
+func NewMessageHandler(sessionService session.SessionService, messageService message.MessageService, meowService *meow.MeowServiceImpl, reindexer *reindex.Reindexer) *MessageHandler {
+	return &MessageHandler{
+		sessionService: sessionService,
+		messageService: messageService,
+		meowService:    meowService,
+		reindexer:      reindexer,
+		logger:         logger.GetLogger().Sub("message-handler"),
+	}
+}
+
+// resolveSession resolves the session from the path parameter, accepting either ID or name
+func (h *MessageHandler) resolveSession(c *gin.Context) (*session.Session, bool) {
+	sessionID := c.Param("sessionId")
+	if sessionID == "" {
+		utils.RespondWithError(c, http.StatusBadRequest, "Session ID is required")
+		return nil, false
+	}
+
+	sess, err := h.sessionService.GetSession(c.Request.Context(), sessionID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Session not found", err.Error())
+		return nil, false
+	}
+
+	return sess, true
+}
+
+func (h *MessageHandler) handleDomainError(c *gin.Context, err error, defaultMessage string) {
+	statusCode, msg := MapDomainError(err)
+	if statusCode == http.StatusInternalServerError {
+		h.logger.Errorf("%s: %v", defaultMessage, err)
+		utils.RespondWithError(c, statusCode, defaultMessage, err.Error())
+		return
+	}
+	utils.RespondWithError(c, statusCode, msg)
+}
+
+// @Summary List chat messages
+// @Description Returns the stored message history of a chat, newest first, using cursor pagination
+// @Tags messages
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param jid path string true "Chat JID or phone number"
+// @Param cursor query string false "Cursor returned as nextCursor by the previous page"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param direction query string false "Filter by direction (incoming, outgoing)"
+// @Param type query string false "Comma separated message types (text, image, video, audio, document, sticker, location, contact, poll, reaction)"
+// @Param sender query string false "Filter by sender JID or phone number"
+// @Param from query string false "Only messages at or after this time (RFC3339)"
+// @Param until query string false "Only messages at or before this time (RFC3339)"
+// @Param hasMedia query bool false "Filter messages with or without media"
+// @Success 200 {object} message.MessageListResponse
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/chats/{jid}/messages [get]
+func (h *MessageHandler) ListChatMessages(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	filter, err := h.parseListFilter(c)
+	if err != nil {
+		h.handleDomainError(c, err, "Invalid query parameters")
+		return
+	}
+	filter.SessionID = sess.ID
+
+	page, err := h.messageService.ListChatMessages(c.Request.Context(), filter)
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to list messages")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, message.NewMessageListResponse(page))
+}
+
+// @Summary Download message media
+// @Description Downloads and decrypts the media of a stored message and returns the raw file
+// @Tags messages
+// @Produce application/octet-stream
+// @Param sessionId path string true "Session ID or Name"
+// @Param id path string true "Message ID"
+// @Success 200 {file} file
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 410 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/messages/{id}/media [get]
+func (h *MessageHandler) GetMessageMedia(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	data, mimeType, err := h.meowService.DownloadMedia(c.Request.Context(), sess.ID, c.Param("id"))
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to download media")
+		return
+	}
+
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	c.Data(http.StatusOK, mimeType, data)
+}
+
+// @Summary Get message delivery status
+// @Description Returns the delivery status timeline of a message sent by the session, broken down per participant for groups
+// @Tags messages
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param id path string true "Message ID"
+// @Success 200 {object} message.MessageStatusResponse
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/messages/{id}/status [get]
+func (h *MessageHandler) GetMessageStatus(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.messageService.GetMessageStatus(c.Request.Context(), sess.ID, c.Param("id"))
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to get message status")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, message.NewMessageStatusResponse(status))
+}
+
+// @Summary Search messages
+// @Description Full-text search over text bodies, captions and document file names of all chats in the session, using the session search language
+// @Tags messages
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param q query string true "Search terms (supports quoted phrases, OR and -exclusion)"
+// @Param chat query string false "Restrict the search to one chat JID or phone number"
+// @Param type query string false "Comma separated message types"
+// @Param from query string false "Only messages at or after this time (RFC3339)"
+// @Param until query string false "Only messages at or before this time (RFC3339)"
+// @Param context query int false "Number of chat messages returned before and after each match (default 2, max 10)"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Offset returned as nextOffset by the previous page"
+// @Success 200 {object} message.SearchResponse
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/messages/search [get]
+func (h *MessageHandler) SearchMessages(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	query, err := h.parseSearchQuery(c)
+	if err != nil {
+		h.handleDomainError(c, err, "Invalid query parameters")
+		return
+	}
+	query.SessionID = sess.ID
+	query.Language = sess.GetSearchLanguage()
+
+	page, err := h.messageService.SearchMessages(c.Request.Context(), query)
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to search messages")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, message.NewSearchResponse(query, page))
+}
+
+// @Summary Get search language
+// @Description Returns the text search language used to index and search the session messages, and the progress of the latest reindex
+// @Tags messages
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Success 200 {object} message.SearchLanguageResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/messages/search/language [get]
+func (h *MessageHandler) GetSearchLanguage(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, message.SearchLanguageResponse{
+		Language:  sess.GetSearchLanguage(),
+		Supported: session.SupportedSearchLanguages(),
+		Reindex:   message.NewReindexJobDTO(h.reindexer.Job(sess.ID)),
+	})
+}
+
+// @Summary Set search language
+// @Description Changes the text search language of the session. Its stored messages are reindexed in the background; follow the progress with GET
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param request body message.SearchLanguageRequest true "Search language"
+// @Success 200 {object} message.SearchLanguageResponse
+// @Success 202 {object} message.SearchLanguageResponse
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/messages/search/language [put]
+func (h *MessageHandler) SetSearchLanguage(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	var req message.SearchLanguageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	previous := sess.GetSearchLanguage()
+	if err := sess.SetSearchLanguage(req.Language); err != nil {
+		h.handleDomainError(c, err, "Invalid search language")
+		return
+	}
+
+	if err := h.sessionService.UpdateSession(c.Request.Context(), sess); err != nil {
+		h.handleDomainError(c, err, "Failed to save search language")
+		return
+	}
+
+	// A failed reindex is retried by setting the same language again
+	status := http.StatusOK
+	job := h.reindexer.Job(sess.ID)
+	if sess.SearchLanguage != previous || (job != nil && job.Status == message.JobStatusFailed) {
+		job = h.reindexer.Start(sess.ID, sess.SearchLanguage)
+		status = http.StatusAccepted
+	}
+
+	utils.RespondWithJSON(c, status, message.SearchLanguageResponse{
+		Language:  sess.SearchLanguage,
+		Supported: session.SupportedSearchLanguages(),
+		Reindex:   message.NewReindexJobDTO(job),
+	})
+}
+
+func (h *MessageHandler) parseListFilter(c *gin.Context) (message.ListFilter, error) {
+	var filter message.ListFilter
+
+	chat, err := meow.JID.ParseJID(c.Param("jid"))
+	if err != nil {
+		return filter, message.ErrInvalidChatJID
+	}
+	filter.ChatJID = chat.ToNonAD().String()
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		decoded, err := message.DecodeCursor(cursor)
+		if err != nil {
+			return filter, err
+		}
+		filter.Cursor = decoded
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return filter, message.ErrInvalidFilter
+		}
+		filter.Limit = n
+	}
+
+	filter.Direction = message.Direction(strings.ToLower(c.Query("direction")))
+
+	filter.Types = parseTypesParam(c.Query("type"))
+
+	if sender := c.Query("sender"); sender != "" {
+		jid, err := meow.JID.ParseJID(sender)
+		if err != nil {
+			return filter, message.ErrInvalidFilter
+		}
+		filter.SenderJID = jid.ToNonAD().String()
+	}
+
+	if filter.Since, err = parseTimeParam(c.Query("from")); err != nil {
+		return filter, message.ErrInvalidFilter
+	}
+	if filter.Until, err = parseTimeParam(c.Query("until")); err != nil {
+		return filter, message.ErrInvalidFilter
+	}
+
+	if hasMedia := c.Query("hasMedia"); hasMedia != "" {
+		v, err := strconv.ParseBool(hasMedia)
+		if err != nil {
+			return filter, message.ErrInvalidFilter
+		}
+		filter.HasMedia = &v
+	}
+
+	return filter, nil
+}
+
+func (h *MessageHandler) parseSearchQuery(c *gin.Context) (message.SearchQuery, error) {
+	query := message.SearchQuery{
+		Query:       c.Query("q"),
+		ContextSize: message.DefaultContextSize,
+	}
+
+	if chat := c.Query("chat"); chat != "" {
+		jid, err := meow.JID.ParseJID(chat)
+		if err != nil {
+			return query, message.ErrInvalidChatJID
+		}
+		query.ChatJID = jid.ToNonAD().String()
+	}
+
+	query.Types = parseTypesParam(c.Query("type"))
+
+	var err error
+	if query.Since, err = parseTimeParam(c.Query("from")); err != nil {
+		return query, message.ErrInvalidFilter
+	}
+	if query.Until, err = parseTimeParam(c.Query("until")); err != nil {
+		return query, message.ErrInvalidFilter
+	}
+
+	for param, target := range map[string]*int{"context": &query.ContextSize, "limit": &query.Limit, "offset": &query.Offset} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return query, message.ErrInvalidFilter
+		}
+		*target = n
+	}
+
+	return query, nil
+}
+
+func parseTypesParam(value string) []string {
+	var types []string
+	for _, t := range strings.Split(value, ",") {
+		if t = strings.TrimSpace(strings.ToLower(t)); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"net/http"
+
+	"zpmeow/internal/domain/retention"
+	"zpmeow/internal/domain/session"
+	"zpmeow/internal/infra/logger"
+	"zpmeow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetentionHandler manages retention policies of a session
+type RetentionHandler struct {
+	sessionService   session.SessionService
+	retentionService retention.RetentionService
+	logger           logger.Logger
+}
+
+// NewRetentionHandler creates a new retention handler
+func NewRetentionHandler(sessionService session.SessionService, retentionService retention.RetentionService) *RetentionHandler {
+	return &RetentionHandler{
+		sessionService:   sessionService,
+		retentionService: retentionService,
+		logger:           logger.GetLogger().Sub("retention-handler"),
+	}
+}
+
+// resolveSession resolves the session from the path parameter, accepting either ID or name
+func (h *RetentionHandler) resolveSession(c *gin.Context) (*session.Session, bool) {
+	sessionID := c.Param("sessionId")
+	if sessionID == "" {
+		utils.RespondWithError(c, http.StatusBadRequest, "Session ID is required")
+		return nil, false
+	}
+
+	sess, err := h.sessionService.GetSession(c.Request.Context(), sessionID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Session not found", err.Error())
+		return nil, false
+	}
+
+	return sess, true
+}
+
+func (h *RetentionHandler) handleDomainError(c *gin.Context, err error, defaultMessage string) {
+	statusCode, msg := MapDomainError(err)
+	if statusCode == http.StatusInternalServerError {
+		h.logger.Errorf("%s: %v", defaultMessage, err)
+		utils.RespondWithError(c, statusCode, defaultMessage, err.Error())
+		return
+	}
+	utils.RespondWithError(c, statusCode, msg)
+}
+
+// @Summary Get retention policy
+// @Description Returns the retention policy applied to the session, either its own or the global default
+// @Tags retention
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Success 200 {object} retention.PolicyResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/retention [get]
+func (h *RetentionHandler) GetPolicy(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	policy, err := h.retentionService.GetPolicy(c.Request.Context(), sess.ID)
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to get retention policy")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, retention.NewPolicyResponse(policy))
+}
+
+// @Summary Set retention policy
+// @Description Sets a session retention policy that replaces the global default. Days set to 0 keep the data forever. Use the preview endpoint before enabling it.
+// @Tags retention
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param request body retention.PolicyRequest true "Retention policy"
+// @Success 200 {object} retention.PolicyResponse
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/retention [put]
+func (h *RetentionHandler) SetPolicy(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	var req retention.PolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	policy := req.ToPolicy(sess.ID)
+	if err := h.retentionService.SetSessionPolicy(c.Request.Context(), policy); err != nil {
+		h.handleDomainError(c, err, "Failed to save retention policy")
+		return
+	}
+
+	h.logger.Infof("Retention policy for session %s set to messages=%dd media=%dd receipts=%dd enabled=%t",
+		sess.ID, policy.MessagesDays, policy.MediaDays, policy.ReceiptsDays, policy.Enabled)
+
+	utils.RespondWithJSON(c, http.StatusOK, retention.NewPolicyResponse(policy))
+}
+
+// @Summary Remove retention policy
+// @Description Removes the session retention policy so the global default applies again
+// @Tags retention
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Success 200 {object} retention.PolicyResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/retention [delete]
+func (h *RetentionHandler) DeletePolicy(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	if err := h.retentionService.ClearSessionPolicy(c.Request.Context(), sess.ID); err != nil {
+		h.handleDomainError(c, err, "Failed to remove retention policy")
+		return
+	}
+
+	h.GetPolicy(c)
+}
+
+// @Summary Preview retention policy
+// @Description Reports how much data a policy would remove from the session right now, without deleting anything. Without a body the current policy is previewed, even when disabled.
+// @Tags retention
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param request body retention.PolicyRequest false "Policy to preview"
+// @Success 200 {object} retention.ReportResponse
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/retention/preview [post]
+func (h *RetentionHandler) PreviewPolicy(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	var policy *retention.Policy
+	if c.Request.ContentLength != 0 {
+		var req retention.PolicyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid request", err.Error())
+			return
+		}
+		policy = req.ToPolicy(sess.ID)
+	}
+
+	report, err := h.retentionService.Preview(c.Request.Context(), sess.ID, policy)
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to preview retention policy")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, retention.NewReportResponse(report))
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"zpmeow/internal/domain/schedule"
+	"zpmeow/internal/domain/session"
+	"zpmeow/internal/infra/logger"
+	"zpmeow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduleHandler manages the queue of scheduled messages of a session
+type ScheduleHandler struct {
+	sessionService  session.SessionService
+	scheduleService schedule.ScheduleService
+	logger          logger.Logger
+}
+
+// NewScheduleHandler creates a new schedule handler
+func NewScheduleHandler(sessionService session.SessionService, scheduleService schedule.ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{
+		sessionService:  sessionService,
+		scheduleService: scheduleService,
+		logger:          logger.GetLogger().Sub("schedule-handler"),
+	}
+}
+
+// resolveSession resolves the session from the path parameter, accepting either ID or name
+func (h *ScheduleHandler) resolveSession(c *gin.Context) (*session.Session, bool) {
+	sessionID := c.Param("sessionId")
+	if sessionID == "" {
+		utils.RespondWithError(c, http.StatusBadRequest, "Session ID is required")
+		return nil, false
+	}
+
+	sess, err := h.sessionService.GetSession(c.Request.Context(), sessionID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Session not found", err.Error())
+		return nil, false
+	}
+
+	return sess, true
+}
+
+func (h *ScheduleHandler) handleDomainError(c *gin.Context, err error, defaultMessage string) {
+	statusCode, msg := MapDomainError(err)
+	if statusCode == http.StatusInternalServerError {
+		h.logger.Errorf("%s: %v", defaultMessage, err)
+		utils.RespondWithError(c, statusCode, defaultMessage, err.Error())
+		return
+	}
+	utils.RespondWithError(c, statusCode, msg)
+}
+
+// @Summary List scheduled messages
+// @Description Lists the scheduled messages of a session ordered by send time. Schedule a message by adding sendAt to any send endpoint.
+// @Tags scheduled
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param status query string false "Filter by status (scheduled, sending, sent, failed, skipped, cancelled)"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Number of messages to skip"
+// @Success 200 {object} schedule.ListResponse
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/scheduled [get]
+func (h *ScheduleHandler) ListScheduled(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	filter := schedule.ListFilter{
+		SessionID: sess.ID,
+		Status:    schedule.Status(c.Query("status")),
+	}
+
+	var err error
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+	}
+	if offset := c.Query("offset"); offset != "" {
+		if filter.Offset, err = strconv.Atoi(offset); err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid offset")
+			return
+		}
+	}
+
+	messages, err := h.scheduleService.List(c.Request.Context(), &filter)
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to list scheduled messages")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, schedule.NewListResponse(messages, filter))
+}
+
+// @Summary Get scheduled message
+// @Description Returns a scheduled message and its delivery outcome
+// @Tags scheduled
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param id path string true "Scheduled message ID"
+// @Success 200 {object} schedule.ScheduledMessageDTO
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/scheduled/{id} [get]
+func (h *ScheduleHandler) GetScheduled(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	msg, err := h.scheduleService.Get(c.Request.Context(), sess.ID, c.Param("id"))
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to get scheduled message")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, schedule.NewScheduledMessageDTO(msg))
+}
+
+// @Summary Edit scheduled message
+// @Description Changes the send time, offline policy, text or caption of a message that has not been sent yet
+// @Tags scheduled
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param id path string true "Scheduled message ID"
+// @Param request body schedule.UpdateRequest true "Changes"
+// @Success 200 {object} schedule.ScheduledMessageDTO
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/scheduled/{id} [patch]
+func (h *ScheduleHandler) UpdateScheduled(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	var req schedule.UpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	changes, err := req.ToChanges()
+	if err != nil {
+		h.handleDomainError(c, err, "Invalid changes")
+		return
+	}
+
+	msg, err := h.scheduleService.Update(c.Request.Context(), sess.ID, c.Param("id"), changes)
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to update scheduled message")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, schedule.NewScheduledMessageDTO(msg))
+}
+
+// @Summary Cancel scheduled message
+// @Description Cancels a message that has not been sent yet; it stays listed with status cancelled
+// @Tags scheduled
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param id path string true "Scheduled message ID"
+// @Success 200 {object} schedule.ScheduledMessageDTO
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/scheduled/{id} [delete]
+func (h *ScheduleHandler) CancelScheduled(c *gin.Context) {
+	sess, ok := h.resolveSession(c)
+	if !ok {
+		return
+	}
+
+	msg, err := h.scheduleService.Cancel(c.Request.Context(), sess.ID, c.Param("id"))
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to cancel scheduled message")
+		return
+	}
+
+	h.logger.Infof("Cancelled scheduled message %s of session %s", msg.ID, sess.ID)
+
+	utils.RespondWithJSON(c, http.StatusOK, schedule.NewScheduledMessageDTO(msg))
+}
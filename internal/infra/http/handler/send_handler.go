@@ -1,26 +1,43 @@
 package handler
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 
+	"zpmeow/internal/domain/idempotency"
+	"zpmeow/internal/domain/schedule"
 	"zpmeow/internal/domain/session"
+	"zpmeow/internal/domain/template"
 	"zpmeow/internal/infra/meow"
 	"zpmeow/internal/infra/logger"
 	"zpmeow/internal/types"
 	"zpmeow/internal/utils"
 
 	"github.com/gin-gonic/gin"
-	"go.mau.fi/whatsmeow"
-	"go.mau.fi/whatsmeow/proto/waE2E"
+)
+
+
+const (
+	IdempotencyKeyHeader   = "Idempotency-Key"
+	IdempotentReplayHeader = "Idempotent-Replayed"
 )
 
 
 type SendHandler struct {
-	sessionService session.SessionService
-	meowService    *meow.MeowServiceImpl
-	logger         logger.Logger
+	sessionService     session.SessionService
+	meowService        *meow.MeowServiceImpl
+	idempotencyService idempotency.IdempotencyService
+	scheduleService    schedule.ScheduleService
+	templateService    template.TemplateService
+	logger             logger.Logger
 }
 
 
@@ -42,28 +59,167 @@ func (h *SendHandler) resolveSessionID(c *gin.Context) (string, bool) {
 		return "", false
 	}
 
+	// Media URLs in the request are fetched under the session's policy
+	c.Request = c.Request.WithContext(utils.WithFetchPolicy(c.Request.Context(), meow.SessionFetchPolicy(sess)))
 	return sess.ID, true
 }
 
 
-func (h *SendHandler) handleSendResponse(c *gin.Context, resp *whatsmeow.SendResponse, requestID string, err error, operation string) {
+// deliver sends the payload now, or queues it when the request carries sendAt.
+func (h *SendHandler) deliver(c *gin.Context, sessionID, requestID string, opts types.ScheduleOptions, req interface{}, operation string, payload *schedule.Payload) {
+	if err := payload.ValidateOptions(); err != nil {
+		h.handleDomainError(c, err, "Invalid message options")
+		return
+	}
+
+	if strings.TrimSpace(opts.SendAt) == "" {
+		h.idempotent(c, sessionID, requestID, req, operation, http.StatusOK, func(ctx context.Context) (interface{}, error) {
+			resp, err := h.meowService.SendPayload(ctx, sessionID, payload)
+			if err != nil {
+				return nil, err
+			}
+			return types.NewSendResponseFromWhatsmeow(resp, requestID), nil
+		})
+		return
+	}
+
+	sendAt, err := schedule.ParseSendAt(opts.SendAt)
 	if err != nil {
-		h.logger.Errorf("Failed to %s: %v", operation, err)
-		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to "+operation, err.Error())
+		h.handleDomainError(c, err, "Invalid sendAt")
 		return
 	}
 
+	// The media of a request is a temporary file, so it is kept in the media directory until sent
+	if err := storePayloadMedia(payload); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to store media", err.Error())
+		return
+	}
 
-	response := types.NewSendResponseFromWhatsmeow(resp, requestID)
-	utils.RespondWithJSON(c, http.StatusOK, response)
+	messageID := requestID
+	if !meow.IsValidMessageID(messageID) {
+		messageID = h.meowService.GenerateMessageID(sessionID)
+	}
+
+	h.idempotent(c, sessionID, requestID, req, "schedule message", http.StatusAccepted, func(ctx context.Context) (interface{}, error) {
+		// The link preview is stored with the message, so retries of the send do not fetch it again
+		h.meowService.ResolveLinkPreview(ctx, sessionID, payload)
+
+		msg := schedule.NewScheduledMessage(sessionID, messageID, *payload, sendAt, opts.WhenOffline)
+		if err := h.scheduleService.Schedule(ctx, msg); err != nil {
+			return nil, err
+		}
+		h.logger.Infof("Scheduled %s message %s of session %s for %s", payload.Kind, msg.ID, sessionID, msg.SendAt.Format(time.RFC3339))
+		return schedule.NewScheduledMessageDTO(msg), nil
+	})
 }
 
 
-func NewSendHandler(sessionService session.SessionService, meowService *meow.MeowServiceImpl) *SendHandler {
+// idempotent runs fn at most once per idempotency key. The key is the Idempotency-Key header,
+// or the request ID when the header is absent; a repeated key within the window replays the
+// first response instead of running fn again. A request ID in WhatsApp format also becomes the
+// message ID.
+func (h *SendHandler) idempotent(c *gin.Context, sessionID, requestID string, req interface{}, operation string, statusCode int, fn func(ctx context.Context) (interface{}, error)) {
+	ctx := c.Request.Context()
+	if meow.IsValidMessageID(requestID) {
+		ctx = meow.WithMessageID(ctx, requestID)
+	}
+
+	key := strings.TrimSpace(c.GetHeader(IdempotencyKeyHeader))
+	if key == "" {
+		key = strings.TrimSpace(requestID)
+	}
+	if key != "" && !h.idempotencyService.Enabled() {
+		key = ""
+	}
+
+	if key != "" {
+		replay, err := h.idempotencyService.Begin(ctx, sessionID, key, requestHash(c, operation, req))
+		if err != nil {
+			h.handleDomainError(c, err, "Failed to "+operation)
+			return
+		}
+		if replay != nil {
+			h.logger.Infof("Replaying response for idempotency key %q of session %s", key, sessionID)
+			c.Header(IdempotentReplayHeader, "true")
+			c.Data(statusCode, "application/json; charset=utf-8", replay)
+			return
+		}
+	}
+
+	response, err := fn(ctx)
+	if err != nil {
+		if key != "" {
+			if abortErr := h.idempotencyService.Abort(context.Background(), sessionID, key); abortErr != nil {
+				h.logger.Warnf("Failed to release idempotency key %q of session %s: %v", key, sessionID, abortErr)
+			}
+		}
+		h.handleDomainError(c, err, "Failed to "+operation)
+		return
+	}
+
+	if key != "" {
+		body, err := json.Marshal(response)
+		if err == nil {
+			err = h.idempotencyService.Complete(context.Background(), sessionID, key, body)
+		}
+		if err != nil {
+			h.logger.Warnf("Failed to store response for idempotency key %q of session %s: %v", key, sessionID, err)
+		}
+	}
+
+	utils.RespondWithJSON(c, statusCode, response)
+}
+
+
+func (h *SendHandler) handleDomainError(c *gin.Context, err error, defaultMessage string) {
+	statusCode, msg := MapDomainError(err)
+	if statusCode == http.StatusInternalServerError {
+		h.logger.Errorf("%s: %v", defaultMessage, err)
+		utils.RespondWithError(c, statusCode, defaultMessage, err.Error())
+		return
+	}
+	utils.RespondWithError(c, statusCode, msg)
+}
+
+
+// requestHash fingerprints a send so a key reused for a different message is rejected. Uploaded
+// files are not part of the bound request, so their contents are hashed as well.
+func requestHash(c *gin.Context, operation string, req interface{}) string {
+	hash := sha256.New()
+	body, _ := json.Marshal(req)
+	hash.Write([]byte(operation + "\n"))
+	hash.Write(body)
+
+	if form := c.Request.MultipartForm; form != nil {
+		fields := make([]string, 0, len(form.File))
+		for field := range form.File {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		for _, field := range fields {
+			for _, header := range form.File[field] {
+				fmt.Fprintf(hash, "\n%s:%s:%d\n", field, header.Filename, header.Size)
+				if file, err := header.Open(); err == nil {
+					_, _ = io.Copy(hash, file)
+					file.Close()
+				}
+			}
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+
+func NewSendHandler(sessionService session.SessionService, meowService *meow.MeowServiceImpl, idempotencyService idempotency.IdempotencyService, scheduleService schedule.ScheduleService, templateService template.TemplateService) *SendHandler {
 	return &SendHandler{
-		sessionService: sessionService,
-		meowService:    meowService,
-		logger:         logger.GetLogger().Sub("send-handler"),
+		sessionService:     sessionService,
+		meowService:        meowService,
+		idempotencyService: idempotencyService,
+		scheduleService:    scheduleService,
+		templateService:    templateService,
+		logger:             logger.GetLogger().Sub("send-handler"),
 	}
 }
 
@@ -74,10 +230,14 @@ func NewSendHandler(sessionService session.SessionService, meowService *meow.Meo
 // @Accept json
 // @Produce json
 // @Param sessionId path string true "Session ID or Name"
+// @Param Idempotency-Key header string false "Deduplicates retries: a repeated key replays the first response (defaults to the request id)"
 // @Param request body types.SendTextRequest true "Text message request"
 // @Success 200 {object} types.SendResponse
+// @Success 202 {object} schedule.ScheduledMessageDTO "Queued for sendAt"
 // @Failure 400 {object} utils.ErrorResponse
 // @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Router /session/{sessionId}/send/text [post]
 func (h *SendHandler) SendText(c *gin.Context) {
@@ -110,16 +270,76 @@ func (h *SendHandler) SendText(c *gin.Context) {
 	h.logger.Infof("Sending text message to %s from session %s", req.Phone, sessionID)
 
 
-	var contextInfo *waE2E.ContextInfo
-	if req.ContextInfo.StanzaID != "" {
-		contextInfo = &waE2E.ContextInfo{
-			StanzaID:    &req.ContextInfo.StanzaID,
-			Participant: &req.ContextInfo.Participant,
+	payload := &schedule.Payload{
+		Kind:          schedule.KindText,
+		Phone:         req.Phone,
+		Text:          req.Body,
+		NoLinkPreview: req.NoLinkPreview,
+		Mentions:      req.Mentions,
+		MentionAll:    req.MentionAll,
+		ReplyTo:       replyTarget(req.ReplyOptions, req.ContextInfo),
+	}
+	if req.LinkPreview != nil && !req.NoLinkPreview {
+		preview, err := buildLinkPreview(c.Request.Context(), req.Body, req.LinkPreview)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid link preview", err.Error())
+			return
 		}
+		payload.LinkPreview = preview
+	}
+
+	h.deliver(c, sessionID, req.ID, req.ScheduleOptions, req, "send text message", payload)
+}
+
+
+// replyTarget returns the message a send replies to: the replyTo message, or else the stanza ID
+// of the legacy contextInfo field.
+func replyTarget(opts types.ReplyOptions, legacy types.ContextInfo) *schedule.Reply {
+	if opts.ReplyTo != nil && strings.TrimSpace(opts.ReplyTo.MessageID) != "" {
+		return &schedule.Reply{StanzaID: strings.TrimSpace(opts.ReplyTo.MessageID)}
 	}
+	if legacy.StanzaID != "" {
+		return &schedule.Reply{StanzaID: legacy.StanzaID, Participant: legacy.Participant}
+	}
+	return nil
+}
 
-	whatsmeowResp, err := h.meowService.SendTextMessage(c.Request.Context(), sessionID, req.Phone, req.Body, contextInfo)
-	h.handleSendResponse(c, whatsmeowResp, req.ID, err, "send text message")
+
+// buildLinkPreview validates a preview supplied with a text message and turns its thumbnail
+// into the small JPEG WhatsApp expects.
+func buildLinkPreview(ctx context.Context, body string, req *types.LinkPreview) (*schedule.LinkPreview, error) {
+	preview := &schedule.LinkPreview{
+		MatchedText: strings.TrimSpace(req.MatchedText),
+		Title:       strings.TrimSpace(req.Title),
+		Description: strings.TrimSpace(req.Description),
+	}
+	if preview.MatchedText == "" {
+		preview.MatchedText, _ = utils.FindLink(body)
+	}
+	if preview.MatchedText == "" {
+		return nil, fmt.Errorf("the message body has no link to attach the preview to")
+	}
+	if !strings.Contains(body, preview.MatchedText) {
+		return nil, fmt.Errorf("matchedText must appear in the message body")
+	}
+	if preview.Title == "" {
+		return nil, fmt.Errorf("title is required")
+	}
+
+	if req.Thumbnail == "" {
+		return preview, nil
+	}
+	thumbnail, err := utils.OpenUnifiedMedia(ctx, req.Thumbnail, nil, "image")
+	if err != nil {
+		return nil, fmt.Errorf("invalid thumbnail: %w", err)
+	}
+	defer thumbnail.Remove()
+
+	preview.Thumbnail, preview.ThumbnailWidth, preview.ThumbnailHeight, err = utils.JPEGThumbnailFile(thumbnail.Path, utils.LinkPreviewThumbnailSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid thumbnail: %w", err)
+	}
+	return preview, nil
 }
 
 
@@ -129,10 +349,14 @@ func (h *SendHandler) SendText(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param sessionId path string true "Session ID or Name"
+// @Param Idempotency-Key header string false "Deduplicates retries: a repeated key replays the first response (defaults to the request id)"
 // @Param request body types.SendImageRequest true "Image message request"
 // @Success 200 {object} types.SendResponse
+// @Success 202 {object} schedule.ScheduledMessageDTO "Queued for sendAt"
 // @Failure 400 {object} utils.ErrorResponse
 // @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Router /session/{sessionId}/send/image [post]
 func (h *SendHandler) SendImage(c *gin.Context) {
@@ -164,8 +388,7 @@ func (h *SendHandler) SendImage(c *gin.Context) {
 	}
 
 
-	var imageData []byte
-	var mimeType string
+	var image *utils.MediaFile
 	var err error
 
 
@@ -176,20 +399,21 @@ func (h *SendHandler) SendImage(c *gin.Context) {
 
 	if file != nil {
 
-		imageData, mimeType, err = utils.ProcessUnifiedMedia(c.Request.Context(), "", file, "image")
+		image, err = utils.OpenUnifiedMedia(c.Request.Context(), "", file, "image")
 	} else {
 
 		media := req.Image
 		if media == "" {
 			media = c.PostForm("media") // Also check for 'media' field
 		}
-		imageData, mimeType, err = utils.ProcessUnifiedMedia(c.Request.Context(), media, nil, "image")
+		image, err = utils.OpenUnifiedMedia(c.Request.Context(), media, nil, "image")
 	}
 
 	if err != nil {
 		utils.RespondWithError(c, http.StatusBadRequest, "Invalid image data", err.Error())
 		return
 	}
+	defer image.Remove()
 
 
 	if req.MimeType != "" {
@@ -198,11 +422,22 @@ func (h *SendHandler) SendImage(c *gin.Context) {
 			utils.RespondWithError(c, http.StatusBadRequest, "Invalid MIME type override", err.Error())
 			return
 		}
-		mimeType = normalizedMimeType
+		image.MimeType = normalizedMimeType
+	}
+
+
+	if req.Compress {
+		compressed, err := utils.CompressImage(image, utils.MaxImageSize)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Failed to compress image", err.Error())
+			return
+		}
+		defer compressed.Remove()
+		image = compressed
 	}
 
 
-	if err := utils.ValidateMediaSize(imageData, "image"); err != nil {
+	if err := utils.ValidateMediaLength(image.Size, "image"); err != nil {
 		utils.RespondWithError(c, http.StatusBadRequest, "Invalid image size", err.Error())
 		return
 	}
@@ -210,8 +445,17 @@ func (h *SendHandler) SendImage(c *gin.Context) {
 
 	h.logger.Infof("Sending image message to %s from session %s", req.Phone, sessionID)
 
-	whatsmeowResp, err := h.meowService.SendImageMessage(c.Request.Context(), sessionID, req.Phone, imageData, req.Caption, mimeType)
-	h.handleSendResponse(c, whatsmeowResp, req.ID, err, "send image message")
+	h.deliver(c, sessionID, req.ID, req.ScheduleOptions, req, "send image message", &schedule.Payload{
+		Kind:       schedule.KindImage,
+		Phone:      req.Phone,
+		MediaPath:  image.Path,
+		Caption:    req.Caption,
+		MimeType:   image.MimeType,
+		ViewOnce:   req.ViewOnce,
+		Mentions:   req.Mentions,
+		MentionAll: req.MentionAll,
+		ReplyTo:    replyTarget(req.ReplyOptions, req.ContextInfo),
+	})
 }
 
 
@@ -221,10 +465,14 @@ func (h *SendHandler) SendImage(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param sessionId path string true "Session ID or Name"
+// @Param Idempotency-Key header string false "Deduplicates retries: a repeated key replays the first response (defaults to the request id)"
 // @Param request body types.SendAudioRequest true "Audio message request"
 // @Success 200 {object} types.SendResponse
+// @Success 202 {object} schedule.ScheduledMessageDTO "Queued for sendAt"
 // @Failure 400 {object} utils.ErrorResponse
 // @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Router /session/{sessionId}/send/audio [post]
 func (h *SendHandler) SendAudio(c *gin.Context) {
@@ -256,8 +504,7 @@ func (h *SendHandler) SendAudio(c *gin.Context) {
 	}
 
 
-	var audioData []byte
-	var mimeType string
+	var audio *utils.MediaFile
 	var err error
 
 
@@ -268,23 +515,24 @@ func (h *SendHandler) SendAudio(c *gin.Context) {
 
 	if file != nil {
 
-		audioData, mimeType, err = utils.ProcessUnifiedMedia(c.Request.Context(), "", file, "audio")
+		audio, err = utils.OpenUnifiedMedia(c.Request.Context(), "", file, "audio")
 	} else {
 
 		media := req.Audio
 		if media == "" {
 			media = c.PostForm("media") // Also check for 'media' field
 		}
-		audioData, mimeType, err = utils.ProcessUnifiedMedia(c.Request.Context(), media, nil, "audio")
+		audio, err = utils.OpenUnifiedMedia(c.Request.Context(), media, nil, "audio")
 	}
 
 	if err != nil {
 		utils.RespondWithError(c, http.StatusBadRequest, "Invalid audio data", err.Error())
 		return
 	}
+	defer audio.Remove()
 
 
-	if err := utils.ValidateMediaSize(audioData, "audio"); err != nil {
+	if err := utils.ValidateMediaLength(audio.Size, "audio"); err != nil {
 		utils.RespondWithError(c, http.StatusBadRequest, "Invalid audio size", err.Error())
 		return
 	}
@@ -292,8 +540,15 @@ func (h *SendHandler) SendAudio(c *gin.Context) {
 
 	h.logger.Infof("Sending audio message to %s from session %s", req.Phone, sessionID)
 
-	whatsmeowResp, err := h.meowService.SendAudioMessage(c.Request.Context(), sessionID, req.Phone, audioData, mimeType)
-	h.handleSendResponse(c, whatsmeowResp, req.ID, err, "send audio message")
+	h.deliver(c, sessionID, req.ID, req.ScheduleOptions, req, "send audio message", &schedule.Payload{
+		Kind:      schedule.KindAudio,
+		Phone:     req.Phone,
+		MediaPath: audio.Path,
+		MimeType:  audio.MimeType,
+		ViewOnce:  req.ViewOnce,
+		NoPTT:     req.PTT != nil && !*req.PTT,
+		ReplyTo:   replyTarget(req.ReplyOptions, req.ContextInfo),
+	})
 }
 
 
@@ -303,10 +558,14 @@ func (h *SendHandler) SendAudio(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param sessionId path string true "Session ID or Name"
+// @Param Idempotency-Key header string false "Deduplicates retries: a repeated key replays the first response (defaults to the request id)"
 // @Param request body types.SendDocumentRequest true "Document message request"
 // @Success 200 {object} types.SendResponse
+// @Success 202 {object} schedule.ScheduledMessageDTO "Queued for sendAt"
 // @Failure 400 {object} utils.ErrorResponse
 // @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Router /session/{sessionId}/send/document [post]
 func (h *SendHandler) SendDocument(c *gin.Context) {
@@ -339,8 +598,7 @@ func (h *SendHandler) SendDocument(c *gin.Context) {
 	}
 
 
-	var documentData []byte
-	var mimeType string
+	var document *utils.MediaFile
 	var err error
 
 
@@ -351,39 +609,45 @@ func (h *SendHandler) SendDocument(c *gin.Context) {
 
 	if file != nil {
 
-		documentData, mimeType, err = utils.ProcessUnifiedMedia(c.Request.Context(), "", file, "document")
+		document, err = utils.OpenUnifiedMedia(c.Request.Context(), "", file, "document")
 	} else {
 
 		media := req.Document
 		if media == "" {
 			media = c.PostForm("media") // Also check for 'media' field
 		}
-		documentData, mimeType, err = utils.ProcessUnifiedMedia(c.Request.Context(), media, nil, "document")
+		document, err = utils.OpenUnifiedMedia(c.Request.Context(), media, nil, "document")
 	}
 
 	if err != nil {
 		utils.RespondWithError(c, http.StatusBadRequest, "Invalid document data", err.Error())
 		return
 	}
+	defer document.Remove()
 
 
-	if err := utils.ValidateMediaSize(documentData, "document"); err != nil {
+	if err := utils.ValidateMediaLength(document.Size, "document"); err != nil {
 		utils.RespondWithError(c, http.StatusBadRequest, "Invalid document size", err.Error())
 		return
 	}
 
 
-	filename := req.Filename
-	if filename == "" {
-		ext := utils.GetFileExtension(mimeType)
-		filename = "document" + ext
-	}
+	filename := utils.DocumentFileName(req.Filename, document.MimeType)
 
 
 	h.logger.Infof("Sending document message to %s from session %s", req.Phone, sessionID)
 
-	whatsmeowResp, err := h.meowService.SendDocumentMessage(c.Request.Context(), sessionID, req.Phone, documentData, filename, req.Caption, mimeType)
-	h.handleSendResponse(c, whatsmeowResp, req.ID, err, "send document message")
+	h.deliver(c, sessionID, req.ID, req.ScheduleOptions, req, "send document message", &schedule.Payload{
+		Kind:       schedule.KindDocument,
+		Phone:      req.Phone,
+		MediaPath:  document.Path,
+		FileName:   filename,
+		Caption:    req.Caption,
+		MimeType:   document.MimeType,
+		Mentions:   req.Mentions,
+		MentionAll: req.MentionAll,
+		ReplyTo:    replyTarget(req.ReplyOptions, req.ContextInfo),
+	})
 }
 
 
@@ -393,10 +657,14 @@ func (h *SendHandler) SendDocument(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param sessionId path string true "Session ID or Name"
+// @Param Idempotency-Key header string false "Deduplicates retries: a repeated key replays the first response (defaults to the request id)"
 // @Param request body types.SendVideoRequest true "Video message request"
 // @Success 200 {object} types.SendResponse
+// @Success 202 {object} schedule.ScheduledMessageDTO "Queued for sendAt"
 // @Failure 400 {object} utils.ErrorResponse
 // @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Router /session/{sessionId}/send/video [post]
 func (h *SendHandler) SendVideo(c *gin.Context) {
@@ -429,8 +697,7 @@ func (h *SendHandler) SendVideo(c *gin.Context) {
 	}
 
 
-	var videoData []byte
-	var mimeType string
+	var video *utils.MediaFile
 	var err error
 
 
@@ -441,23 +708,35 @@ func (h *SendHandler) SendVideo(c *gin.Context) {
 
 	if file != nil {
 
-		videoData, mimeType, err = utils.ProcessUnifiedMedia(c.Request.Context(), "", file, "video")
+		video, err = utils.OpenUnifiedMedia(c.Request.Context(), "", file, "video")
 	} else {
 
 		media := req.Video
 		if media == "" {
 			media = c.PostForm("media") // Also check for 'media' field
 		}
-		videoData, mimeType, err = utils.ProcessUnifiedMedia(c.Request.Context(), media, nil, "video")
+		video, err = utils.OpenUnifiedMedia(c.Request.Context(), media, nil, "video")
 	}
 
 	if err != nil {
 		utils.RespondWithError(c, http.StatusBadRequest, "Invalid video data", err.Error())
 		return
 	}
+	defer video.Remove()
 
 
-	if err := utils.ValidateMediaSize(videoData, "video"); err != nil {
+	if req.Transcode {
+		transcoded, err := utils.TranscodeVideo(c.Request.Context(), video)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Failed to transcode video", err.Error())
+			return
+		}
+		defer transcoded.Remove()
+		video = transcoded
+	}
+
+
+	if err := utils.ValidateMediaLength(video.Size, "video"); err != nil {
 		utils.RespondWithError(c, http.StatusBadRequest, "Invalid video size", err.Error())
 		return
 	}
@@ -465,8 +744,18 @@ func (h *SendHandler) SendVideo(c *gin.Context) {
 
 	h.logger.Infof("Sending video message to %s from session %s", req.Phone, sessionID)
 
-	whatsmeowResp, err := h.meowService.SendVideoMessage(c.Request.Context(), sessionID, req.Phone, videoData, req.Caption, mimeType)
-	h.handleSendResponse(c, whatsmeowResp, req.ID, err, "send video message")
+	h.deliver(c, sessionID, req.ID, req.ScheduleOptions, req, "send video message", &schedule.Payload{
+		Kind:        schedule.KindVideo,
+		Phone:       req.Phone,
+		MediaPath:   video.Path,
+		Caption:     req.Caption,
+		MimeType:    video.MimeType,
+		ViewOnce:    req.ViewOnce,
+		GifPlayback: req.GifPlayback,
+		Mentions:    req.Mentions,
+		MentionAll:  req.MentionAll,
+		ReplyTo:     replyTarget(req.ReplyOptions, req.ContextInfo),
+	})
 }
 
 
@@ -476,10 +765,14 @@ func (h *SendHandler) SendVideo(c *gin.Context) {
 // @Accept json,multipart/form-data
 // @Produce json
 // @Param sessionId path string true "Session ID or Name"
+// @Param Idempotency-Key header string false "Deduplicates retries: a repeated key replays the first response (defaults to the request id)"
 // @Param request body types.SendMediaRequest true "Media message request"
 // @Success 200 {object} types.SendResponse
+// @Success 202 {object} schedule.ScheduledMessageDTO "Queued for sendAt"
 // @Failure 400 {object} utils.ErrorResponse
 // @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Router /session/{sessionId}/send/media [post]
 func (h *SendHandler) SendMedia(c *gin.Context) {
@@ -507,6 +800,21 @@ func (h *SendHandler) SendMedia(c *gin.Context) {
 		req.Filename = c.PostForm("filename")
 		req.ID = c.PostForm("id")
 		req.MimeType = c.PostForm("mimeType")
+		req.SendAt = c.PostForm("sendAt")
+		req.WhenOffline = c.PostForm("whenOffline")
+		req.Mentions = c.PostFormArray("mentions")
+		req.MentionAll = c.PostForm("mentionAll") == "true"
+		req.ViewOnce = c.PostForm("viewOnce") == "true"
+		req.Compress = c.PostForm("compress") == "true"
+		req.GifPlayback = c.PostForm("gifPlayback") == "true"
+		req.Transcode = c.PostForm("transcode") == "true"
+		if ptt := c.PostForm("ptt"); ptt != "" {
+			isPTT := ptt == "true"
+			req.PTT = &isPTT
+		}
+		if replyTo := c.PostForm("replyTo"); replyTo != "" {
+			req.ReplyTo = &types.ReplyTo{MessageID: replyTo}
+		}
 	} else {
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -528,28 +836,37 @@ func (h *SendHandler) SendMedia(c *gin.Context) {
 	}
 
 
-	var mediaData []byte
-	var mimeType string
-	var err error
-
-
 	file, _ := c.FormFile("media")
 
-	if file != nil {
-
-		mediaData, mimeType, err = utils.ProcessUnifiedMedia(c.Request.Context(), "", file, req.MediaType)
-	} else {
-
-		mediaData, mimeType, err = utils.ProcessUnifiedMedia(c.Request.Context(), req.Media, nil, req.MediaType)
-	}
-
+	media, err := utils.OpenUnifiedMedia(c.Request.Context(), req.Media, file, req.MediaType)
 	if err != nil {
 		utils.RespondWithError(c, http.StatusBadRequest, "Invalid media data", err.Error())
 		return
 	}
+	defer media.Remove()
 
 
-	if err := utils.ValidateMediaSize(mediaData, req.MediaType); err != nil {
+	if req.Compress && req.MediaType == "image" {
+		compressed, err := utils.CompressImage(media, utils.MaxImageSize)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Failed to compress image", err.Error())
+			return
+		}
+		defer compressed.Remove()
+		media = compressed
+	}
+	if req.Transcode && req.MediaType == "video" {
+		transcoded, err := utils.TranscodeVideo(c.Request.Context(), media)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Failed to transcode video", err.Error())
+			return
+		}
+		defer transcoded.Remove()
+		media = transcoded
+	}
+
+
+	if err := utils.ValidateMediaLength(media.Size, req.MediaType); err != nil {
 		utils.RespondWithError(c, http.StatusBadRequest, "Invalid media size", err.Error())
 		return
 	}
@@ -557,28 +874,34 @@ func (h *SendHandler) SendMedia(c *gin.Context) {
 
 	h.logger.Infof("Sending %s message to %s from session %s", req.MediaType, req.Phone, sessionID)
 
-	var whatsmeowResp *whatsmeow.SendResponse
+	payload := &schedule.Payload{
+		Kind:       req.MediaType,
+		Phone:      req.Phone,
+		MediaPath:  media.Path,
+		MimeType:   media.MimeType,
+		ViewOnce:   req.ViewOnce,
+		Mentions:   req.Mentions,
+		MentionAll: req.MentionAll,
+		ReplyTo:    replyTarget(req.ReplyOptions, req.ContextInfo),
+	}
 
 	switch req.MediaType {
 	case "image":
-		whatsmeowResp, err = h.meowService.SendImageMessage(c.Request.Context(), sessionID, req.Phone, mediaData, req.Caption, mimeType)
+		payload.Caption = req.Caption
+	case "video":
+		payload.Caption = req.Caption
+		payload.GifPlayback = req.GifPlayback
 	case "audio":
-		whatsmeowResp, err = h.meowService.SendAudioMessage(c.Request.Context(), sessionID, req.Phone, mediaData, mimeType)
+		payload.NoPTT = req.PTT != nil && !*req.PTT
 	case "document":
-		filename := req.Filename
-		if filename == "" {
-			ext := utils.GetFileExtension(mimeType)
-			filename = "document" + ext
-		}
-		whatsmeowResp, err = h.meowService.SendDocumentMessage(c.Request.Context(), sessionID, req.Phone, mediaData, filename, req.Caption, mimeType)
-	case "video":
-		whatsmeowResp, err = h.meowService.SendVideoMessage(c.Request.Context(), sessionID, req.Phone, mediaData, req.Caption, mimeType)
+		payload.FileName = utils.DocumentFileName(req.Filename, media.MimeType)
+		payload.Caption = req.Caption
 	default:
 		utils.RespondWithError(c, http.StatusBadRequest, "Unsupported media type")
 		return
 	}
 
-	h.handleSendResponse(c, whatsmeowResp, req.ID, err, fmt.Sprintf("send %s message", req.MediaType))
+	h.deliver(c, sessionID, req.ID, req.ScheduleOptions, req, fmt.Sprintf("send %s message", req.MediaType), payload)
 }
 
 
@@ -588,10 +911,14 @@ func (h *SendHandler) SendMedia(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param sessionId path string true "Session ID or Name"
+// @Param Idempotency-Key header string false "Deduplicates retries: a repeated key replays the first response (defaults to the request id)"
 // @Param request body types.SendStickerRequest true "Sticker message request"
 // @Success 200 {object} types.SendResponse
+// @Success 202 {object} schedule.ScheduledMessageDTO "Queued for sendAt"
 // @Failure 400 {object} utils.ErrorResponse
 // @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Router /session/{sessionId}/send/sticker [post]
 func (h *SendHandler) SendSticker(c *gin.Context) {
@@ -619,7 +946,7 @@ func (h *SendHandler) SendSticker(c *gin.Context) {
 	}
 
 
-	stickerData, mimeType, err := utils.DecodeUniversalMedia(req.Sticker, "sticker")
+	stickerData, _, err := utils.DecodeUniversalMedia(req.Sticker, "sticker")
 	if err != nil {
 		utils.RespondWithError(c, http.StatusBadRequest, "Invalid sticker data", err.Error())
 		return
@@ -632,10 +959,25 @@ func (h *SendHandler) SendSticker(c *gin.Context) {
 	}
 
 
+	stickerData, err = utils.ConvertSticker(c.Request.Context(), stickerData, utils.StickerMetadata{
+		PackName: req.PackName,
+		Author:   req.Author,
+	})
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid sticker data", err.Error())
+		return
+	}
+
+
 	h.logger.Infof("Sending sticker message to %s from session %s", req.Phone, sessionID)
 
-	whatsmeowResp, err := h.meowService.SendStickerMessage(c.Request.Context(), sessionID, req.Phone, stickerData, mimeType)
-	h.handleSendResponse(c, whatsmeowResp, req.ID, err, "send sticker message")
+	h.deliver(c, sessionID, req.ID, req.ScheduleOptions, req, "send sticker message", &schedule.Payload{
+		Kind:     schedule.KindSticker,
+		Phone:    req.Phone,
+		Media:    stickerData,
+		MimeType: "image/webp",
+		ReplyTo:  replyTarget(req.ReplyOptions, req.ContextInfo),
+	})
 }
 
 
@@ -645,10 +987,14 @@ func (h *SendHandler) SendSticker(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param sessionId path string true "Session ID or Name"
+// @Param Idempotency-Key header string false "Deduplicates retries: a repeated key replays the first response (defaults to the request id)"
 // @Param request body types.SendLocationRequest true "Location message request"
 // @Success 200 {object} types.SendResponse
+// @Success 202 {object} schedule.ScheduledMessageDTO "Queued for sendAt"
 // @Failure 400 {object} utils.ErrorResponse
 // @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Router /session/{sessionId}/send/location [post]
 func (h *SendHandler) SendLocation(c *gin.Context) {
@@ -688,8 +1034,15 @@ func (h *SendHandler) SendLocation(c *gin.Context) {
 
 	h.logger.Infof("Sending location message to %s from session %s", req.Phone, sessionID)
 
-	whatsmeowResp, err := h.meowService.SendLocationMessage(c.Request.Context(), sessionID, req.Phone, req.Latitude, req.Longitude, req.Name, req.Address)
-	h.handleSendResponse(c, whatsmeowResp, req.ID, err, "send location message")
+	h.deliver(c, sessionID, req.ID, req.ScheduleOptions, req, "send location message", &schedule.Payload{
+		Kind:      schedule.KindLocation,
+		Phone:     req.Phone,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Name:      req.Name,
+		Address:   req.Address,
+		ReplyTo:   replyTarget(req.ReplyOptions, types.ContextInfo{}),
+	})
 }
 
 
@@ -699,10 +1052,14 @@ func (h *SendHandler) SendLocation(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param sessionId path string true "Session ID or Name"
+// @Param Idempotency-Key header string false "Deduplicates retries: a repeated key replays the first response (defaults to the request id)"
 // @Param request body types.SendContactRequest true "Contact message request"
 // @Success 200 {object} types.SendResponse
+// @Success 202 {object} schedule.ScheduledMessageDTO "Queued for sendAt"
 // @Failure 400 {object} utils.ErrorResponse
 // @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Router /session/{sessionId}/send/contact [post]
 func (h *SendHandler) SendContact(c *gin.Context) {
@@ -732,8 +1089,13 @@ func (h *SendHandler) SendContact(c *gin.Context) {
 
 	h.logger.Infof("Sending contact message to %s from session %s", req.Phone, sessionID)
 
-	whatsmeowResp, err := h.meowService.SendContactMessage(c.Request.Context(), sessionID, req.Phone, req.Contact.DisplayName, req.Contact.VCard)
-	h.handleSendResponse(c, whatsmeowResp, req.ID, err, "send contact message")
+	h.deliver(c, sessionID, req.ID, req.ScheduleOptions, req, "send contact message", &schedule.Payload{
+		Kind:        schedule.KindContact,
+		Phone:       req.Phone,
+		DisplayName: req.Contact.DisplayName,
+		VCard:       req.Contact.VCard,
+		ReplyTo:     replyTarget(req.ReplyOptions, req.ContextInfo),
+	})
 }
 
 
@@ -743,10 +1105,14 @@ func (h *SendHandler) SendContact(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param sessionId path string true "Session ID or Name"
+// @Param Idempotency-Key header string false "Deduplicates retries: a repeated key replays the first response (defaults to the request id)"
 // @Param request body types.SendButtonsRequest true "Buttons message request"
 // @Success 200 {object} types.SendResponse
+// @Success 202 {object} schedule.ScheduledMessageDTO "Queued for sendAt"
 // @Failure 400 {object} utils.ErrorResponse
 // @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Router /session/{sessionId}/send/buttons [post]
 func (h *SendHandler) SendButtons(c *gin.Context) {
@@ -786,8 +1152,14 @@ func (h *SendHandler) SendButtons(c *gin.Context) {
 
 	h.logger.Infof("Sending buttons message to %s from session %s", req.Phone, sessionID)
 
-	whatsmeowResp, err := h.meowService.SendButtonsMessage(c.Request.Context(), sessionID, req.Phone, req.Text, req.Buttons, req.Footer)
-	h.handleSendResponse(c, whatsmeowResp, req.ID, err, "send buttons message")
+	h.deliver(c, sessionID, req.ID, req.ScheduleOptions, req, "send buttons message", &schedule.Payload{
+		Kind:    schedule.KindButtons,
+		Phone:   req.Phone,
+		Text:    req.Text,
+		Buttons: req.Buttons,
+		Footer:  req.Footer,
+		ReplyTo: replyTarget(req.ReplyOptions, types.ContextInfo{}),
+	})
 }
 
 
@@ -797,10 +1169,14 @@ func (h *SendHandler) SendButtons(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param sessionId path string true "Session ID or Name"
+// @Param Idempotency-Key header string false "Deduplicates retries: a repeated key replays the first response (defaults to the request id)"
 // @Param request body types.SendListRequest true "List message request"
 // @Success 200 {object} types.SendResponse
+// @Success 202 {object} schedule.ScheduledMessageDTO "Queued for sendAt"
 // @Failure 400 {object} utils.ErrorResponse
 // @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Router /session/{sessionId}/send/list [post]
 func (h *SendHandler) SendList(c *gin.Context) {
@@ -840,8 +1216,15 @@ func (h *SendHandler) SendList(c *gin.Context) {
 
 	h.logger.Infof("Sending list message to %s from session %s", req.Phone, sessionID)
 
-	whatsmeowResp, err := h.meowService.SendListMessage(c.Request.Context(), sessionID, req.Phone, req.Text, req.ButtonText, req.Sections, req.Footer)
-	h.handleSendResponse(c, whatsmeowResp, req.ID, err, "send list message")
+	h.deliver(c, sessionID, req.ID, req.ScheduleOptions, req, "send list message", &schedule.Payload{
+		Kind:       schedule.KindList,
+		Phone:      req.Phone,
+		Text:       req.Text,
+		ButtonText: req.ButtonText,
+		Sections:   req.Sections,
+		Footer:     req.Footer,
+		ReplyTo:    replyTarget(req.ReplyOptions, types.ContextInfo{}),
+	})
 }
 
 
@@ -851,10 +1234,14 @@ func (h *SendHandler) SendList(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param sessionId path string true "Session ID or Name"
+// @Param Idempotency-Key header string false "Deduplicates retries: a repeated key replays the first response (defaults to the request id)"
 // @Param request body types.SendPollRequest true "Poll message request"
 // @Success 200 {object} types.SendResponse
+// @Success 202 {object} schedule.ScheduledMessageDTO "Queued for sendAt"
 // @Failure 400 {object} utils.ErrorResponse
 // @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Router /session/{sessionId}/send/poll [post]
 func (h *SendHandler) SendPoll(c *gin.Context) {
@@ -903,6 +1290,213 @@ func (h *SendHandler) SendPoll(c *gin.Context) {
 
 	h.logger.Infof("Sending poll message to %s from session %s", req.Phone, sessionID)
 
-	whatsmeowResp, err := h.meowService.SendPollMessage(c.Request.Context(), sessionID, req.Phone, req.Name, req.Options, req.SelectableCount)
-	h.handleSendResponse(c, whatsmeowResp, req.ID, err, "send poll message")
+	h.deliver(c, sessionID, req.ID, req.ScheduleOptions, req, "send poll message", &schedule.Payload{
+		Kind:            schedule.KindPoll,
+		Phone:           req.Phone,
+		Name:            req.Name,
+		Options:         req.Options,
+		SelectableCount: req.SelectableCount,
+		ReplyTo:         replyTarget(req.ReplyOptions, types.ContextInfo{}),
+	})
+}
+
+
+// @Summary Send a template message
+// @Description Renders a stored template, found by ID or name, with the given variables and sends it. Session templates take precedence over shared templates with the same name.
+// @Tags send
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param Idempotency-Key header string false "Deduplicates retries: a repeated key replays the first response (defaults to the request id)"
+// @Param request body types.SendTemplateRequest true "Template message request"
+// @Success 200 {object} types.SendResponse
+// @Success 202 {object} schedule.ScheduledMessageDTO "Queued for sendAt"
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/send/template [post]
+func (h *SendHandler) SendTemplate(c *gin.Context) {
+	sessionID, ok := h.resolveSessionID(c)
+	if !ok {
+		return
+	}
+
+	var req types.SendTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	if !utils.IsValidPhoneNumber(req.Phone) {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid phone number format")
+		return
+	}
+
+	tmpl, err := h.templateService.Resolve(c.Request.Context(), sessionID, req.Template)
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to load template")
+		return
+	}
+
+	if missing := tmpl.MissingVariables(req.Variables); len(missing) > 0 {
+		_, msg := MapDomainError(template.ErrMissingVariables)
+		utils.RespondWithError(c, http.StatusBadRequest, msg, strings.Join(missing, ", "))
+		return
+	}
+
+	h.logger.Infof("Sending template %s to %s from session %s", tmpl.Name, req.Phone, sessionID)
+
+	payload := tmpl.Render(req.Phone, req.Variables)
+	payload.ReplyTo = replyTarget(req.ReplyOptions, types.ContextInfo{})
+
+	h.deliver(c, sessionID, req.ID, req.ScheduleOptions, req, "send template message", payload)
+}
+
+
+// @Summary Post a status update
+// @Description Post a text, image or video status update (story), to everyone the status privacy allows or to the given audience, which must be saved contacts
+// @Tags status
+// @Accept json,multipart/form-data
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param Idempotency-Key header string false "Deduplicates retries: a repeated key replays the first response (defaults to the request id)"
+// @Param request body types.SendStatusRequest true "Status update request"
+// @Success 200 {object} types.SendResponse
+// @Success 202 {object} schedule.ScheduledMessageDTO "Queued for sendAt"
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/status/send [post]
+func (h *SendHandler) SendStatus(c *gin.Context) {
+	sessionID, ok := h.resolveSessionID(c)
+	if !ok {
+		return
+	}
+
+	var req types.SendStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if err := c.ShouldBind(&req); err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid request", err.Error())
+			return
+		}
+	}
+
+	payload := &schedule.Payload{
+		Kind:            strings.ToLower(strings.TrimSpace(req.Type)),
+		Phone:           schedule.StatusBroadcast,
+		Text:            req.Text,
+		Caption:         req.Caption,
+		BackgroundColor: req.BackgroundColor,
+		Font:            req.Font,
+		Audience:        req.Audience,
+	}
+
+	switch payload.Kind {
+	case schedule.KindText:
+		if strings.TrimSpace(req.Text) == "" {
+			h.handleDomainError(c, schedule.ErrEmptyText, "Invalid status update")
+			return
+		}
+	case schedule.KindImage, schedule.KindVideo:
+		file, _ := c.FormFile("media")
+		media, err := utils.OpenUnifiedMedia(c.Request.Context(), req.Media, file, payload.Kind)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid media data", err.Error())
+			return
+		}
+		defer media.Remove()
+		if req.MimeType != "" {
+			if media.MimeType, err = utils.ValidateAndNormalizeMimeType(req.MimeType, payload.Kind); err != nil {
+				utils.RespondWithError(c, http.StatusBadRequest, "Invalid MIME type override", err.Error())
+				return
+			}
+		}
+		if err := utils.ValidateMediaLength(media.Size, payload.Kind); err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid media size", err.Error())
+			return
+		}
+		payload.MediaPath, payload.MimeType = media.Path, media.MimeType
+	default:
+		h.handleDomainError(c, schedule.ErrStatusKindNotSupported, "Invalid status update")
+		return
+	}
+
+	h.logger.Infof("Posting %s status update from session %s", payload.Kind, sessionID)
+
+	h.deliver(c, sessionID, req.ID, req.ScheduleOptions, req, "send status update", payload)
+}
+
+
+// @Summary Send an album
+// @Description Send 2 to 30 images and videos grouped in one album, each with an optional caption
+// @Tags send
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param Idempotency-Key header string false "Deduplicates retries: a repeated key replays the first response (defaults to the request id)"
+// @Param request body types.SendAlbumRequest true "Album request"
+// @Success 200 {object} types.SendAlbumResponse
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/send/album [post]
+func (h *SendHandler) SendAlbum(c *gin.Context) {
+	sessionID, ok := h.resolveSessionID(c)
+	if !ok {
+		return
+	}
+
+	var req types.SendAlbumRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	if !utils.IsValidPhoneNumber(req.Phone) {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid phone number format")
+		return
+	}
+
+	// Items stay on disk until each one is prepared for sending
+	items := make([]meow.AlbumMedia, len(req.Items))
+	for i, item := range req.Items {
+		spooled, err := utils.OpenUnifiedMedia(c.Request.Context(), item.Media, nil, item.Type)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid media for item %d", i), err.Error())
+			return
+		}
+		defer spooled.Remove()
+
+		mimeType := spooled.MimeType
+		if item.MimeType != "" {
+			if mimeType, err = utils.ValidateAndNormalizeMimeType(item.MimeType, item.Type); err != nil {
+				utils.RespondWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid MIME type override for item %d", i), err.Error())
+				return
+			}
+		}
+		if err := utils.ValidateMediaLength(spooled.Size, item.Type); err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid media size for item %d", i), err.Error())
+			return
+		}
+		items[i] = meow.AlbumMedia{Kind: item.Type, Path: spooled.Path, Caption: item.Caption, MimeType: mimeType}
+	}
+
+	h.logger.Infof("Sending album of %d items to %s from session %s", len(items), req.Phone, sessionID)
+
+	h.idempotent(c, sessionID, req.ID, req, "send album", http.StatusOK, func(ctx context.Context) (interface{}, error) {
+		album, results, err := h.meowService.SendAlbum(ctx, sessionID, req.Phone, items)
+		if err != nil {
+			return nil, err
+		}
+		return types.SendAlbumResponse{
+			Album: types.NewSendResponseFromWhatsmeow(album, req.ID),
+			Items: results,
+		}, nil
+	})
 }
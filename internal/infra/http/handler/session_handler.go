@@ -2,24 +2,31 @@ package handler
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"zpmeow/internal/domain/message"
 	"zpmeow/internal/domain/session"
 	"zpmeow/internal/infra/logger"
+	"zpmeow/internal/infra/meow"
 	"zpmeow/internal/utils"
 )
 
 
 type SessionHandler struct {
 	sessionService session.SessionService
+	meowService    *meow.MeowServiceImpl
+	messageService message.MessageService
 	logger         logger.Logger
 }
 
 
-func NewSessionHandler(sessionService session.SessionService) *SessionHandler {
+func NewSessionHandler(sessionService session.SessionService, meowService *meow.MeowServiceImpl, messageService message.MessageService) *SessionHandler {
 	return &SessionHandler{
 		sessionService: sessionService,
+		meowService:    meowService,
+		messageService: messageService,
 		logger:         logger.GetLogger().Sub("session-handler"),
 	}
 }
@@ -344,6 +351,70 @@ func (h *SessionHandler) GetProxy(c *gin.Context) {
 	utils.RespondWithData(c, response)
 }
 
+
+// @Summary Set media fetch policy for session
+// @Description Sets where the session may fetch media URLs and link previews from. Lists that are set replace the server defaults; blocked domains add to them. An empty policy restores the defaults.
+// @Tags sessions
+// @Accept json
+// @Produce json
+// @Param id path string true "Session ID"
+// @Param request body session.MediaFetchPolicy true "Media fetch policy"
+// @Success 200 {object} session.MediaFetchPolicyResponse
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /sessions/{id}/media-fetch/set [post]
+func (h *SessionHandler) SetMediaFetchPolicy(c *gin.Context) {
+	id, ok := ValidateSessionIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req session.MediaFetchPolicy
+	if !ValidateAndBindJSON(c, &req) {
+		return
+	}
+
+	sess, err := h.sessionService.SetMediaFetchPolicy(c.Request.Context(), id, req)
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to set media fetch policy")
+		return
+	}
+
+	utils.RespondWithData(c, session.MediaFetchPolicyResponse{
+		Policy:  sess.MediaFetch,
+		Message: "Media fetch policy updated successfully.",
+	})
+}
+
+
+// @Summary Get media fetch policy for session
+// @Description Retrieves where the session may fetch media URLs and link previews from, on top of the server defaults
+// @Tags sessions
+// @Produce json
+// @Param id path string true "Session ID"
+// @Success 200 {object} session.MediaFetchPolicyResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /sessions/{id}/media-fetch/find [get]
+func (h *SessionHandler) GetMediaFetchPolicy(c *gin.Context) {
+	id, ok := ValidateSessionIDParam(c)
+	if !ok {
+		return
+	}
+
+	sess, err := h.sessionService.GetSession(c.Request.Context(), id)
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to get session")
+		return
+	}
+
+	utils.RespondWithData(c, session.MediaFetchPolicyResponse{
+		Policy:     sess.MediaFetch,
+		Unreadable: sess.MediaFetch.Unreadable,
+		Message:    "Media fetch policy retrieved successfully.",
+	})
+}
+
 // @Summary Disconnect WhatsApp session
 // @Description Disconnect an active WhatsApp session
 // @Tags sessions
@@ -414,15 +485,17 @@ func (h *SessionHandler) GetSessionStatus(c *gin.Context) {
 }
 
 // @Summary Request history sync
-// @Description Request WhatsApp to sync message history for the session
+// @Description Asks the primary device for older messages of a chat, anchored on a known message. The messages are stored in the message store; poll the returned job or subscribe to the history_sync webhook for completion. GET is kept for existing callers and takes the same fields as query parameters.
 // @Tags sessions
 // @Accept json
 // @Produce json
 // @Param id path string true "Session ID or Name"
-// @Success 200 {object} utils.SuccessResponse
+// @Param request body message.HistorySyncRequest true "History sync request"
+// @Success 202 {object} message.HistorySyncJobDTO
 // @Failure 400 {object} utils.ErrorResponse
 // @Failure 404 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
+// @Router /sessions/{id}/history [post]
 // @Router /sessions/{id}/history [get]
 func (h *SessionHandler) RequestHistorySync(c *gin.Context) {
 	sessionID := c.Param("id")
@@ -431,22 +504,61 @@ func (h *SessionHandler) RequestHistorySync(c *gin.Context) {
 		return
 	}
 
+	var req message.HistorySyncRequest
+	bind := c.ShouldBindJSON
+	if c.Request.Method == http.MethodGet {
+		bind = c.ShouldBindQuery
+	}
+	if err := bind(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
 	h.logger.Infof("Requesting history sync for session: %s", sessionID)
 
 	// Check if session exists
-	_, err := h.sessionService.GetSession(c.Request.Context(), sessionID)
+	sess, err := h.sessionService.GetSession(c.Request.Context(), sessionID)
 	if err != nil {
 		h.handleDomainError(c, err, "Failed to get session")
 		return
 	}
 
-	// TODO: Implement history sync functionality in the WhatsApp service
-	// For now, we'll return a success response
-	utils.RespondWithJSON(c, http.StatusOK, utils.SuccessResponse{
-		Success: true,
-		Message: "History sync requested successfully",
-		Data: map[string]interface{}{
-			"Details": "History sync has been requested",
-		},
-	})
+	var before time.Time
+	if req.Before > 0 {
+		before = time.Unix(req.Before, 0)
+	}
+
+	job, err := h.meowService.RequestHistorySync(c.Request.Context(), sess.ID, req.Chat, req.AnchorID, req.AnchorFromMe, before, req.Count)
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to request history sync")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusAccepted, message.NewHistorySyncJobDTO(job))
+}
+
+// @Summary Get history sync job
+// @Description Reports the progress of a history sync request
+// @Tags sessions
+// @Produce json
+// @Param id path string true "Session ID or Name"
+// @Param jobId path string true "History sync job ID"
+// @Success 200 {object} message.HistorySyncJobDTO
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /sessions/{id}/history/{jobId} [get]
+func (h *SessionHandler) GetHistorySyncJob(c *gin.Context) {
+	sess, err := h.sessionService.GetSession(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to get session")
+		return
+	}
+
+	job, err := h.messageService.GetHistorySyncJob(c.Request.Context(), sess.ID, c.Param("jobId"))
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to get history sync job")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, message.NewHistorySyncJobDTO(job))
 }
@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"net/http"
+
+	"zpmeow/internal/domain/session"
+	"zpmeow/internal/domain/template"
+	"zpmeow/internal/infra/logger"
+	"zpmeow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TemplateHandler manages message templates of a session and the shared templates
+type TemplateHandler struct {
+	sessionService  session.SessionService
+	templateService template.TemplateService
+	logger          logger.Logger
+}
+
+// NewTemplateHandler creates a new template handler
+func NewTemplateHandler(sessionService session.SessionService, templateService template.TemplateService) *TemplateHandler {
+	return &TemplateHandler{
+		sessionService:  sessionService,
+		templateService: templateService,
+		logger:          logger.GetLogger().Sub("template-handler"),
+	}
+}
+
+// resolveScope returns the session ID of session routes, or an empty scope for the shared
+// template routes
+func (h *TemplateHandler) resolveScope(c *gin.Context) (string, bool) {
+	sessionID := c.Param("sessionId")
+	if sessionID == "" {
+		return "", true
+	}
+
+	sess, err := h.sessionService.GetSession(c.Request.Context(), sessionID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Session not found", err.Error())
+		return "", false
+	}
+
+	return sess.ID, true
+}
+
+func (h *TemplateHandler) handleDomainError(c *gin.Context, err error, defaultMessage string) {
+	statusCode, msg := MapDomainError(err)
+	if statusCode == http.StatusInternalServerError {
+		h.logger.Errorf("%s: %v", defaultMessage, err)
+		utils.RespondWithError(c, statusCode, defaultMessage, err.Error())
+		return
+	}
+	utils.RespondWithError(c, statusCode, msg)
+}
+
+// @Summary Create template
+// @Description Stores a text, media with caption, list or poll message for reuse. Texts may contain {{variable}} placeholders that are filled when the template is sent; {{phone}} is always available. Templates created under /templates are shared by all sessions.
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param request body template.SaveRequest true "Template"
+// @Success 201 {object} template.TemplateDTO
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/templates [post]
+// @Router /templates [post]
+func (h *TemplateHandler) CreateTemplate(c *gin.Context) {
+	scope, ok := h.resolveScope(c)
+	if !ok {
+		return
+	}
+
+	var req template.SaveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	payload, err := buildPayload(c.Request.Context(), req.Type, &req.Message)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid message", err.Error())
+		return
+	}
+
+	t := template.NewTemplate(scope, req.Name, *payload)
+	if err := h.templateService.Create(c.Request.Context(), t); err != nil {
+		h.handleDomainError(c, err, "Failed to create template")
+		return
+	}
+
+	h.logger.Infof("Created %s template %s (%s)", t.Scope(), t.Name, t.ID)
+
+	utils.RespondWithJSON(c, http.StatusCreated, template.NewTemplateDTO(t))
+}
+
+// @Summary List templates
+// @Description Lists the templates of the session together with the shared templates, or only the shared templates under /templates
+// @Tags templates
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Success 200 {object} template.ListResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/templates [get]
+// @Router /templates [get]
+func (h *TemplateHandler) ListTemplates(c *gin.Context) {
+	scope, ok := h.resolveScope(c)
+	if !ok {
+		return
+	}
+
+	templates, err := h.templateService.List(c.Request.Context(), scope)
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to list templates")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, template.NewListResponse(templates))
+}
+
+// @Summary Get template
+// @Description Returns a template with the variables it expects
+// @Tags templates
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param id path string true "Template ID"
+// @Success 200 {object} template.TemplateDTO
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/templates/{id} [get]
+// @Router /templates/{id} [get]
+func (h *TemplateHandler) GetTemplate(c *gin.Context) {
+	scope, ok := h.resolveScope(c)
+	if !ok {
+		return
+	}
+
+	t, err := h.templateService.Get(c.Request.Context(), scope, c.Param("id"))
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to get template")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, template.NewTemplateDTO(t))
+}
+
+// @Summary Replace template
+// @Description Replaces the name and content of a template. Shared templates can only be changed under /templates.
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param id path string true "Template ID"
+// @Param request body template.SaveRequest true "Template"
+// @Success 200 {object} template.TemplateDTO
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/templates/{id} [put]
+// @Router /templates/{id} [put]
+func (h *TemplateHandler) UpdateTemplate(c *gin.Context) {
+	scope, ok := h.resolveScope(c)
+	if !ok {
+		return
+	}
+
+	var req template.SaveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	payload, err := buildPayload(c.Request.Context(), req.Type, &req.Message)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid message", err.Error())
+		return
+	}
+
+	t, err := h.templateService.Replace(c.Request.Context(), scope, c.Param("id"), req.Name, *payload)
+	if err != nil {
+		h.handleDomainError(c, err, "Failed to update template")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, template.NewTemplateDTO(t))
+}
+
+// @Summary Delete template
+// @Description Deletes a template. Shared templates can only be deleted under /templates.
+// @Tags templates
+// @Produce json
+// @Param sessionId path string true "Session ID or Name"
+// @Param id path string true "Template ID"
+// @Success 200 {object} utils.SuccessResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /session/{sessionId}/templates/{id} [delete]
+// @Router /templates/{id} [delete]
+func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
+	scope, ok := h.resolveScope(c)
+	if !ok {
+		return
+	}
+
+	if err := h.templateService.Delete(c.Request.Context(), scope, c.Param("id")); err != nil {
+		h.handleDomainError(c, err, "Failed to delete template")
+		return
+	}
+
+	utils.RespondWithSuccess(c, "Template deleted")
+}
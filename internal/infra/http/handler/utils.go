@@ -1,11 +1,21 @@
 package handler
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
+	"zpmeow/internal/domain/broadcast"
+	"zpmeow/internal/domain/idempotency"
+	"zpmeow/internal/domain/message"
+	"zpmeow/internal/domain/retention"
+	"zpmeow/internal/domain/schedule"
 	"zpmeow/internal/domain/session"
+	"zpmeow/internal/domain/template"
+	"zpmeow/internal/types"
 	"zpmeow/internal/utils"
 )
 
@@ -30,6 +40,8 @@ var domainErrorMappings = map[error]ErrorMapping{
 	session.ErrInvalidSessionNameFormat:  {http.StatusBadRequest, "Invalid session name format"},
 	session.ErrReservedSessionName:       {http.StatusBadRequest, "Session name is reserved"},
 	session.ErrInvalidSessionStatus:      {http.StatusBadRequest, "Invalid session status"},
+	session.ErrUnsupportedSearchLanguage: {http.StatusBadRequest, "Search language is not supported"},
+	session.ErrInvalidMediaFetchPolicy:   {http.StatusBadRequest, "Media fetch policy is invalid"},
 	
 
 	session.ErrSessionAlreadyExists:      {http.StatusConflict, "Session already exists"},
@@ -38,6 +50,80 @@ var domainErrorMappings = map[error]ErrorMapping{
 	
 
 	session.ErrSessionNotFound:           {http.StatusNotFound, "Session not found"},
+
+
+	message.ErrInvalidMessageID:          {http.StatusBadRequest, "Invalid message ID"},
+	message.ErrInvalidChatJID:            {http.StatusBadRequest, "Invalid chat JID"},
+	message.ErrInvalidCursor:             {http.StatusBadRequest, "Invalid pagination cursor"},
+	message.ErrInvalidDirection:          {http.StatusBadRequest, "Invalid message direction"},
+	message.ErrInvalidDateRange:          {http.StatusBadRequest, "Invalid date range"},
+	message.ErrInvalidFilter:             {http.StatusBadRequest, "Invalid message filter"},
+	message.ErrEmptySearchQuery:          {http.StatusBadRequest, "Search query is required"},
+	message.ErrInvalidStatus:             {http.StatusBadRequest, "Invalid message status"},
+	message.ErrStatusNotTracked:          {http.StatusBadRequest, "Delivery status is only tracked for outgoing messages"},
+	message.ErrNoHistoryAnchor:           {http.StatusBadRequest, "No known message to anchor the history request"},
+	message.ErrInvalidHistoryCount:       {http.StatusBadRequest, "History message count is out of range"},
+	message.ErrJobNotFound:               {http.StatusNotFound, "Job not found"},
+	message.ErrInvalidExportFormat:       {http.StatusBadRequest, "Export format must be jsonl, csv or html"},
+	message.ErrInvalidMediaMode:          {http.StatusBadRequest, "Media mode must be link, embed or none"},
+	message.ErrExportNotReady:            {http.StatusConflict, "Export is not finished yet"},
+	message.ErrMessageHasNoMedia:         {http.StatusBadRequest, "Message has no media"},
+	message.ErrMediaUnavailable:          {http.StatusGone, "Media of this message is no longer available"},
+	message.ErrNotForwardable:            {http.StatusBadRequest, "This kind of message cannot be forwarded"},
+
+
+	idempotency.ErrInvalidKey:            {http.StatusBadRequest, "Idempotency key must be between 1 and 255 characters"},
+	idempotency.ErrRequestInProgress:     {http.StatusConflict, "A request with this idempotency key is still in progress"},
+	idempotency.ErrKeyReused:             {http.StatusUnprocessableEntity, "Idempotency key was already used for a different request"},
+
+
+	broadcast.ErrNoRecipients:            {http.StatusBadRequest, "Broadcast needs at least one recipient"},
+	broadcast.ErrTooManyRecipients:       {http.StatusBadRequest, "Broadcast has too many recipients"},
+	broadcast.ErrInvalidCSV:              {http.StatusBadRequest, "Variables CSV must have a header row with a phone column and the same number of fields on every row"},
+	broadcast.ErrUnknownVariable:         {http.StatusBadRequest, "Message uses a placeholder that is not a CSV column"},
+	broadcast.ErrInvalidStatusFilter:     {http.StatusBadRequest, "Invalid broadcast recipient status"},
+	broadcast.ErrInvalidTransition:       {http.StatusConflict, "Broadcast cannot change to that state"},
+	broadcast.ErrJobNotFound:             {http.StatusNotFound, "Broadcast not found"},
+
+
+	template.ErrInvalidTemplateName:      {http.StatusBadRequest, "Template name must start with a letter or digit and contain only letters, digits, '.', '_' and '-'"},
+	template.ErrUnsupportedKind:          {http.StatusBadRequest, "Templates support text, image, video, document, list and poll messages"},
+	template.ErrMissingVariables:         {http.StatusBadRequest, "Template variables are missing"},
+	template.ErrTemplateNameTaken:        {http.StatusConflict, "A template with this name already exists"},
+	template.ErrTemplateReadOnly:         {http.StatusConflict, "Shared templates can only be changed under /templates"},
+	template.ErrTemplateNotFound:         {http.StatusNotFound, "Template not found"},
+
+
+	schedule.ErrInvalidSendAt:            {http.StatusBadRequest, "sendAt must be an RFC 3339 timestamp with time zone"},
+	schedule.ErrSendAtInPast:             {http.StatusBadRequest, "sendAt must be in the future"},
+	schedule.ErrInvalidOfflinePolicy:     {http.StatusBadRequest, "whenOffline must be hold or skip"},
+	schedule.ErrInvalidKind:              {http.StatusBadRequest, "Unsupported message kind"},
+	schedule.ErrInvalidRecipient:         {http.StatusBadRequest, "Recipient phone is required"},
+	schedule.ErrMissingMedia:             {http.StatusBadRequest, "Media is required"},
+	schedule.ErrEmptyText:                {http.StatusBadRequest, "Message text cannot be empty"},
+	schedule.ErrNothingToEdit:            {http.StatusBadRequest, "This change does not apply to the message"},
+	schedule.ErrInvalidStatusFilter:      {http.StatusBadRequest, "Invalid scheduled message status"},
+	schedule.ErrMentionsNotSupported:     {http.StatusBadRequest, "Mentions are only supported in text messages and captions"},
+	schedule.ErrMentionAllNotGroup:       {http.StatusBadRequest, "mentionAll requires a group recipient"},
+	schedule.ErrInvalidMention:           {http.StatusBadRequest, "Mentions cannot be empty"},
+	schedule.ErrNotEditable:              {http.StatusConflict, "Only messages that are still scheduled can be changed"},
+	schedule.ErrScheduledNotFound:        {http.StatusNotFound, "Scheduled message not found"},
+	schedule.ErrQuotedMessageNotFound:    {http.StatusNotFound, "The message to reply to was not found"},
+	schedule.ErrViewOnceNotSupported:     {http.StatusBadRequest, "viewOnce is only supported for images, videos and audio"},
+	schedule.ErrStatusKindNotSupported:   {http.StatusBadRequest, "Status updates can only be text, image or video"},
+	schedule.ErrStatusOptionNotSupported: {http.StatusBadRequest, "Replies, mentions and viewOnce are not supported in status updates"},
+	schedule.ErrStatusOptionsNotStatus:   {http.StatusBadRequest, "backgroundColor, font and audience only apply to status updates"},
+	schedule.ErrStatusStyleNotText:       {http.StatusBadRequest, "backgroundColor and font only apply to text status updates"},
+	schedule.ErrInvalidStatusColor:       {http.StatusBadRequest, "backgroundColor must be a #RRGGBB or #AARRGGBB color"},
+	schedule.ErrInvalidStatusFont:        {http.StatusBadRequest, "Unsupported status font"},
+	schedule.ErrInvalidStatusAudience:    {http.StatusBadRequest, "Audience entries cannot be empty"},
+	schedule.ErrStatusAudienceConflict:   {http.StatusConflict, "Audience cannot be used while status privacy is limited to selected contacts"},
+	schedule.ErrStatusAudienceNotContact: {http.StatusBadRequest, "Audience members must be saved contacts"},
+
+
+	retention.ErrInvalidRetentionDays:    {http.StatusBadRequest, "Retention days cannot be negative"},
+	retention.ErrPolicyNotFound:          {http.StatusNotFound, "Session has no retention policy of its own"},
+	message.ErrMessageNotFound:           {http.StatusNotFound, "Message not found"},
 }
 
 
@@ -207,3 +293,95 @@ func ValidateAndBindJSON(c *gin.Context, obj interface{}) bool {
 	}
 	return true
 }
+
+
+// buildPayload validates message content of the given type and decodes its media, for
+// endpoints that store a message to send later or to many recipients
+func buildPayload(ctx context.Context, kind string, msg *types.MessageContent) (*schedule.Payload, error) {
+	payload := &schedule.Payload{
+		Kind:            strings.ToLower(strings.TrimSpace(kind)),
+		Text:            msg.Body,
+		Caption:         msg.Caption,
+		FileName:        msg.FileName,
+		Latitude:        msg.Latitude,
+		Longitude:       msg.Longitude,
+		Name:            msg.Name,
+		Address:         msg.Address,
+		DisplayName:     msg.DisplayName,
+		VCard:           msg.VCard,
+		Buttons:         msg.Buttons,
+		ButtonText:      msg.ButtonText,
+		Sections:        msg.Sections,
+		Footer:          msg.Footer,
+		Options:         msg.Options,
+		SelectableCount: msg.SelectableCount,
+		NoLinkPreview:   msg.NoLinkPreview,
+	}
+
+	switch payload.Kind {
+	case schedule.KindPoll:
+		if len(payload.Options) < 2 || len(payload.Options) > 12 {
+			return nil, fmt.Errorf("a poll needs between 2 and 12 options")
+		}
+		if payload.SelectableCount <= 0 {
+			payload.SelectableCount = 1
+		}
+		if payload.SelectableCount > len(payload.Options) {
+			return nil, fmt.Errorf("selectable count cannot exceed number of options")
+		}
+	case schedule.KindContact:
+		if payload.DisplayName == "" || payload.VCard == "" {
+			return nil, fmt.Errorf("displayName and vcard are required")
+		}
+	}
+
+	if !payload.HasMedia() {
+		return payload, nil
+	}
+
+	media, err := utils.OpenUnifiedMedia(ctx, msg.Media, nil, payload.Kind)
+	if err != nil {
+		return nil, err
+	}
+	defer media.Remove()
+
+	if msg.MimeType != "" && payload.Kind != schedule.KindSticker {
+		if media.MimeType, err = utils.ValidateAndNormalizeMimeType(msg.MimeType, payload.Kind); err != nil {
+			return nil, err
+		}
+	}
+	if err := utils.ValidateMediaLength(media.Size, payload.Kind); err != nil {
+		return nil, err
+	}
+
+	payload.MediaPath = media.Path
+	payload.MimeType = media.MimeType
+	if payload.Kind == schedule.KindDocument {
+		payload.FileName = utils.DocumentFileName(payload.FileName, media.MimeType)
+	}
+	if err := storePayloadMedia(payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+
+// storePayloadMedia keeps a copy of the payload media in the media directory, for payloads
+// stored to be sent later. Media held in memory is written there as well.
+func storePayloadMedia(payload *schedule.Payload) error {
+	if !payload.HasMedia() {
+		return nil
+	}
+
+	var err error
+	if payload.MediaPath != "" {
+		payload.MediaPath, err = utils.StoreMedia(payload.MediaPath)
+	} else {
+		payload.MediaPath, err = utils.StoreMediaData(payload.Media)
+	}
+	if err != nil {
+		return err
+	}
+	payload.Media = nil
+	return nil
+}
@@ -46,6 +46,9 @@ func (h *WebhookHandler) resolveSessionID(c *gin.Context) (string, bool) {
 // Supported event types for webhooks
 var supportedEventTypes = []string{
 	"message",
+	"message.normalized",
+	"message.status",
+	"status_update",
 	"status",
 	"presence",
 	"typing",
@@ -57,6 +60,9 @@ var supportedEventTypes = []string{
 	"reaction",
 	"edit",
 	"delete",
+	"history_sync",
+	"scheduled_message",
+	"broadcast",
 }
 
 // Helper function to check if an event type is supported
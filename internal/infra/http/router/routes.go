@@ -20,6 +20,12 @@ func SetupRoutes(
 	webhookHandler *handler.WebhookHandler,
 	userHandler *handler.UserHandler,
 	newsletterHandler *handler.NewsletterHandler,
+	messageHandler *handler.MessageHandler,
+	retentionHandler *handler.RetentionHandler,
+	exportHandler *handler.ExportHandler,
+	scheduleHandler *handler.ScheduleHandler,
+	broadcastHandler *handler.BroadcastHandler,
+	templateHandler *handler.TemplateHandler,
 ) {
 
 	router.Use(middleware.CORS())
@@ -42,9 +48,24 @@ func SetupRoutes(
 		sessionGroup.GET("/:id/qr", sessionHandler.GetSessionQR)
 		sessionGroup.POST("/:id/pair", sessionHandler.PairSession)
 		sessionGroup.GET("/:id/status", sessionHandler.GetSessionStatus)
+		sessionGroup.POST("/:id/history", sessionHandler.RequestHistorySync)
 		sessionGroup.GET("/:id/history", sessionHandler.RequestHistorySync)
+		sessionGroup.GET("/:id/history/:jobId", sessionHandler.GetHistorySyncJob)
 		sessionGroup.POST("/:id/proxy/set", sessionHandler.SetProxy)
 		sessionGroup.GET("/:id/proxy/find", sessionHandler.GetProxy)
+		sessionGroup.POST("/:id/media-fetch/set", sessionHandler.SetMediaFetchPolicy)
+		sessionGroup.GET("/:id/media-fetch/find", sessionHandler.GetMediaFetchPolicy)
+	}
+
+
+	// Shared message templates, available to every session
+	templateGroup := router.Group("/templates")
+	{
+		templateGroup.POST("", templateHandler.CreateTemplate)
+		templateGroup.GET("", templateHandler.ListTemplates)
+		templateGroup.GET("/:id", templateHandler.GetTemplate)
+		templateGroup.PUT("/:id", templateHandler.UpdateTemplate)
+		templateGroup.DELETE("/:id", templateHandler.DeleteTemplate)
 	}
 
 
@@ -65,6 +86,14 @@ func SetupRoutes(
 			sendGroup.POST("/buttons", sendHandler.SendButtons)
 			sendGroup.POST("/list", sendHandler.SendList)
 			sendGroup.POST("/poll", sendHandler.SendPoll)
+			sendGroup.POST("/template", sendHandler.SendTemplate)
+			sendGroup.POST("/album", sendHandler.SendAlbum)
+		}
+
+
+		statusGroup := sessionAPIGroup.Group("/status")
+		{
+			statusGroup.POST("/send", sendHandler.SendStatus)
 		}
 
 
@@ -75,6 +104,7 @@ func SetupRoutes(
 			chatGroup.POST("/react", chatHandler.React)
 			chatGroup.POST("/delete", chatHandler.Delete)
 			chatGroup.POST("/edit", chatHandler.Edit)
+			chatGroup.POST("/forward", chatHandler.Forward)
 			chatGroup.POST("/download/image", chatHandler.DownloadImage)
 			chatGroup.POST("/download/video", chatHandler.DownloadVideo)
 			chatGroup.POST("/download/audio", chatHandler.DownloadAudio)
@@ -111,6 +141,70 @@ func SetupRoutes(
 			userGroup.GET("/contacts", userHandler.GetContacts)
 		}
 
+		// Chat history routes
+		chatsGroup := sessionAPIGroup.Group("/chats")
+		{
+			chatsGroup.GET("/:jid/messages", messageHandler.ListChatMessages)
+		}
+
+		// Retention routes
+		retentionGroup := sessionAPIGroup.Group("/retention")
+		{
+			retentionGroup.GET("", retentionHandler.GetPolicy)
+			retentionGroup.PUT("", retentionHandler.SetPolicy)
+			retentionGroup.DELETE("", retentionHandler.DeletePolicy)
+			retentionGroup.POST("/preview", retentionHandler.PreviewPolicy)
+		}
+
+		// Message search, status and media routes
+		messagesGroup := sessionAPIGroup.Group("/messages")
+		{
+			messagesGroup.GET("/search", messageHandler.SearchMessages)
+			messagesGroup.GET("/search/language", messageHandler.GetSearchLanguage)
+			messagesGroup.PUT("/search/language", messageHandler.SetSearchLanguage)
+			messagesGroup.GET("/:id/status", messageHandler.GetMessageStatus)
+			messagesGroup.GET("/:id/media", messageHandler.GetMessageMedia)
+		}
+
+		// Transcript export routes
+		exportsGroup := sessionAPIGroup.Group("/exports")
+		{
+			exportsGroup.POST("", exportHandler.CreateExport)
+			exportsGroup.GET("/:jobId", exportHandler.GetExport)
+			exportsGroup.GET("/:jobId/download", exportHandler.DownloadExport)
+		}
+
+		// Scheduled message routes
+		scheduledGroup := sessionAPIGroup.Group("/scheduled")
+		{
+			scheduledGroup.GET("", scheduleHandler.ListScheduled)
+			scheduledGroup.GET("/:id", scheduleHandler.GetScheduled)
+			scheduledGroup.PATCH("/:id", scheduleHandler.UpdateScheduled)
+			scheduledGroup.DELETE("/:id", scheduleHandler.CancelScheduled)
+		}
+
+		// Message template routes
+		sessionTemplateGroup := sessionAPIGroup.Group("/templates")
+		{
+			sessionTemplateGroup.POST("", templateHandler.CreateTemplate)
+			sessionTemplateGroup.GET("", templateHandler.ListTemplates)
+			sessionTemplateGroup.GET("/:id", templateHandler.GetTemplate)
+			sessionTemplateGroup.PUT("/:id", templateHandler.UpdateTemplate)
+			sessionTemplateGroup.DELETE("/:id", templateHandler.DeleteTemplate)
+		}
+
+		// Broadcast routes
+		broadcastGroup := sessionAPIGroup.Group("/broadcast")
+		{
+			broadcastGroup.POST("", broadcastHandler.CreateBroadcast)
+			broadcastGroup.GET("", broadcastHandler.ListBroadcasts)
+			broadcastGroup.GET("/:id", broadcastHandler.GetBroadcast)
+			broadcastGroup.GET("/:id/recipients", broadcastHandler.ListRecipients)
+			broadcastGroup.POST("/:id/pause", broadcastHandler.PauseBroadcast)
+			broadcastGroup.POST("/:id/resume", broadcastHandler.ResumeBroadcast)
+			broadcastGroup.POST("/:id/cancel", broadcastHandler.CancelBroadcast)
+		}
+
 		// Newsletter routes
 		newsletterGroup := sessionAPIGroup.Group("/newsletter")
 		{
@@ -0,0 +1,162 @@
+package janitor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"zpmeow/internal/domain/idempotency"
+	"zpmeow/internal/domain/retention"
+	"zpmeow/internal/infra/logger"
+	"zpmeow/internal/utils"
+)
+
+// storedMediaGrace spares stored media younger than this, which may belong to a message being saved
+const storedMediaGrace = time.Hour
+
+// ExportPurger deletes chat exports past their retention
+type ExportPurger interface {
+	PurgeExpired(ctx context.Context) (int, error)
+}
+
+// MediaReferences lists the stored media files still needed by scheduled messages, broadcasts and
+// templates
+type MediaReferences interface {
+	ReferencedMedia(ctx context.Context) ([]string, error)
+}
+
+// Janitor periodically applies the retention policies of all sessions and drops expired idempotency keys,
+// exports and stored media no longer needed
+type Janitor struct {
+	retentionService   retention.RetentionService
+	idempotencyService idempotency.IdempotencyService
+	exports            ExportPurger
+	media              MediaReferences
+	interval           time.Duration
+	logger             logger.Logger
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// NewJanitor creates a janitor that runs every interval
+func NewJanitor(retentionService retention.RetentionService, idempotencyService idempotency.IdempotencyService, exports ExportPurger, media MediaReferences, interval time.Duration) *Janitor {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	return &Janitor{
+		retentionService:   retentionService,
+		idempotencyService: idempotencyService,
+		exports:            exports,
+		media:              media,
+		interval:           interval,
+		logger:             logger.GetLogger().Sub("janitor"),
+	}
+}
+
+// Start runs the janitor in the background until Stop is called or ctx is done
+func (j *Janitor) Start(ctx context.Context) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.cancel != nil {
+		return
+	}
+
+	ctx, j.cancel = context.WithCancel(ctx)
+	go j.loop(ctx)
+
+	j.logger.Infof("Retention janitor started (interval %s)", j.interval)
+}
+
+// Stop stops the background loop
+func (j *Janitor) Stop() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.cancel != nil {
+		j.cancel()
+		j.cancel = nil
+	}
+}
+
+func (j *Janitor) loop(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce purges expired data of every session, expired idempotency keys and exports, and stored media
+// no longer needed, and logs what was removed
+func (j *Janitor) RunOnce(ctx context.Context) {
+	start := time.Now()
+
+	reports, err := j.retentionService.PurgeAll(ctx)
+	if err != nil {
+		j.logger.Errorf("Retention purge failed: %v", err)
+	}
+
+	var messages, media, receipts int64
+	for _, report := range reports {
+		if report.Total() == 0 {
+			continue
+		}
+		j.logger.Infof("Session %s: purged %d messages, %d media, %d receipts",
+			report.SessionID, report.Messages, report.Media, report.Receipts)
+		messages += report.Messages
+		media += report.Media
+		receipts += report.Receipts
+	}
+
+	j.logger.Debugf("Retention purge finished in %s: %d messages, %d media, %d receipts",
+		time.Since(start), messages, media, receipts)
+
+	keys, err := j.idempotencyService.PurgeExpired(ctx)
+	if err != nil {
+		j.logger.Errorf("Idempotency key purge failed: %v", err)
+	} else if keys > 0 {
+		j.logger.Debugf("Purged %d expired idempotency keys", keys)
+	}
+
+	if j.exports != nil {
+		exports, err := j.exports.PurgeExpired(ctx)
+		if err != nil {
+			j.logger.Errorf("Export purge failed: %v", err)
+		} else if exports > 0 {
+			j.logger.Infof("Purged %d expired exports", exports)
+		}
+	}
+
+	if j.media != nil {
+		files, err := j.purgeStoredMedia(ctx)
+		if err != nil {
+			j.logger.Errorf("Stored media purge failed: %v", err)
+		} else if files > 0 {
+			j.logger.Infof("Purged %d stored media files no longer needed", files)
+		}
+	}
+}
+
+// purgeStoredMedia deletes the files of the media directory that no message refers to
+func (j *Janitor) purgeStoredMedia(ctx context.Context) (int, error) {
+	paths, err := j.media.ReferencedMedia(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	keep := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		keep[path] = true
+	}
+	return utils.PurgeStoredMedia(keep, time.Now().Add(-storedMediaGrace))
+}
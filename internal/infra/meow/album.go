@@ -0,0 +1,91 @@
+package meow
+
+import (
+	"context"
+	"fmt"
+
+	"zpmeow/internal/types"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waCommon"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	waTypes "go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+
+// AlbumMedia is an image or video to send as part of an album, spooled to the file at Path. Each
+// file is read only while its item is prepared, so an album never holds all its media in memory.
+type AlbumMedia struct {
+	Kind     string
+	Path     string
+	Caption  string
+	MimeType string
+}
+
+
+// SendAlbum sends media grouped as an album. See MeowClient.SendAlbum.
+func (m *MeowServiceImpl) SendAlbum(ctx context.Context, sessionID, to string, items []AlbumMedia) (*whatsmeow.SendResponse, []types.AlbumItemResult, error) {
+	client, jid, err := m.validateAndGetClient(sessionID, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.SendAlbum(ctx, jid, items)
+}
+
+
+// SendAlbum uploads every item, then sends the album message announcing how many images and
+// videos it groups, followed by each item associated with it. Nothing is sent when an upload
+// fails; once the album message is out, an item that fails to send is reported in its result
+// and the remaining items are still sent.
+func (mc *MeowClient) SendAlbum(ctx context.Context, to waTypes.JID, items []AlbumMedia) (*whatsmeow.SendResponse, []types.AlbumItemResult, error) {
+	children := make([]*waE2E.Message, len(items))
+	var images, videos uint32
+	for i, item := range items {
+		var err error
+		switch item.Kind {
+		case "image":
+			children[i], err = mc.prepareImageMessage(ctx, item.Path, item.Caption, item.MimeType, nil, false)
+			images++
+		case "video":
+			children[i], err = mc.prepareVideoMessage(ctx, item.Path, item.Caption, item.MimeType, nil, false, false)
+			videos++
+		default:
+			err = fmt.Errorf("unsupported album item type %q", item.Kind)
+		}
+		if err != nil {
+			return nil, nil, Error.WrapError(err, fmt.Sprintf("failed to prepare album item %d", i))
+		}
+	}
+
+	album, err := mc.sendAndStore(ctx, to, MsgBuilder.BuildAlbumMessage(images, videos, nil))
+	if err != nil {
+		return nil, nil, Error.WrapError(err, "failed to send album message")
+	}
+	parent := &waCommon.MessageKey{
+		RemoteJID: proto.String(to.String()),
+		FromMe:    proto.Bool(true),
+		ID:        proto.String(string(album.ID)),
+	}
+
+	// A message ID requested for the send belongs to the album message, so the items get their own
+	itemCtx := WithMessageID(ctx, "")
+
+	results := make([]types.AlbumItemResult, len(children))
+	for i, msg := range children {
+		results[i] = types.AlbumItemResult{Index: i, Type: items[i].Kind}
+		MsgBuilder.AddToAlbum(msg, parent)
+
+		resp, err := mc.sendAndStore(itemCtx, to, msg)
+		if err != nil {
+			mc.logger.Warnf("Failed to send item %d of album %s to %s: %v", i, album.ID, to, err)
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Success = true
+		results[i].ID = string(resp.ID)
+		results[i].Timestamp = resp.Timestamp.Unix()
+	}
+
+	return album, results, nil
+}
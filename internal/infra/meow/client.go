@@ -8,10 +8,13 @@ import (
 	"sync"
 	"time"
 
+	"zpmeow/internal/domain/message"
+	"zpmeow/internal/domain/schedule"
 	"zpmeow/internal/domain/session"
 	"zpmeow/internal/infra/logger"
 	"zpmeow/internal/infra/webhook"
 	"zpmeow/internal/types"
+	"zpmeow/internal/utils"
 
 	"github.com/mdp/qrterminal/v3"
 	"go.mau.fi/whatsmeow"
@@ -30,6 +33,10 @@ type MeowClient struct {
 	logger       logger.Logger
 	waLogger     waLog.Logger
 
+	messageService message.MessageService
+	recent         *recentMessages
+	uploads        *uploadCache
+
 	
 	mu           sync.RWMutex
 	status       types.Status
@@ -53,7 +60,7 @@ type MeowClient struct {
 }
 
 
-func NewMeowClient(sessionID string, deviceStore *store.Device, waLogger waLog.Logger, manager *ClientManager, webhookService *webhook.WebhookService, sessionService session.SessionService) (*MeowClient, error) {
+func NewMeowClient(sessionID string, deviceStore *store.Device, waLogger waLog.Logger, manager *ClientManager, webhookService *webhook.WebhookService, sessionService session.SessionService, messageService message.MessageService) (*MeowClient, error) {
 	if waLogger == nil {
 		waLogger = waLog.Noop
 	}
@@ -61,6 +68,9 @@ func NewMeowClient(sessionID string, deviceStore *store.Device, waLogger waLog.L
 	appLogger := logger.GetLogger().Sub("meow-client").Sub(sessionID)
 
 	
+	if _, wrapped := deviceStore.Contacts.(*statusAudienceContacts); !wrapped {
+		deviceStore.Contacts = &statusAudienceContacts{ContactStore: deviceStore.Contacts}
+	}
 	waClient := whatsmeow.NewClient(deviceStore, waLogger)
 
 	
@@ -79,10 +89,14 @@ func NewMeowClient(sessionID string, deviceStore *store.Device, waLogger waLog.L
 		qrStopChannel: make(chan bool, 1),
 		ctx:           ctx,
 		cancel:        cancel,
+
+		messageService: messageService,
+		recent:         newRecentMessages(recentMessageLimit),
+		uploads:        newUploadCache(),
 	}
 
 	
-	eventHandler := NewEventHandler(sessionID, waLogger, meowClient, webhookService, sessionService)
+	eventHandler := NewEventHandler(sessionID, waLogger, meowClient, webhookService, sessionService, messageService)
 	meowClient.eventHandler = eventHandler
 
 	
@@ -452,18 +466,63 @@ func (mc *MeowClient) GetClient() *whatsmeow.Client {
 }
 
 
-func (mc *MeowClient) SendTextMessage(ctx context.Context, to waTypes.JID, text string, contextInfo *waE2E.ContextInfo) (*whatsmeow.SendResponse, error) {
+// RequestHistorySync sends an on-demand history request to the primary device. The
+// response arrives asynchronously as an events.HistorySync of type ON_DEMAND.
+func (mc *MeowClient) RequestHistorySync(ctx context.Context, anchor *waTypes.MessageInfo, count int) error {
+	if mc.client.Store.ID == nil {
+		return fmt.Errorf("client not logged in for session %s", mc.sessionID)
+	}
+
+	msg := mc.client.BuildHistorySyncRequest(anchor, count)
+	_, err := mc.client.SendMessage(ctx, mc.client.Store.ID.ToNonAD(), msg, whatsmeow.SendRequestExtra{Peer: true})
+	if err != nil {
+		return err
+	}
+
+	mc.updateActivity()
+	return nil
+}
+
+
+// sendAndStore sends a message and records it in the message store so it shows up in chat history.
+func (mc *MeowClient) sendAndStore(ctx context.Context, to waTypes.JID, msg *waE2E.Message) (*whatsmeow.SendResponse, error) {
+	sender := NewMessageSender(mc.client)
+	resp, err := sender.SendMessage(ctx, to, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	mc.storeMessage(NewOutgoingMessage(mc.sessionID, to, msg, resp))
+	return resp, nil
+}
+
+
+func (mc *MeowClient) storeMessage(stored *message.Message) {
+	mc.recent.Add(stored)
+	if mc.messageService == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := mc.messageService.SaveMessage(ctx, stored); err != nil {
+		mc.logger.Warnf("Failed to store message %s for session %s: %v", stored.ID, mc.sessionID, err)
+	}
+}
+
+
+func (mc *MeowClient) SendTextMessage(ctx context.Context, to waTypes.JID, text string, contextInfo *waE2E.ContextInfo, preview *schedule.LinkPreview) (*whatsmeow.SendResponse, error) {
 	mc.logger.Infof("DEBUG: MeowClient.SendTextMessage called - to: %s, text: %s", to.String(), text)
 
 
 	mc.logger.Infof("DEBUG: Skipping connection validation to avoid deadlock")
 
 	mc.logger.Infof("DEBUG: Creating message...")
-	msg := MsgBuilder.BuildTextMessage(text, contextInfo)
+	msg := MsgBuilder.BuildTextMessage(text, contextInfo, preview)
 
 	mc.logger.Infof("DEBUG: Calling whatsmeow client.SendMessage...")
-	sender := NewMessageSender(mc.client)
-	resp, err := sender.SendMessage(ctx, to, msg)
+	resp, err := mc.sendAndStore(ctx, to, msg)
 	if err != nil {
 		mc.logger.Errorf("DEBUG: whatsmeow client.SendMessage failed: %v", err)
 		return nil, Error.WrapError(err, "failed to send text message")
@@ -476,14 +535,13 @@ func (mc *MeowClient) SendTextMessage(ctx context.Context, to waTypes.JID, text
 }
 
 
-func (mc *MeowClient) SendLocationMessage(ctx context.Context, to waTypes.JID, latitude, longitude float64, name, address string) (*whatsmeow.SendResponse, error) {
+func (mc *MeowClient) SendLocationMessage(ctx context.Context, to waTypes.JID, latitude, longitude float64, name, address string, contextInfo *waE2E.ContextInfo) (*whatsmeow.SendResponse, error) {
 
 	mc.logger.Infof("DEBUG: Skipping connection validation to avoid deadlock")
 
-	msg := MsgBuilder.BuildLocationMessage(latitude, longitude, name, address)
-	sender := NewMessageSender(mc.client)
+	msg := MsgBuilder.BuildLocationMessage(latitude, longitude, name, address, contextInfo)
 
-	resp, err := sender.SendMessage(ctx, to, msg)
+	resp, err := mc.sendAndStore(ctx, to, msg)
 	if err != nil {
 		return nil, Error.WrapError(err, "failed to send location message")
 	}
@@ -493,14 +551,13 @@ func (mc *MeowClient) SendLocationMessage(ctx context.Context, to waTypes.JID, l
 }
 
 
-func (mc *MeowClient) SendContactMessage(ctx context.Context, to waTypes.JID, displayName, vcard string) (*whatsmeow.SendResponse, error) {
+func (mc *MeowClient) SendContactMessage(ctx context.Context, to waTypes.JID, displayName, vcard string, contextInfo *waE2E.ContextInfo) (*whatsmeow.SendResponse, error) {
 
 	mc.logger.Infof("DEBUG: Skipping connection validation to avoid deadlock")
 
-	msg := MsgBuilder.BuildContactMessage(displayName, vcard)
-	sender := NewMessageSender(mc.client)
+	msg := MsgBuilder.BuildContactMessage(displayName, vcard, contextInfo)
 
-	resp, err := sender.SendMessage(ctx, to, msg)
+	resp, err := mc.sendAndStore(ctx, to, msg)
 	if err != nil {
 		return nil, Error.WrapError(err, "failed to send contact message")
 	}
@@ -848,43 +905,61 @@ func (mc *MeowClient) GetSessionID() string {
 }
 
 
-func (mc *MeowClient) SendImageMessage(ctx context.Context, to waTypes.JID, imageData []byte, caption string, mimeType string) (*whatsmeow.SendResponse, error) {
+// SendImageFile sends the image file at path, uploading it without loading it into memory.
+func (mc *MeowClient) SendImageFile(ctx context.Context, to waTypes.JID, path, caption, mimeType string, contextInfo *waE2E.ContextInfo, viewOnce bool) (*whatsmeow.SendResponse, error) {
+	msg, err := mc.prepareImageMessage(ctx, path, caption, mimeType, contextInfo, viewOnce)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := mc.sendAndStore(ctx, to, msg)
+	if err != nil {
+		return nil, Error.WrapError(err, "failed to send image message")
+	}
+
 
-	mc.logger.Infof("DEBUG: Skipping connection validation to avoid deadlock")
+	return resp, nil
+}
 
 
-	uploader := NewMediaUploader(mc.client)
-	uploaded, err := uploader.UploadMedia(ctx, imageData, whatsmeow.MediaImage)
+// prepareImageMessage uploads the image file at path and builds its message, with the size and
+// thumbnail read from the image.
+func (mc *MeowClient) prepareImageMessage(ctx context.Context, path, caption, mimeType string, contextInfo *waE2E.ContextInfo, viewOnce bool) (*waE2E.Message, error) {
+	uploader := NewMediaUploader(mc.client, mc.uploads)
+	uploaded, err := uploader.UploadFile(ctx, path, whatsmeow.MediaImage)
 	if err != nil {
 		return nil, Error.WrapError(err, "failed to upload image")
 	}
 
-
 	params := MediaMessageParams{
 		UploadResponse: uploaded,
 		Caption:        caption,
 		MimeType:       mimeType,
+		ContextInfo:    contextInfo,
+		ViewOnce:       viewOnce,
 	}
-	msg := MsgBuilder.BuildImageMessage(params)
-
-	sender := NewMessageSender(mc.client)
-	resp, err := sender.SendMessage(ctx, to, msg)
-	if err != nil {
-		return nil, Error.WrapError(err, "failed to send image message")
+	if info, err := utils.ReadImageInfo(path); err != nil {
+		mc.logger.Debugf("No thumbnail for image: %v", err)
+	} else {
+		params.Width, params.Height = uint32(info.Width), uint32(info.Height)
+		params.Thumbnail = info.Thumbnail
 	}
-
-
-	return resp, nil
+	return MsgBuilder.BuildImageMessage(params), nil
 }
 
 
-func (mc *MeowClient) SendAudioMessage(ctx context.Context, to waTypes.JID, audioData []byte, mimeType string) (*whatsmeow.SendResponse, error) {
-
-	mc.logger.Infof("DEBUG: Skipping connection validation to avoid deadlock")
+// SendAudioFile sends the audio file at path as a voice note when ptt is set, or as a regular
+// audio file.
+func (mc *MeowClient) SendAudioFile(ctx context.Context, to waTypes.JID, path, mimeType string, ptt bool, contextInfo *waE2E.ContextInfo, viewOnce bool) (*whatsmeow.SendResponse, error) {
+	audio, err := utils.PrepareAudio(ctx, path, mimeType, ptt)
+	if err != nil {
+		return nil, Error.WrapError(err, "failed to prepare audio")
+	}
+	defer audio.Remove()
 
 
-	uploader := NewMediaUploader(mc.client)
-	uploaded, err := uploader.UploadMedia(ctx, audioData, whatsmeow.MediaAudio)
+	uploader := NewMediaUploader(mc.client, mc.uploads)
+	uploaded, err := uploader.UploadFile(ctx, audio.Path, whatsmeow.MediaAudio)
 	if err != nil {
 		return nil, Error.WrapError(err, "failed to upload audio")
 	}
@@ -892,18 +967,16 @@ func (mc *MeowClient) SendAudioMessage(ctx context.Context, to waTypes.JID, audi
 
 	params := MediaMessageParams{
 		UploadResponse: uploaded,
-		MimeType:       func() string {
-			if mimeType != "" {
-				return mimeType
-			}
-			return "audio/ogg; codecs=opus"
-		}(),
+		MimeType:       audio.MimeType,
+		ContextInfo:    contextInfo,
+		ViewOnce:       viewOnce,
+		Seconds:        audio.Seconds,
+		Waveform:       audio.Waveform,
 	}
 
-	msg := MsgBuilder.BuildAudioMessage(params, true) // PTT = true
-	sender := NewMessageSender(mc.client)
+	msg := MsgBuilder.BuildAudioMessage(params, audio.PTT)
 
-	resp, err := sender.SendMessage(ctx, to, msg)
+	resp, err := mc.sendAndStore(ctx, to, msg)
 	if err != nil {
 		return nil, Error.WrapError(err, "failed to send audio message")
 	}
@@ -913,28 +986,29 @@ func (mc *MeowClient) SendAudioMessage(ctx context.Context, to waTypes.JID, audi
 }
 
 
-func (mc *MeowClient) SendDocumentMessage(ctx context.Context, to waTypes.JID, documentData []byte, filename, caption, mimeType string) (*whatsmeow.SendResponse, error) {
-
-	mc.logger.Infof("DEBUG: Skipping connection validation to avoid deadlock")
-
-
-	uploader := NewMediaUploader(mc.client)
-	uploaded, err := uploader.UploadMedia(ctx, documentData, whatsmeow.MediaDocument)
+// SendDocumentFile sends a document kept on disk, uploading it without loading it into memory.
+func (mc *MeowClient) SendDocumentFile(ctx context.Context, to waTypes.JID, path, filename, caption, mimeType string, contextInfo *waE2E.ContextInfo) (*whatsmeow.SendResponse, error) {
+	uploader := NewMediaUploader(mc.client, mc.uploads)
+	uploaded, err := uploader.UploadFile(ctx, path, whatsmeow.MediaDocument)
 	if err != nil {
 		return nil, Error.WrapError(err, "failed to upload document")
 	}
 
-
+	info := utils.ReadDocumentFileInfo(ctx, path, mimeType)
 	params := MediaMessageParams{
 		UploadResponse: uploaded,
 		Caption:        caption,
 		MimeType:       mimeType,
-		FileName:       filename,
+		FileName:       utils.DocumentFileName(filename, mimeType),
+		ContextInfo:    contextInfo,
+		PageCount:      info.PageCount,
+		Thumbnail:      info.Thumbnail,
+		ThumbWidth:     uint32(info.ThumbnailWidth),
+		ThumbHeight:    uint32(info.ThumbnailHeight),
 	}
 	msg := MsgBuilder.BuildDocumentMessage(params)
 
-	sender := NewMessageSender(mc.client)
-	resp, err := sender.SendMessage(ctx, to, msg)
+	resp, err := mc.sendAndStore(ctx, to, msg)
 	if err != nil {
 		return nil, Error.WrapError(err, "failed to send document message")
 	}
@@ -944,42 +1018,54 @@ func (mc *MeowClient) SendDocumentMessage(ctx context.Context, to waTypes.JID, d
 }
 
 
-func (mc *MeowClient) SendVideoMessage(ctx context.Context, to waTypes.JID, videoData []byte, caption, mimeType string) (*whatsmeow.SendResponse, error) {
+// SendVideoFile sends the video file at path, uploading it without loading it into memory.
+func (mc *MeowClient) SendVideoFile(ctx context.Context, to waTypes.JID, path, caption, mimeType string, contextInfo *waE2E.ContextInfo, viewOnce, gifPlayback bool) (*whatsmeow.SendResponse, error) {
+	msg, err := mc.prepareVideoMessage(ctx, path, caption, mimeType, contextInfo, viewOnce, gifPlayback)
+	if err != nil {
+		return nil, err
+	}
 
-	mc.logger.Infof("DEBUG: Skipping connection validation to avoid deadlock")
+	resp, err := mc.sendAndStore(ctx, to, msg)
+	if err != nil {
+		return nil, Error.WrapError(err, "failed to send video message")
+	}
 
 
-	uploader := NewMediaUploader(mc.client)
-	uploaded, err := uploader.UploadMedia(ctx, videoData, whatsmeow.MediaVideo)
+	return resp, nil
+}
+
+
+// prepareVideoMessage uploads the video file at path and builds its message, with the duration,
+// size and thumbnail read from the video.
+func (mc *MeowClient) prepareVideoMessage(ctx context.Context, path, caption, mimeType string, contextInfo *waE2E.ContextInfo, viewOnce, gifPlayback bool) (*waE2E.Message, error) {
+	uploader := NewMediaUploader(mc.client, mc.uploads)
+	uploaded, err := uploader.UploadFile(ctx, path, whatsmeow.MediaVideo)
 	if err != nil {
 		return nil, Error.WrapError(err, "failed to upload video")
 	}
 
-
-	params := MediaMessageParams{
+	info := utils.ReadVideoInfo(ctx, path)
+	return MsgBuilder.BuildVideoMessage(MediaMessageParams{
 		UploadResponse: uploaded,
 		Caption:        caption,
 		MimeType:       mimeType,
-	}
-	msg := MsgBuilder.BuildVideoMessage(params)
-
-	sender := NewMessageSender(mc.client)
-	resp, err := sender.SendMessage(ctx, to, msg)
-	if err != nil {
-		return nil, Error.WrapError(err, "failed to send video message")
-	}
-
-
-	return resp, nil
+		ContextInfo:    contextInfo,
+		ViewOnce:       viewOnce,
+		Seconds:        info.Seconds,
+		Width:          uint32(info.Width),
+		Height:         uint32(info.Height),
+		Thumbnail:      info.Thumbnail,
+		GifPlayback:    gifPlayback,
+	}), nil
 }
 
 
-func (mc *MeowClient) SendStickerMessage(ctx context.Context, to waTypes.JID, stickerData []byte, mimeType string) (*whatsmeow.SendResponse, error) {
+func (mc *MeowClient) SendStickerMessage(ctx context.Context, to waTypes.JID, stickerData []byte, mimeType string, contextInfo *waE2E.ContextInfo) (*whatsmeow.SendResponse, error) {
 
 	mc.logger.Infof("DEBUG: Skipping connection validation to avoid deadlock")
 
 
-	uploader := NewMediaUploader(mc.client)
+	uploader := NewMediaUploader(mc.client, mc.uploads)
 	uploaded, err := uploader.UploadMedia(ctx, stickerData, whatsmeow.MediaImage)
 	if err != nil {
 		return nil, Error.WrapError(err, "failed to upload sticker")
@@ -989,11 +1075,17 @@ func (mc *MeowClient) SendStickerMessage(ctx context.Context, to waTypes.JID, st
 	params := MediaMessageParams{
 		UploadResponse: uploaded,
 		MimeType:       mimeType,
+		ContextInfo:    contextInfo,
+	}
+	if info, err := utils.ReadStickerInfo(stickerData); err != nil {
+		mc.logger.Debugf("No sticker info for sticker to %s: %v", to, err)
+	} else {
+		params.Width, params.Height = uint32(info.Width), uint32(info.Height)
+		params.IsAnimated = info.IsAnimated
 	}
 	msg := MsgBuilder.BuildStickerMessage(params)
 
-	sender := NewMessageSender(mc.client)
-	resp, err := sender.SendMessage(ctx, to, msg)
+	resp, err := mc.sendAndStore(ctx, to, msg)
 	if err != nil {
 		return nil, Error.WrapError(err, "failed to send sticker message")
 	}
@@ -1003,15 +1095,14 @@ func (mc *MeowClient) SendStickerMessage(ctx context.Context, to waTypes.JID, st
 }
 
 
-func (mc *MeowClient) SendButtonsMessage(ctx context.Context, to waTypes.JID, text string, buttons []types.Button, footer string) (*whatsmeow.SendResponse, error) {
+func (mc *MeowClient) SendButtonsMessage(ctx context.Context, to waTypes.JID, text string, buttons []types.Button, footer string, contextInfo *waE2E.ContextInfo) (*whatsmeow.SendResponse, error) {
 
 	mc.logger.Infof("DEBUG: Skipping connection validation to avoid deadlock")
 
 
-	msg := MsgBuilder.BuildButtonsMessage(text, buttons, footer)
+	msg := MsgBuilder.BuildButtonsMessage(text, buttons, footer, contextInfo)
 
-	sender := NewMessageSender(mc.client)
-	resp, err := sender.SendMessage(ctx, to, msg)
+	resp, err := mc.sendAndStore(ctx, to, msg)
 	if err != nil {
 		return nil, Error.WrapError(err, "failed to send buttons message")
 	}
@@ -1021,15 +1112,14 @@ func (mc *MeowClient) SendButtonsMessage(ctx context.Context, to waTypes.JID, te
 }
 
 
-func (mc *MeowClient) SendListMessage(ctx context.Context, to waTypes.JID, text, buttonText string, sections []types.Section, footer string) (*whatsmeow.SendResponse, error) {
+func (mc *MeowClient) SendListMessage(ctx context.Context, to waTypes.JID, text, buttonText string, sections []types.Section, footer string, contextInfo *waE2E.ContextInfo) (*whatsmeow.SendResponse, error) {
 
 	mc.logger.Infof("DEBUG: Skipping connection validation to avoid deadlock")
 
 
-	msg := MsgBuilder.BuildListMessage(text, buttonText, sections, footer)
+	msg := MsgBuilder.BuildListMessage(text, buttonText, sections, footer, contextInfo)
 
-	sender := NewMessageSender(mc.client)
-	resp, err := sender.SendMessage(ctx, to, msg)
+	resp, err := mc.sendAndStore(ctx, to, msg)
 	if err != nil {
 		return nil, Error.WrapError(err, "failed to send list message")
 	}
@@ -1039,18 +1129,17 @@ func (mc *MeowClient) SendListMessage(ctx context.Context, to waTypes.JID, text,
 }
 
 
-func (mc *MeowClient) SendPollMessage(ctx context.Context, to waTypes.JID, name string, options []string, selectableCount int) (*whatsmeow.SendResponse, error) {
+func (mc *MeowClient) SendPollMessage(ctx context.Context, to waTypes.JID, name string, options []string, selectableCount int, contextInfo *waE2E.ContextInfo) (*whatsmeow.SendResponse, error) {
 	mc.logger.Infof("DEBUG: MeowClient.SendPollMessage called - to: %s, name: %s", to.String(), name)
 
 
 	mc.logger.Infof("DEBUG: Skipping connection validation to avoid deadlock")
 
 	mc.logger.Infof("DEBUG: Building poll message...")
-	msg := MsgBuilder.BuildPollMessage(name, options, selectableCount)
+	msg := MsgBuilder.BuildPollMessage(name, options, selectableCount, contextInfo)
 
 	mc.logger.Infof("DEBUG: Calling whatsmeow client.SendMessage...")
-	sender := NewMessageSender(mc.client)
-	resp, err := sender.SendMessage(ctx, to, msg)
+	resp, err := mc.sendAndStore(ctx, to, msg)
 	if err != nil {
 		mc.logger.Errorf("DEBUG: whatsmeow client.SendMessage failed: %v", err)
 		return nil, Error.WrapError(err, "failed to send poll message")
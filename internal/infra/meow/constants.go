@@ -19,6 +19,7 @@ const (
 	QRTimeout            = 2 * time.Minute
 	MessageTimeout       = 10 * time.Second
 	HistorySyncTimeout   = 30 * time.Second
+	HistorySyncRequestTimeout = 2 * time.Minute
 	ClientCleanupTimeout = 30 * time.Minute
 	ConnectionTimeout    = 60 * time.Second
 	ShutdownTimeout      = 10 * time.Second
@@ -0,0 +1,102 @@
+package meow
+
+import (
+	"context"
+
+	"zpmeow/internal/domain/message"
+	"zpmeow/internal/domain/schedule"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+
+// payloadContextInfo builds the ContextInfo of a payload from the message it replies to and the
+// people it mentions. It returns nil when the payload has neither.
+func (m *MeowServiceImpl) payloadContextInfo(ctx context.Context, sessionID string, p *schedule.Payload) (*waE2E.ContextInfo, error) {
+	mentioned, err := m.mentionedJIDs(ctx, sessionID, p)
+	if err != nil {
+		return nil, err
+	}
+
+	contextInfo := &waE2E.ContextInfo{}
+	if p.ReplyTo != nil && p.ReplyTo.StanzaID != "" {
+		if contextInfo, err = m.quoteContextInfo(ctx, sessionID, p); err != nil {
+			return nil, err
+		}
+	} else if len(mentioned) == 0 {
+		return nil, nil
+	}
+
+	contextInfo.MentionedJID = mentioned
+	return contextInfo, nil
+}
+
+
+// findMessage returns a message of the session from the recent messages kept in memory, or
+// else from the message store.
+func (m *MeowServiceImpl) findMessage(ctx context.Context, sessionID, id string) (*message.Message, error) {
+	if client, exists := m.clientManager.GetClient(sessionID); exists {
+		if msg, ok := client.recent.Get(id); ok {
+			return msg, nil
+		}
+	}
+	return m.messageService.GetMessage(ctx, sessionID, id)
+}
+
+
+// quoteContextInfo builds the ContextInfo that quotes the message a payload replies to. A reply
+// to a message that cannot be found is only sent when the caller named its participant.
+func (m *MeowServiceImpl) quoteContextInfo(ctx context.Context, sessionID string, p *schedule.Payload) (*waE2E.ContextInfo, error) {
+	reply := p.ReplyTo
+
+	quoted, err := m.findMessage(ctx, sessionID, reply.StanzaID)
+	if err == message.ErrMessageNotFound {
+		if reply.Participant == "" {
+			return nil, schedule.ErrQuotedMessageNotFound
+		}
+		return &waE2E.ContextInfo{
+			StanzaID:    proto.String(reply.StanzaID),
+			Participant: proto.String(reply.Participant),
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	participant := quoted.SenderJID
+	if participant == "" {
+		participant = quoted.ChatJID
+	}
+
+	contextInfo := &waE2E.ContextInfo{
+		StanzaID:      proto.String(quoted.ID),
+		Participant:   proto.String(participant),
+		QuotedMessage: quotedMessage(quoted),
+	}
+
+	// Quoting a message of another chat, such as a private reply to a group message
+	if to, ok := parseJID(p.Phone); ok && to.ToNonAD().String() != quoted.ChatJID {
+		contextInfo.RemoteJID = proto.String(quoted.ChatJID)
+	}
+
+	return contextInfo, nil
+}
+
+
+// quotedMessage rebuilds the content of a stored message for a quote. Messages whose payload was
+// purged are quoted by their text alone.
+func quotedMessage(stored *message.Message) *waE2E.Message {
+	if len(stored.Raw) > 0 {
+		var msg waE2E.Message
+		if err := proto.Unmarshal(stored.Raw, &msg); err == nil {
+			return &msg
+		}
+	}
+
+	text := stored.Text
+	if text == "" {
+		text = stored.Caption
+	}
+	return &waE2E.Message{Conversation: proto.String(text)}
+}
@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"time"
 
+	"zpmeow/internal/domain/message"
 	"zpmeow/internal/domain/session"
 	"zpmeow/internal/infra/logger"
 	"zpmeow/internal/infra/webhook"
 
+	"go.mau.fi/whatsmeow/proto/waHistorySync"
+	waTypes "go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
 	waLog "go.mau.fi/whatsmeow/util/log"
 )
@@ -21,6 +24,7 @@ type EventHandler struct {
 	client          *MeowClient
 	webhookService  *webhook.WebhookService
 	sessionService  session.SessionService
+	messageService  message.MessageService
 
 
 	messageCount    int64
@@ -28,7 +32,7 @@ type EventHandler struct {
 }
 
 
-func NewEventHandler(sessionID string, waLogger waLog.Logger, client *MeowClient, webhookService *webhook.WebhookService, sessionService session.SessionService) *EventHandler {
+func NewEventHandler(sessionID string, waLogger waLog.Logger, client *MeowClient, webhookService *webhook.WebhookService, sessionService session.SessionService, messageService message.MessageService) *EventHandler {
 	if waLogger == nil {
 		waLogger = waLog.Noop
 	}
@@ -42,6 +46,7 @@ func NewEventHandler(sessionID string, waLogger waLog.Logger, client *MeowClient
 		client:         client,
 		webhookService: webhookService,
 		sessionService: sessionService,
+		messageService: messageService,
 	}
 }
 
@@ -203,8 +208,24 @@ func (eh *EventHandler) processMessageWithTimeout(ctx context.Context, evt *even
 			eh.client.updateActivity()
 		}
 
-		
-		
+
+		stored := NewIncomingMessage(eh.sessionID, evt)
+		if eh.client != nil {
+			eh.client.recent.Add(stored)
+		}
+		if eh.messageService != nil {
+			if err := eh.messageService.SaveMessage(ctx, stored); err != nil {
+				eh.logger.Warnf("Session %s: Failed to store message %s: %v", eh.sessionID, evt.Info.ID, err)
+			}
+		}
+
+		// The message webhook keeps the raw event; the normalized model is a separate event.
+		// Status updates posted by contacts arrive as messages to the status broadcast list.
+		if evt.Info.Chat == waTypes.StatusBroadcastJID {
+			eh.sendWebhook("status_update", message.NewMessageDTO(stored))
+		} else {
+			eh.sendWebhook("message.normalized", message.NewMessageDTO(stored))
+		}
 
 		duration := time.Since(start)
 		eh.logger.Debugf("Session %s: Message processed successfully: %s (took %v)",
@@ -230,10 +251,68 @@ func (eh *EventHandler) handleReceipt(evt *events.Receipt) {
 
 		eh.logger.Debugf("Session %s: Received receipt for %s from %s",
 			eh.sessionID, evt.MessageIDs, evt.SourceString())
+
+		eh.trackDeliveryStatus(evt)
 	}()
 }
 
 
+// receiptStatuses maps the receipt types that describe our outgoing messages to a delivery status.
+var receiptStatuses = map[waTypes.ReceiptType]message.Status{
+	waTypes.ReceiptTypeDelivered: message.StatusDelivered,
+	waTypes.ReceiptTypeRead:      message.StatusRead,
+	waTypes.ReceiptTypePlayed:    message.StatusPlayed,
+}
+
+
+// trackDeliveryStatus records receipts of messages sent by this session and emits message.status.
+func (eh *EventHandler) trackDeliveryStatus(evt *events.Receipt) {
+	status, ok := receiptStatuses[evt.Type]
+	if !ok || evt.IsFromMe || eh.messageService == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	participant := evt.Sender.ToNonAD().String()
+	chat := evt.Chat.ToNonAD().String()
+
+	var recorded []string
+	for _, id := range evt.MessageIDs {
+		isNew, err := eh.messageService.RecordReceipt(ctx, &message.Receipt{
+			SessionID:   eh.sessionID,
+			MessageID:   string(id),
+			ChatJID:     chat,
+			Participant: participant,
+			Status:      status,
+			Timestamp:   evt.Timestamp,
+		})
+		if err != nil {
+			eh.logger.Warnf("Session %s: Failed to record %s receipt for %s: %v", eh.sessionID, status, id, err)
+			continue
+		}
+		if isNew {
+			recorded = append(recorded, string(id))
+		}
+	}
+
+	if len(recorded) == 0 {
+		return
+	}
+
+	eh.sendWebhook("message.status", message.MessageStatusEventDTO{
+		SessionID:   eh.sessionID,
+		MessageIDs:  recorded,
+		Chat:        chat,
+		Participant: participant,
+		IsGroup:     evt.IsGroup,
+		Status:      string(status),
+		Timestamp:   evt.Timestamp.Unix(),
+	})
+}
+
+
 func (eh *EventHandler) handlePresence(evt *events.Presence) {
 	eh.logger.Debugf("Session %s: Presence update from %s: unavailable=%t",
 		eh.sessionID, evt.From, evt.Unavailable)
@@ -408,24 +487,105 @@ func (eh *EventHandler) handleHistorySync(evt *events.HistorySync) {
 			}
 		}()
 
-		eh.logger.Infof("Session %s: History sync: %d conversations",
-			eh.sessionID, len(evt.Data.Conversations))
+		eh.logger.Infof("Session %s: History sync (%s): %d conversations",
+			eh.sessionID, evt.Data.GetSyncType(), len(evt.Data.Conversations))
 
-		
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+		onDemand := evt.Data.GetSyncType() == waHistorySync.HistorySync_ON_DEMAND
+		for _, conv := range evt.Data.GetConversations() {
+			chat, err := waTypes.ParseJID(conv.GetID())
+			if err != nil {
+				eh.logger.Warnf("Session %s: Skipping history conversation with invalid JID %q: %v", eh.sessionID, conv.GetID(), err)
+				continue
+			}
 
-		select {
-		case <-ctx.Done():
-			eh.logger.Warnf("Session %s: History sync processing timeout", eh.sessionID)
-		default:
-			
-			eh.logger.Debugf("Session %s: History sync processed successfully", eh.sessionID)
+			ctx, cancel := context.WithTimeout(context.Background(), HistorySyncTimeout)
+			stored := eh.storeHistoryConversation(ctx, chat, conv)
+			if onDemand {
+				eh.completeHistorySyncJob(ctx, chat.ToNonAD().String(), stored, int(evt.Data.GetProgress()))
+			}
+			cancel()
 		}
+
+		eh.logger.Debugf("Session %s: History sync processed successfully", eh.sessionID)
 	}()
 }
 
 
+// storeHistoryConversation parses the messages of a synced conversation into the message store.
+func (eh *EventHandler) storeHistoryConversation(ctx context.Context, chat waTypes.JID, conv *waHistorySync.Conversation) int {
+	if eh.messageService == nil || eh.client == nil {
+		return 0
+	}
+
+	stored := 0
+	for _, hm := range conv.GetMessages() {
+		evt, err := eh.client.client.ParseWebMessage(chat, hm.GetMessage())
+		if err != nil {
+			eh.logger.Debugf("Session %s: Failed to parse history message in %s: %v", eh.sessionID, chat, err)
+			continue
+		}
+
+		if err := eh.messageService.SaveMessage(ctx, NewIncomingMessage(eh.sessionID, evt)); err != nil {
+			eh.logger.Warnf("Session %s: Failed to store history message %s: %v", eh.sessionID, evt.Info.ID, err)
+			continue
+		}
+		stored++
+	}
+
+	return stored
+}
+
+
+// completeHistorySyncJob matches an on-demand history sync to the oldest pending request of the chat.
+func (eh *EventHandler) completeHistorySyncJob(ctx context.Context, chatJID string, stored, progress int) {
+	if eh.messageService == nil {
+		return
+	}
+
+	job, err := eh.messageService.FindActiveHistorySyncJob(ctx, eh.sessionID, chatJID)
+	if err != nil {
+		if err != message.ErrJobNotFound {
+			eh.logger.Warnf("Session %s: Failed to find history sync job for %s: %v", eh.sessionID, chatJID, err)
+		}
+		return
+	}
+
+	job.RecordChunk(1, stored, progress)
+	job.Complete()
+	eh.finishHistorySyncJob(ctx, job)
+}
+
+
+// expireHistorySyncJob fails a history sync job that got no response in time.
+func (eh *EventHandler) expireHistorySyncJob(jobID string) {
+	if eh.messageService == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	job, err := eh.messageService.GetHistorySyncJob(ctx, eh.sessionID, jobID)
+	if err != nil || job.Status.IsFinished() {
+		return
+	}
+
+	job.Fail("no history sync response received from the primary device")
+	eh.finishHistorySyncJob(ctx, job)
+}
+
+
+func (eh *EventHandler) finishHistorySyncJob(ctx context.Context, job *message.HistorySyncJob) {
+	if err := eh.messageService.UpdateHistorySyncJob(ctx, job); err != nil {
+		eh.logger.Warnf("Session %s: Failed to update history sync job %s: %v", eh.sessionID, job.ID, err)
+		return
+	}
+
+	eh.logger.Infof("Session %s: History sync job %s %s (%d messages)", eh.sessionID, job.ID, job.Status, job.MessagesStored)
+	eh.sendWebhook("history_sync", message.NewHistorySyncJobDTO(job))
+}
+
+
 func (eh *EventHandler) handleAppState(evt *events.AppState) {
 	eh.logger.Debugf("Session %s: App state update with %d indices", eh.sessionID, len(evt.Index))
 }
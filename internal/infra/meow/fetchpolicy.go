@@ -0,0 +1,51 @@
+package meow
+
+import (
+	"context"
+
+	"zpmeow/internal/domain/session"
+	"zpmeow/internal/utils"
+)
+
+
+// SessionFetchPolicy applies the media fetch policy of a session over the server default. Lists
+// the session sets replace the default ones, and its blocked domains add to the default ones.
+func SessionFetchPolicy(sess *session.Session) *utils.FetchPolicy {
+	policy := *utils.DefaultFetchPolicy()
+	custom := sess.MediaFetch
+
+	if custom.Unreadable {
+		policy.Disabled = true
+		return &policy
+	}
+
+	if len(custom.AllowedSchemes) > 0 {
+		policy.AllowedSchemes = custom.AllowedSchemes
+	}
+	if len(custom.AllowedDomains) > 0 {
+		policy.AllowedDomains = custom.AllowedDomains
+	}
+	if len(custom.BlockedDomains) > 0 {
+		policy.BlockedDomains = append(append([]string(nil), policy.BlockedDomains...), custom.BlockedDomains...)
+	}
+	if len(custom.AllowedNetworks) > 0 {
+		// The session policy was validated when it was set
+		policy.AllowedNetworks, _ = utils.ParseNetworks(custom.AllowedNetworks)
+	}
+	return &policy
+}
+
+
+// withFetchPolicy makes remote fetches under ctx follow the policy of the session, falling back
+// to the default policy when the session cannot be loaded.
+func (m *MeowServiceImpl) withFetchPolicy(ctx context.Context, sessionID string) context.Context {
+	sess, err := m.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		m.logger.Debugf("Using the default fetch policy for session %s: %v", sessionID, err)
+		return ctx
+	}
+	if sess.MediaFetch.Unreadable {
+		m.logger.Errorf("Stored media fetch policy of session %s is unreadable, refusing remote fetches until it is set again", sessionID)
+	}
+	return utils.WithFetchPolicy(ctx, SessionFetchPolicy(sess))
+}
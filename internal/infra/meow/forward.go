@@ -0,0 +1,189 @@
+package meow
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"zpmeow/internal/domain/message"
+	"zpmeow/internal/types"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+
+// forwardMediaReuseWindow is how long media stays on the WhatsApp servers for certain. Forwarded
+// media younger than this is sent by its existing direct path; older media is uploaded again.
+const forwardMediaReuseWindow = 14 * 24 * time.Hour
+
+
+// ForwardMessage re-sends a stored message to each target, marked as forwarded. A failure for
+// one target does not stop the others; it is reported in that target's result.
+func (m *MeowServiceImpl) ForwardMessage(ctx context.Context, sessionID, messageID string, targets []string) ([]types.ForwardResult, error) {
+	client, exists := m.clientManager.GetClient(sessionID)
+	if !exists {
+		return nil, fmt.Errorf("client not found for session %s", sessionID)
+	}
+
+	stored, err := m.findMessage(ctx, sessionID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := forwardableMessage(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	if stored.HasMedia() && time.Since(stored.Timestamp) > forwardMediaReuseWindow {
+		if err := reuploadMedia(ctx, client.client, msg); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]types.ForwardResult, len(targets))
+	for i, target := range targets {
+		results[i].To = target
+
+		jid, ok := parseJID(target)
+		if !ok {
+			results[i].Error = fmt.Sprintf("invalid JID %s", target)
+			continue
+		}
+
+		resp, err := client.sendAndStore(ctx, jid, msg)
+		if err != nil {
+			m.logger.Warnf("Failed to forward message %s of session %s to %s: %v", messageID, sessionID, target, err)
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Success = true
+		results[i].ID = string(resp.ID)
+		results[i].Timestamp = resp.Timestamp.Unix()
+	}
+
+	return results, nil
+}
+
+
+// forwardableMessage rebuilds the content of a stored message with a fresh ContextInfo that
+// marks it as forwarded once more than the original. Quotes and mentions are not carried over.
+func forwardableMessage(stored *message.Message) (*waE2E.Message, error) {
+	if stored.ViewOnce {
+		return nil, message.ErrNotForwardable
+	}
+	if len(stored.Raw) == 0 {
+		if stored.HasMedia() {
+			return nil, message.ErrMediaUnavailable
+		}
+		if stored.Type != message.TypeText {
+			return nil, message.ErrNotForwardable
+		}
+		return &waE2E.Message{ExtendedTextMessage: &waE2E.ExtendedTextMessage{Text: proto.String(stored.Text)}}, nil
+	}
+
+	var raw waE2E.Message
+	if err := proto.Unmarshal(stored.Raw, &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode stored message: %w", err)
+	}
+
+	msg := (&events.Message{RawMessage: &raw}).UnwrapRaw().Message
+	if msg.Conversation != nil {
+		msg = &waE2E.Message{ExtendedTextMessage: &waE2E.ExtendedTextMessage{Text: msg.Conversation}}
+	}
+
+	score := extractMessageContent(msg).ContextInfo.GetForwardingScore() + 1
+	contextInfo := &waE2E.ContextInfo{
+		IsForwarded:     proto.Bool(true),
+		ForwardingScore: proto.Uint32(score),
+	}
+
+	switch {
+	case msg.ExtendedTextMessage != nil:
+		msg.ExtendedTextMessage.ContextInfo = contextInfo
+	case msg.ImageMessage != nil:
+		msg.ImageMessage.ContextInfo = contextInfo
+	case msg.VideoMessage != nil:
+		msg.VideoMessage.ContextInfo = contextInfo
+	case msg.AudioMessage != nil:
+		msg.AudioMessage.ContextInfo = contextInfo
+	case msg.DocumentMessage != nil:
+		msg.DocumentMessage.ContextInfo = contextInfo
+	case msg.StickerMessage != nil:
+		msg.StickerMessage.ContextInfo = contextInfo
+	case msg.LocationMessage != nil:
+		msg.LocationMessage.ContextInfo = contextInfo
+	case msg.ContactMessage != nil:
+		msg.ContactMessage.ContextInfo = contextInfo
+	default:
+		return nil, message.ErrNotForwardable
+	}
+
+	return msg, nil
+}
+
+
+// reuploadMedia downloads the media of msg and uploads it again, replacing its direct path and
+// keys, for media that may have expired on the WhatsApp servers.
+func reuploadMedia(ctx context.Context, client *whatsmeow.Client, msg *waE2E.Message) error {
+	data, err := client.DownloadAny(ctx, msg)
+	if err != nil {
+		return message.ErrMediaUnavailable
+	}
+
+	upload := func(mediaType whatsmeow.MediaType) (*whatsmeow.UploadResponse, error) {
+		uploaded, err := client.Upload(ctx, data, mediaType)
+		if err != nil {
+			return nil, Error.WrapError(err, "failed to upload forwarded media")
+		}
+		return &uploaded, nil
+	}
+
+	switch {
+	case msg.ImageMessage != nil:
+		up, err := upload(whatsmeow.MediaImage)
+		if err != nil {
+			return err
+		}
+		m := msg.ImageMessage
+		m.URL, m.DirectPath, m.MediaKey = &up.URL, &up.DirectPath, up.MediaKey
+		m.FileEncSHA256, m.FileSHA256, m.FileLength = up.FileEncSHA256, up.FileSHA256, &up.FileLength
+	case msg.VideoMessage != nil:
+		up, err := upload(whatsmeow.MediaVideo)
+		if err != nil {
+			return err
+		}
+		m := msg.VideoMessage
+		m.URL, m.DirectPath, m.MediaKey = &up.URL, &up.DirectPath, up.MediaKey
+		m.FileEncSHA256, m.FileSHA256, m.FileLength = up.FileEncSHA256, up.FileSHA256, &up.FileLength
+	case msg.AudioMessage != nil:
+		up, err := upload(whatsmeow.MediaAudio)
+		if err != nil {
+			return err
+		}
+		m := msg.AudioMessage
+		m.URL, m.DirectPath, m.MediaKey = &up.URL, &up.DirectPath, up.MediaKey
+		m.FileEncSHA256, m.FileSHA256, m.FileLength = up.FileEncSHA256, up.FileSHA256, &up.FileLength
+	case msg.DocumentMessage != nil:
+		up, err := upload(whatsmeow.MediaDocument)
+		if err != nil {
+			return err
+		}
+		m := msg.DocumentMessage
+		m.URL, m.DirectPath, m.MediaKey = &up.URL, &up.DirectPath, up.MediaKey
+		m.FileEncSHA256, m.FileSHA256, m.FileLength = up.FileEncSHA256, up.FileSHA256, &up.FileLength
+	case msg.StickerMessage != nil:
+		up, err := upload(whatsmeow.MediaImage)
+		if err != nil {
+			return err
+		}
+		m := msg.StickerMessage
+		m.URL, m.DirectPath, m.MediaKey = &up.URL, &up.DirectPath, up.MediaKey
+		m.FileEncSHA256, m.FileSHA256, m.FileLength = up.FileEncSHA256, up.FileSHA256, &up.FileLength
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package meow
+
+import (
+	"context"
+	"strings"
+
+	"zpmeow/internal/domain/schedule"
+	"zpmeow/internal/utils"
+)
+
+
+// linkPreview returns the preview card for a text payload: the one supplied with the request,
+// or one built from the OpenGraph tags of the first link in the text. A preview that cannot be
+// fetched is left out rather than failing the send. Fetches follow the session's fetch policy.
+func (m *MeowServiceImpl) linkPreview(ctx context.Context, sessionID string, p *schedule.Payload) *schedule.LinkPreview {
+	if p.NoLinkPreview {
+		return nil
+	}
+
+	matched, target := utils.FindLink(p.Text)
+	if p.LinkPreview != nil {
+		preview := *p.LinkPreview
+		if preview.MatchedText == "" {
+			preview.MatchedText = matched
+		}
+		if preview.MatchedText == "" {
+			return nil
+		}
+		return &preview
+	}
+	if target == "" {
+		return nil
+	}
+	return m.fetchLinkPreview(ctx, sessionID, matched, target)
+}
+
+
+// ResolveLinkPreview fetches the preview card of a text payload that is stored to be sent later
+// or to many recipients, so every send reuses it instead of fetching the page again. A link that
+// cannot be previewed turns the preview off. Links built from {{placeholders}} differ per
+// recipient and are left to the send.
+func (m *MeowServiceImpl) ResolveLinkPreview(ctx context.Context, sessionID string, p *schedule.Payload) {
+	if p.Kind != schedule.KindText || p.NoLinkPreview || p.LinkPreview != nil {
+		return
+	}
+
+	matched, target := utils.FindLink(p.Text)
+	if target == "" || strings.Contains(matched, "{{") {
+		return
+	}
+
+	p.LinkPreview = m.fetchLinkPreview(ctx, sessionID, matched, target)
+	p.NoLinkPreview = p.LinkPreview == nil
+}
+
+
+// fetchLinkPreview builds a preview card from the OpenGraph tags of target, or returns nil if
+// the page cannot be fetched.
+func (m *MeowServiceImpl) fetchLinkPreview(ctx context.Context, sessionID, matched, target string) *schedule.LinkPreview {
+	ctx, cancel := context.WithTimeout(m.withFetchPolicy(ctx, sessionID), utils.LinkPreviewTimeout)
+	defer cancel()
+
+	og, err := utils.FetchOpenGraph(ctx, target)
+	if err != nil {
+		m.logger.Debugf("No link preview for %s: %v", target, err)
+		return nil
+	}
+
+	preview := &schedule.LinkPreview{
+		MatchedText: matched,
+		Title:       og.Title,
+		Description: og.Description,
+	}
+	if og.Image == "" {
+		return preview
+	}
+
+	image, err := utils.FetchLinkPreviewImage(ctx, og.Image)
+	if err == nil {
+		preview.Thumbnail, preview.ThumbnailWidth, preview.ThumbnailHeight, err = utils.JPEGThumbnail(image, utils.LinkPreviewThumbnailSize)
+	}
+	if err != nil {
+		m.logger.Debugf("No link preview image for %s: %v", target, err)
+	}
+	return preview
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"zpmeow/internal/domain/message"
 	"zpmeow/internal/domain/session"
 	"zpmeow/internal/infra/logger"
 	"zpmeow/internal/infra/webhook"
@@ -28,10 +29,11 @@ type ClientManager struct {
 	waLogger       waLog.Logger
 	webhookService *webhook.WebhookService
 	sessionService session.SessionService
+	messageService message.MessageService
 }
 
 
-func NewClientManager(db *sqlx.DB, container *sqlstore.Container, waLogger waLog.Logger, webhookService *webhook.WebhookService, sessionService session.SessionService) *ClientManager {
+func NewClientManager(db *sqlx.DB, container *sqlstore.Container, waLogger waLog.Logger, webhookService *webhook.WebhookService, sessionService session.SessionService, messageService message.MessageService) *ClientManager {
 	if waLogger == nil {
 		waLogger = waLog.Noop
 	}
@@ -46,6 +48,7 @@ func NewClientManager(db *sqlx.DB, container *sqlstore.Container, waLogger waLog
 		waLogger:       waLogger,
 		webhookService: webhookService,
 		sessionService: sessionService,
+		messageService: messageService,
 	}
 }
 
@@ -80,7 +83,7 @@ func (cm *ClientManager) CreateClient(ctx context.Context, sessionID string) (*M
 	}
 
 
-	client, err := NewMeowClient(sessionID, deviceStore, cm.waLogger, cm, cm.webhookService, cm.sessionService)
+	client, err := NewMeowClient(sessionID, deviceStore, cm.waLogger, cm, cm.webhookService, cm.sessionService, cm.messageService)
 	if err != nil {
 		return nil, Error.WrapError(err, "failed to create meow client")
 	}
@@ -0,0 +1,67 @@
+package meow
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"zpmeow/internal/domain/schedule"
+)
+
+
+// mentionPattern matches @5511999999999 style mentions in a text or caption
+var mentionPattern = regexp.MustCompile(`@(\d{7,15})\b`)
+
+
+// mentionedJIDs collects the JIDs a payload mentions: the explicit mentions, @number tokens in
+// its text or caption and, with mentionAll, every participant of the group.
+func (m *MeowServiceImpl) mentionedJIDs(ctx context.Context, sessionID string, p *schedule.Payload) ([]string, error) {
+	var text string
+	switch {
+	case p.Kind == schedule.KindText:
+		text = p.Text
+	case p.HasCaption():
+		text = p.Caption
+	default:
+		return nil, nil
+	}
+
+	seen := map[string]bool{}
+	var jids []string
+	add := func(value string) error {
+		jid, ok := parseJID(value)
+		if !ok || jid.User == "" {
+			return fmt.Errorf("invalid mention %q", value)
+		}
+		if key := jid.String(); !seen[key] {
+			seen[key] = true
+			jids = append(jids, key)
+		}
+		return nil
+	}
+
+	for _, mention := range p.Mentions {
+		if err := add(mention); err != nil {
+			return nil, err
+		}
+	}
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		if err := add(match[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.MentionAll {
+		group, err := m.GetGroupInfo(ctx, sessionID, p.Phone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get group participants: %w", err)
+		}
+		for _, participant := range group.Participants {
+			if err := add(participant.JID.String()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return jids, nil
+}
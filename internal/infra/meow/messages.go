@@ -2,21 +2,30 @@ package meow
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"regexp"
 
+	"zpmeow/internal/domain/schedule"
 	"zpmeow/internal/types"
 
 	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waCommon"
 	"go.mau.fi/whatsmeow/proto/waE2E"
 	waTypes "go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
 )
 
 
 type MessageBuilder struct{}
 
 
-func (mb *MessageBuilder) BuildTextMessage(text string, contextInfo *waE2E.ContextInfo) *waE2E.Message {
+// BuildTextMessage builds a text message, with a preview card for the link in the text when
+// preview is not nil.
+func (mb *MessageBuilder) BuildTextMessage(text string, contextInfo *waE2E.ContextInfo, preview *schedule.LinkPreview) *waE2E.Message {
 	msg := &waE2E.Message{
 		ExtendedTextMessage: &waE2E.ExtendedTextMessage{
 			Text: &text,
@@ -27,15 +36,47 @@ func (mb *MessageBuilder) BuildTextMessage(text string, contextInfo *waE2E.Conte
 		msg.ExtendedTextMessage.ContextInfo = contextInfo
 	}
 
+	if preview != nil {
+		ext := msg.ExtendedTextMessage
+		ext.MatchedText = proto.String(preview.MatchedText)
+		ext.PreviewType = waE2E.ExtendedTextMessage_NONE.Enum()
+		if preview.Title != "" {
+			ext.Title = proto.String(preview.Title)
+		}
+		if preview.Description != "" {
+			ext.Description = proto.String(preview.Description)
+		}
+		if len(preview.Thumbnail) > 0 {
+			ext.JPEGThumbnail = preview.Thumbnail
+			ext.ThumbnailWidth = proto.Uint32(uint32(preview.ThumbnailWidth))
+			ext.ThumbnailHeight = proto.Uint32(uint32(preview.ThumbnailHeight))
+		}
+	}
+
 	return msg
 }
 
 
-func (mb *MessageBuilder) BuildLocationMessage(latitude, longitude float64, name, address string) *waE2E.Message {
+// BuildStatusTextMessage builds a text status update: white text in font on a background of
+// the given ARGB color.
+func (mb *MessageBuilder) BuildStatusTextMessage(text string, background uint32, font int32) *waE2E.Message {
+	return &waE2E.Message{
+		ExtendedTextMessage: &waE2E.ExtendedTextMessage{
+			Text:           proto.String(text),
+			TextArgb:       proto.Uint32(0xFFFFFFFF),
+			BackgroundArgb: proto.Uint32(background),
+			Font:           waE2E.ExtendedTextMessage_FontType(font).Enum(),
+		},
+	}
+}
+
+
+func (mb *MessageBuilder) BuildLocationMessage(latitude, longitude float64, name, address string, contextInfo *waE2E.ContextInfo) *waE2E.Message {
 	msg := &waE2E.Message{
 		LocationMessage: &waE2E.LocationMessage{
 			DegreesLatitude:  &latitude,
 			DegreesLongitude: &longitude,
+			ContextInfo:      contextInfo,
 		},
 	}
 
@@ -50,11 +91,12 @@ func (mb *MessageBuilder) BuildLocationMessage(latitude, longitude float64, name
 }
 
 
-func (mb *MessageBuilder) BuildContactMessage(displayName, vcard string) *waE2E.Message {
+func (mb *MessageBuilder) BuildContactMessage(displayName, vcard string, contextInfo *waE2E.ContextInfo) *waE2E.Message {
 	return &waE2E.Message{
 		ContactMessage: &waE2E.ContactMessage{
 			DisplayName: &displayName,
 			Vcard:       &vcard,
+			ContextInfo: contextInfo,
 		},
 	}
 }
@@ -65,6 +107,18 @@ type MediaMessageParams struct {
 	Caption        string
 	MimeType       string
 	FileName       string
+	ContextInfo    *waE2E.ContextInfo
+	ViewOnce       bool
+	Seconds        uint32
+	Waveform       []byte
+	Width          uint32
+	Height         uint32
+	Thumbnail      []byte
+	GifPlayback    bool
+	IsAnimated     bool
+	PageCount      uint32
+	ThumbWidth     uint32
+	ThumbHeight    uint32
 }
 
 
@@ -84,6 +138,20 @@ func (mb *MessageBuilder) BuildImageMessage(params MediaMessageParams) *waE2E.Me
 	if params.Caption != "" {
 		msg.ImageMessage.Caption = &params.Caption
 	}
+	if params.ContextInfo != nil {
+		msg.ImageMessage.ContextInfo = params.ContextInfo
+	}
+	if params.Width > 0 && params.Height > 0 {
+		msg.ImageMessage.Width = proto.Uint32(params.Width)
+		msg.ImageMessage.Height = proto.Uint32(params.Height)
+	}
+	if len(params.Thumbnail) > 0 {
+		msg.ImageMessage.JPEGThumbnail = params.Thumbnail
+	}
+	if params.ViewOnce {
+		msg.ImageMessage.ViewOnce = proto.Bool(true)
+		return mb.wrapViewOnce(msg)
+	}
 
 	return msg
 }
@@ -95,7 +163,7 @@ func (mb *MessageBuilder) BuildAudioMessage(params MediaMessageParams, isPTT boo
 		mimeType = "audio/ogg; codecs=opus"
 	}
 
-	return &waE2E.Message{
+	msg := &waE2E.Message{
 		AudioMessage: &waE2E.AudioMessage{
 			URL:           &params.UploadResponse.URL,
 			DirectPath:    &params.UploadResponse.DirectPath,
@@ -105,8 +173,55 @@ func (mb *MessageBuilder) BuildAudioMessage(params MediaMessageParams, isPTT boo
 			FileSHA256:    params.UploadResponse.FileSHA256,
 			FileLength:    &params.UploadResponse.FileLength,
 			PTT:           &isPTT,
+			ContextInfo:   params.ContextInfo,
 		},
 	}
+
+	if params.Seconds > 0 {
+		msg.AudioMessage.Seconds = proto.Uint32(params.Seconds)
+	}
+	if len(params.Waveform) > 0 {
+		msg.AudioMessage.Waveform = params.Waveform
+	}
+	if params.ViewOnce {
+		msg.AudioMessage.ViewOnce = proto.Bool(true)
+		return &waE2E.Message{ViewOnceMessageV2Extension: &waE2E.FutureProofMessage{Message: msg}}
+	}
+
+	return msg
+}
+
+
+// wrapViewOnce wraps an image or video message so it can only be opened once. Voice notes use
+// the V2 extension wrapper instead, see BuildAudioMessage.
+func (mb *MessageBuilder) wrapViewOnce(msg *waE2E.Message) *waE2E.Message {
+	return &waE2E.Message{ViewOnceMessageV2: &waE2E.FutureProofMessage{Message: msg}}
+}
+
+
+// BuildAlbumMessage builds the parent of an album, which tells clients how many images and
+// videos to group under it.
+func (mb *MessageBuilder) BuildAlbumMessage(images, videos uint32, contextInfo *waE2E.ContextInfo) *waE2E.Message {
+	return &waE2E.Message{
+		AlbumMessage: &waE2E.AlbumMessage{
+			ExpectedImageCount: proto.Uint32(images),
+			ExpectedVideoCount: proto.Uint32(videos),
+			ContextInfo:        contextInfo,
+		},
+	}
+}
+
+
+// AddToAlbum makes msg an item of the album whose parent message is parent. Items are shown
+// in the order they are sent.
+func (mb *MessageBuilder) AddToAlbum(msg *waE2E.Message, parent *waCommon.MessageKey) {
+	if msg.MessageContextInfo == nil {
+		msg.MessageContextInfo = &waE2E.MessageContextInfo{}
+	}
+	msg.MessageContextInfo.MessageAssociation = &waE2E.MessageAssociation{
+		AssociationType:  waE2E.MessageAssociation_MEDIA_ALBUM.Enum(),
+		ParentMessageKey: parent,
+	}
 }
 
 
@@ -127,6 +242,17 @@ func (mb *MessageBuilder) BuildDocumentMessage(params MediaMessageParams) *waE2E
 	if params.Caption != "" {
 		msg.DocumentMessage.Caption = &params.Caption
 	}
+	if params.PageCount > 0 {
+		msg.DocumentMessage.PageCount = proto.Uint32(params.PageCount)
+	}
+	if len(params.Thumbnail) > 0 {
+		msg.DocumentMessage.JPEGThumbnail = params.Thumbnail
+		msg.DocumentMessage.ThumbnailWidth = proto.Uint32(params.ThumbWidth)
+		msg.DocumentMessage.ThumbnailHeight = proto.Uint32(params.ThumbHeight)
+	}
+	if params.ContextInfo != nil {
+		msg.DocumentMessage.ContextInfo = params.ContextInfo
+	}
 
 	return msg
 }
@@ -148,13 +274,33 @@ func (mb *MessageBuilder) BuildVideoMessage(params MediaMessageParams) *waE2E.Me
 	if params.Caption != "" {
 		msg.VideoMessage.Caption = &params.Caption
 	}
+	if params.ContextInfo != nil {
+		msg.VideoMessage.ContextInfo = params.ContextInfo
+	}
+	if params.Seconds > 0 {
+		msg.VideoMessage.Seconds = proto.Uint32(params.Seconds)
+	}
+	if params.Width > 0 && params.Height > 0 {
+		msg.VideoMessage.Width = proto.Uint32(params.Width)
+		msg.VideoMessage.Height = proto.Uint32(params.Height)
+	}
+	if len(params.Thumbnail) > 0 {
+		msg.VideoMessage.JPEGThumbnail = params.Thumbnail
+	}
+	if params.GifPlayback {
+		msg.VideoMessage.GifPlayback = proto.Bool(true)
+	}
+	if params.ViewOnce {
+		msg.VideoMessage.ViewOnce = proto.Bool(true)
+		return mb.wrapViewOnce(msg)
+	}
 
 	return msg
 }
 
 
 func (mb *MessageBuilder) BuildStickerMessage(params MediaMessageParams) *waE2E.Message {
-	return &waE2E.Message{
+	msg := &waE2E.Message{
 		StickerMessage: &waE2E.StickerMessage{
 			URL:           &params.UploadResponse.URL,
 			DirectPath:    &params.UploadResponse.DirectPath,
@@ -163,12 +309,21 @@ func (mb *MessageBuilder) BuildStickerMessage(params MediaMessageParams) *waE2E.
 			FileEncSHA256: params.UploadResponse.FileEncSHA256,
 			FileSHA256:    params.UploadResponse.FileSHA256,
 			FileLength:    &params.UploadResponse.FileLength,
+			ContextInfo:   params.ContextInfo,
+			IsAnimated:    proto.Bool(params.IsAnimated),
 		},
 	}
+
+	if params.Width > 0 && params.Height > 0 {
+		msg.StickerMessage.Width = proto.Uint32(params.Width)
+		msg.StickerMessage.Height = proto.Uint32(params.Height)
+	}
+
+	return msg
 }
 
 
-func (mb *MessageBuilder) BuildPollMessage(name string, options []string, selectableCount int) *waE2E.Message {
+func (mb *MessageBuilder) BuildPollMessage(name string, options []string, selectableCount int, contextInfo *waE2E.ContextInfo) *waE2E.Message {
 	pollOptions := make([]*waE2E.PollCreationMessage_Option, len(options))
 	for i, option := range options {
 		pollOptions[i] = &waE2E.PollCreationMessage_Option{
@@ -182,8 +337,9 @@ func (mb *MessageBuilder) BuildPollMessage(name string, options []string, select
 
 	return &waE2E.Message{
 		PollCreationMessage: &waE2E.PollCreationMessage{
-			Name:    &name,
-			Options: pollOptions,
+			Name:        &name,
+			Options:     pollOptions,
+			ContextInfo: contextInfo,
 		},
 	}
 }
@@ -191,7 +347,7 @@ func (mb *MessageBuilder) BuildPollMessage(name string, options []string, select
 
 
 
-func (mb *MessageBuilder) BuildButtonsMessage(text string, buttons []types.Button, footer string) *waE2E.Message {
+func (mb *MessageBuilder) BuildButtonsMessage(text string, buttons []types.Button, footer string, contextInfo *waE2E.ContextInfo) *waE2E.Message {
 
 	buttonText := text + "\n\n"
 	for i, button := range buttons {
@@ -203,13 +359,14 @@ func (mb *MessageBuilder) BuildButtonsMessage(text string, buttons []types.Butto
 
 	return &waE2E.Message{
 		ExtendedTextMessage: &waE2E.ExtendedTextMessage{
-			Text: &buttonText,
+			Text:        &buttonText,
+			ContextInfo: contextInfo,
 		},
 	}
 }
 
 
-func (mb *MessageBuilder) BuildListMessage(text, buttonText string, sections []types.Section, footer string) *waE2E.Message {
+func (mb *MessageBuilder) BuildListMessage(text, buttonText string, sections []types.Section, footer string, contextInfo *waE2E.ContextInfo) *waE2E.Message {
 
 	listText := text + "\n\n"
 	for _, section := range sections {
@@ -231,19 +388,23 @@ func (mb *MessageBuilder) BuildListMessage(text, buttonText string, sections []t
 
 	return &waE2E.Message{
 		ExtendedTextMessage: &waE2E.ExtendedTextMessage{
-			Text: &listText,
+			Text:        &listText,
+			ContextInfo: contextInfo,
 		},
 	}
 }
 
 
+// MediaUploader encrypts and uploads media, reusing an earlier upload of the same content from
+// cache when it has one.
 type MediaUploader struct {
 	client *whatsmeow.Client
+	cache  *uploadCache
 }
 
 
-func NewMediaUploader(client *whatsmeow.Client) *MediaUploader {
-	return &MediaUploader{client: client}
+func NewMediaUploader(client *whatsmeow.Client, cache *uploadCache) *MediaUploader {
+	return &MediaUploader{client: client, cache: cache}
 }
 
 
@@ -252,15 +413,82 @@ func (mu *MediaUploader) UploadMedia(ctx context.Context, data []byte, mediaType
 		return whatsmeow.UploadResponse{}, errors.New(ErrClientNotFound)
 	}
 
+	key := uploadKey{sum: sha256.Sum256(data), mediaType: mediaType}
+	if uploaded, ok := mu.cache.Get(key); ok {
+		return uploaded, nil
+	}
+
 	uploaded, err := mu.client.Upload(ctx, data, mediaType)
 	if err != nil {
 		return whatsmeow.UploadResponse{}, Error.WrapError(err, "failed to upload media")
 	}
 
+	mu.cache.Put(key, uploaded)
+	return uploaded, nil
+}
+
+
+// UploadFile encrypts and uploads a file on disk, streaming it instead of loading it into memory.
+func (mu *MediaUploader) UploadFile(ctx context.Context, path string, mediaType whatsmeow.MediaType) (whatsmeow.UploadResponse, error) {
+	if mu.client == nil {
+		return whatsmeow.UploadResponse{}, errors.New(ErrClientNotFound)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return whatsmeow.UploadResponse{}, Error.WrapError(err, "failed to open media file")
+	}
+	defer file.Close()
+
+	// Hashing reads the file once more, which costs far less than uploading it again
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return whatsmeow.UploadResponse{}, Error.WrapError(err, "failed to read media file")
+	}
+	key := uploadKey{mediaType: mediaType}
+	hash.Sum(key.sum[:0])
+	if uploaded, ok := mu.cache.Get(key); ok {
+		return uploaded, nil
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return whatsmeow.UploadResponse{}, Error.WrapError(err, "failed to read media file")
+	}
+
+	uploaded, err := mu.client.UploadReader(ctx, file, nil, mediaType)
+	if err != nil {
+		return whatsmeow.UploadResponse{}, Error.WrapError(err, "failed to upload media")
+	}
+
+	mu.cache.Put(key, uploaded)
 	return uploaded, nil
 }
 
 
+// messageIDPattern matches IDs in the format whatsmeow generates: uppercase hex, 16 to 64 characters.
+var messageIDPattern = regexp.MustCompile(`^[0-9A-F]{16,64}$`)
+
+
+type messageIDKey struct{}
+
+
+// IsValidMessageID reports whether a client supplied ID can be used as the WhatsApp message ID.
+func IsValidMessageID(id string) bool {
+	return messageIDPattern.MatchString(id)
+}
+
+
+// WithMessageID makes the next send under ctx use id as the WhatsApp message ID.
+func WithMessageID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, messageIDKey{}, waTypes.MessageID(id))
+}
+
+
+func messageIDFromContext(ctx context.Context) waTypes.MessageID {
+	id, _ := ctx.Value(messageIDKey{}).(waTypes.MessageID)
+	return id
+}
+
+
 type MessageSender struct {
 	client *whatsmeow.Client
 }
@@ -278,7 +506,12 @@ func (ms *MessageSender) SendMessage(ctx context.Context, to waTypes.JID, messag
 
 
 
-	resp, err := ms.client.SendMessage(ctx, to, message)
+	var extra []whatsmeow.SendRequestExtra
+	if id := messageIDFromContext(ctx); id != "" {
+		extra = append(extra, whatsmeow.SendRequestExtra{ID: id})
+	}
+
+	resp, err := ms.client.SendMessage(ctx, to, message, extra...)
 	if err != nil {
 		return nil, Error.WrapError(err, "failed to send message")
 	}
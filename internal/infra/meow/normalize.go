@@ -0,0 +1,200 @@
+package meow
+
+import (
+	"time"
+
+	"zpmeow/internal/domain/message"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	waTypes "go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+
+type messageContent struct {
+	Type        string
+	Text        string
+	Caption     string
+	MimeType    string
+	FileName    string
+	FileLength  uint64
+	ViewOnce    bool
+	ContextInfo *waE2E.ContextInfo
+}
+
+
+func extractMessageContent(msg *waE2E.Message) messageContent {
+	switch {
+	case msg == nil:
+		return messageContent{Type: message.TypeUnknown}
+
+	case msg.Conversation != nil:
+		return messageContent{Type: message.TypeText, Text: msg.GetConversation()}
+
+	case msg.ExtendedTextMessage != nil:
+		m := msg.GetExtendedTextMessage()
+		return messageContent{Type: message.TypeText, Text: m.GetText(), ContextInfo: m.GetContextInfo()}
+
+	case msg.ImageMessage != nil:
+		m := msg.GetImageMessage()
+		return messageContent{
+			Type:        message.TypeImage,
+			Caption:     m.GetCaption(),
+			MimeType:    m.GetMimetype(),
+			FileLength:  m.GetFileLength(),
+			ViewOnce:    m.GetViewOnce(),
+			ContextInfo: m.GetContextInfo(),
+		}
+
+	case msg.VideoMessage != nil:
+		m := msg.GetVideoMessage()
+		return messageContent{
+			Type:        message.TypeVideo,
+			Caption:     m.GetCaption(),
+			MimeType:    m.GetMimetype(),
+			FileLength:  m.GetFileLength(),
+			ViewOnce:    m.GetViewOnce(),
+			ContextInfo: m.GetContextInfo(),
+		}
+
+	case msg.AudioMessage != nil:
+		m := msg.GetAudioMessage()
+		return messageContent{
+			Type:        message.TypeAudio,
+			MimeType:    m.GetMimetype(),
+			FileLength:  m.GetFileLength(),
+			ViewOnce:    m.GetViewOnce(),
+			ContextInfo: m.GetContextInfo(),
+		}
+
+	case msg.DocumentMessage != nil:
+		m := msg.GetDocumentMessage()
+		return messageContent{
+			Type:        message.TypeDocument,
+			Caption:     m.GetCaption(),
+			MimeType:    m.GetMimetype(),
+			FileName:    m.GetFileName(),
+			FileLength:  m.GetFileLength(),
+			ContextInfo: m.GetContextInfo(),
+		}
+
+	case msg.StickerMessage != nil:
+		m := msg.GetStickerMessage()
+		return messageContent{
+			Type:        message.TypeSticker,
+			MimeType:    m.GetMimetype(),
+			FileLength:  m.GetFileLength(),
+			ContextInfo: m.GetContextInfo(),
+		}
+
+	case msg.LocationMessage != nil:
+		m := msg.GetLocationMessage()
+		return messageContent{Type: message.TypeLocation, Text: m.GetName(), ContextInfo: m.GetContextInfo()}
+
+	case msg.ContactMessage != nil:
+		m := msg.GetContactMessage()
+		return messageContent{Type: message.TypeContact, Text: m.GetDisplayName(), ContextInfo: m.GetContextInfo()}
+
+	case msg.PollCreationMessage != nil:
+		m := msg.GetPollCreationMessage()
+		return messageContent{Type: message.TypePoll, Text: m.GetName(), ContextInfo: m.GetContextInfo()}
+
+	case msg.PollCreationMessageV3 != nil:
+		m := msg.GetPollCreationMessageV3()
+		return messageContent{Type: message.TypePoll, Text: m.GetName(), ContextInfo: m.GetContextInfo()}
+
+	case msg.ReactionMessage != nil:
+		m := msg.GetReactionMessage()
+		content := messageContent{Type: message.TypeReaction, Text: m.GetText()}
+		if key := m.GetKey(); key != nil {
+			content.ContextInfo = &waE2E.ContextInfo{StanzaID: key.ID, Participant: key.Participant}
+		}
+		return content
+
+	case msg.AlbumMessage != nil:
+		return messageContent{Type: message.TypeAlbum, ContextInfo: msg.GetAlbumMessage().GetContextInfo()}
+
+	default:
+		return messageContent{Type: message.TypeUnknown}
+	}
+}
+
+
+// unwrapViewOnce returns the content of a view-once wrapper, and whether msg was one.
+func unwrapViewOnce(msg *waE2E.Message) (*waE2E.Message, bool) {
+	switch {
+	case msg.GetViewOnceMessage() != nil:
+		return msg.GetViewOnceMessage().GetMessage(), true
+	case msg.GetViewOnceMessageV2() != nil:
+		return msg.GetViewOnceMessageV2().GetMessage(), true
+	case msg.GetViewOnceMessageV2Extension() != nil:
+		return msg.GetViewOnceMessageV2Extension().GetMessage(), true
+	}
+	return msg, false
+}
+
+
+// newStoredMessage converts message content into the stored model. View-once messages are
+// stored unwrapped, like whatsmeow delivers incoming ones, and flagged instead.
+func newStoredMessage(sessionID string, msg *waE2E.Message) *message.Message {
+	msg, viewOnce := unwrapViewOnce(msg)
+	content := extractMessageContent(msg)
+
+	stored := &message.Message{
+		SessionID:  sessionID,
+		Type:       content.Type,
+		Text:       content.Text,
+		Caption:    content.Caption,
+		MimeType:   content.MimeType,
+		FileName:   content.FileName,
+		FileLength: content.FileLength,
+		ViewOnce:   viewOnce || content.ViewOnce,
+		CreatedAt:  time.Now(),
+	}
+
+	if ci := content.ContextInfo; ci != nil && ci.GetStanzaID() != "" {
+		stored.QuotedID = ci.GetStanzaID()
+		stored.QuotedParticipant = ci.GetParticipant()
+	}
+
+	if msg != nil {
+		if raw, err := proto.Marshal(msg); err == nil {
+			stored.Raw = raw
+		}
+	}
+
+	return stored
+}
+
+
+// NewIncomingMessage converts a whatsmeow message event into the stored message model.
+func NewIncomingMessage(sessionID string, evt *events.Message) *message.Message {
+	stored := newStoredMessage(sessionID, evt.Message)
+	stored.ID = string(evt.Info.ID)
+	stored.ChatJID = evt.Info.Chat.ToNonAD().String()
+	stored.SenderJID = evt.Info.Sender.ToNonAD().String()
+	stored.PushName = evt.Info.PushName
+	stored.FromMe = evt.Info.IsFromMe
+	stored.IsGroup = evt.Info.IsGroup
+	stored.ViewOnce = stored.ViewOnce || evt.IsViewOnce
+	stored.Timestamp = evt.Info.Timestamp
+	return stored
+}
+
+
+// NewOutgoingMessage converts a message sent through the API into the stored message model.
+func NewOutgoingMessage(sessionID string, to waTypes.JID, msg *waE2E.Message, resp *whatsmeow.SendResponse) *message.Message {
+	stored := newStoredMessage(sessionID, msg)
+	stored.ID = string(resp.ID)
+	stored.ChatJID = to.ToNonAD().String()
+	stored.SenderJID = resp.Sender.ToNonAD().String()
+	stored.FromMe = true
+	stored.IsGroup = to.Server == waTypes.GroupServer || to.Server == waTypes.BroadcastServer
+	stored.Timestamp = resp.Timestamp
+	if stored.Timestamp.IsZero() {
+		stored.Timestamp = time.Now()
+	}
+	return stored
+}
@@ -0,0 +1,61 @@
+package meow
+
+import (
+	"sync"
+
+	"zpmeow/internal/domain/message"
+)
+
+
+// recentMessageLimit is how many messages of a session stay in memory
+const recentMessageLimit = 1000
+
+
+// recentMessages remembers the last messages sent and received by a session, so a reply can
+// quote a message that is not in the message store, or not there yet.
+type recentMessages struct {
+	mu    sync.Mutex
+	byID  map[string]*message.Message
+	order []string
+	next  int
+}
+
+
+func newRecentMessages(limit int) *recentMessages {
+	return &recentMessages{
+		byID:  make(map[string]*message.Message, limit),
+		order: make([]string, limit),
+	}
+}
+
+
+// Add stores msg, evicting the oldest message once the limit is reached.
+func (r *recentMessages) Add(msg *message.Message) {
+	if msg == nil || msg.ID == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.byID[msg.ID]; exists {
+		r.byID[msg.ID] = msg
+		return
+	}
+
+	if oldest := r.order[r.next]; oldest != "" {
+		delete(r.byID, oldest)
+	}
+	r.order[r.next] = msg.ID
+	r.next = (r.next + 1) % len(r.order)
+	r.byID[msg.ID] = msg
+}
+
+
+func (r *recentMessages) Get(id string) (*message.Message, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	msg, ok := r.byID[id]
+	return msg, ok
+}
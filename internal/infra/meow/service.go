@@ -3,13 +3,17 @@ package meow
 import (
 	"context"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
+	"zpmeow/internal/domain/message"
+	"zpmeow/internal/domain/schedule"
 	"zpmeow/internal/domain/session"
 	"zpmeow/internal/infra/logger"
 	"zpmeow/internal/infra/webhook"
 	"zpmeow/internal/types"
+	"zpmeow/internal/utils"
 
 	"github.com/jmoiron/sqlx"
 	"go.mau.fi/whatsmeow"
@@ -17,6 +21,7 @@ import (
 	"go.mau.fi/whatsmeow/store/sqlstore"
 	waTypes "go.mau.fi/whatsmeow/types"
 	waLog "go.mau.fi/whatsmeow/util/log"
+	"google.golang.org/protobuf/proto"
 )
 
 
@@ -84,13 +89,16 @@ func (m *MeowServiceImpl) validateAndGetClient(sessionID, to string) (*MeowClien
 
 
 type MeowServiceImpl struct {
-	clientManager *ClientManager
-	logger        logger.Logger
-	waLogger      waLog.Logger
+	clientManager  *ClientManager
+	messageService message.MessageService
+	sessionService session.SessionService
+	webhookService *webhook.WebhookService
+	logger         logger.Logger
+	waLogger       waLog.Logger
 }
 
 
-func NewMeowService(db *sqlx.DB, container *sqlstore.Container, waLogger waLog.Logger, sessionService session.SessionService) session.WhatsAppService {
+func NewMeowService(db *sqlx.DB, container *sqlstore.Container, waLogger waLog.Logger, sessionService session.SessionService, messageService message.MessageService) session.WhatsAppService {
 	if waLogger == nil {
 		waLogger = waLog.Noop
 	}
@@ -100,12 +108,15 @@ func NewMeowService(db *sqlx.DB, container *sqlstore.Container, waLogger waLog.L
 	// Create webhook service
 	webhookService := webhook.NewWebhookService()
 
-	clientManager := NewClientManager(db, container, waLogger, webhookService, sessionService)
+	clientManager := NewClientManager(db, container, waLogger, webhookService, sessionService, messageService)
 
 	service := &MeowServiceImpl{
-		clientManager: clientManager,
-		logger:        appLogger,
-		waLogger:      waLogger,
+		clientManager:  clientManager,
+		messageService: messageService,
+		sessionService: sessionService,
+		webhookService: webhookService,
+		logger:         appLogger,
+		waLogger:       waLogger,
 	}
 
 	return service
@@ -212,6 +223,18 @@ func (m *MeowServiceImpl) IsClientConnected(sessionID string) bool {
 }
 
 
+// ConnectedSessions returns the IDs of the sessions whose client is connected.
+func (m *MeowServiceImpl) ConnectedSessions() []string {
+	var sessionIDs []string
+	for sessionID, client := range m.clientManager.GetAllClients() {
+		if client.IsConnected() {
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+	}
+	return sessionIDs
+}
+
+
 func (m *MeowServiceImpl) GetClientStatus(sessionID string) types.Status {
 	status := m.clientManager.GetClientStatus(sessionID)
 	m.logger.Debugf("Client status for session %s: %s", sessionID, status)
@@ -294,7 +317,7 @@ func (m *MeowServiceImpl) SendMessage(ctx context.Context, sessionID, to, messag
 }
 
 
-func (m *MeowServiceImpl) SendTextMessage(ctx context.Context, sessionID, to, text string, contextInfo *waE2E.ContextInfo) (*whatsmeow.SendResponse, error) {
+func (m *MeowServiceImpl) SendTextMessage(ctx context.Context, sessionID, to, text string, contextInfo *waE2E.ContextInfo, preview *schedule.LinkPreview) (*whatsmeow.SendResponse, error) {
 	m.logger.Infof("DEBUG: SendTextMessage called - sessionID: %s, to: %s, text: %s", sessionID, to, text)
 
 
@@ -310,7 +333,7 @@ func (m *MeowServiceImpl) SendTextMessage(ctx context.Context, sessionID, to, te
 	m.logger.Infof("DEBUG: Skipping IsConnected() check to avoid deadlock")
 
 	m.logger.Infof("DEBUG: Calling client.SendTextMessage...")
-	resp, err := client.SendTextMessage(ctx, jid, text, contextInfo)
+	resp, err := client.SendTextMessage(ctx, jid, text, contextInfo, preview)
 	if err != nil {
 		m.logger.Errorf("DEBUG: client.SendTextMessage failed: %v", err)
 		return nil, err
@@ -321,25 +344,25 @@ func (m *MeowServiceImpl) SendTextMessage(ctx context.Context, sessionID, to, te
 }
 
 
-func (m *MeowServiceImpl) SendLocationMessage(ctx context.Context, sessionID, to string, latitude, longitude float64, name, address string) (*whatsmeow.SendResponse, error) {
+func (m *MeowServiceImpl) SendLocationMessage(ctx context.Context, sessionID, to string, latitude, longitude float64, name, address string, contextInfo *waE2E.ContextInfo) (*whatsmeow.SendResponse, error) {
 
 	client, jid, err := m.validateAndGetClient(sessionID, to)
 	if err != nil {
 		return nil, err
 	}
 
-	return client.SendLocationMessage(ctx, jid, latitude, longitude, name, address)
+	return client.SendLocationMessage(ctx, jid, latitude, longitude, name, address, contextInfo)
 }
 
 
-func (m *MeowServiceImpl) SendContactMessage(ctx context.Context, sessionID, to, displayName, vcard string) (*whatsmeow.SendResponse, error) {
+func (m *MeowServiceImpl) SendContactMessage(ctx context.Context, sessionID, to, displayName, vcard string, contextInfo *waE2E.ContextInfo) (*whatsmeow.SendResponse, error) {
 
 	client, jid, err := m.validateAndGetClient(sessionID, to)
 	if err != nil {
 		return nil, err
 	}
 
-	return client.SendContactMessage(ctx, jid, displayName, vcard)
+	return client.SendContactMessage(ctx, jid, displayName, vcard, contextInfo)
 }
 
 
@@ -600,80 +623,80 @@ func (m *MeowServiceImpl) SetGroupTopic(ctx context.Context, sessionID, groupJID
 }
 
 
-func (m *MeowServiceImpl) SendImageMessage(ctx context.Context, sessionID, to string, imageData []byte, caption, mimeType string) (*whatsmeow.SendResponse, error) {
-
+// SendImageFile sends the image file at path, without loading it into memory.
+func (m *MeowServiceImpl) SendImageFile(ctx context.Context, sessionID, to, path, caption, mimeType string, contextInfo *waE2E.ContextInfo, viewOnce bool) (*whatsmeow.SendResponse, error) {
 	client, jid, err := m.validateAndGetClient(sessionID, to)
 	if err != nil {
 		return nil, err
 	}
 
-	return client.SendImageMessage(ctx, jid, imageData, caption, mimeType)
+	return client.SendImageFile(ctx, jid, path, caption, mimeType, contextInfo, viewOnce)
 }
 
 
-func (m *MeowServiceImpl) SendAudioMessage(ctx context.Context, sessionID, to string, audioData []byte, mimeType string) (*whatsmeow.SendResponse, error) {
-
+// SendAudioFile sends the audio file at path, as a voice note when ptt is set.
+func (m *MeowServiceImpl) SendAudioFile(ctx context.Context, sessionID, to, path, mimeType string, ptt bool, contextInfo *waE2E.ContextInfo, viewOnce bool) (*whatsmeow.SendResponse, error) {
 	client, jid, err := m.validateAndGetClient(sessionID, to)
 	if err != nil {
 		return nil, err
 	}
 
-	return client.SendAudioMessage(ctx, jid, audioData, mimeType)
+	return client.SendAudioFile(ctx, jid, path, mimeType, ptt, contextInfo, viewOnce)
 }
 
 
-func (m *MeowServiceImpl) SendDocumentMessage(ctx context.Context, sessionID, to string, documentData []byte, filename, caption, mimeType string) (*whatsmeow.SendResponse, error) {
-
+// SendDocumentFile sends a document kept on disk at path, without loading it into memory.
+func (m *MeowServiceImpl) SendDocumentFile(ctx context.Context, sessionID, to, path, filename, caption, mimeType string, contextInfo *waE2E.ContextInfo) (*whatsmeow.SendResponse, error) {
 	client, jid, err := m.validateAndGetClient(sessionID, to)
 	if err != nil {
 		return nil, err
 	}
 
-	return client.SendDocumentMessage(ctx, jid, documentData, filename, caption, mimeType)
+	return client.SendDocumentFile(ctx, jid, path, filename, caption, mimeType, contextInfo)
 }
 
 
-func (m *MeowServiceImpl) SendVideoMessage(ctx context.Context, sessionID, to string, videoData []byte, caption, mimeType string) (*whatsmeow.SendResponse, error) {
-
+// SendVideoFile sends the video file at path, without loading it into memory.
+func (m *MeowServiceImpl) SendVideoFile(ctx context.Context, sessionID, to, path, caption, mimeType string, contextInfo *waE2E.ContextInfo, viewOnce, gifPlayback bool) (*whatsmeow.SendResponse, error) {
 	client, jid, err := m.validateAndGetClient(sessionID, to)
 	if err != nil {
 		return nil, err
 	}
 
-	return client.SendVideoMessage(ctx, jid, videoData, caption, mimeType)
+	return client.SendVideoFile(ctx, jid, path, caption, mimeType, contextInfo, viewOnce, gifPlayback)
 }
 
 
-func (m *MeowServiceImpl) SendStickerMessage(ctx context.Context, sessionID, to string, stickerData []byte, mimeType string) (*whatsmeow.SendResponse, error) {
+func (m *MeowServiceImpl) SendStickerMessage(ctx context.Context, sessionID, to string, stickerData []byte, mimeType string, contextInfo *waE2E.ContextInfo) (*whatsmeow.SendResponse, error) {
 
 	client, jid, err := m.validateAndGetClient(sessionID, to)
 	if err != nil {
 		return nil, err
 	}
 
-	return client.SendStickerMessage(ctx, jid, stickerData, mimeType)
+	return client.SendStickerMessage(ctx, jid, stickerData, mimeType, contextInfo)
 }
 
 
-func (m *MeowServiceImpl) SendButtonsMessage(ctx context.Context, sessionID, to, text string, buttons []types.Button, footer string) (*whatsmeow.SendResponse, error) {
+func (m *MeowServiceImpl) SendButtonsMessage(ctx context.Context, sessionID, to, text string, buttons []types.Button, footer string, contextInfo *waE2E.ContextInfo) (*whatsmeow.SendResponse, error) {
 
 	client, jid, err := m.validateAndGetClient(sessionID, to)
 	if err != nil {
 		return nil, err
 	}
 
-	return client.SendButtonsMessage(ctx, jid, text, buttons, footer)
+	return client.SendButtonsMessage(ctx, jid, text, buttons, footer, contextInfo)
 }
 
 
-func (m *MeowServiceImpl) SendListMessage(ctx context.Context, sessionID, to, text, buttonText string, sections []types.Section, footer string) (*whatsmeow.SendResponse, error) {
+func (m *MeowServiceImpl) SendListMessage(ctx context.Context, sessionID, to, text, buttonText string, sections []types.Section, footer string, contextInfo *waE2E.ContextInfo) (*whatsmeow.SendResponse, error) {
 
 	client, jid, err := m.validateAndGetClient(sessionID, to)
 	if err != nil {
 		return nil, err
 	}
 
-	return client.SendListMessage(ctx, jid, text, buttonText, sections, footer)
+	return client.SendListMessage(ctx, jid, text, buttonText, sections, footer, contextInfo)
 }
 
 
@@ -815,15 +838,38 @@ func (m *MeowServiceImpl) EditMessage(ctx context.Context, sessionID, chatJID, m
 }
 
 
+// DownloadMedia fetches the media of a stored message, rebuilding the original protobuf so
+// whatsmeow can decrypt it with the keys received with the message.
 func (m *MeowServiceImpl) DownloadMedia(ctx context.Context, sessionID, messageID string) ([]byte, string, error) {
 	m.logger.Infof("Downloading media for message %s in session %s", messageID, sessionID)
 
+	client, exists := m.clientManager.GetClient(sessionID)
+	if !exists {
+		return nil, "", fmt.Errorf("client not found for session %s", sessionID)
+	}
 
+	stored, err := m.messageService.GetMessage(ctx, sessionID, messageID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !stored.HasMedia() {
+		return nil, "", message.ErrMessageHasNoMedia
+	}
+	if len(stored.Raw) == 0 {
+		return nil, "", message.ErrMediaUnavailable
+	}
 
+	var msg waE2E.Message
+	if err := proto.Unmarshal(stored.Raw, &msg); err != nil {
+		return nil, "", fmt.Errorf("failed to decode stored message: %w", err)
+	}
 
+	data, err := client.client.DownloadAny(ctx, &msg)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download media: %w", err)
+	}
 
-
-	return nil, "", fmt.Errorf("media download requires message metadata storage - not implemented in this simplified version")
+	return data, stored.MimeType, nil
 }
 
 
@@ -967,7 +1013,7 @@ func (m *MeowServiceImpl) ReactToMessage(ctx context.Context, sessionID, chatJID
 }
 
 
-func (m *MeowServiceImpl) SendPollMessage(ctx context.Context, sessionID, to, name string, options []string, selectableCount int) (*whatsmeow.SendResponse, error) {
+func (m *MeowServiceImpl) SendPollMessage(ctx context.Context, sessionID, to, name string, options []string, selectableCount int, contextInfo *waE2E.ContextInfo) (*whatsmeow.SendResponse, error) {
 	m.logger.Infof("DEBUG: SendPollMessage called - sessionID: %s, to: %s, name: %s", sessionID, to, name)
 
 
@@ -980,7 +1026,7 @@ func (m *MeowServiceImpl) SendPollMessage(ctx context.Context, sessionID, to, na
 	m.logger.Infof("DEBUG: Client found and JID parsed successfully: %s -> %s", to, jid.String())
 	m.logger.Infof("DEBUG: Calling client.SendPollMessage...")
 
-	resp, err := client.SendPollMessage(ctx, jid, name, options, selectableCount)
+	resp, err := client.SendPollMessage(ctx, jid, name, options, selectableCount, contextInfo)
 	if err != nil {
 		m.logger.Errorf("DEBUG: client.SendPollMessage failed: %v", err)
 		return nil, err
@@ -991,6 +1037,87 @@ func (m *MeowServiceImpl) SendPollMessage(ctx context.Context, sessionID, to, na
 }
 
 
+// RequestHistorySync asks the primary device for up to count messages of a chat older than the
+// anchor message. The anchor is the stored message anchorID, the given anchor fields when that message
+// is not stored, or the oldest stored message of the chat before the given time. The returned job
+// completes when the matching history sync arrives.
+func (m *MeowServiceImpl) RequestHistorySync(ctx context.Context, sessionID, chatJID, anchorID string, anchorFromMe bool, before time.Time, count int) (*message.HistorySyncJob, error) {
+	client, chat, err := m.validateAndGetClient(sessionID, chatJID)
+	if err != nil {
+		return nil, err
+	}
+	chat = chat.ToNonAD()
+
+	if !client.IsConnected() {
+		return nil, fmt.Errorf("client not connected for session %s", sessionID)
+	}
+
+	anchor, err := m.resolveHistoryAnchor(ctx, sessionID, chat, anchorID, anchorFromMe, before)
+	if err != nil {
+		return nil, err
+	}
+
+	job := message.NewHistorySyncJob(sessionID, chat.String(), anchor.ID, count)
+	if err := m.messageService.CreateHistorySyncJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	m.logger.Infof("Requesting %d history messages for chat %s before %s (job %s, session %s)",
+		job.Count, job.ChatJID, anchor.ID, job.ID, sessionID)
+
+	if err := client.RequestHistorySync(ctx, anchor, job.Count); err != nil {
+		job.Fail(err.Error())
+		if updateErr := m.messageService.UpdateHistorySyncJob(ctx, job); updateErr != nil {
+			m.logger.Warnf("Failed to update history sync job %s: %v", job.ID, updateErr)
+		}
+		return nil, fmt.Errorf("failed to request history sync: %w", err)
+	}
+
+	job.MarkRunning()
+	if err := m.messageService.UpdateHistorySyncJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	jobID := job.ID
+	time.AfterFunc(HistorySyncRequestTimeout, func() {
+		client.eventHandler.expireHistorySyncJob(jobID)
+	})
+
+	return job, nil
+}
+
+
+func (m *MeowServiceImpl) resolveHistoryAnchor(ctx context.Context, sessionID string, chat waTypes.JID, anchorID string, anchorFromMe bool, before time.Time) (*waTypes.MessageInfo, error) {
+	var stored *message.Message
+	var err error
+
+	if anchorID != "" {
+		stored, err = m.messageService.GetMessage(ctx, sessionID, anchorID)
+		if err == message.ErrMessageNotFound {
+			if before.IsZero() {
+				return nil, message.ErrNoHistoryAnchor
+			}
+			return &waTypes.MessageInfo{
+				MessageSource: waTypes.MessageSource{Chat: chat, IsFromMe: anchorFromMe},
+				ID:            anchorID,
+				Timestamp:     before,
+			}, nil
+		}
+	} else {
+		stored, err = m.messageService.FindHistoryAnchor(ctx, sessionID, chat.String(), before)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &waTypes.MessageInfo{
+		MessageSource: waTypes.MessageSource{Chat: chat, IsFromMe: stored.FromMe},
+		ID:            stored.ID,
+		Timestamp:     stored.Timestamp,
+	}, nil
+}
+
+
 func (m *MeowServiceImpl) ListGroups(ctx context.Context, sessionID string) ([]*waTypes.GroupInfo, error) {
 	client, exists := m.clientManager.GetClient(sessionID)
 	if !exists {
@@ -1366,3 +1493,111 @@ func (m *MeowServiceImpl) updateSessionStatus(ctx context.Context, sessionID, st
 }
 
 
+// SendPayload sends a validated send request, as queued by the scheduler. Use WithMessageID on
+// ctx to send it under a reserved message ID.
+func (m *MeowServiceImpl) SendPayload(ctx context.Context, sessionID string, p *schedule.Payload) (*whatsmeow.SendResponse, error) {
+	p, cleanup, err := stagePayloadMedia(p)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if p.IsStatus() {
+		return m.SendStatusMessage(ctx, sessionID, p)
+	}
+
+	contextInfo, err := m.payloadContextInfo(ctx, sessionID, p)
+	if err != nil {
+		return nil, err
+	}
+
+	switch p.Kind {
+	case schedule.KindText:
+		return m.SendTextMessage(ctx, sessionID, p.Phone, p.Text, contextInfo, m.linkPreview(ctx, sessionID, p))
+	case schedule.KindImage:
+		return m.SendImageFile(ctx, sessionID, p.Phone, p.MediaPath, p.Caption, p.MimeType, contextInfo, p.ViewOnce)
+	case schedule.KindAudio:
+		return m.SendAudioFile(ctx, sessionID, p.Phone, p.MediaPath, p.MimeType, !p.NoPTT, contextInfo, p.ViewOnce)
+	case schedule.KindDocument:
+		return m.SendDocumentFile(ctx, sessionID, p.Phone, p.MediaPath, p.FileName, p.Caption, p.MimeType, contextInfo)
+	case schedule.KindVideo:
+		return m.SendVideoFile(ctx, sessionID, p.Phone, p.MediaPath, p.Caption, p.MimeType, contextInfo, p.ViewOnce, p.GifPlayback)
+	case schedule.KindSticker:
+		return m.SendStickerMessage(ctx, sessionID, p.Phone, p.Media, p.MimeType, contextInfo)
+	case schedule.KindLocation:
+		return m.SendLocationMessage(ctx, sessionID, p.Phone, p.Latitude, p.Longitude, p.Name, p.Address, contextInfo)
+	case schedule.KindContact:
+		return m.SendContactMessage(ctx, sessionID, p.Phone, p.DisplayName, p.VCard, contextInfo)
+	case schedule.KindButtons:
+		return m.SendButtonsMessage(ctx, sessionID, p.Phone, p.Text, p.Buttons, p.Footer, contextInfo)
+	case schedule.KindList:
+		return m.SendListMessage(ctx, sessionID, p.Phone, p.Text, p.ButtonText, p.Sections, p.Footer, contextInfo)
+	case schedule.KindPoll:
+		return m.SendPollMessage(ctx, sessionID, p.Phone, p.Name, p.Options, p.SelectableCount, contextInfo)
+	default:
+		return nil, schedule.ErrInvalidKind
+	}
+}
+
+
+// stagePayloadMedia readies the payload media the way it is sent: images, audio, documents and
+// videos are uploaded from a file, while stickers are small and converted in memory. Media held
+// in memory, as stored before media was kept on disk, is written to a temporary file that
+// cleanup removes.
+func stagePayloadMedia(p *schedule.Payload) (*schedule.Payload, func(), error) {
+	cleanup := func() {}
+	if !p.HasMedia() {
+		return p, cleanup, nil
+	}
+
+	staged := *p
+	if p.Kind == schedule.KindSticker {
+		if len(p.Media) == 0 {
+			data, err := os.ReadFile(p.MediaPath)
+			if err != nil {
+				return nil, cleanup, Error.WrapError(err, "failed to read sticker")
+			}
+			staged.Media = data
+		}
+		return &staged, cleanup, nil
+	}
+
+	if p.MediaPath == "" {
+		media, err := utils.NewMediaFile(p.Media, p.MimeType)
+		if err != nil {
+			return nil, cleanup, Error.WrapError(err, "failed to stage media")
+		}
+		staged.MediaPath, staged.Media = media.Path, nil
+		cleanup = media.Remove
+	}
+	return &staged, cleanup, nil
+}
+
+
+// GenerateMessageID reserves a WhatsApp message ID for a send that happens later.
+func (m *MeowServiceImpl) GenerateMessageID(sessionID string) string {
+	if client, exists := m.clientManager.GetClient(sessionID); exists && client.client != nil {
+		return string(client.client.GenerateMessageID())
+	}
+	return string(whatsmeow.GenerateMessageID())
+}
+
+
+// NotifyWebhook delivers an event that does not come from a connected client, such as the
+// outcome of a scheduled message, to the session webhook when the event is subscribed.
+func (m *MeowServiceImpl) NotifyWebhook(sessionID, eventType string, data interface{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sess, err := m.sessionService.GetSession(ctx, sessionID)
+	if err != nil {
+		m.logger.Debugf("Failed to get session %s for webhook: %v", sessionID, err)
+		return
+	}
+
+	if !sess.HasWebhook() || !sess.IsEventSubscribed(eventType) {
+		return
+	}
+
+	m.webhookService.SendWebhookAsync(sess.WebhookURL, eventType, sessionID, data)
+}
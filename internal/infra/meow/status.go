@@ -0,0 +1,135 @@
+package meow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"zpmeow/internal/domain/schedule"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
+	waTypes "go.mau.fi/whatsmeow/types"
+)
+
+
+type statusAudienceKey struct{}
+
+
+// statusAudienceContacts narrows the contact list under a context carrying a status audience.
+// whatsmeow sends status updates to every contact allowed by the status privacy settings, read
+// from the contact store, so this is where a send can be limited to a chosen audience. The
+// stored contact info is passed through unchanged, so whatsmeow still leaves out numbers that
+// are not saved contacts and contacts hidden by the privacy settings.
+type statusAudienceContacts struct {
+	store.ContactStore
+}
+
+
+func (s *statusAudienceContacts) GetAllContacts(ctx context.Context) (map[waTypes.JID]waTypes.ContactInfo, error) {
+	audience, ok := ctx.Value(statusAudienceKey{}).([]waTypes.JID)
+	if !ok {
+		return s.ContactStore.GetAllContacts(ctx)
+	}
+
+	contacts := make(map[waTypes.JID]waTypes.ContactInfo, len(audience))
+	for _, jid := range audience {
+		info, err := s.ContactStore.GetContact(ctx, jid)
+		if err != nil {
+			return nil, err
+		}
+		if info.Found {
+			contacts[jid] = info
+		}
+	}
+	return contacts, nil
+}
+
+
+// unsavedContacts returns the audience members that are not saved contacts, which whatsmeow
+// never sends status updates to.
+func unsavedContacts(ctx context.Context, contacts store.ContactStore, audience []waTypes.JID) ([]string, error) {
+	var unsaved []string
+	for _, jid := range audience {
+		info, err := contacts.GetContact(ctx, jid)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Found || info.FullName == "" {
+			unsaved = append(unsaved, jid.User)
+		}
+	}
+	return unsaved, nil
+}
+
+
+// SendStatusMessage posts a text, image or video status update, to the contacts in the payload
+// audience when it has one. An audience naming numbers that are not saved contacts is refused
+// rather than sent to part of it.
+func (m *MeowServiceImpl) SendStatusMessage(ctx context.Context, sessionID string, p *schedule.Payload) (*whatsmeow.SendResponse, error) {
+	client, exists := m.clientManager.GetClient(sessionID)
+	if !exists {
+		return nil, fmt.Errorf("client not found for session %s", sessionID)
+	}
+
+	if len(p.Audience) > 0 {
+		audience := make([]waTypes.JID, 0, len(p.Audience))
+		for _, member := range p.Audience {
+			jid, ok := parseJID(member)
+			if !ok {
+				return nil, fmt.Errorf("invalid JID %s", member)
+			}
+			audience = append(audience, jid.ToNonAD())
+		}
+
+		// With the privacy set to selected contacts whatsmeow sends to that list as it is
+		privacy, err := client.client.GetStatusPrivacy()
+		if err != nil {
+			return nil, Error.WrapError(err, "failed to get status privacy")
+		}
+		if len(privacy) > 0 && privacy[0].Type == waTypes.StatusPrivacyTypeWhitelist {
+			return nil, schedule.ErrStatusAudienceConflict
+		}
+
+		unsaved, err := unsavedContacts(ctx, client.client.Store.Contacts, audience)
+		if err != nil {
+			return nil, Error.WrapError(err, "failed to read contacts")
+		}
+		if len(unsaved) > 0 {
+			m.logger.Warnf("Status audience of session %s has numbers that are not saved contacts: %s", sessionID, strings.Join(unsaved, ", "))
+			return nil, schedule.ErrStatusAudienceNotContact
+		}
+		ctx = context.WithValue(ctx, statusAudienceKey{}, audience)
+	}
+
+	to := waTypes.StatusBroadcastJID
+	switch p.Kind {
+	case schedule.KindText:
+		color := p.BackgroundColor
+		if color == "" {
+			color = schedule.DefaultStatusColor
+		}
+		background, err := schedule.ParseStatusColor(color)
+		if err != nil {
+			return nil, err
+		}
+		return client.SendStatusText(ctx, p.Text, background, int32(p.Font))
+	case schedule.KindImage:
+		return client.SendImageFile(ctx, to, p.MediaPath, p.Caption, p.MimeType, nil, false)
+	case schedule.KindVideo:
+		return client.SendVideoFile(ctx, to, p.MediaPath, p.Caption, p.MimeType, nil, false, false)
+	default:
+		return nil, schedule.ErrStatusKindNotSupported
+	}
+}
+
+
+func (mc *MeowClient) SendStatusText(ctx context.Context, text string, background uint32, font int32) (*whatsmeow.SendResponse, error) {
+	msg := MsgBuilder.BuildStatusTextMessage(text, background, font)
+
+	resp, err := mc.sendAndStore(ctx, waTypes.StatusBroadcastJID, msg)
+	if err != nil {
+		return nil, Error.WrapError(err, "failed to send status update")
+	}
+	return resp, nil
+}
@@ -0,0 +1,56 @@
+package meow
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mau.fi/whatsmeow/store"
+	waTypes "go.mau.fi/whatsmeow/types"
+)
+
+// memoryContacts is a contact store holding a fixed set of contacts
+type memoryContacts struct {
+	store.ContactStore
+
+	contacts map[waTypes.JID]waTypes.ContactInfo
+}
+
+func (m *memoryContacts) GetContact(ctx context.Context, jid waTypes.JID) (waTypes.ContactInfo, error) {
+	return m.contacts[jid], nil
+}
+
+func (m *memoryContacts) GetAllContacts(ctx context.Context) (map[waTypes.JID]waTypes.ContactInfo, error) {
+	return m.contacts, nil
+}
+
+func TestStatusAudienceContacts(t *testing.T) {
+	saved := waTypes.NewJID("5511999999999", waTypes.DefaultUserServer)
+	pushNameOnly := waTypes.NewJID("5511888888888", waTypes.DefaultUserServer)
+	other := waTypes.NewJID("5511777777777", waTypes.DefaultUserServer)
+	stranger := waTypes.NewJID("5511666666666", waTypes.DefaultUserServer)
+
+	contacts := &statusAudienceContacts{&memoryContacts{contacts: map[waTypes.JID]waTypes.ContactInfo{
+		saved:        {Found: true, FullName: "Alice"},
+		pushNameOnly: {Found: true, PushName: "Bob"},
+		other:        {Found: true, FullName: "Carol"},
+	}}}
+
+	all, err := contacts.GetAllContacts(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, all, 3, "without an audience every contact is returned")
+
+	audience := []waTypes.JID{saved, pushNameOnly, stranger}
+	ctx := context.WithValue(context.Background(), statusAudienceKey{}, audience)
+	narrowed, err := contacts.GetAllContacts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[waTypes.JID]waTypes.ContactInfo{
+		saved:        {Found: true, FullName: "Alice"},
+		pushNameOnly: {Found: true, PushName: "Bob"},
+	}, narrowed, "contact info is passed through without filling in names")
+
+	unsaved, err := unsavedContacts(context.Background(), contacts, audience)
+	require.NoError(t, err)
+	assert.Equal(t, []string{pushNameOnly.User, stranger.User}, unsaved)
+}
@@ -0,0 +1,112 @@
+package meow
+
+import (
+	"crypto/sha256"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+)
+
+
+const (
+	// DefaultUploadCacheTTL is how long an uploaded file is reused when no TTL is configured
+	DefaultUploadCacheTTL = time.Hour
+
+	// uploadCacheLimit is how many uploads of a session are remembered at most
+	uploadCacheLimit = 256
+)
+
+
+var uploadCacheTTL atomic.Int64
+
+
+func init() {
+	uploadCacheTTL.Store(int64(DefaultUploadCacheTTL))
+}
+
+
+// SetUploadCacheTTL sets how long uploaded media is reused for later sends of the same content.
+// Zero or less disables the cache.
+func SetUploadCacheTTL(ttl time.Duration) {
+	uploadCacheTTL.Store(int64(ttl))
+}
+
+
+type uploadKey struct {
+	sum       [sha256.Size]byte
+	mediaType whatsmeow.MediaType
+}
+
+
+type uploadEntry struct {
+	uploaded whatsmeow.UploadResponse
+	expires  time.Time
+}
+
+
+// uploadCache remembers the media a session uploaded by content hash and media type, so sending
+// the same file again, as bulk sends and broadcasts do, reuses the encrypted upload instead of
+// encrypting and uploading it once per recipient.
+type uploadCache struct {
+	mu      sync.Mutex
+	entries map[uploadKey]uploadEntry
+}
+
+
+func newUploadCache() *uploadCache {
+	return &uploadCache{entries: make(map[uploadKey]uploadEntry)}
+}
+
+
+func (c *uploadCache) Get(key uploadKey) (whatsmeow.UploadResponse, bool) {
+	if c == nil || uploadCacheTTL.Load() <= 0 {
+		return whatsmeow.UploadResponse{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return whatsmeow.UploadResponse{}, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return whatsmeow.UploadResponse{}, false
+	}
+	return entry.uploaded, true
+}
+
+
+// Put stores an upload, dropping expired entries and then the one closest to expiring once the
+// limit is reached.
+func (c *uploadCache) Put(key uploadKey, uploaded whatsmeow.UploadResponse) {
+	ttl := time.Duration(uploadCacheTTL.Load())
+	if c == nil || ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= uploadCacheLimit {
+		var oldest uploadKey
+		var oldestExpiry time.Time
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+				continue
+			}
+			if oldestExpiry.IsZero() || entry.expires.Before(oldestExpiry) {
+				oldest, oldestExpiry = k, entry.expires
+			}
+		}
+		if len(c.entries) >= uploadCacheLimit {
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[key] = uploadEntry{uploaded: uploaded, expires: now.Add(ttl)}
+}
@@ -0,0 +1,107 @@
+package meow
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mau.fi/whatsmeow"
+)
+
+func testUploadKey(i int) uploadKey {
+	return uploadKey{sum: sha256.Sum256([]byte(fmt.Sprint(i))), mediaType: whatsmeow.MediaImage}
+}
+
+func testUpload(i int) whatsmeow.UploadResponse {
+	return whatsmeow.UploadResponse{DirectPath: fmt.Sprintf("/v/t62/%d", i)}
+}
+
+func withUploadCacheTTL(t *testing.T, ttl time.Duration) {
+	SetUploadCacheTTL(ttl)
+	t.Cleanup(func() { SetUploadCacheTTL(DefaultUploadCacheTTL) })
+}
+
+// expire moves the expiry of an entry, keeping it relative to now
+func expire(c *uploadCache, key uploadKey, in time.Duration) {
+	entry := c.entries[key]
+	entry.expires = time.Now().Add(in)
+	c.entries[key] = entry
+}
+
+func TestUploadCache_GetPut(t *testing.T) {
+	withUploadCacheTTL(t, time.Hour)
+	cache := newUploadCache()
+
+	_, ok := cache.Get(testUploadKey(1))
+	assert.False(t, ok)
+
+	cache.Put(testUploadKey(1), testUpload(1))
+	uploaded, ok := cache.Get(testUploadKey(1))
+	assert.True(t, ok)
+	assert.Equal(t, testUpload(1), uploaded)
+
+	other := testUploadKey(1)
+	other.mediaType = whatsmeow.MediaVideo
+	_, ok = cache.Get(other)
+	assert.False(t, ok, "the same content uploaded as another media type is a different upload")
+}
+
+func TestUploadCache_Expiry(t *testing.T) {
+	withUploadCacheTTL(t, time.Hour)
+	cache := newUploadCache()
+
+	cache.Put(testUploadKey(1), testUpload(1))
+	expire(cache, testUploadKey(1), -time.Second)
+
+	_, ok := cache.Get(testUploadKey(1))
+	assert.False(t, ok)
+	assert.NotContains(t, cache.entries, testUploadKey(1), "expired entries are dropped on lookup")
+}
+
+func TestUploadCache_EvictsAtLimit(t *testing.T) {
+	withUploadCacheTTL(t, time.Hour)
+	cache := newUploadCache()
+
+	for i := 0; i < uploadCacheLimit; i++ {
+		cache.Put(testUploadKey(i), testUpload(i))
+	}
+	expire(cache, testUploadKey(7), time.Minute)
+
+	// Storing a new upload drops the one closest to expiring
+	cache.Put(testUploadKey(uploadCacheLimit), testUpload(uploadCacheLimit))
+	assert.Len(t, cache.entries, uploadCacheLimit)
+	assert.NotContains(t, cache.entries, testUploadKey(7))
+	assert.Contains(t, cache.entries, testUploadKey(uploadCacheLimit))
+
+	// Replacing a stored upload evicts nothing
+	cache.Put(testUploadKey(0), testUpload(0))
+	assert.Len(t, cache.entries, uploadCacheLimit)
+
+	// Expired entries go first, all of them
+	expire(cache, testUploadKey(1), -time.Second)
+	expire(cache, testUploadKey(2), -time.Second)
+	cache.Put(testUploadKey(uploadCacheLimit+1), testUpload(uploadCacheLimit+1))
+	assert.Len(t, cache.entries, uploadCacheLimit-1)
+	assert.NotContains(t, cache.entries, testUploadKey(1))
+	assert.NotContains(t, cache.entries, testUploadKey(2))
+}
+
+func TestUploadCache_Disabled(t *testing.T) {
+	withUploadCacheTTL(t, time.Hour)
+	cache := newUploadCache()
+	cache.Put(testUploadKey(1), testUpload(1))
+
+	SetUploadCacheTTL(0)
+	_, ok := cache.Get(testUploadKey(1))
+	assert.False(t, ok, "a disabled cache returns nothing stored before")
+
+	cache.Put(testUploadKey(2), testUpload(2))
+	assert.NotContains(t, cache.entries, testUploadKey(2))
+
+	var missing *uploadCache
+	missing.Put(testUploadKey(1), testUpload(1))
+	_, ok = missing.Get(testUploadKey(1))
+	assert.False(t, ok)
+}
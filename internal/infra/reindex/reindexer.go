@@ -0,0 +1,143 @@
+package reindex
+
+import (
+	"context"
+	"sync"
+
+	"zpmeow/internal/domain/message"
+	"zpmeow/internal/domain/session"
+	"zpmeow/internal/infra/logger"
+)
+
+// Reindexer rebuilds the search index of sessions in the background after their search language changes
+type Reindexer struct {
+	messageService message.MessageService
+	logger         logger.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[string]*run
+}
+
+// run is the latest reindex of a session; cancel interrupts it when a newer one starts
+type run struct {
+	job    message.ReindexJob
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewReindexer creates a reindexer
+func NewReindexer(messageService message.MessageService) *Reindexer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Reindexer{
+		messageService: messageService,
+		logger:         logger.GetLogger().Sub("reindex"),
+		ctx:            ctx,
+		cancel:         cancel,
+		jobs:           make(map[string]*run),
+	}
+}
+
+// Start reindexes the messages of a session with language in the background. A reindex already
+// running for the session is interrupted first; the messages it rebuilt are not rebuilt again.
+func (r *Reindexer) Start(sessionID, language string) *message.ReindexJob {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := r.jobs[sessionID]
+	if previous != nil {
+		previous.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	current := &run{job: *message.NewReindexJob(sessionID, language), cancel: cancel, done: make(chan struct{})}
+	r.jobs[sessionID] = current
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer close(current.done)
+		defer cancel()
+
+		if previous != nil {
+			<-previous.done
+		}
+		r.run(ctx, current)
+	}()
+
+	job := current.job
+	return &job
+}
+
+// Job returns the latest reindex of a session started since the server started, or nil
+func (r *Reindexer) Job(sessionID string) *message.ReindexJob {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.jobs[sessionID]
+	if !ok {
+		return nil
+	}
+	job := current.job
+	return &job
+}
+
+// Recover restarts the reindex of sessions whose messages are not all indexed with the session
+// search language, as left by a reindex interrupted by a restart. It returns how many were started.
+func (r *Reindexer) Recover(ctx context.Context, sessions []*session.Session) (int, error) {
+	started := 0
+	for _, sess := range sessions {
+		pending, err := r.messageService.CountUnindexed(ctx, sess.ID, sess.GetSearchLanguage())
+		if err != nil {
+			return started, err
+		}
+		if pending > 0 {
+			r.Start(sess.ID, sess.GetSearchLanguage())
+			started++
+		}
+	}
+	return started, nil
+}
+
+// Stop interrupts the running reindexes and waits for them to return. Messages not reindexed yet
+// are picked up by Recover on the next start.
+func (r *Reindexer) Stop() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+func (r *Reindexer) run(ctx context.Context, current *run) {
+	sessionID, language := current.job.SessionID, current.job.Language
+
+	total, err := r.messageService.CountUnindexed(ctx, sessionID, language)
+	if err == nil {
+		r.update(current, func(job *message.ReindexJob) { job.MarkRunning(total) })
+		r.logger.Infof("Reindexing %d messages of session %s with search language %s", total, sessionID, language)
+
+		_, err = r.messageService.ReindexSession(ctx, sessionID, language, func(reindexed int64) {
+			r.update(current, func(job *message.ReindexJob) { job.RecordBatch(reindexed) })
+		})
+	}
+
+	if err != nil {
+		if ctx.Err() != nil {
+			r.update(current, func(job *message.ReindexJob) { job.Fail("reindex interrupted") })
+			return
+		}
+		r.logger.Errorf("Reindex of session %s failed: %v", sessionID, err)
+		r.update(current, func(job *message.ReindexJob) { job.Fail(err.Error()) })
+		return
+	}
+
+	r.update(current, func(job *message.ReindexJob) { job.Complete() })
+	r.logger.Infof("Reindexed messages of session %s with search language %s", sessionID, language)
+}
+
+func (r *Reindexer) update(current *run, fn func(job *message.ReindexJob)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(&current.job)
+}
@@ -0,0 +1,152 @@
+package reindex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"zpmeow/internal/config"
+	"zpmeow/internal/domain/message"
+	"zpmeow/internal/domain/session"
+	"zpmeow/internal/infra/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMessageService reindexes pending messages per session and language in batches of two; anything
+// else panics
+type fakeMessageService struct {
+	message.MessageService
+
+	mu      sync.Mutex
+	pending map[string]int64
+	fail    error
+	// block, when set, holds every batch until it is closed or the reindex is cancelled
+	block chan struct{}
+}
+
+func (f *fakeMessageService) CountUnindexed(ctx context.Context, sessionID, language string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pending[sessionID+"/"+language], nil
+}
+
+func (f *fakeMessageService) ReindexSession(ctx context.Context, sessionID, language string, progress func(int64)) (int64, error) {
+	var total int64
+	for {
+		if f.block != nil {
+			select {
+			case <-f.block:
+			case <-ctx.Done():
+				return total, ctx.Err()
+			}
+		}
+		if f.fail != nil {
+			return total, f.fail
+		}
+
+		f.mu.Lock()
+		n := min(f.pending[sessionID+"/"+language], 2)
+		f.pending[sessionID+"/"+language] -= n
+		f.mu.Unlock()
+
+		if n == 0 {
+			return total, nil
+		}
+		total += n
+		progress(n)
+	}
+}
+
+func newTestReindexer(service message.MessageService) *Reindexer {
+	logger.SetLogger(logger.Initialize(&config.LoggerConfig{Level: "fatal", Format: "console"}))
+	return NewReindexer(service)
+}
+
+// waitFinished waits for the latest reindex of a session to complete or fail
+func waitFinished(t *testing.T, r *Reindexer, sessionID string) *message.ReindexJob {
+	var job *message.ReindexJob
+	require.Eventually(t, func() bool {
+		job = r.Job(sessionID)
+		return job != nil && job.Status.IsFinished()
+	}, 5*time.Second, 5*time.Millisecond)
+	return job
+}
+
+func TestReindexer_ReportsProgress(t *testing.T) {
+	service := &fakeMessageService{pending: map[string]int64{"session-1/english": 5}}
+	r := newTestReindexer(service)
+	defer r.Stop()
+
+	assert.Nil(t, r.Job("session-1"))
+
+	job := r.Start("session-1", "english")
+	assert.Equal(t, "english", job.Language)
+
+	job = waitFinished(t, r, "session-1")
+	assert.Equal(t, message.JobStatusCompleted, job.Status)
+	assert.Equal(t, int64(5), job.Total)
+	assert.Equal(t, int64(5), job.Reindexed)
+	assert.False(t, job.CompletedAt.IsZero())
+}
+
+func TestReindexer_Failure(t *testing.T) {
+	service := &fakeMessageService{pending: map[string]int64{"session-1/english": 5}, fail: errors.New("database is down")}
+	r := newTestReindexer(service)
+	defer r.Stop()
+
+	r.Start("session-1", "english")
+	job := waitFinished(t, r, "session-1")
+	assert.Equal(t, message.JobStatusFailed, job.Status)
+	assert.Equal(t, "database is down", job.Error)
+}
+
+func TestReindexer_RestartInterruptsPrevious(t *testing.T) {
+	service := &fakeMessageService{
+		pending: map[string]int64{"session-1/english": 5, "session-1/spanish": 3},
+		block:   make(chan struct{}),
+	}
+	r := newTestReindexer(service)
+	defer r.Stop()
+
+	first := r.Start("session-1", "english")
+	r.Start("session-1", "spanish")
+	close(service.block)
+
+	job := waitFinished(t, r, "session-1")
+	assert.Equal(t, "spanish", job.Language)
+	assert.Equal(t, message.JobStatusCompleted, job.Status)
+	assert.Equal(t, int64(3), job.Reindexed)
+	assert.Equal(t, message.JobStatusPending, first.Status, "Start returns a snapshot")
+}
+
+func TestReindexer_StopAndRecover(t *testing.T) {
+	service := &fakeMessageService{
+		pending: map[string]int64{"session-1/english": 5},
+		block:   make(chan struct{}),
+	}
+	r := newTestReindexer(service)
+
+	r.Start("session-1", "english")
+	r.Stop()
+	job := r.Job("session-1")
+	assert.Equal(t, message.JobStatusFailed, job.Status)
+	assert.Equal(t, "reindex interrupted", job.Error)
+
+	service.block = nil
+	sessions := []*session.Session{
+		{ID: "session-1", SearchLanguage: "english"},
+		{ID: "session-2", SearchLanguage: "english"},
+	}
+	r = newTestReindexer(service)
+	defer r.Stop()
+
+	started, err := r.Recover(context.Background(), sessions)
+	require.NoError(t, err)
+	assert.Equal(t, 1, started, "only sessions with messages left to reindex are resumed")
+	assert.Equal(t, message.JobStatusCompleted, waitFinished(t, r, "session-1").Status)
+	assert.Nil(t, r.Job("session-2"))
+}
@@ -0,0 +1,185 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"zpmeow/internal/domain/schedule"
+	"zpmeow/internal/infra/logger"
+	"zpmeow/internal/infra/meow"
+
+	"go.mau.fi/whatsmeow"
+)
+
+// EventScheduledMessage is the webhook event reporting the outcome of a scheduled message
+const EventScheduledMessage = "scheduled_message"
+
+// claimBatchSize bounds how many due messages one tick sends
+const claimBatchSize = 50
+
+// Sender delivers queued payloads and reports their outcome
+type Sender interface {
+	IsClientConnected(sessionID string) bool
+	ConnectedSessions() []string
+	SendPayload(ctx context.Context, sessionID string, p *schedule.Payload) (*whatsmeow.SendResponse, error)
+	NotifyWebhook(sessionID, eventType string, data interface{})
+}
+
+// Scheduler sends queued messages once they are due
+type Scheduler struct {
+	scheduleService schedule.ScheduleService
+	sender          Sender
+	interval        time.Duration
+	logger          logger.Logger
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewScheduler creates a scheduler that checks the queue every interval
+func NewScheduler(scheduleService schedule.ScheduleService, sender Sender, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	return &Scheduler{
+		scheduleService: scheduleService,
+		sender:          sender,
+		interval:        interval,
+		logger:          logger.GetLogger().Sub("scheduler"),
+	}
+}
+
+// Start requeues messages left behind by a previous run and processes the queue in the
+// background until Stop is called or ctx is done
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return
+	}
+
+	if released, err := s.scheduleService.ReleaseStale(ctx); err != nil {
+		s.logger.Errorf("Failed to requeue stale scheduled messages: %v", err)
+	} else if released > 0 {
+		s.logger.Warnf("Requeued %d scheduled messages left sending by a previous run", released)
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go s.loop(ctx, s.done)
+
+	s.logger.Infof("Message scheduler started (interval %s)", s.interval)
+}
+
+// Stop stops the background loop and waits for the current batch to finish
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+		s.cancel = nil
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce sends the messages that are due now. Messages held for offline sessions are not
+// claimed; one whose session dropped after the claim is held and due again right away, so it
+// still stops claiming once a batch held everything it got.
+func (s *Scheduler) RunOnce(ctx context.Context) {
+	connected := s.sender.ConnectedSessions()
+	for {
+		due, err := s.scheduleService.ClaimDue(ctx, claimBatchSize, connected)
+		if err != nil {
+			s.logger.Errorf("Failed to claim due scheduled messages: %v", err)
+			return
+		}
+
+		held := 0
+		for _, msg := range due {
+			if !s.process(msg) {
+				held++
+			}
+		}
+
+		if len(due) < claimBatchSize || held == len(due) || ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// process sends one claimed message and reports whether it left the held state. It uses its
+// own context so a shutdown does not leave a message half way through.
+func (s *Scheduler) process(msg *schedule.ScheduledMessage) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	now := time.Now()
+
+	if !s.sender.IsClientConnected(msg.SessionID) {
+		if msg.WhenOffline == schedule.OfflineSkip {
+			msg.MarkSkipped("session is not connected", now)
+			s.finish(ctx, msg)
+			return true
+		}
+		msg.Hold("session is not connected", now)
+		s.save(ctx, msg)
+		return false
+	}
+
+	resp, err := s.sender.SendPayload(meow.WithMessageID(ctx, msg.MessageID), msg.SessionID, &msg.Payload)
+	now = time.Now()
+
+	switch {
+	case err == nil:
+		msg.MarkSent(string(resp.ID), now)
+	case msg.Attempts < schedule.MaxAttempts:
+		s.logger.Warnf("Scheduled message %s of session %s failed (attempt %d): %v", msg.ID, msg.SessionID, msg.Attempts, err)
+		msg.Retry(err.Error(), now.Add(time.Duration(msg.Attempts)*schedule.RetryDelay), now)
+		s.save(ctx, msg)
+		return true
+	default:
+		msg.MarkFailed(err.Error(), now)
+	}
+
+	s.finish(ctx, msg)
+	return true
+}
+
+func (s *Scheduler) save(ctx context.Context, msg *schedule.ScheduledMessage) bool {
+	if err := s.scheduleService.RecordOutcome(ctx, msg); err != nil {
+		s.logger.Errorf("Failed to update scheduled message %s: %v", msg.ID, err)
+		return false
+	}
+	return true
+}
+
+// finish records a final outcome and reports it over webhook
+func (s *Scheduler) finish(ctx context.Context, msg *schedule.ScheduledMessage) {
+	s.logger.Infof("Scheduled message %s of session %s %s", msg.ID, msg.SessionID, msg.Status)
+
+	if s.save(ctx, msg) {
+		s.sender.NotifyWebhook(msg.SessionID, EventScheduledMessage, schedule.NewScheduledMessageDTO(msg))
+	}
+}
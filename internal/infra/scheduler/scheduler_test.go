@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"zpmeow/internal/config"
+	"zpmeow/internal/domain/schedule"
+	"zpmeow/internal/infra/logger"
+
+	"github.com/stretchr/testify/assert"
+	"go.mau.fi/whatsmeow"
+	waTypes "go.mau.fi/whatsmeow/types"
+)
+
+// queueService keeps scheduled messages in memory and claims them like the Postgres repository:
+// oldest first, leaving messages held for sessions that are not connected in the queue
+type queueService struct {
+	schedule.ScheduleService
+
+	queue  []*schedule.ScheduledMessage
+	claims int
+}
+
+func (q *queueService) ClaimDue(ctx context.Context, limit int, connected []string) ([]*schedule.ScheduledMessage, error) {
+	q.claims++
+
+	var due []*schedule.ScheduledMessage
+	for _, msg := range q.queue {
+		if len(due) == limit {
+			break
+		}
+		if msg.Status != schedule.StatusScheduled {
+			continue
+		}
+		if msg.WhenOffline == schedule.OfflineHold && !contains(connected, msg.SessionID) {
+			continue
+		}
+		msg.Status = schedule.StatusSending
+		msg.Attempts++
+		due = append(due, msg)
+	}
+	return due, nil
+}
+
+func (q *queueService) RecordOutcome(ctx context.Context, msg *schedule.ScheduledMessage) error {
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeSender has one connected session and records what it sent
+type fakeSender struct {
+	connected string
+	sent      []string
+}
+
+func (f *fakeSender) IsClientConnected(sessionID string) bool {
+	return sessionID == f.connected
+}
+
+func (f *fakeSender) ConnectedSessions() []string {
+	return []string{f.connected}
+}
+
+func (f *fakeSender) SendPayload(ctx context.Context, sessionID string, p *schedule.Payload) (*whatsmeow.SendResponse, error) {
+	f.sent = append(f.sent, sessionID)
+	return &whatsmeow.SendResponse{ID: waTypes.MessageID(fmt.Sprintf("3EB0%016d", len(f.sent)))}, nil
+}
+
+func (f *fakeSender) NotifyWebhook(sessionID, eventType string, data interface{}) {}
+
+func newTestMessage(sessionID string, sendAt time.Time) *schedule.ScheduledMessage {
+	return schedule.NewScheduledMessage(sessionID, "", schedule.Payload{
+		Kind:  schedule.KindText,
+		Phone: "5511999999999",
+		Text:  "hello",
+	}, sendAt, "")
+}
+
+func TestRunOnce_HeldSessionDoesNotBlockOthers(t *testing.T) {
+	logger.SetLogger(logger.Initialize(&config.LoggerConfig{Level: "fatal", Format: "console"}))
+
+	oldest := time.Now().Add(-time.Hour)
+	service := &queueService{}
+	for i := 0; i < 2*claimBatchSize; i++ {
+		service.queue = append(service.queue, newTestMessage("offline", oldest))
+	}
+	connected := newTestMessage("online", time.Now().Add(-time.Minute))
+	service.queue = append(service.queue, connected)
+
+	sender := &fakeSender{connected: "online"}
+	s := NewScheduler(service, sender, time.Minute)
+	s.RunOnce(context.Background())
+
+	assert.Equal(t, []string{"online"}, sender.sent)
+	assert.Equal(t, schedule.StatusSent, connected.Status)
+	for _, msg := range service.queue[:2*claimBatchSize] {
+		assert.Equal(t, schedule.StatusScheduled, msg.Status)
+		assert.Zero(t, msg.Attempts)
+	}
+}
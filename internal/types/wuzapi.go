@@ -7,10 +7,25 @@ import (
 
 
 type SendTextRequest struct {
-	Phone       string      `json:"phone" binding:"required" example:"+5511999999999"`
-	Body        string      `json:"body" binding:"required" example:"Hello, World!"`
-	ID          string      `json:"id,omitempty" example:"custom-message-id"`
-	ContextInfo ContextInfo `json:"contextInfo,omitempty"`
+	Phone         string       `json:"phone" binding:"required" example:"+5511999999999"`
+	Body          string       `json:"body" binding:"required" example:"Hello, World!"`
+	ID            string       `json:"id,omitempty" example:"custom-message-id"`
+	ContextInfo   ContextInfo  `json:"contextInfo,omitempty"`
+	LinkPreview   *LinkPreview `json:"linkPreview,omitempty"`
+	NoLinkPreview bool         `json:"noLinkPreview,omitempty" example:"false"`
+	MentionOptions
+	ReplyOptions
+	ScheduleOptions
+}
+
+
+// LinkPreview is a preview card supplied by the caller instead of the one fetched for the first
+// link in the body. The thumbnail is an image as a data URI, base64 string or URL.
+type LinkPreview struct {
+	MatchedText string `json:"matchedText,omitempty" example:"https://example.com/sale"`
+	Title       string `json:"title" example:"Summer sale"`
+	Description string `json:"description,omitempty" example:"Up to 50% off this week"`
+	Thumbnail   string `json:"thumbnail,omitempty" example:"https://example.com/banner.jpg"`
 }
 
 
@@ -20,7 +35,12 @@ type SendImageRequest struct {
 	Caption     string      `json:"caption,omitempty" example:"Image caption"`
 	ID          string      `json:"id,omitempty" example:"custom-message-id"`
 	MimeType    string      `json:"mimeType,omitempty" example:"image/jpeg"`
+	Compress    bool        `json:"compress,omitempty" example:"false"`
+	ViewOnce    bool        `json:"viewOnce,omitempty" example:"false"`
 	ContextInfo ContextInfo `json:"contextInfo,omitempty"`
+	MentionOptions
+	ReplyOptions
+	ScheduleOptions
 }
 
 
@@ -29,7 +49,11 @@ type SendAudioRequest struct {
 	Audio       string      `json:"audio" binding:"required" example:"data:audio/ogg;base64,T2dnU..."`
 	Caption     string      `json:"caption,omitempty" example:"Audio caption"`
 	ID          string      `json:"id,omitempty" example:"custom-message-id"`
+	PTT         *bool       `json:"ptt,omitempty" example:"true"`
+	ViewOnce    bool        `json:"viewOnce,omitempty" example:"false"`
 	ContextInfo ContextInfo `json:"contextInfo,omitempty"`
+	ReplyOptions
+	ScheduleOptions
 }
 
 
@@ -40,6 +64,9 @@ type SendDocumentRequest struct {
 	Caption     string      `json:"caption,omitempty" example:"Document caption"`
 	ID          string      `json:"id,omitempty" example:"custom-message-id"`
 	ContextInfo ContextInfo `json:"contextInfo,omitempty"`
+	MentionOptions
+	ReplyOptions
+	ScheduleOptions
 }
 
 
@@ -48,7 +75,13 @@ type SendVideoRequest struct {
 	Video       string      `json:"video" binding:"required" example:"data:video/mp4;base64,AAAAIGZ0eXA..."`
 	Caption     string      `json:"caption,omitempty" example:"Video caption"`
 	ID          string      `json:"id,omitempty" example:"custom-message-id"`
+	GifPlayback bool        `json:"gifPlayback,omitempty" example:"false"`
+	Transcode   bool        `json:"transcode,omitempty" example:"false"`
+	ViewOnce    bool        `json:"viewOnce,omitempty" example:"false"`
 	ContextInfo ContextInfo `json:"contextInfo,omitempty"`
+	MentionOptions
+	ReplyOptions
+	ScheduleOptions
 }
 
 
@@ -56,7 +89,28 @@ type SendStickerRequest struct {
 	Phone       string      `json:"phone" binding:"required" example:"+5511999999999"`
 	Sticker     string      `json:"sticker" binding:"required" example:"data:image/webp;base64,UklGRv4..."`
 	ID          string      `json:"id,omitempty" example:"custom-message-id"`
+	PackName    string      `json:"packName,omitempty" example:"My stickers"`
+	Author      string      `json:"author,omitempty" example:"John Doe"`
 	ContextInfo ContextInfo `json:"contextInfo,omitempty"`
+	ReplyOptions
+	ScheduleOptions
+}
+
+
+// SendStatusRequest posts a status update. Text statuses take text, drawn in font on
+// backgroundColor; image and video statuses take media and an optional caption. audience
+// limits the update to these contacts instead of everyone the status privacy allows.
+type SendStatusRequest struct {
+	Type            string   `json:"type" form:"type" binding:"required" example:"text" enums:"text,image,video"`
+	Text            string   `json:"text,omitempty" form:"text" example:"Open today until 10pm"`
+	BackgroundColor string   `json:"backgroundColor,omitempty" form:"backgroundColor" example:"#128C7E"`
+	Font            int      `json:"font,omitempty" form:"font" example:"0" enums:"0,1,2,6,7,8,9,10"`
+	Media           string   `json:"media,omitempty" form:"media" example:"data:image/jpeg;base64,/9j/4AAQ..."`
+	Caption         string   `json:"caption,omitempty" form:"caption" example:"New arrivals"`
+	MimeType        string   `json:"mimeType,omitempty" form:"mimeType" example:"image/jpeg"`
+	Audience        []string `json:"audience,omitempty" form:"audience" example:"5511999999999"`
+	ID              string   `json:"id,omitempty" form:"id" example:"custom-message-id"`
+	ScheduleOptions
 }
 
 
@@ -69,7 +123,15 @@ type SendMediaRequest struct {
 	Filename    string      `json:"filename" form:"filename" example:"document.pdf"`
 	ID          string      `json:"id" form:"id" example:"custom-message-id"`
 	MimeType    string      `json:"mimeType" form:"mimeType" example:"image/jpeg"`
+	PTT         *bool       `json:"ptt,omitempty" form:"ptt" example:"true"`
+	Compress    bool        `json:"compress" form:"compress" example:"false"`
+	GifPlayback bool        `json:"gifPlayback" form:"gifPlayback" example:"false"`
+	Transcode   bool        `json:"transcode" form:"transcode" example:"false"`
+	ViewOnce    bool        `json:"viewOnce" form:"viewOnce" example:"false"`
 	ContextInfo ContextInfo `json:"contextInfo,omitempty"`
+	MentionOptions
+	ReplyOptions
+	ScheduleOptions
 }
 
 
@@ -80,6 +142,8 @@ type SendLocationRequest struct {
 	Name      string  `json:"name,omitempty" example:"São Paulo"`
 	Address   string  `json:"address,omitempty" example:"São Paulo, SP, Brazil"`
 	ID        string  `json:"id,omitempty" example:"custom-message-id"`
+	ReplyOptions
+	ScheduleOptions
 }
 
 
@@ -88,6 +152,8 @@ type SendContactRequest struct {
 	Contact     Contact     `json:"contact" binding:"required"`
 	ID          string      `json:"id,omitempty" example:"custom-message-id"`
 	ContextInfo ContextInfo `json:"contextInfo,omitempty"`
+	ReplyOptions
+	ScheduleOptions
 }
 
 
@@ -97,6 +163,8 @@ type SendButtonsRequest struct {
 	Buttons    []Button `json:"buttons" binding:"required"`
 	Footer     string   `json:"footer,omitempty" example:"Footer text"`
 	ID         string   `json:"id,omitempty" example:"custom-message-id"`
+	ReplyOptions
+	ScheduleOptions
 }
 
 
@@ -107,6 +175,8 @@ type SendListRequest struct {
 	Sections   []Section `json:"sections" binding:"required"`
 	Footer     string    `json:"footer,omitempty" example:"Footer text"`
 	ID         string    `json:"id,omitempty" example:"custom-message-id"`
+	ReplyOptions
+	ScheduleOptions
 }
 
 
@@ -116,6 +186,73 @@ type SendPollRequest struct {
 	Options         []string `json:"options" binding:"required" example:"Red,Blue,Green"`
 	SelectableCount int      `json:"selectableCount,omitempty" example:"1"`
 	ID              string   `json:"id,omitempty" example:"custom-message-id"`
+	ReplyOptions
+	ScheduleOptions
+}
+
+
+// SendTemplateRequest sends a stored template, found by ID or name, filling its placeholders
+// from variables.
+type SendTemplateRequest struct {
+	Phone     string            `json:"phone" binding:"required" example:"+5511999999999"`
+	Template  string            `json:"template" binding:"required" example:"order-shipped"`
+	Variables map[string]string `json:"variables,omitempty"`
+	ID        string            `json:"id,omitempty" example:"custom-message-id"`
+	ReplyOptions
+	ScheduleOptions
+}
+
+
+// ScheduleOptions defers a send: with sendAt the message is queued and delivered at that time.
+// whenOffline says whether a due message waits for a disconnected session (hold) or is dropped (skip).
+type ScheduleOptions struct {
+	SendAt      string `json:"sendAt,omitempty" form:"sendAt" example:"2025-01-31T09:00:00-03:00"`
+	WhenOffline string `json:"whenOffline,omitempty" form:"whenOffline" example:"hold" enums:"hold,skip"`
+}
+
+
+// ReplyOptions makes a send a reply that quotes an earlier message of the session, found by its
+// ID among recent messages or in the message store.
+type ReplyOptions struct {
+	ReplyTo *ReplyTo `json:"replyTo,omitempty"`
+}
+
+
+type ReplyTo struct {
+	MessageID string `json:"messageId" binding:"required" example:"3EB0C431C26A1916E07A"`
+}
+
+
+// MentionOptions mentions people in a text or caption. Mentions are phone numbers or JIDs;
+// @5511999999999 tokens in the text are mentioned too. mentionAll mentions every participant of
+// the group the message is sent to.
+type MentionOptions struct {
+	Mentions   []string `json:"mentions,omitempty" form:"mentions" example:"5511999999999"`
+	MentionAll bool     `json:"mentionAll,omitempty" form:"mentionAll" example:"false"`
+}
+
+
+// MessageContent holds the fields of a message of any type, for endpoints where the type is a
+// separate field; media is a data URI, base64 string or URL.
+type MessageContent struct {
+	Body            string    `json:"body,omitempty" example:"Hi {{name}}, our sale starts today!"`
+	Media           string    `json:"media,omitempty" example:"https://example.com/banner.jpg"`
+	Caption         string    `json:"caption,omitempty" example:"Hi {{name}}"`
+	MimeType        string    `json:"mimeType,omitempty" example:"image/jpeg"`
+	FileName        string    `json:"fileName,omitempty" example:"catalog.pdf"`
+	Latitude        float64   `json:"latitude,omitempty" example:"-23.5505"`
+	Longitude       float64   `json:"longitude,omitempty" example:"-46.6333"`
+	Name            string    `json:"name,omitempty" example:"Store"`
+	Address         string    `json:"address,omitempty" example:"Av. Paulista, 1000"`
+	DisplayName     string    `json:"displayName,omitempty" example:"Support"`
+	VCard           string    `json:"vcard,omitempty"`
+	Buttons         []Button  `json:"buttons,omitempty"`
+	ButtonText      string    `json:"buttonText,omitempty" example:"Options"`
+	Sections        []Section `json:"sections,omitempty"`
+	Footer          string    `json:"footer,omitempty" example:"Reply STOP to opt out"`
+	Options         []string  `json:"options,omitempty"`
+	SelectableCount int       `json:"selectableCount,omitempty" example:"1"`
+	NoLinkPreview   bool      `json:"noLinkPreview,omitempty" example:"false"`
 }
 
 
@@ -305,6 +442,65 @@ type ChatEditRequest struct {
 }
 
 
+// ChatForwardRequest forwards a stored message to one or more chats.
+type ChatForwardRequest struct {
+	MessageID string   `json:"messageId" binding:"required" example:"3EB0C431C26A1916E07A"`
+	To        []string `json:"to" binding:"required,min=1,max=50" example:"5511999999999,120363313346913103@g.us"`
+}
+
+
+// ForwardResult is the outcome of forwarding a message to one target.
+type ForwardResult struct {
+	To        string `json:"to" example:"5511999999999"`
+	Success   bool   `json:"success" example:"true"`
+	ID        string `json:"id,omitempty" example:"3EB0C431C26A1916E07B"`
+	Timestamp int64  `json:"timestamp,omitempty" example:"1640995200"`
+	Error     string `json:"error,omitempty"`
+}
+
+
+type ChatForwardResponse struct {
+	MessageID string          `json:"messageId" example:"3EB0C431C26A1916E07A"`
+	Results   []ForwardResult `json:"results"`
+}
+
+
+// SendAlbumRequest sends images and videos grouped in one album bubble. The request id, when
+// given, becomes the ID of the album message.
+type SendAlbumRequest struct {
+	Phone string      `json:"phone" binding:"required" example:"+5511999999999"`
+	Items []AlbumItem `json:"items" binding:"required,min=2,max=30,dive"`
+	ID    string      `json:"id,omitempty" example:"custom-message-id"`
+}
+
+
+// AlbumItem is one image or video of an album, with the caption shown under it.
+type AlbumItem struct {
+	Type     string `json:"type" binding:"required,oneof=image video" example:"image" enums:"image,video"`
+	Media    string `json:"media" binding:"required" example:"data:image/jpeg;base64,/9j/4AAQ..."`
+	Caption  string `json:"caption,omitempty" example:"Living room"`
+	MimeType string `json:"mimeType,omitempty" example:"image/jpeg"`
+}
+
+
+// AlbumItemResult is the outcome of sending one item of an album.
+type AlbumItemResult struct {
+	Index     int    `json:"index" example:"0"`
+	Type      string `json:"type" example:"image"`
+	Success   bool   `json:"success" example:"true"`
+	ID        string `json:"id,omitempty" example:"3EB0C431C26A1916E07B"`
+	Timestamp int64  `json:"timestamp,omitempty" example:"1640995200"`
+	Error     string `json:"error,omitempty"`
+}
+
+
+// SendAlbumResponse holds the album message and each of its items, in request order.
+type SendAlbumResponse struct {
+	Album SendResponse      `json:"album"`
+	Items []AlbumItemResult `json:"items"`
+}
+
+
 type ChatDownloadRequest struct {
 	MessageID string `json:"messageId" binding:"required" example:"3EB0C431C26A1916E07A"`
 	Phone     string `json:"phone,omitempty" example:"+5511999999999"`
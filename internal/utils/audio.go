@@ -0,0 +1,246 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+
+const (
+	// VoiceNoteMimeType is the only format WhatsApp plays as a voice note
+	VoiceNoteMimeType = "audio/ogg; codecs=opus"
+
+	// WaveformSamples is the number of bars in a voice note waveform
+	WaveformSamples = 64
+
+	// waveformSampleRate is the rate audio is decoded at to measure it
+	waveformSampleRate = 8000
+
+	// opusGranuleRate is the clock of Ogg/Opus granule positions, whatever the input rate
+	opusGranuleRate = 48000
+
+	// audioSniffSize is how much of an audio file is read to detect its format
+	audioSniffSize = 4096
+
+	// maxOggPageSize is the largest an Ogg page can be: its header with 255 lacing values, each
+	// for a 255 byte segment
+	maxOggPageSize = 27 + 255 + 255*255
+)
+
+
+// playableAudioTypes are the audio formats WhatsApp clients play as a regular audio file
+var playableAudioTypes = map[string]bool{
+	"audio/ogg":  true,
+	"audio/mpeg": true,
+	"audio/mp4":  true,
+	"audio/aac":  true,
+	"audio/amr":  true,
+}
+
+
+// Audio is audio ready to upload, with the duration and waveform shown by WhatsApp. PTT says
+// whether it goes out as a voice note. Path is the file to upload: the input file, or a
+// temporary file when the audio was transcoded, which Remove deletes.
+type Audio struct {
+	Path     string
+	MimeType string
+	Seconds  uint32
+	Waveform []byte
+	PTT      bool
+
+	transcoded bool
+}
+
+
+// PrepareAudio readies the audio file at path for sending. Voice notes are transcoded to
+// OGG/Opus, and other audio only when WhatsApp cannot play its format. The format is sniffed
+// from the content, since uploads are often labelled with the wrong MIME type. The duration and
+// waveform are measured by decoding the result. Without a local ffmpeg the file is sent as
+// received, and only the duration of OGG/Opus files is read from the container; a voice note in
+// another format, which WhatsApp could not play, goes out as a regular audio file instead.
+func PrepareAudio(ctx context.Context, path string, mimeType string, ptt bool) (*Audio, error) {
+	head, err := readFileHead(path, audioSniffSize)
+	if err != nil {
+		return nil, err
+	}
+
+	audio := &Audio{Path: path, MimeType: mimeType, PTT: ptt}
+	format := sniffAudioType(head)
+	if format != "" {
+		audio.MimeType = format
+	}
+
+	if !HasFFmpeg() {
+		if isOggOpus(head) {
+			audio.MimeType = VoiceNoteMimeType
+			audio.Seconds = oggOpusFileDuration(path)
+		} else {
+			audio.PTT = false
+		}
+		return audio, nil
+	}
+
+	opus := isOggOpus(head)
+	if (ptt && !opus) || !playableAudioTypes[format] {
+		transcoded, err := ffmpegToTempFile(ctx, path, ".ogg",
+			"-vn", "-map_metadata", "-1",
+			"-ac", "1", "-ar", strconv.Itoa(opusGranuleRate),
+			"-c:a", "libopus", "-b:a", "32k", "-application", "voip",
+			"-f", "ogg")
+		if err != nil {
+			return nil, fmt.Errorf("failed to transcode audio: %w", err)
+		}
+		audio.Path, audio.transcoded, opus = transcoded, true, true
+	}
+	if opus {
+		audio.MimeType = VoiceNoteMimeType
+	}
+
+	seconds, waveform, err := measureAudio(ctx, audio.Path)
+	if err != nil {
+		audio.Remove()
+		return nil, fmt.Errorf("failed to read audio: %w", err)
+	}
+	audio.Seconds, audio.Waveform = seconds, waveform
+	return audio, nil
+}
+
+
+// Remove deletes the transcoded file, if the audio was transcoded. The input file is left to
+// its owner.
+func (a *Audio) Remove() {
+	if a != nil && a.transcoded {
+		os.Remove(a.Path)
+	}
+}
+
+
+// measureAudio decodes audio to mono PCM and returns its duration and waveform.
+func measureAudio(ctx context.Context, path string) (uint32, []byte, error) {
+	pcm, err := runFFmpeg(ctx, path,
+		"-vn", "-ac", "1", "-ar", strconv.Itoa(waveformSampleRate),
+		"-f", "s16le", "pipe:1")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	samples := len(pcm) / 2
+	if samples == 0 {
+		return 0, nil, fmt.Errorf("audio has no samples")
+	}
+
+	seconds := uint32(math.Ceil(float64(samples) / waveformSampleRate))
+	return seconds, audioWaveform(pcm, samples), nil
+}
+
+
+// audioWaveform splits 16-bit PCM into WaveformSamples bars of its mean loudness, scaled so the
+// loudest bar is 100.
+func audioWaveform(pcm []byte, samples int) []byte {
+	levels := make([]float64, WaveformSamples)
+	for i := range levels {
+		start, end := i*samples/WaveformSamples, (i+1)*samples/WaveformSamples
+		if end <= start {
+			continue
+		}
+		var sum float64
+		for j := start; j < end; j++ {
+			sum += math.Abs(float64(int16(binary.LittleEndian.Uint16(pcm[2*j:]))))
+		}
+		levels[i] = sum / float64(end-start)
+	}
+
+	waveform := make([]byte, WaveformSamples)
+	peak := slices.Max(levels)
+	if peak == 0 {
+		return waveform
+	}
+	for i, level := range levels {
+		waveform[i] = byte(math.Round(level / peak * 100))
+	}
+	return waveform
+}
+
+
+// isOggOpus reports whether data is an Ogg container holding an Opus stream.
+func isOggOpus(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("OggS")) && bytes.Contains(data[:min(len(data), 128)], []byte("OpusHead"))
+}
+
+
+// oggOpusDuration reads the duration of an Ogg/Opus file from the granule position of its last
+// page, less the pre-skip of the Opus header. It returns 0 when the file cannot be read.
+func oggOpusDuration(data []byte) uint32 {
+	head := bytes.Index(data, []byte("OpusHead"))
+	last := bytes.LastIndex(data, []byte("OggS"))
+	if head < 0 || len(data) < head+12 || last < 0 || len(data) < last+14 || data[last+4] != 0 {
+		return 0
+	}
+
+	granule := int64(binary.LittleEndian.Uint64(data[last+6:]))
+	preSkip := int64(binary.LittleEndian.Uint16(data[head+10:]))
+	if granule <= preSkip {
+		return 0
+	}
+	return uint32(math.Ceil(float64(granule-preSkip) / opusGranuleRate))
+}
+
+
+// oggOpusFileDuration is oggOpusDuration for an Ogg/Opus file on disk. Only the head, holding
+// the Opus header, and the tail, holding the last page, are read.
+func oggOpusFileDuration(path string) uint32 {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0
+	}
+
+	size := stat.Size()
+	if size > audioSniffSize+maxOggPageSize {
+		size = audioSniffSize + maxOggPageSize
+	}
+	data := make([]byte, size)
+	if _, err := file.ReadAt(data[:min(size, audioSniffSize)], 0); err != nil {
+		return 0
+	}
+	if size > audioSniffSize {
+		if _, err := file.ReadAt(data[audioSniffSize:], stat.Size()-(size-audioSniffSize)); err != nil {
+			return 0
+		}
+	}
+	return oggOpusDuration(data)
+}
+
+
+// sniffAudioType detects the audio format from its content, or returns "" when it is unknown.
+func sniffAudioType(data []byte) string {
+	switch detected := baseMimeType(http.DetectContentType(data)); {
+	case detected == "application/ogg":
+		return "audio/ogg"
+	case detected == "video/mp4":
+		return "audio/mp4"
+	case strings.HasPrefix(detected, "audio/"):
+		return detected
+	}
+	return ""
+}
+
+
+// baseMimeType strips the parameters from a MIME type
+func baseMimeType(mimeType string) string {
+	base, _, _ := strings.Cut(mimeType, ";")
+	return strings.ToLower(strings.TrimSpace(base))
+}
@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// oggPage builds an Ogg page holding payload, ending at the given granule position
+func oggPage(granule uint64, payload []byte) []byte {
+	page := []byte("OggS")
+	page = append(page, 0, 0)
+	page = binary.LittleEndian.AppendUint64(page, granule)
+	page = append(page, make([]byte, 12)...) // serial, sequence and checksum
+	page = append(page, 1, byte(len(payload)))
+	return append(page, payload...)
+}
+
+// oggOpus builds an Ogg/Opus file with the given pre-skip whose last page ends at granule
+func oggOpus(preSkip uint16, granule uint64) []byte {
+	head := []byte("OpusHead")
+	head = append(head, 1, 1)
+	head = binary.LittleEndian.AppendUint16(head, preSkip)
+	head = binary.LittleEndian.AppendUint32(head, opusGranuleRate)
+	head = append(head, 0, 0, 0)
+
+	data := oggPage(0, head)
+	data = append(data, oggPage(0, []byte("OpusTags"))...)
+	return append(data, oggPage(granule, []byte{0xfc, 0xff, 0xfe})...)
+}
+
+// pcm16 encodes samples as 16-bit little endian PCM
+func pcm16(samples ...int16) []byte {
+	var buf []byte
+	for _, s := range samples {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(s))
+	}
+	return buf
+}
+
+func repeatSample(sample int16, n int) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = sample
+	}
+	return samples
+}
+
+func TestAudioWaveform(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []int16
+		want    func(i int) byte
+	}{
+		{
+			name:    "silence",
+			samples: repeatSample(0, 2*WaveformSamples),
+			want:    func(int) byte { return 0 },
+		},
+		{
+			name:    "quiet then loud",
+			samples: append(repeatSample(0, WaveformSamples), repeatSample(-1000, WaveformSamples)...),
+			want: func(i int) byte {
+				if i < WaveformSamples/2 {
+					return 0
+				}
+				return 100
+			},
+		},
+		{
+			name:    "scaled to the loudest bar",
+			samples: append(repeatSample(500, WaveformSamples), repeatSample(1000, WaveformSamples)...),
+			want: func(i int) byte {
+				if i < WaveformSamples/2 {
+					return 50
+				}
+				return 100
+			},
+		},
+		{
+			name:    "fewer samples than bars",
+			samples: repeatSample(1000, WaveformSamples/2),
+			want: func(i int) byte {
+				if i%2 == 1 {
+					return 100
+				}
+				return 0
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waveform := audioWaveform(pcm16(tt.samples...), len(tt.samples))
+			require.Len(t, waveform, WaveformSamples)
+			for i, level := range waveform {
+				assert.Equal(t, tt.want(i), level, "bar %d", i)
+			}
+		})
+	}
+}
+
+func TestOggOpusDuration(t *testing.T) {
+	voice := oggOpus(312, 3*opusGranuleRate+312)
+
+	tests := []struct {
+		name string
+		data []byte
+		want uint32
+	}{
+		{"whole seconds", voice, 3},
+		{"rounded up", oggOpus(312, 2*opusGranuleRate+opusGranuleRate/2+312), 3},
+		{"pre-skip only", oggOpus(312, 312), 0},
+		{"not opus", oggPage(opusGranuleRate, []byte("vorbis")), 0},
+		{"truncated last page", voice[:len(voice)-20], 0},
+		{"empty", nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, oggOpusDuration(tt.data))
+		})
+	}
+}
+
+func TestSniffAudioType(t *testing.T) {
+	mp4 := append([]byte{0, 0, 0, 0x18}, []byte("ftypmp42")...)
+	mp4 = append(mp4, make([]byte, 12)...)
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"ogg", oggOpus(312, opusGranuleRate), "audio/ogg"},
+		{"mp3", append([]byte("ID3\x04\x00"), make([]byte, 32)...), "audio/mpeg"},
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), "audio/wave"},
+		{"mp4", mp4, "audio/mp4"},
+		{"text", []byte("hello world"), ""},
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sniffAudioType(tt.data))
+		})
+	}
+}
+
+func TestOggOpusFileDuration(t *testing.T) {
+	voice := oggOpus(312, 3*opusGranuleRate+312)
+	assert.Equal(t, uint32(3), oggOpusFileDuration(writeTestFile(t, voice)))
+
+	// Only the head and the tail of long files are read
+	pages := bytes.SplitAfterN(voice, []byte("OpusTags"), 2)
+	long := concat(pages[0], make([]byte, 4*maxOggPageSize), pages[1])
+	assert.Equal(t, uint32(3), oggOpusFileDuration(writeTestFile(t, long)))
+
+	assert.Zero(t, oggOpusFileDuration(filepath.Join(t.TempDir(), "missing.ogg")))
+}
+
+func TestPrepareAudio_WithoutFFmpeg(t *testing.T) {
+	original := ffmpegPath
+	ffmpegPath = func() string { return "" }
+	defer func() { ffmpegPath = original }()
+
+	voice := oggOpus(312, 3*opusGranuleRate+312)
+	audio, err := PrepareAudio(context.Background(), writeTestFile(t, voice), "application/octet-stream", true)
+	require.NoError(t, err)
+	assert.True(t, audio.PTT)
+	assert.Equal(t, VoiceNoteMimeType, audio.MimeType)
+	assert.Equal(t, uint32(3), audio.Seconds)
+
+	mp3 := append([]byte("ID3\x04\x00"), make([]byte, 32)...)
+	path := writeTestFile(t, mp3)
+	audio, err = PrepareAudio(context.Background(), path, "audio/ogg", true)
+	require.NoError(t, err)
+	assert.False(t, audio.PTT, "a voice note that cannot be converted is sent as an audio file")
+	assert.Equal(t, "audio/mpeg", audio.MimeType)
+	assert.Equal(t, path, audio.Path)
+}
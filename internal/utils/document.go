@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+
+// DocumentThumbnailSize is the longest side of the first page preview embedded in PDF documents
+const DocumentThumbnailSize = 480
+
+
+// maxScannedPDFSize is the largest PDF whose pages are counted in memory when pdfinfo is missing
+const maxScannedPDFSize = 16 * 1024 * 1024
+
+
+// pdftoppmPath and pdfinfoPath are the poppler binaries found on the PATH, or empty when not installed
+var (
+	pdftoppmPath = lookPathOnce("pdftoppm")
+	pdfinfoPath  = lookPathOnce("pdfinfo")
+)
+
+
+var (
+	pdfPageObject = regexp.MustCompile(`/Type\s*/Page\b`)
+	pdfinfoPages  = regexp.MustCompile(`(?m)^Pages:\s+(\d+)`)
+)
+
+
+// DocumentInfo is what a document message shows before its file is downloaded.
+type DocumentInfo struct {
+	PageCount       uint32
+	Thumbnail       []byte
+	ThumbnailWidth  int
+	ThumbnailHeight int
+}
+
+
+// ReadDocumentFileInfo reads the page count of a PDF on disk and renders its first page as a
+// thumbnail when poppler's pdftoppm is installed. Without pdfinfo, the pages are only counted in
+// files small enough to scan in memory. Other documents, and fields that cannot be read, are left
+// zero.
+func ReadDocumentFileInfo(ctx context.Context, path, mimeType string) *DocumentInfo {
+	info := &DocumentInfo{}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return info
+	}
+	head := make([]byte, 8)
+	n, _ := io.ReadFull(file, head)
+	file.Close()
+	if !isPDF(head[:n], mimeType) {
+		return info
+	}
+
+	info = readPDFInfo(ctx, path)
+	if info.PageCount == 0 {
+		if stat, err := os.Stat(path); err == nil && stat.Size() <= maxScannedPDFSize {
+			if data, err := os.ReadFile(path); err == nil {
+				info.PageCount = countPDFPages(data)
+			}
+		}
+	}
+	return info
+}
+
+
+// DocumentFileName returns the name a document is sent with: filename when it has an extension,
+// otherwise filename (or "document" when empty) with the extension of mimeType.
+func DocumentFileName(filename, mimeType string) string {
+	filename = strings.TrimSpace(filename)
+	if filename != "" && filepath.Ext(filename) != "" {
+		return filename
+	}
+	if filename == "" {
+		filename = "document"
+	}
+	return filename + GetFileExtension(mimeType)
+}
+
+
+// readPDFInfo reads the page count with pdfinfo and renders the first page with pdftoppm, each
+// when installed.
+func readPDFInfo(ctx context.Context, path string) *DocumentInfo {
+	info := &DocumentInfo{}
+
+	if pdfinfoPath() != "" {
+		if out, err := runTool(ctx, pdfinfoPath(), path); err == nil {
+			if match := pdfinfoPages.FindSubmatch(out); match != nil {
+				if pages, err := strconv.ParseUint(string(match[1]), 10, 32); err == nil {
+					info.PageCount = uint32(pages)
+				}
+			}
+		}
+	}
+
+	if pdftoppmPath() != "" {
+		page, err := runTool(ctx, pdftoppmPath(), "-f", "1", "-l", "1", "-singlefile", "-png",
+			"-scale-to", strconv.Itoa(DocumentThumbnailSize), path)
+		if err == nil {
+			info.Thumbnail, info.ThumbnailWidth, info.ThumbnailHeight, _ = JPEGThumbnail(page, DocumentThumbnailSize)
+		}
+	}
+
+	return info
+}
+
+
+// countPDFPages counts the page objects of a PDF. It finds nothing in PDFs whose objects are
+// compressed, and then returns 0.
+func countPDFPages(data []byte) uint32 {
+	return uint32(len(pdfPageObject.FindAllIndex(data, -1)))
+}
+
+
+func isPDF(data []byte, mimeType string) bool {
+	return baseMimeType(mimeType) == "application/pdf" || bytes.HasPrefix(data, []byte("%PDF-"))
+}
@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+
+// DefaultMaxRedirects is the number of redirects followed when a policy does not set one
+const DefaultMaxRedirects = 5
+
+
+// ErrFetchNotAllowed is returned when a URL, a redirect or the address a host resolves to is
+// refused by the fetch policy.
+var ErrFetchNotAllowed = errors.New("remote URL is not allowed")
+
+
+// reservedNetworks are the ranges refused besides the loopback, private, link-local, multicast
+// and unspecified addresses netip classifies itself.
+var reservedNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+
+// FetchPolicy decides which remote URLs media, link previews and their images may be fetched
+// from. Hosts are refused when they resolve to a loopback, private, link-local or otherwise
+// internal address outside AllowedNetworks, which is checked on the address actually dialled
+// so that DNS cannot be used to slip past it. Every redirect hop is checked again.
+type FetchPolicy struct {
+	// AllowedSchemes defaults to http and https
+	AllowedSchemes []string
+
+	// AllowedDomains, when set, limits fetching to these domains and their subdomains
+	AllowedDomains []string
+
+	BlockedDomains  []string
+	AllowedNetworks []netip.Prefix
+	MaxRedirects    int
+
+	// Disabled refuses every fetch
+	Disabled bool
+}
+
+
+var defaultFetchPolicy atomic.Pointer[FetchPolicy]
+
+
+type fetchPolicyKey struct{}
+
+
+// DefaultFetchPolicy returns the policy used when the context carries none.
+func DefaultFetchPolicy() *FetchPolicy {
+	if policy := defaultFetchPolicy.Load(); policy != nil {
+		return policy
+	}
+	return &FetchPolicy{}
+}
+
+
+// SetDefaultFetchPolicy replaces the server wide fetch policy.
+func SetDefaultFetchPolicy(policy *FetchPolicy) {
+	defaultFetchPolicy.Store(policy)
+}
+
+
+// WithFetchPolicy makes remote fetches under ctx follow policy instead of the default.
+func WithFetchPolicy(ctx context.Context, policy *FetchPolicy) context.Context {
+	return context.WithValue(ctx, fetchPolicyKey{}, policy)
+}
+
+
+// FetchPolicyFrom returns the policy set on ctx, or the default policy.
+func FetchPolicyFrom(ctx context.Context) *FetchPolicy {
+	if policy, ok := ctx.Value(fetchPolicyKey{}).(*FetchPolicy); ok && policy != nil {
+		return policy
+	}
+	return DefaultFetchPolicy()
+}
+
+
+// ParseNetworks parses CIDR prefixes and bare IP addresses, which count as single hosts.
+func ParseNetworks(values []string) ([]netip.Prefix, error) {
+	networks := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(value); err == nil {
+			networks = append(networks, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", value, err)
+		}
+		networks = append(networks, prefix.Masked())
+	}
+	return networks, nil
+}
+
+
+// CheckURL checks the scheme and host of u against the policy. Addresses are checked when
+// they are dialled.
+func (p *FetchPolicy) CheckURL(u *url.URL) error {
+	if p.Disabled {
+		return fmt.Errorf("%w: remote fetching is disabled", ErrFetchNotAllowed)
+	}
+
+	schemes := p.AllowedSchemes
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+	if !slices.Contains(schemes, strings.ToLower(u.Scheme)) {
+		return fmt.Errorf("%w: scheme %q", ErrFetchNotAllowed, u.Scheme)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return fmt.Errorf("%w: missing host", ErrFetchNotAllowed)
+	}
+	if matchesDomain(host, p.BlockedDomains) {
+		return fmt.Errorf("%w: host %s is blocked", ErrFetchNotAllowed, host)
+	}
+	if len(p.AllowedDomains) > 0 && !matchesDomain(host, p.AllowedDomains) {
+		return fmt.Errorf("%w: host %s is not in the allowed domains", ErrFetchNotAllowed, host)
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return p.CheckAddr(addr)
+	}
+	return nil
+}
+
+
+// CheckAddr refuses internal addresses that are not in AllowedNetworks.
+func (p *FetchPolicy) CheckAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+	for _, network := range p.AllowedNetworks {
+		if network.Contains(addr) {
+			return nil
+		}
+	}
+	if isInternalAddr(addr) {
+		return fmt.Errorf("%w: address %s is internal", ErrFetchNotAllowed, addr)
+	}
+	return nil
+}
+
+
+// Client returns an HTTP client that enforces the policy on every connection and redirect.
+// It ignores proxy settings from the environment, which would hide the address dialled.
+func (p *FetchPolicy) Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: address %s", ErrFetchNotAllowed, address)
+			}
+			return p.CheckAddr(addrPort.Addr())
+		},
+	}
+
+	maxRedirects := p.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: timeout,
+			DisableKeepAlives:     true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("%w: more than %d redirects", ErrFetchNotAllowed, maxRedirects)
+			}
+			return p.CheckURL(req.URL)
+		},
+	}
+}
+
+
+// Get fetches target with the policy applied, failing on any status other than 200 OK.
+func (p *FetchPolicy) Get(ctx context.Context, target string, timeout time.Duration, header http.Header) (*http.Response, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := p.CheckURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := p.Client(timeout).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", target, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch %s: HTTP %d", target, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+
+func isInternalAddr(addr netip.Addr) bool {
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return true
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+
+// matchesDomain reports whether host is one of domains or a subdomain of one.
+func matchesDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchPolicy_CheckAddr(t *testing.T) {
+	allowLAN := &FetchPolicy{AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+
+	tests := []struct {
+		name    string
+		policy  *FetchPolicy
+		addr    string
+		allowed bool
+	}{
+		{"public IPv4", &FetchPolicy{}, "93.184.216.34", true},
+		{"public IPv6", &FetchPolicy{}, "2606:4700:4700::1111", true},
+		{"loopback", &FetchPolicy{}, "127.0.0.1", false},
+		{"loopback range", &FetchPolicy{}, "127.10.0.1", false},
+		{"IPv6 loopback", &FetchPolicy{}, "::1", false},
+		{"IPv4-mapped loopback", &FetchPolicy{}, "::ffff:127.0.0.1", false},
+		{"RFC 1918 10/8", &FetchPolicy{}, "10.1.2.3", false},
+		{"RFC 1918 172.16/12", &FetchPolicy{}, "172.16.5.4", false},
+		{"RFC 1918 192.168/16", &FetchPolicy{}, "192.168.1.1", false},
+		{"cloud metadata", &FetchPolicy{}, "169.254.169.254", false},
+		{"IPv6 link-local", &FetchPolicy{}, "fe80::1", false},
+		{"IPv6 unique local", &FetchPolicy{}, "fd12:3456:789a::1", false},
+		{"unspecified", &FetchPolicy{}, "0.0.0.0", false},
+		{"carrier-grade NAT", &FetchPolicy{}, "100.64.0.1", false},
+		{"multicast", &FetchPolicy{}, "224.0.0.1", false},
+		{"allowed network", allowLAN, "10.1.2.3", true},
+		{"allowed network mapped", allowLAN, "::ffff:10.1.2.3", true},
+		{"outside allowed network", allowLAN, "192.168.1.1", false},
+		{"loopback outside allowed network", allowLAN, "127.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.CheckAddr(netip.MustParseAddr(tt.addr))
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrFetchNotAllowed)
+			}
+		})
+	}
+}
+
+func TestFetchPolicy_CheckURL(t *testing.T) {
+	blocked := &FetchPolicy{BlockedDomains: []string{"evil.com"}}
+	allowed := &FetchPolicy{AllowedDomains: []string{"Example.com."}}
+	httpsOnly := &FetchPolicy{AllowedSchemes: []string{"https"}}
+
+	tests := []struct {
+		name    string
+		policy  *FetchPolicy
+		url     string
+		allowed bool
+	}{
+		{"http", &FetchPolicy{}, "http://example.com/a.jpg", true},
+		{"uppercase scheme", &FetchPolicy{}, "HTTPS://example.com/a.jpg", true},
+		{"ftp", &FetchPolicy{}, "ftp://example.com/a.jpg", false},
+		{"file", &FetchPolicy{}, "file:///etc/passwd", false},
+		{"scheme not allowed", httpsOnly, "http://example.com/a.jpg", false},
+		{"scheme allowed", httpsOnly, "https://example.com/a.jpg", true},
+		{"missing host", &FetchPolicy{}, "http:///a.jpg", false},
+		{"loopback literal", &FetchPolicy{}, "http://127.0.0.1:8080/", false},
+		{"IPv6 loopback literal", &FetchPolicy{}, "http://[::1]/", false},
+		{"metadata literal", &FetchPolicy{}, "http://169.254.169.254/latest/meta-data/", false},
+		{"blocked domain", blocked, "https://evil.com/", false},
+		{"blocked subdomain", blocked, "https://cdn.EVIL.com./", false},
+		{"blocked suffix only", blocked, "https://notevil.com/", true},
+		{"allowed domain", allowed, "https://example.com/", true},
+		{"allowed subdomain", allowed, "https://cdn.example.com/", true},
+		{"outside allowed domains", allowed, "https://example.org/", false},
+		{"allowed suffix only", allowed, "https://badexample.com/", false},
+		{"disabled", &FetchPolicy{Disabled: true}, "https://example.com/", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			require.NoError(t, err)
+
+			err = tt.policy.CheckURL(u)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrFetchNotAllowed)
+			}
+		})
+	}
+}
+
+// listenOn starts a test server on addr, skipping the test when the address is not available
+func listenOn(t *testing.T, addr string, handler http.Handler) *httptest.Server {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("cannot listen on %s: %v", addr, err)
+	}
+
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchPolicy_GetRechecksRedirects(t *testing.T) {
+	var hits atomic.Int32
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte("secret"))
+	}))
+	defer internal.Close()
+	internalURL, err := url.Parse(internal.URL)
+	require.NoError(t, err)
+
+	// The redirecting server stands in for a public host: its address is the only one allowed
+	public := listenOn(t, "127.0.0.2:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte("media"))
+		case "/to-ip":
+			http.Redirect(w, r, internal.URL+"/secret", http.StatusFound)
+		case "/to-name":
+			http.Redirect(w, r, "http://localhost:"+internalURL.Port()+"/secret", http.StatusFound)
+		}
+	}))
+	policy := &FetchPolicy{AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("127.0.0.2/32")}}
+	ctx := context.Background()
+
+	resp, err := policy.Get(ctx, public.URL+"/ok", 5*time.Second, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// A redirect to an internal address is refused before it is followed
+	_, err = policy.Get(ctx, public.URL+"/to-ip", 5*time.Second, nil)
+	assert.ErrorIs(t, err, ErrFetchNotAllowed)
+
+	// A redirect to a name passes the URL check and is refused when its address is dialled
+	_, err = policy.Get(ctx, public.URL+"/to-name", 5*time.Second, nil)
+	assert.ErrorIs(t, err, ErrFetchNotAllowed)
+	assert.ErrorContains(t, err, "is internal")
+
+	// So is a name resolving to an internal address on the first hop
+	_, err = policy.Get(ctx, "http://localhost:"+internalURL.Port()+"/secret", 5*time.Second, nil)
+	assert.ErrorIs(t, err, ErrFetchNotAllowed)
+	assert.ErrorContains(t, err, "is internal")
+
+	assert.Zero(t, hits.Load(), "the internal server must never be reached")
+}
+
+func TestFetchPolicy_GetTooManyRedirects(t *testing.T) {
+	loop := listenOn(t, "127.0.0.2:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path, http.StatusFound)
+	}))
+	policy := &FetchPolicy{
+		AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("127.0.0.2/32")},
+		MaxRedirects:    2,
+	}
+
+	_, err := policy.Get(context.Background(), loop.URL+"/", 5*time.Second, nil)
+	assert.ErrorIs(t, err, ErrFetchNotAllowed)
+	assert.ErrorContains(t, err, "more than 2 redirects")
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+
+// FFmpegTimeout bounds a single ffmpeg or ffprobe run
+const FFmpegTimeout = 2 * time.Minute
+
+
+// ffmpegPath and ffprobePath are the binaries found on the PATH, or empty when not installed
+var (
+	ffmpegPath  = lookPathOnce("ffmpeg")
+	ffprobePath = lookPathOnce("ffprobe")
+)
+
+
+func lookPathOnce(name string) func() string {
+	return sync.OnceValue(func() string {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			return ""
+		}
+		return path
+	})
+}
+
+
+// HasFFmpeg reports whether a local ffmpeg binary is available for media processing.
+func HasFFmpeg() bool {
+	return ffmpegPath() != ""
+}
+
+
+// HasFFprobe reports whether a local ffprobe binary is available for reading media metadata.
+func HasFFprobe() bool {
+	return ffprobePath() != ""
+}
+
+
+// runFFmpeg runs ffmpeg on the file at input and returns what it writes to standard output.
+// args are the arguments that follow the input, ending with the output (usually pipe:1).
+func runFFmpeg(ctx context.Context, input string, args ...string) ([]byte, error) {
+	if !HasFFmpeg() {
+		return nil, fmt.Errorf("ffmpeg is not installed")
+	}
+	return runTool(ctx, ffmpegPath(), append([]string{"-hide_banner", "-loglevel", "error", "-nostdin", "-y", "-i", input}, args...)...)
+}
+
+
+// runFFprobe runs ffprobe with args on the file at input and returns its standard output.
+func runFFprobe(ctx context.Context, input string, args ...string) ([]byte, error) {
+	if !HasFFprobe() {
+		return nil, fmt.Errorf("ffprobe is not installed")
+	}
+	return runTool(ctx, ffprobePath(), append(append([]string{"-v", "error"}, args...), input)...)
+}
+
+
+// runTool runs a media tool on files already on disk and returns its standard output.
+func runTool(ctx context.Context, path string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, FFmpegTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	name := filepath.Base(path)
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %w: %s", name, err, msg)
+		}
+		return nil, fmt.Errorf("%s failed: %w", name, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+
+// writeTempFile writes data to a new temporary file and returns its path.
+func writeTempFile(data []byte) (string, error) {
+	file, err := os.CreateTemp("", "zpmeow-media-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	return file.Name(), nil
+}
+
+
+// ffmpegToTempFile runs ffmpeg on the file at input with a new temporary file as the output, for
+// containers such as MP4 whose header is written after the media. args are followed by the
+// output path. The caller must remove the returned file.
+func ffmpegToTempFile(ctx context.Context, input, extension string, args ...string) (string, error) {
+	output, err := os.CreateTemp("", "zpmeow-output-*"+extension)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	output.Close()
+
+	if _, err := runFFmpeg(ctx, input, append(args, output.Name())...); err != nil {
+		os.Remove(output.Name())
+		return "", err
+	}
+	return output.Name(), nil
+}
+
+
+// runFFmpegToFile runs ffmpeg on input held in memory and returns the output file it wrote, for
+// small media such as stickers.
+func runFFmpegToFile(ctx context.Context, input []byte, extension string, args ...string) ([]byte, error) {
+	inputPath, err := writeTempFile(input)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(inputPath)
+
+	output, err := ffmpegToTempFile(ctx, inputPath, extension, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(output)
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ffmpeg output: %w", err)
+	}
+	return data, nil
+}
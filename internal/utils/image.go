@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"os"
+)
+
+
+const (
+	// ImageThumbnailSize is the longest side of the thumbnail embedded in image messages
+	ImageThumbnailSize = 72
+
+	// MaxImageSize is the largest image WhatsApp accepts in an image message
+	MaxImageSize = 16 * 1024 * 1024
+
+	// maxCompressedImageSide caps the resolution of images re-encoded to fit MaxImageSize
+	maxCompressedImageSide = 4096
+
+	// exifSearchSize is how much of a JPEG file is searched for its EXIF segment, which is
+	// limited to 64 KB and comes before the image data
+	exifSearchSize = 128 * 1024
+)
+
+
+// compressQualities are the JPEG qualities tried, in order, at each resolution
+var compressQualities = []int{85, 70, 55}
+
+
+// ImageInfo is what an image message shows before its file is downloaded.
+type ImageInfo struct {
+	Width     int
+	Height    int
+	Thumbnail []byte
+}
+
+
+// ReadImageInfo decodes the image file at path to read its dimensions and build the small JPEG
+// thumbnail recipients see while the image downloads, both as displayed once the EXIF
+// orientation is applied.
+func ReadImageInfo(path string) (*ImageInfo, error) {
+	canvas, err := decodeUprightFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := canvas.Bounds().Dx(), canvas.Bounds().Dy()
+	thumbWidth, thumbHeight := fitWithin(width, height, ImageThumbnailSize)
+	thumbnail, err := encodeJPEG(canvas, thumbWidth, thumbHeight, ThumbnailQuality)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ImageInfo{Width: width, Height: height, Thumbnail: thumbnail}, nil
+}
+
+
+// CompressImage re-encodes an image larger than maxBytes as JPEG, lowering the quality first and
+// then the resolution until it fits. Images within the limit are returned unchanged; a
+// re-encoded image is a new temporary file, which the caller must Remove as well.
+func CompressImage(media *MediaFile, maxBytes int) (*MediaFile, error) {
+	if media.Size <= int64(maxBytes) {
+		return media, nil
+	}
+
+	// The re-encoded JPEG carries no EXIF, so its pixels are turned upright
+	canvas, err := decodeUprightFile(media.Path)
+	if err != nil {
+		return nil, err
+	}
+	width, height := canvas.Bounds().Dx(), canvas.Bounds().Dy()
+
+	for side := min(max(width, height), maxCompressedImageSide); side >= ImageThumbnailSize; side = side * 3 / 4 {
+		w, h := fitWithin(width, height, side)
+		for _, quality := range compressQualities {
+			out, err := encodeJPEG(canvas, w, h, quality)
+			if err != nil {
+				return nil, err
+			}
+			if len(out) <= maxBytes {
+				return NewMediaFile(out, "image/jpeg")
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("image cannot be compressed below %d bytes", maxBytes)
+}
+
+
+// decodeUpright decodes an image onto a white canvas, turned as its EXIF orientation says it
+// should be displayed.
+func decodeUpright(data []byte) (*image.RGBA, error) {
+	src, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	return orient(flatten(src), jpegOrientation(data)), nil
+}
+
+
+// decodeUprightFile is decodeUpright for an image on disk. Only the head of the file is read for
+// the orientation; the image is decoded from the file.
+func decodeUprightFile(path string) (*image.RGBA, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer file.Close()
+
+	head := make([]byte, exifSearchSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	src, err := decodeImageFrom(file)
+	if err != nil {
+		return nil, err
+	}
+	return orient(flatten(src), jpegOrientation(head[:n])), nil
+}
+
+
+// jpegOrientation returns the EXIF orientation of a JPEG, from 1 to 8, or 1 when it has none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return 1
+	}
+
+	// Walk the segments before the image data looking for the EXIF APP1 segment
+	for i := 2; i+4 <= len(data) && data[i] == 0xff; {
+		marker := data[i+1]
+		if marker == 0xda || marker == 0xd9 {
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xe1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+
+// exifOrientation reads the Orientation tag from the first IFD of a TIFF header.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		// Orientation is a SHORT, stored in the first two bytes of the value
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			if orientation := int(order.Uint16(tiff[entry+8:])); orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+
+// orient mirrors and rotates src as EXIF orientation 2 to 8 asks; orientations 5 to 8 swap the
+// width and height.
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if orientation >= 5 {
+		dst = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dy*dst.Stride+dx*4:][:4], src.Pix[y*src.Stride+x*4:][:4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	red  = color.RGBA{R: 0xff, A: 0xff}
+	blue = color.RGBA{B: 0xff, A: 0xff}
+)
+
+// exifSegment builds an APP1 segment whose first IFD holds the orientation tag
+func exifSegment(order binary.AppendByteOrder, orientation uint16) []byte {
+	tiff := []byte("MM")
+	if order == binary.LittleEndian {
+		tiff = []byte("II")
+	}
+	tiff = order.AppendUint16(tiff, 42)
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, 2)
+	// An unrelated tag first: ImageWidth, LONG
+	tiff = order.AppendUint16(tiff, 0x0100)
+	tiff = order.AppendUint16(tiff, 4)
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = order.AppendUint32(tiff, 40)
+	tiff = order.AppendUint16(tiff, 0x0112)
+	tiff = order.AppendUint16(tiff, 3)
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = order.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xff, 0xe1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// halvesJPEG encodes a 40x20 JPEG, red on the left half and blue on the right, with the given
+// segments inserted after the start of image marker
+func halvesJPEG(t *testing.T, segments ...[]byte) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			if x < 20 {
+				img.Set(x, y, red)
+			} else {
+				img.Set(x, y, blue)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}))
+	data := buf.Bytes()
+	return concat(data[:2], concat(segments...), data[2:])
+}
+
+// isRed reports whether a decoded pixel is mostly red rather than blue
+func isRed(c color.Color) bool {
+	r, _, b, _ := c.RGBA()
+	return r > b
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	plain := halvesJPEG(t)
+	rotated := halvesJPEG(t, exifSegment(binary.BigEndian, 6))
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"big endian", rotated, 6},
+		{"little endian", halvesJPEG(t, exifSegment(binary.LittleEndian, 8)), 8},
+		{"no EXIF", plain, 1},
+		{"out of range", halvesJPEG(t, exifSegment(binary.BigEndian, 9)), 1},
+		{"truncated EXIF", rotated[:30], 1},
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"empty", nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, jpegOrientation(tt.data))
+		})
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// A 3x2 image whose pixels are numbered in reading order
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i := 0; i < 6; i++ {
+		src.Pix[i*4] = byte(i)
+	}
+
+	tests := []struct {
+		orientation int
+		width       int
+		rows        [][]byte
+	}{
+		{1, 3, [][]byte{{0, 1, 2}, {3, 4, 5}}},
+		{2, 3, [][]byte{{2, 1, 0}, {5, 4, 3}}},
+		{3, 3, [][]byte{{5, 4, 3}, {2, 1, 0}}},
+		{4, 3, [][]byte{{3, 4, 5}, {0, 1, 2}}},
+		{5, 2, [][]byte{{0, 3}, {1, 4}, {2, 5}}},
+		{6, 2, [][]byte{{3, 0}, {4, 1}, {5, 2}}},
+		{7, 2, [][]byte{{5, 2}, {4, 1}, {3, 0}}},
+		{8, 2, [][]byte{{2, 5}, {1, 4}, {0, 3}}},
+	}
+
+	for _, tt := range tests {
+		dst := orient(src, tt.orientation)
+		require.Equal(t, tt.width, dst.Bounds().Dx(), "orientation %d", tt.orientation)
+		require.Equal(t, len(tt.rows), dst.Bounds().Dy(), "orientation %d", tt.orientation)
+		for y, row := range tt.rows {
+			for x, want := range row {
+				assert.Equal(t, want, dst.Pix[y*dst.Stride+x*4], "orientation %d at %d,%d", tt.orientation, x, y)
+			}
+		}
+	}
+}
+
+func TestReadImageInfo_Orientation(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          []byte
+		width, height int
+		topRed        bool
+	}{
+		{"upright", halvesJPEG(t), 40, 20, true},
+		{"rotated clockwise", halvesJPEG(t, exifSegment(binary.BigEndian, 6)), 20, 40, true},
+		{"rotated counterclockwise", halvesJPEG(t, exifSegment(binary.LittleEndian, 8)), 20, 40, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ReadImageInfo(writeTestFile(t, tt.data))
+			require.NoError(t, err)
+			assert.Equal(t, tt.width, info.Width)
+			assert.Equal(t, tt.height, info.Height)
+
+			thumb, err := jpeg.Decode(bytes.NewReader(info.Thumbnail))
+			require.NoError(t, err)
+			assert.Equal(t, image.Pt(tt.width, tt.height), thumb.Bounds().Size())
+
+			if tt.height > tt.width {
+				// The left half of the stored image ends up on top or at the bottom
+				assert.Equal(t, tt.topRed, isRed(thumb.At(tt.width/2, 2)))
+				assert.Equal(t, !tt.topRed, isRed(thumb.At(tt.width/2, tt.height-3)))
+			} else {
+				assert.True(t, isRed(thumb.At(2, tt.height/2)))
+			}
+		})
+	}
+}
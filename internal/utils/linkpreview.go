@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+
+const (
+	// LinkPreviewTimeout bounds the whole preview lookup: the page and its image
+	LinkPreviewTimeout = 5 * time.Second
+
+	// LinkPreviewThumbnailSize is the longest side of a generated preview thumbnail
+	LinkPreviewThumbnailSize = 320
+
+	maxLinkPreviewPageBytes  = 512 * 1024
+	maxLinkPreviewImageBytes = 5 * 1024 * 1024
+)
+
+
+// linkPattern matches http(s) links and bare www. hosts in message text
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+
+// OpenGraph holds the preview fields of a web page. Image is an absolute URL.
+type OpenGraph struct {
+	Title       string
+	Description string
+	Image       string
+}
+
+
+// FindLink returns the first link in text as written, and the URL to fetch for it.
+// Both are empty when the text has no link.
+func FindLink(text string) (string, string) {
+	for _, match := range linkPattern.FindAllString(text, -1) {
+		match = strings.TrimRight(match, ".,;:!?)]}'*_~")
+		target := match
+		if !strings.Contains(strings.ToLower(target), "://") {
+			target = "https://" + target
+		}
+		if isValidURL(target) {
+			return match, target
+		}
+	}
+	return "", ""
+}
+
+
+// FetchOpenGraph downloads an HTML page and reads its OpenGraph tags, falling back to the
+// <title> and description meta tags. Only the first part of the page is read.
+func FetchOpenGraph(ctx context.Context, pageURL string) (*OpenGraph, error) {
+	resp, err := linkPreviewGet(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if mimeType := resp.Header.Get("Content-Type"); !strings.Contains(mimeType, "html") {
+		return nil, fmt.Errorf("page is not HTML: %s", mimeType)
+	}
+
+	og := parseOpenGraph(io.LimitReader(resp.Body, maxLinkPreviewPageBytes))
+	if og.Title == "" {
+		return nil, fmt.Errorf("page has no title")
+	}
+
+	if og.Image != "" {
+		imageURL, err := resp.Request.URL.Parse(og.Image)
+		if err != nil || (imageURL.Scheme != "http" && imageURL.Scheme != "https") {
+			og.Image = ""
+		} else {
+			og.Image = imageURL.String()
+		}
+	}
+	return og, nil
+}
+
+
+// FetchLinkPreviewImage downloads a preview image, refusing files over the size limit.
+func FetchLinkPreviewImage(ctx context.Context, imageURL string) ([]byte, error) {
+	resp, err := linkPreviewGet(ctx, imageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.ContentLength > maxLinkPreviewImageBytes {
+		return nil, fmt.Errorf("image too large: %d bytes", resp.ContentLength)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxLinkPreviewImageBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if len(data) > maxLinkPreviewImageBytes {
+		return nil, fmt.Errorf("image too large: more than %d bytes", maxLinkPreviewImageBytes)
+	}
+	return data, nil
+}
+
+
+func linkPreviewGet(ctx context.Context, target string) (*http.Response, error) {
+	if !isValidURL(target) {
+		return nil, fmt.Errorf("invalid URL: %s", target)
+	}
+
+	return FetchPolicyFrom(ctx).Get(ctx, target, LinkPreviewTimeout, http.Header{
+		"User-Agent": {"ZpMeow/1.0 (link preview)"},
+		"Accept":     {"text/html,image/*;q=0.9,*/*;q=0.5"},
+	})
+}
+
+
+// parseOpenGraph scans the document head for preview tags and stops at <body>.
+func parseOpenGraph(r io.Reader) *OpenGraph {
+	og := &OpenGraph{}
+	var title, description string
+
+	tokenizer := html.NewTokenizer(r)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return og.withFallback(title, description)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "body":
+				return og.withFallback(title, description)
+			case "title":
+				if title == "" && tokenizer.Next() == html.TextToken {
+					title = strings.TrimSpace(string(tokenizer.Text()))
+				}
+			case "meta":
+				var key, content string
+				for _, attr := range token.Attr {
+					switch strings.ToLower(attr.Key) {
+					case "property", "name":
+						key = strings.ToLower(strings.TrimSpace(attr.Val))
+					case "content":
+						content = strings.TrimSpace(attr.Val)
+					}
+				}
+				switch key {
+				case "og:title":
+					og.Title = firstNonEmpty(og.Title, content)
+				case "og:description":
+					og.Description = firstNonEmpty(og.Description, content)
+				case "og:image", "og:image:url", "og:image:secure_url":
+					og.Image = firstNonEmpty(og.Image, content)
+				case "description":
+					description = firstNonEmpty(description, content)
+				}
+			}
+		}
+	}
+}
+
+
+func (og *OpenGraph) withFallback(title, description string) *OpenGraph {
+	og.Title = firstNonEmpty(og.Title, title)
+	og.Description = firstNonEmpty(og.Description, description)
+	return og
+}
+
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
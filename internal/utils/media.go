@@ -9,7 +9,9 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -430,45 +432,69 @@ func NormalizeMimeTypeFromRaw(rawMimeType string) string {
 }
 
 
+// GetFileExtension returns the usual extension for a MIME type, falling back to the system MIME
+// table for types not listed here, or "" when none is known.
 func GetFileExtension(mimeType string) string {
+	mimeType = baseMimeType(mimeType)
+	switch mimeType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "audio/mpeg":
+		return ".mp3"
+	case "audio/mp4":
+		return ".m4a"
+	case "audio/ogg":
+		return ".ogg"
+	case "video/mp4":
+		return ".mp4"
+	case "video/avi":
+		return ".avi"
+	case "application/pdf":
+		return ".pdf"
+	case "text/plain":
+		return ".txt"
+	case "text/csv":
+		return ".csv"
+	case "application/zip":
+		return ".zip"
+	case "application/msword":
+		return ".doc"
+	case "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		return ".docx"
+	case "application/vnd.ms-excel":
+		return ".xls"
+	case "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+		return ".xlsx"
+	case "application/vnd.ms-powerpoint":
+		return ".ppt"
+	case "application/vnd.openxmlformats-officedocument.presentationml.presentation":
+		return ".pptx"
+	}
+
 	extensions, err := mime.ExtensionsByType(mimeType)
 	if err != nil || len(extensions) == 0 {
-
-		switch mimeType {
-		case "image/jpeg":
-			return ".jpg"
-		case "image/png":
-			return ".png"
-		case "image/gif":
-			return ".gif"
-		case "image/webp":
-			return ".webp"
-		case "audio/mpeg":
-			return ".mp3"
-		case "audio/mp4":
-			return ".m4a"
-		case "audio/ogg":
-			return ".ogg"
-		case "video/mp4":
-			return ".mp4"
-		case "video/avi":
-			return ".avi"
-		case "application/pdf":
-			return ".pdf"
-		default:
-			return ""
-		}
+		return ""
 	}
 	return extensions[0]
 }
 
 
 func ValidateMediaSize(data []byte, mediaType string) error {
-	size := len(data)
-	
+	return ValidateMediaLength(int64(len(data)), mediaType)
+}
+
+
+// ValidateMediaLength checks the size of media of the given type, for media kept on disk.
+func ValidateMediaLength(size int64, mediaType string) error {
 	switch mediaType {
 	case "image":
-		if size > 16*1024*1024 { // 16MB
+		if size > MaxImageSize {
 			return fmt.Errorf("image size too large: %d bytes (max 16MB)", size)
 		}
 	case "audio":
@@ -497,8 +523,75 @@ func ValidateMediaSize(data []byte, mediaType string) error {
 }
 
 
+// MaxUploadSize caps any media received for sending, before it is checked against the limit of
+// its media type. Images and videos may exceed their limit until compressed or transcoded.
+const MaxUploadSize = 100 * 1024 * 1024
+
+
+// MediaFile is media received for sending, spooled to a temporary file so that large uploads
+// are never held in memory. Remove deletes the file once the media is no longer needed.
+type MediaFile struct {
+	Path     string
+	Size     int64
+	MimeType string
+}
+
+
+// ReadAll loads the media into memory, for media that is processed before it is sent.
+func (f *MediaFile) ReadAll() ([]byte, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media: %w", err)
+	}
+	return data, nil
+}
 
-func ProcessUnifiedMedia(ctx context.Context, media string, file *multipart.FileHeader, mediaType string) ([]byte, string, error) {
+
+// Remove deletes the temporary file. It is safe to call on a nil MediaFile.
+func (f *MediaFile) Remove() {
+	if f != nil && f.Path != "" {
+		os.Remove(f.Path)
+	}
+}
+
+
+// NewMediaFile writes media held in memory, such as a compressed image, to a temporary file.
+func NewMediaFile(data []byte, mimeType string) (*MediaFile, error) {
+	path, err := writeTempFile(data)
+	if err != nil {
+		return nil, err
+	}
+	return &MediaFile{Path: path, Size: int64(len(data)), MimeType: mimeType}, nil
+}
+
+
+// Head reads the first n bytes of the media, or all of it when it is shorter, for sniffing its
+// format.
+func (f *MediaFile) Head(n int) ([]byte, error) {
+	return readFileHead(f.Path, n)
+}
+
+
+// readFileHead reads the first n bytes of a file, or all of it when it is shorter.
+func readFileHead(path string, n int) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open media: %w", err)
+	}
+	defer file.Close()
+
+	head := make([]byte, n)
+	read, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("failed to read media: %w", err)
+	}
+	return head[:read], nil
+}
+
+
+// OpenUnifiedMedia streams media from a multipart file, a URL or a data URL into a temporary
+// file, failing once it exceeds MaxUploadSize. The caller must Remove the returned file.
+func OpenUnifiedMedia(ctx context.Context, media string, file *multipart.FileHeader, mediaType string) (*MediaFile, error) {
 
 	if file != nil {
 		return processFormDataMedia(file, mediaType)
@@ -506,7 +599,7 @@ func ProcessUnifiedMedia(ctx context.Context, media string, file *multipart.File
 
 
 	if media == "" {
-		return nil, "", fmt.Errorf("media parameter is required")
+		return nil, fmt.Errorf("media parameter is required")
 	}
 
 
@@ -515,26 +608,30 @@ func ProcessUnifiedMedia(ctx context.Context, media string, file *multipart.File
 	}
 
 
-	return DecodeUniversalMedia(media, mediaType)
+	return decodeDataURLToFile(media, mediaType)
 }
 
 
-func processFormDataMedia(fileHeader *multipart.FileHeader, mediaType string) ([]byte, string, error) {
+func processFormDataMedia(fileHeader *multipart.FileHeader, mediaType string) (*MediaFile, error) {
+
+	if fileHeader.Size > MaxUploadSize {
+		return nil, fmt.Errorf("file too large: %d bytes (max 100MB)", fileHeader.Size)
+	}
 
 	file, err := fileHeader.Open()
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to open uploaded file: %w", err)
+		return nil, fmt.Errorf("failed to open uploaded file: %w", err)
 	}
 	defer file.Close()
 
 
-	data, err := io.ReadAll(file)
+	spooled, head, err := spoolMedia(file)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to read uploaded file: %w", err)
+		return nil, fmt.Errorf("failed to read uploaded file: %w", err)
 	}
 
 
-	mimeType := http.DetectContentType(data)
+	mimeType := http.DetectContentType(head)
 
 
 	if mimeType == "application/octet-stream" {
@@ -548,72 +645,170 @@ func processFormDataMedia(fileHeader *multipart.FileHeader, mediaType string) ([
 	}
 
 
-	normalizedMimeType, err := ValidateAndNormalizeMimeType(mimeType, mediaType)
+	spooled.MimeType, err = ValidateAndNormalizeMimeType(mimeType, mediaType)
 	if err != nil {
-		return nil, "", fmt.Errorf("MIME type validation failed: %w", err)
+		spooled.Remove()
+		return nil, fmt.Errorf("MIME type validation failed: %w", err)
 	}
 
-	return data, normalizedMimeType, nil
+	return spooled, nil
 }
 
 
-func downloadMediaFromURL(ctx context.Context, mediaURL string, mediaType string) ([]byte, string, error) {
+// downloadMediaFromURL fetches media under the fetch policy of ctx. The size limit is enforced
+// on the bytes read, as ContentLength may be missing or wrong.
+func downloadMediaFromURL(ctx context.Context, mediaURL string, mediaType string) (*MediaFile, error) {
+
+	resp, err := FetchPolicyFrom(ctx).Get(ctx, mediaURL, 30*time.Second, http.Header{
+		"User-Agent": {"ZpMeow/1.0"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download media from URL: %w", err)
+	}
+	defer resp.Body.Close()
+
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	if resp.ContentLength > MaxUploadSize {
+		return nil, fmt.Errorf("file too large: %d bytes (max 100MB)", resp.ContentLength)
 	}
 
 
-	req, err := http.NewRequestWithContext(ctx, "GET", mediaURL, nil)
+	spooled, head, err := spoolMedia(resp.Body)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+
+	mimeType := resp.Header.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = http.DetectContentType(head)
 	}
 
 
-	req.Header.Set("User-Agent", "ZpMeow/1.0")
+	if strings.Contains(mimeType, ";") {
+		mimeType = strings.Split(mimeType, ";")[0]
+	}
+	mimeType = strings.TrimSpace(mimeType)
 
 
-	resp, err := client.Do(req)
+	spooled.MimeType, err = ValidateAndNormalizeMimeType(mimeType, mediaType)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to download media from URL: %w", err)
+		spooled.Remove()
+		return nil, fmt.Errorf("MIME type validation failed: %w", err)
 	}
-	defer resp.Body.Close()
+
+	return spooled, nil
+}
 
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("failed to download media: HTTP %d", resp.StatusCode)
+// decodeDataURLToFile decodes a data URL into a temporary file as it reads it, so the decoded
+// media is not held in memory next to the encoded string. It follows DecodeUniversalMedia for
+// the MIME type.
+func decodeDataURLToFile(dataURI, mediaType string) (*MediaFile, error) {
+	if !strings.HasPrefix(dataURI, "data:") {
+		return nil, fmt.Errorf("invalid data URI format: must start with 'data:'")
 	}
 
+	metadata, encodedData, found := strings.Cut(dataURI[5:], ",")
+	if !found {
+		return nil, fmt.Errorf("invalid data URI format: missing comma")
+	}
 
-	if resp.ContentLength > 100*1024*1024 {
-		return nil, "", fmt.Errorf("file too large: %d bytes (max 100MB)", resp.ContentLength)
+	parts := strings.Split(metadata, ";")
+	var body io.Reader = base64.NewDecoder(base64.StdEncoding, whitespaceSkipper{strings.NewReader(encodedData)})
+	if !slices.ContainsFunc(parts[1:], func(part string) bool { return strings.TrimSpace(part) == "base64" }) {
+		unescaped, err := url.PathUnescape(encodedData)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode data URL: %w", err)
+		}
+		body = strings.NewReader(unescaped)
 	}
 
+	mimeType := "audio/ogg; codecs=opus"
+	if mediaType != "audio" {
+		rawMimeType := "text/plain"
+		if strings.TrimSpace(parts[0]) != "" {
+			rawMimeType = NormalizeMimeTypeFromRaw(parts[0])
+		}
+
+		var err error
+		if mimeType, err = ValidateAndNormalizeMimeType(rawMimeType, mediaType); err != nil {
+			return nil, fmt.Errorf("MIME type validation failed: %w", err)
+		}
+	}
 
-	data, err := io.ReadAll(resp.Body)
+	spooled, _, err := spoolMedia(body)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("could not decode data URL: %w", err)
 	}
+	spooled.MimeType = mimeType
+	return spooled, nil
+}
 
 
-	mimeType := resp.Header.Get("Content-Type")
-	if mimeType == "" {
-		mimeType = http.DetectContentType(data)
+// spoolMedia copies r into a temporary file, up to MaxUploadSize, and returns it with its first
+// 512 bytes for content sniffing.
+func spoolMedia(r io.Reader) (*MediaFile, []byte, error) {
+	file, err := os.CreateTemp("", "zpmeow-upload-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temporary file: %w", err)
 	}
+	spooled := &MediaFile{Path: file.Name()}
 
-
-	if strings.Contains(mimeType, ";") {
-		mimeType = strings.Split(mimeType, ";")[0]
+	head := &headBuffer{limit: 512}
+	size, err := io.Copy(file, io.TeeReader(io.LimitReader(r, MaxUploadSize+1), head))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	switch {
+	case err != nil:
+		spooled.Remove()
+		return nil, nil, err
+	case size > MaxUploadSize:
+		spooled.Remove()
+		return nil, nil, fmt.Errorf("file too large: more than %d bytes (max 100MB)", MaxUploadSize)
+	case size == 0:
+		spooled.Remove()
+		return nil, nil, fmt.Errorf("media data is empty")
 	}
-	mimeType = strings.TrimSpace(mimeType)
 
+	spooled.Size = size
+	return spooled, head.data, nil
+}
 
-	normalizedMimeType, err := ValidateAndNormalizeMimeType(mimeType, mediaType)
-	if err != nil {
-		return nil, "", fmt.Errorf("MIME type validation failed: %w", err)
+
+// headBuffer keeps the first limit bytes written to it and discards the rest.
+type headBuffer struct {
+	data  []byte
+	limit int
+}
+
+
+func (b *headBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - len(b.data); room > 0 {
+		b.data = append(b.data, p[:min(room, len(p))]...)
 	}
+	return len(p), nil
+}
+
 
-	return data, normalizedMimeType, nil
+// whitespaceSkipper drops the spaces and line breaks base64 data is often wrapped with, which
+// the standard decoder rejects.
+type whitespaceSkipper struct {
+	r io.Reader
+}
+
+
+func (s whitespaceSkipper) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	kept := 0
+	for _, c := range p[:n] {
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			p[kept] = c
+			kept++
+		}
+	}
+	return kept, err
 }
 
 
@@ -0,0 +1,241 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zeros reads as an endless run of zero bytes
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// failingReader returns data, then fails
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New("connection reset")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// withTempDir sends temporary files to a directory of the test and returns it
+func withTempDir(t *testing.T) string {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	return dir
+}
+
+// writeTestFile writes data to a file of the test and returns its path
+func writeTestFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "media")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "temporary files must be removed")
+}
+
+func TestSpoolMedia(t *testing.T) {
+	dir := withTempDir(t)
+
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	spooled, head, err := spoolMedia(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), spooled.Size)
+	assert.Equal(t, data[:512], head)
+
+	stored, err := spooled.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, data, stored)
+
+	spooled.Remove()
+	assertNoTempFiles(t, dir)
+
+	// Remove is safe on nil
+	var missing *MediaFile
+	missing.Remove()
+}
+
+func TestSpoolMedia_Limit(t *testing.T) {
+	dir := withTempDir(t)
+
+	spooled, _, err := spoolMedia(io.LimitReader(zeros{}, MaxUploadSize))
+	require.NoError(t, err)
+	assert.Equal(t, int64(MaxUploadSize), spooled.Size)
+	spooled.Remove()
+
+	_, _, err = spoolMedia(io.LimitReader(zeros{}, MaxUploadSize+1))
+	assert.ErrorContains(t, err, "file too large")
+	assertNoTempFiles(t, dir)
+}
+
+func TestSpoolMedia_Errors(t *testing.T) {
+	dir := withTempDir(t)
+
+	tests := []struct {
+		name string
+		r    io.Reader
+		err  string
+	}{
+		{"empty", strings.NewReader(""), "media data is empty"},
+		{"read error", &failingReader{data: []byte("partial")}, "connection reset"},
+		{"endless", zeros{}, "file too large"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spooled, head, err := spoolMedia(tt.r)
+			assert.ErrorContains(t, err, tt.err)
+			assert.Nil(t, spooled)
+			assert.Nil(t, head)
+			assertNoTempFiles(t, dir)
+		})
+	}
+}
+
+func TestHeadBuffer(t *testing.T) {
+	head := &headBuffer{limit: 5}
+	for _, chunk := range []string{"ab", "cdef", "ghi"} {
+		n, err := head.Write([]byte(chunk))
+		require.NoError(t, err)
+		assert.Equal(t, len(chunk), n, "writes always report the whole chunk")
+	}
+	assert.Equal(t, []byte("abcde"), head.data)
+}
+
+func TestWhitespaceSkipper(t *testing.T) {
+	data, err := io.ReadAll(whitespaceSkipper{strings.NewReader(" aGVs\r\nbG8g\td29y\nbGQ= \n")})
+	require.NoError(t, err)
+	assert.Equal(t, "aGVsbG8gd29ybGQ=", string(data))
+
+	// A read of nothing but whitespace keeps nothing and does not end the stream
+	data, err = io.ReadAll(io.MultiReader(whitespaceSkipper{strings.NewReader("\n\n\n")}, strings.NewReader("x")))
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(data))
+}
+
+func TestDecodeDataURLToFile(t *testing.T) {
+	dir := withTempDir(t)
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	encoded := base64.StdEncoding.EncodeToString(png)
+
+	// Base64 wrapped at 4 characters, as MIME encoders do
+	var wrapped strings.Builder
+	for i := 0; i < len(encoded); i += 4 {
+		wrapped.WriteString(encoded[i:min(i+4, len(encoded))] + "\r\n")
+	}
+
+	tests := []struct {
+		name      string
+		dataURL   string
+		mediaType string
+		want      []byte
+		mimeType  string
+	}{
+		{"base64", "data:image/png;base64," + encoded, "image", png, "image/png"},
+		{"line-wrapped base64", "data:image/png;base64," + wrapped.String(), "image", png, "image/png"},
+		{"percent-encoded", "data:text/plain,hello%20world%21", "document", []byte("hello world!"), "text/plain"},
+		{"default MIME type", "data:,plain%20text", "document", []byte("plain text"), "text/plain"},
+		{"voice note", "data:audio/mpeg;base64," + base64.StdEncoding.EncodeToString([]byte("opus")), "audio", []byte("opus"), "audio/ogg; codecs=opus"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spooled, err := decodeDataURLToFile(tt.dataURL, tt.mediaType)
+			require.NoError(t, err)
+			defer spooled.Remove()
+
+			assert.Equal(t, tt.mimeType, spooled.MimeType)
+			assert.Equal(t, int64(len(tt.want)), spooled.Size)
+			data, err := spooled.ReadAll()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, data)
+		})
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func TestDecodeDataURLToFile_Errors(t *testing.T) {
+	dir := withTempDir(t)
+
+	tests := []struct {
+		name      string
+		dataURL   string
+		mediaType string
+		err       string
+	}{
+		{"not a data URL", "image/png;base64,AAAA", "image", "must start with 'data:'"},
+		{"missing comma", "data:image/png;base64", "image", "missing comma"},
+		{"empty base64", "data:image/png;base64,", "image", "media data is empty"},
+		{"empty percent-encoded", "data:text/plain,", "document", "media data is empty"},
+		{"corrupt base64", "data:image/png;base64,iVBO!!!!", "image", "could not decode data URL"},
+		{"bad percent escape", "data:text/plain,100%zz", "document", "could not decode data URL"},
+		{"wrong media type", "data:text/plain;base64,aGVsbG8=", "image", "MIME type validation failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spooled, err := decodeDataURLToFile(tt.dataURL, tt.mediaType)
+			assert.ErrorContains(t, err, tt.err)
+			assert.Nil(t, spooled)
+			assertNoTempFiles(t, dir)
+		})
+	}
+}
+
+// formFile builds the header of a multipart file upload
+func formFile(t *testing.T, filename string, content []byte) *multipart.FileHeader {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = part.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(1 << 20)
+	require.NoError(t, err)
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["file"][0]
+}
+
+func TestProcessFormDataMedia(t *testing.T) {
+	dir := withTempDir(t)
+
+	spooled, err := processFormDataMedia(formFile(t, "report.pdf", []byte("%PDF-1.7\n")), "document")
+	require.NoError(t, err)
+	assert.Equal(t, "application/pdf", spooled.MimeType)
+	spooled.Remove()
+
+	_, err = processFormDataMedia(formFile(t, "notes.txt", []byte("plain text")), "image")
+	assert.ErrorContains(t, err, "MIME type validation failed")
+
+	_, err = processFormDataMedia(formFile(t, "empty.pdf", nil), "document")
+	assert.ErrorContains(t, err, "media data is empty")
+
+	assertNoTempFiles(t, dir)
+}
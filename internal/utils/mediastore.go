@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+
+// mediaDir is where media of scheduled messages, broadcasts and templates is kept until it is
+// sent. Files are named by the SHA-256 of their content, so the same media is stored once.
+var (
+	mediaDirMu sync.RWMutex
+	mediaDir   = "media"
+)
+
+
+// SetMediaDir sets the directory media kept for later sends is stored in.
+func SetMediaDir(dir string) {
+	mediaDirMu.Lock()
+	defer mediaDirMu.Unlock()
+	mediaDir = dir
+}
+
+
+// MediaDir returns the directory media kept for later sends is stored in.
+func MediaDir() string {
+	mediaDirMu.RLock()
+	defer mediaDirMu.RUnlock()
+	return mediaDir
+}
+
+
+// StoreMedia keeps the file at path in the media directory and returns the path of the stored
+// copy, which outlives the temporary file. Media already stored is reused, and its modification
+// time refreshed so that PurgeStoredMedia leaves it alone while the new reference is saved.
+func StoreMedia(path string) (string, error) {
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+
+	dir := MediaDir()
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("failed to create media directory: %w", err)
+	}
+	stored := filepath.Join(dir, sum)
+
+	if _, err := os.Stat(stored); err == nil {
+		now := time.Now()
+		if err := os.Chtimes(stored, now, now); err != nil {
+			return "", fmt.Errorf("failed to store media: %w", err)
+		}
+		return stored, nil
+	}
+
+	// A temporary file on the same file system is linked rather than copied
+	if err := os.Link(path, stored); err == nil {
+		return stored, nil
+	}
+	if err := copyFileAtomic(path, stored); err != nil {
+		return "", fmt.Errorf("failed to store media: %w", err)
+	}
+	return stored, nil
+}
+
+
+// StoreMediaData is StoreMedia for media held in memory.
+func StoreMediaData(data []byte) (string, error) {
+	path, err := writeTempFile(data)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(path)
+
+	return StoreMedia(path)
+}
+
+
+// PurgeStoredMedia deletes the files of the media directory that are not in keep and were last
+// stored before the given time, and returns how many it deleted. The cutoff spares media stored
+// for a message that is not saved yet.
+func PurgeStoredMedia(keep map[string]bool, before time.Time) (int, error) {
+	dir := MediaDir()
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to list media directory: %w", err)
+	}
+
+	purged := 0
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() || keep[path] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return purged, fmt.Errorf("failed to delete stored media: %w", err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open media: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read media: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+
+// copyFileAtomic copies src to a temporary file next to dst and renames it into place, so that
+// dst is never seen half written.
+func copyFileAtomic(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(filepath.Dir(dst), ".incoming-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
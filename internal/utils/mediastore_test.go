@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withMediaDir stores media in a directory of the test and returns it
+func withMediaDir(t *testing.T) string {
+	dir := filepath.Join(t.TempDir(), "media")
+	original := MediaDir()
+	SetMediaDir(dir)
+	t.Cleanup(func() { SetMediaDir(original) })
+	return dir
+}
+
+func TestStoreMedia(t *testing.T) {
+	dir := withMediaDir(t)
+
+	source := writeTestFile(t, []byte("voice note"))
+	stored, err := StoreMedia(source)
+	require.NoError(t, err)
+	assert.Equal(t, dir, filepath.Dir(stored))
+
+	// The stored copy outlives the temporary file it came from
+	require.NoError(t, os.Remove(source))
+	data, err := os.ReadFile(stored)
+	require.NoError(t, err)
+	assert.Equal(t, "voice note", string(data))
+
+	// The same content is stored once, and storing it again refreshes it
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(stored, old, old))
+	again, err := StoreMediaData([]byte("voice note"))
+	require.NoError(t, err)
+	assert.Equal(t, stored, again)
+	info, err := os.Stat(stored)
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().After(old))
+
+	other, err := StoreMediaData([]byte("another note"))
+	require.NoError(t, err)
+	assert.NotEqual(t, stored, other)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	_, err = StoreMedia(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestPurgeStoredMedia(t *testing.T) {
+	withMediaDir(t)
+
+	purged, err := PurgeStoredMedia(nil, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, purged, "a missing media directory has nothing to purge")
+
+	store := func(content string, age time.Duration) string {
+		path, err := StoreMediaData([]byte(content))
+		require.NoError(t, err)
+		stamp := time.Now().Add(-age)
+		require.NoError(t, os.Chtimes(path, stamp, stamp))
+		return path
+	}
+	referenced := store("referenced", 3*time.Hour)
+	orphaned := store("orphaned", 3*time.Hour)
+	recent := store("recent", time.Minute)
+
+	purged, err = PurgeStoredMedia(map[string]bool{referenced: true}, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.FileExists(t, referenced)
+	assert.NoFileExists(t, orphaned)
+	assert.FileExists(t, recent, "media stored for a message being saved is spared")
+}
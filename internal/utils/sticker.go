@@ -0,0 +1,330 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"net/http"
+
+	"github.com/google/uuid"
+	"golang.org/x/image/webp"
+)
+
+
+const (
+	// StickerSize is the side of the square canvas WhatsApp stickers are drawn on
+	StickerSize = 512
+
+	// DefaultStickerPack is the pack name of stickers sent without one
+	DefaultStickerPack = "zpmeow"
+
+	maxStaticStickerBytes   = 100 * 1024
+	maxAnimatedStickerBytes = 500 * 1024
+
+	// maxAnimatedStickerSeconds cuts longer GIFs, as WhatsApp does not play longer stickers
+	maxAnimatedStickerSeconds = 10
+)
+
+
+// stickerQualities are the WebP qualities tried, in order, until a sticker fits its size limit
+var stickerQualities = []int{80, 60, 40, 20}
+
+
+// VP8X feature flags
+const (
+	webpFlagAnimation = 0x02
+	webpFlagEXIF      = 0x08
+	webpFlagAlpha     = 0x10
+)
+
+
+// StickerMetadata names the pack a sticker belongs to, shown when the recipient opens it.
+type StickerMetadata struct {
+	PackName string
+	Author   string
+}
+
+
+// StickerInfo describes a WebP sticker for the sticker message.
+type StickerInfo struct {
+	Width      int
+	Height     int
+	IsAnimated bool
+}
+
+
+// ConvertSticker turns a PNG, JPEG, GIF or WebP image into a WhatsApp sticker: a 512x512 WebP
+// with the image centred on a transparent background, animated for animated GIFs, carrying the
+// pack metadata in its EXIF. WebP input is kept as is apart from the metadata. Converting other
+// formats needs a local ffmpeg built with libwebp.
+func ConvertSticker(ctx context.Context, data []byte, meta StickerMetadata) ([]byte, error) {
+	if meta.PackName == "" {
+		meta.PackName = DefaultStickerPack
+	}
+
+	format := baseMimeType(http.DetectContentType(data))
+	if format == "image/webp" {
+		return addWebPEXIF(data, stickerEXIF(meta))
+	}
+	if format != "image/png" && format != "image/jpeg" && format != "image/gif" {
+		return nil, fmt.Errorf("unsupported sticker format: %s", format)
+	}
+
+	// Only the header is decoded, so a small file cannot claim a huge canvas
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sticker: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxDecodedImagePixels {
+		return nil, fmt.Errorf("sticker is too large: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	if !HasFFmpeg() {
+		return nil, fmt.Errorf("ffmpeg is required to convert %s stickers", format)
+	}
+
+	filter := fmt.Sprintf("scale=%[1]d:%[1]d:force_original_aspect_ratio=decrease:flags=lanczos,format=rgba,"+
+		"pad=%[1]d:%[1]d:(ow-iw)/2:(oh-ih)/2:color=0x00000000", StickerSize)
+	limit := maxStaticStickerBytes
+	args := []string{"-an", "-vf", filter, "-frames:v", "1"}
+	if format == "image/gif" && isAnimatedGIF(data) {
+		limit = maxAnimatedStickerBytes
+		args = []string{"-an", "-vf", "fps=15," + filter, "-t", fmt.Sprint(maxAnimatedStickerSeconds), "-loop", "0"}
+	}
+
+	for _, quality := range stickerQualities {
+		out, err := runFFmpegToFile(ctx, data, ".webp",
+			append(args, "-c:v", "libwebp", "-q:v", fmt.Sprint(quality), "-f", "webp")...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert sticker: %w", err)
+		}
+		if len(out) <= limit {
+			return addWebPEXIF(out, stickerEXIF(meta))
+		}
+	}
+	return nil, fmt.Errorf("sticker cannot be compressed below %d bytes", limit)
+}
+
+
+// ReadStickerInfo reads the canvas size of a WebP sticker and whether it is animated.
+func ReadStickerInfo(data []byte) (*StickerInfo, error) {
+	chunks, err := parseWebP(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if vp8x := chunks[0]; vp8x.fourCC == "VP8X" && len(vp8x.data) >= 10 {
+		return &StickerInfo{
+			Width:      1 + int(uint24(vp8x.data[4:])),
+			Height:     1 + int(uint24(vp8x.data[7:])),
+			IsAnimated: vp8x.data[0]&webpFlagAnimation != 0,
+		}, nil
+	}
+
+	cfg, err := webpConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	return &StickerInfo{Width: cfg.Width, Height: cfg.Height}, nil
+}
+
+
+// webpConfig reads the size of a simple WebP file, which a truncated bitstream may leave at zero.
+func webpConfig(data []byte) (image.Config, error) {
+	cfg, err := webp.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return cfg, fmt.Errorf("failed to decode sticker: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return cfg, fmt.Errorf("failed to decode sticker: image is empty")
+	}
+	return cfg, nil
+}
+
+
+// stickerEXIF builds the EXIF block WhatsApp reads sticker pack details from: a little-endian
+// TIFF header with a single tag 0x5741 holding the pack as JSON.
+func stickerEXIF(meta StickerMetadata) []byte {
+	doc, _ := json.Marshal(struct {
+		PackID    string   `json:"sticker-pack-id"`
+		PackName  string   `json:"sticker-pack-name"`
+		Publisher string   `json:"sticker-pack-publisher"`
+		Emojis    []string `json:"emojis"`
+	}{
+		PackID:    uuid.NewString(),
+		PackName:  meta.PackName,
+		Publisher: meta.Author,
+		Emojis:    []string{""},
+	})
+
+	exif := []byte{
+		0x49, 0x49, 0x2a, 0x00, 0x08, 0x00, 0x00, 0x00, // TIFF header, first IFD at offset 8
+		0x01, 0x00, // one entry
+		0x41, 0x57, 0x07, 0x00, // tag 0x5741, type UNDEFINED
+		0x00, 0x00, 0x00, 0x00, // count, filled below
+		0x16, 0x00, 0x00, 0x00, // value offset 22
+	}
+	binary.LittleEndian.PutUint32(exif[14:], uint32(len(doc)))
+	return append(exif, doc...)
+}
+
+
+type riffChunk struct {
+	fourCC string
+	data   []byte
+}
+
+
+// parseWebP splits a WebP file into its RIFF chunks.
+func parseWebP(data []byte) ([]riffChunk, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("not a WebP image")
+	}
+
+	body := data[12:]
+	if size := int(binary.LittleEndian.Uint32(data[4:])); size+8 < len(data) && size >= 4 {
+		body = data[12 : size+8]
+	}
+
+	var chunks []riffChunk
+	for len(body) >= 8 {
+		size := int(binary.LittleEndian.Uint32(body[4:]))
+		if size < 0 || 8+size > len(body) {
+			return nil, fmt.Errorf("truncated WebP chunk %q", body[:4])
+		}
+		chunks = append(chunks, riffChunk{fourCC: string(body[:4]), data: body[8 : 8+size]})
+		body = body[min(len(body), 8+size+size&1):]
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("empty WebP image")
+	}
+	return chunks, nil
+}
+
+
+// addWebPEXIF stores exif in a WebP file, replacing any EXIF it had. Simple lossy and lossless
+// files are converted to the extended format, which is the only one with metadata.
+func addWebPEXIF(data, exif []byte) ([]byte, error) {
+	chunks, err := parseWebP(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var vp8x []byte
+	if chunks[0].fourCC == "VP8X" && len(chunks[0].data) >= 10 {
+		vp8x = append([]byte(nil), chunks[0].data...)
+		chunks = chunks[1:]
+	} else {
+		cfg, err := webpConfig(data)
+		if err != nil {
+			return nil, err
+		}
+		vp8x = make([]byte, 10)
+		putUint24(vp8x[4:], uint32(cfg.Width-1))
+		putUint24(vp8x[7:], uint32(cfg.Height-1))
+		for _, chunk := range chunks {
+			if chunk.fourCC == "VP8L" || chunk.fourCC == "ALPH" {
+				vp8x[0] |= webpFlagAlpha
+			}
+		}
+	}
+	vp8x[0] |= webpFlagEXIF
+
+	// Chunk order: VP8X, the image chunks, EXIF, XMP
+	out := []riffChunk{{fourCC: "VP8X", data: vp8x}}
+	var xmp []riffChunk
+	for _, chunk := range chunks {
+		switch chunk.fourCC {
+		case "EXIF":
+		case "XMP ":
+			xmp = append(xmp, chunk)
+		default:
+			out = append(out, chunk)
+		}
+	}
+	out = append(out, riffChunk{fourCC: "EXIF", data: exif})
+	out = append(out, xmp...)
+
+	return buildWebP(out), nil
+}
+
+
+func buildWebP(chunks []riffChunk) []byte {
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	for _, chunk := range chunks {
+		var header [8]byte
+		copy(header[:4], chunk.fourCC)
+		binary.LittleEndian.PutUint32(header[4:], uint32(len(chunk.data)))
+		body.Write(header[:])
+		body.Write(chunk.data)
+		if len(chunk.data)%2 == 1 {
+			body.WriteByte(0)
+		}
+	}
+
+	out := make([]byte, 8, 8+body.Len())
+	copy(out, "RIFF")
+	binary.LittleEndian.PutUint32(out[4:], uint32(body.Len()))
+	return append(out, body.Bytes()...)
+}
+
+
+// isAnimatedGIF reports whether a GIF has more than one frame.
+func isAnimatedGIF(data []byte) bool {
+	return gifFrames(data, 2) > 1
+}
+
+
+// gifFrames counts the frames of a GIF, up to limit, by walking its blocks without decoding any
+// pixels. Counting stops at the first malformed block.
+func gifFrames(data []byte, limit int) int {
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return 0
+	}
+
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+
+	frames := 0
+	for pos < len(data) && frames < limit {
+		switch data[pos] {
+		case 0x21: // extension: label, then data sub-blocks
+			pos += 2
+		case 0x2c: // image descriptor, optional local color table, LZW code size, then data sub-blocks
+			if pos+10 > len(data) {
+				return frames
+			}
+			packed := data[pos+9]
+			pos += 10
+			if packed&0x80 != 0 {
+				pos += 3 << (packed&0x07 + 1)
+			}
+			pos++
+			frames++
+		default: // trailer or garbage
+			return frames
+		}
+
+		for pos < len(data) && data[pos] != 0 {
+			pos += 1 + int(data[pos])
+		}
+		pos++
+	}
+	return frames
+}
+
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testGIF encodes a GIF with the given number of 4x4 frames, each with a local color table
+func testGIF(t *testing.T, frames int) []byte {
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, 4, 4), palette))
+		anim.Delay = append(anim.Delay, 10)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, gif.EncodeAll(&buf, anim))
+	return buf.Bytes()
+}
+
+func TestGIFFrames(t *testing.T) {
+	three := testGIF(t, 3)
+
+	// A global color table and a comment extension before the first frame
+	global := []byte("GIF89a\x04\x00\x04\x00\x80\x00\x00\x00\x00\x00\xff\xff\xff")
+	global = append(global, 0x21, 0xfe, 5, 'h', 'e', 'l', 'l', 'o', 0)
+	frame := []byte{0x2c, 0, 0, 0, 0, 4, 0, 4, 0, 0, 2, 2, 0x44, 0x01, 0}
+	global = concat(global, frame, frame, []byte{0x3b})
+
+	tests := []struct {
+		name  string
+		data  []byte
+		limit int
+		want  int
+	}{
+		{"single frame", testGIF(t, 1), 10, 1},
+		{"three frames", three, 10, 3},
+		{"stops at the limit", three, 2, 2},
+		{"global color table and extension", global, 10, 2},
+		{"truncated after the first frame", three[:len(three)/2], 10, 1},
+		{"not a GIF", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x00\x00"), 10, 0},
+		{"empty", nil, 10, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, gifFrames(tt.data, tt.limit))
+		})
+	}
+
+	assert.True(t, isAnimatedGIF(three))
+	assert.False(t, isAnimatedGIF(testGIF(t, 1)))
+}
+
+func TestConvertSticker_RefusesHugeCanvas(t *testing.T) {
+	// A tiny two-frame GIF claiming a 16000x16000 canvas
+	data := testGIF(t, 2)
+	binary.LittleEndian.PutUint16(data[6:], 16000)
+	binary.LittleEndian.PutUint16(data[8:], 16000)
+
+	_, err := ConvertSticker(context.Background(), data, StickerMetadata{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sticker is too large: 16000x16000")
+}
+
+// chunk builds a RIFF chunk, padded to an even length
+func chunk(fourCC string, data []byte) []byte {
+	out := binary.LittleEndian.AppendUint32([]byte(fourCC), uint32(len(data)))
+	out = append(out, data...)
+	if len(data)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+// webpFile wraps chunks in a RIFF WEBP container
+func webpFile(chunks ...[]byte) []byte {
+	body := concat(append([][]byte{[]byte("WEBP")}, chunks...)...)
+	return concat([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body))), body)
+}
+
+// vp8lChunk is the header of a lossless bitstream of the given size
+func vp8lChunk(width, height int, alpha bool) []byte {
+	bits := uint32(width-1) | uint32(height-1)<<14
+	if alpha {
+		bits |= 1 << 28
+	}
+	return chunk("VP8L", binary.LittleEndian.AppendUint32([]byte{0x2f}, bits))
+}
+
+// vp8Chunk is the key frame header of a lossy bitstream of the given size
+func vp8Chunk(width, height int) []byte {
+	frame := []byte{0x10, 0x00, 0x00, 0x9d, 0x01, 0x2a}
+	frame = binary.LittleEndian.AppendUint16(frame, uint16(width))
+	frame = binary.LittleEndian.AppendUint16(frame, uint16(height))
+	return chunk("VP8 ", append(frame, make([]byte, 7)...))
+}
+
+func vp8xChunk(flags byte, width, height int) []byte {
+	data := make([]byte, 10)
+	data[0] = flags
+	putUint24(data[4:], uint32(width-1))
+	putUint24(data[7:], uint32(height-1))
+	return chunk("VP8X", data)
+}
+
+func fourCCs(t *testing.T, data []byte) []string {
+	chunks, err := parseWebP(data)
+	require.NoError(t, err)
+	var names []string
+	for _, c := range chunks {
+		names = append(names, c.fourCC)
+	}
+	return names
+}
+
+func TestParseWebP(t *testing.T) {
+	odd := webpFile(chunk("ICCP", []byte("abc")), vp8lChunk(4, 4, false))
+	chunks, err := parseWebP(odd)
+	require.NoError(t, err)
+	require.Len(t, chunks, 2)
+	assert.Equal(t, []byte("abc"), chunks[0].data, "the padding byte of an odd chunk is not part of its data")
+	assert.Equal(t, "VP8L", chunks[1].fourCC)
+
+	// Bytes after the RIFF size are ignored
+	_, err = parseWebP(append(odd, "trailing"...))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"truncated chunk", odd[:len(odd)-2], "truncated WebP chunk"},
+		{"no chunks", webpFile(), "empty WebP image"},
+		{"not RIFF", []byte("GIF89a\x04\x00\x04\x00\x00\x00"), "not a WebP image"},
+		{"short", []byte("RIFF"), "not a WebP image"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseWebP(tt.data)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestAddWebPEXIF(t *testing.T) {
+	exif := stickerEXIF(StickerMetadata{PackName: "pack", Author: "me"})
+
+	t.Run("lossless to extended", func(t *testing.T) {
+		out, err := addWebPEXIF(webpFile(vp8lChunk(300, 200, true)), exif)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"VP8X", "VP8L", "EXIF"}, fourCCs(t, out))
+
+		chunks, _ := parseWebP(out)
+		assert.Equal(t, byte(webpFlagEXIF|webpFlagAlpha), chunks[0].data[0])
+		assert.Equal(t, exif, chunks[2].data)
+		assert.Equal(t, uint32(len(out)-8), binary.LittleEndian.Uint32(out[4:]), "the RIFF size covers the new chunks")
+
+		info, err := ReadStickerInfo(out)
+		require.NoError(t, err)
+		assert.Equal(t, StickerInfo{Width: 300, Height: 200}, *info)
+	})
+
+	t.Run("lossy to extended", func(t *testing.T) {
+		out, err := addWebPEXIF(webpFile(vp8Chunk(512, 512)), exif)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"VP8X", "VP8 ", "EXIF"}, fourCCs(t, out))
+
+		chunks, _ := parseWebP(out)
+		assert.Equal(t, byte(webpFlagEXIF), chunks[0].data[0], "lossy images without ALPH are opaque")
+	})
+
+	t.Run("replaces EXIF and keeps XMP last", func(t *testing.T) {
+		in := webpFile(
+			vp8xChunk(webpFlagAnimation|webpFlagEXIF, 512, 512),
+			chunk("ANIM", make([]byte, 6)),
+			chunk("ANMF", make([]byte, 17)),
+			chunk("XMP ", []byte("<x:xmpmeta/>")),
+			chunk("EXIF", []byte("old")),
+		)
+		out, err := addWebPEXIF(in, exif)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"VP8X", "ANIM", "ANMF", "EXIF", "XMP "}, fourCCs(t, out))
+
+		chunks, _ := parseWebP(out)
+		assert.Equal(t, exif, chunks[3].data)
+		assert.Len(t, chunks[2].data, 17, "odd chunks keep their length")
+
+		info, err := ReadStickerInfo(out)
+		require.NoError(t, err)
+		assert.Equal(t, StickerInfo{Width: 512, Height: 512, IsAnimated: true}, *info)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		in := webpFile(vp8lChunk(4, 4, false))
+		_, err := addWebPEXIF(in[:len(in)-3], exif)
+		assert.Error(t, err)
+
+		_, err = addWebPEXIF(webpFile(chunk("VP8L", []byte{0x2f})), exif)
+		assert.ErrorContains(t, err, "failed to decode sticker")
+	})
+}
+
+func TestStickerEXIF(t *testing.T) {
+	exif := stickerEXIF(StickerMetadata{PackName: "pack", Author: "me"})
+	assert.Equal(t, []byte("II*\x00\x08\x00\x00\x00"), exif[:8])
+	assert.Equal(t, uint32(len(exif)-22), binary.LittleEndian.Uint32(exif[14:]))
+	assert.True(t, strings.Contains(string(exif[22:]), `"sticker-pack-name":"pack"`))
+}
+
+func TestReadStickerInfo_Invalid(t *testing.T) {
+	_, err := ReadStickerInfo([]byte("not a sticker"))
+	assert.ErrorContains(t, err, "not a WebP image")
+
+	_, err = ReadStickerInfo(webpFile(chunk("VP8 ", []byte{1, 2, 3})))
+	assert.ErrorContains(t, err, "failed to decode sticker")
+
+	_, err = addWebPEXIF(webpFile(chunk("VP8 ", []byte{1, 2, 3})), nil)
+	assert.ErrorContains(t, err, "failed to decode sticker")
+}
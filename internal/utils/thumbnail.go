@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+
+	// Register the decoders accepted by image.Decode
+	_ "image/gif"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+
+// ThumbnailQuality is the JPEG quality of generated thumbnails
+const ThumbnailQuality = 75
+
+
+// maxDecodedImagePixels rejects images whose decoded size would use excessive memory
+const maxDecodedImagePixels = 50_000_000
+
+
+// JPEGThumbnail decodes a JPEG, PNG, GIF or WebP image and re-encodes it as a JPEG that fits
+// within maxSide pixels, upright and keeping the aspect ratio. It returns the thumbnail and its
+// dimensions.
+func JPEGThumbnail(data []byte, maxSide int) ([]byte, int, int, error) {
+	canvas, err := decodeUpright(data)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	width, height := fitWithin(canvas.Bounds().Dx(), canvas.Bounds().Dy(), maxSide)
+	out, err := encodeJPEG(canvas, width, height, ThumbnailQuality)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return out, width, height, nil
+}
+
+
+// JPEGThumbnailFile is JPEGThumbnail for an image on disk.
+func JPEGThumbnailFile(path string, maxSide int) ([]byte, int, int, error) {
+	canvas, err := decodeUprightFile(path)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	width, height := fitWithin(canvas.Bounds().Dx(), canvas.Bounds().Dy(), maxSide)
+	out, err := encodeJPEG(canvas, width, height, ThumbnailQuality)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return out, width, height, nil
+}
+
+
+// decodeImage decodes a JPEG, PNG, GIF or WebP image, refusing images too large to hold in memory.
+func decodeImage(data []byte) (image.Image, error) {
+	return decodeImageFrom(bytes.NewReader(data))
+}
+
+
+// decodeImageFrom is decodeImage for an image read from r, which is rewound before each pass.
+func decodeImageFrom(r io.ReadSeeker) (image.Image, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	cfg, _, err := image.DecodeConfig(bufio.NewReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxDecodedImagePixels {
+		return nil, fmt.Errorf("image is too large: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	src, _, err := image.Decode(bufio.NewReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if src.Bounds().Dx() == 0 || src.Bounds().Dy() == 0 {
+		return nil, fmt.Errorf("image is empty")
+	}
+	return src, nil
+}
+
+
+// fitWithin scales width x height down to fit within maxSide pixels, keeping the aspect ratio.
+func fitWithin(width, height, maxSide int) (int, int) {
+	if width <= maxSide && height <= maxSide {
+		return width, height
+	}
+	if width >= height {
+		return maxSide, max(1, height*maxSide/width)
+	}
+	return max(1, width*maxSide/height), maxSide
+}
+
+
+// flatten draws src onto a white canvas. JPEG has no alpha channel, so transparent areas
+// would otherwise turn black.
+func flatten(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	canvas := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(canvas, canvas.Bounds(), src, bounds.Min, draw.Over)
+	return canvas
+}
+
+
+// encodeJPEG scales a flattened image to width x height and encodes it at the given quality.
+func encodeJPEG(canvas *image.RGBA, width, height, quality int) ([]byte, error) {
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, scaleBox(canvas, width, height), &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+
+// scaleBox downscales src to width x height, averaging the source pixels each target pixel covers
+func scaleBox(src *image.RGBA, width, height int) *image.RGBA {
+	srcW, srcH := src.Bounds().Dx(), src.Bounds().Dy()
+	if srcW == width && srcH == height {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcH/height, max((y+1)*srcH/height, y*srcH/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcW/width, max((x+1)*srcW/width, x*srcW/width+1)
+
+			var r, g, b, n uint32
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					r += uint32(row[sx*4])
+					g += uint32(row[sx*4+1])
+					b += uint32(row[sx*4+2])
+					n++
+				}
+			}
+
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = 0xff
+		}
+	}
+	return dst
+}
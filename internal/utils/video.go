@@ -0,0 +1,303 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+
+// maxMP4MovieBox caps the movie box read from an MP4 file; it holds the sample tables, which
+// stay well below this even for long videos
+const maxMP4MovieBox = 64 * 1024 * 1024
+
+
+// VideoInfo is what a video message shows before its file is downloaded.
+type VideoInfo struct {
+	Seconds   uint32
+	Width     int
+	Height    int
+	Thumbnail []byte
+}
+
+
+// ReadVideoInfo reads the duration and display size of the video file at path with ffprobe,
+// falling back to the MP4 headers when ffprobe is not installed, and grabs its first frame as a
+// thumbnail when ffmpeg is. Fields that cannot be read are left zero.
+func ReadVideoInfo(ctx context.Context, path string) *VideoInfo {
+	info := &VideoInfo{}
+
+	var ok bool
+	if HasFFprobe() {
+		info.Seconds, info.Width, info.Height, ok = probeVideo(ctx, path)
+	}
+	if !ok {
+		info.Seconds, info.Width, info.Height, _ = parseMP4File(path)
+	}
+
+	if HasFFmpeg() {
+		frame, err := runFFmpeg(ctx, path, "-an", "-frames:v", "1", "-f", "image2", "-c:v", "png", "pipe:1")
+		if err == nil {
+			info.Thumbnail, _, _, _ = JPEGThumbnail(frame, ImageThumbnailSize)
+			if info.Width == 0 || info.Height == 0 {
+				if cfg, _, err := image.DecodeConfig(bytes.NewReader(frame)); err == nil {
+					info.Width, info.Height = cfg.Width, cfg.Height
+				}
+			}
+		}
+	}
+
+	return info
+}
+
+
+// TranscodeVideo converts a video that is not MP4 into H.264/AAC MP4, the format every WhatsApp
+// client plays. MP4 input is returned unchanged; a transcoded video is a new temporary file,
+// which the caller must Remove as well.
+func TranscodeVideo(ctx context.Context, media *MediaFile) (*MediaFile, error) {
+	head, err := media.Head(12)
+	if err != nil {
+		return nil, err
+	}
+	if isMP4(head) {
+		return media, nil
+	}
+	if !HasFFmpeg() {
+		return nil, fmt.Errorf("ffmpeg is not installed")
+	}
+
+	path, err := ffmpegToTempFile(ctx, media.Path, ".mp4",
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "28", "-pix_fmt", "yuv420p",
+		"-vf", "scale=trunc(iw/2)*2:trunc(ih/2)*2",
+		"-c:a", "aac", "-b:a", "128k",
+		"-movflags", "+faststart", "-f", "mp4")
+	if err != nil {
+		return nil, fmt.Errorf("failed to transcode video: %w", err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to transcode video: %w", err)
+	}
+	return &MediaFile{Path: path, Size: stat.Size(), MimeType: "video/mp4"}, nil
+}
+
+
+// probeVideo reads the duration and display size of the first video stream with ffprobe.
+func probeVideo(ctx context.Context, path string) (uint32, int, int, bool) {
+	out, err := runFFprobe(ctx, path,
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height:stream_tags=rotate:stream_side_data=rotation:format=duration",
+		"-of", "json")
+	if err != nil {
+		return 0, 0, 0, false
+	}
+
+	var probe struct {
+		Streams []struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+			Tags   struct {
+				Rotate string `json:"rotate"`
+			} `json:"tags"`
+			SideData []struct {
+				Rotation float64 `json:"rotation"`
+			} `json:"side_data_list"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil || len(probe.Streams) == 0 {
+		return 0, 0, 0, false
+	}
+
+	stream := probe.Streams[0]
+	width, height := stream.Width, stream.Height
+
+	rotation, _ := strconv.ParseFloat(stream.Tags.Rotate, 64)
+	for _, side := range stream.SideData {
+		if side.Rotation != 0 {
+			rotation = side.Rotation
+		}
+	}
+	if int(math.Abs(rotation))%180 == 90 {
+		width, height = height, width
+	}
+
+	duration, _ := strconv.ParseFloat(probe.Format.Duration, 64)
+	return uint32(math.Ceil(duration)), width, height, width > 0 && height > 0
+}
+
+
+// isMP4 reports whether data is an ISO MP4 file. QuickTime files share the layout but are not
+// played by every client, so they do not count.
+func isMP4(data []byte) bool {
+	return len(data) >= 12 && string(data[4:8]) == "ftyp" && string(data[8:12]) != "qt  "
+}
+
+
+// parseMP4File is parseMP4Info for an MP4 file on disk. Only the movie box is read, wherever it
+// sits in the file.
+func parseMP4File(path string) (uint32, int, int, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, 0, 0, false
+	}
+
+	moov := readMP4Box(file, stat.Size(), "moov")
+	if moov == nil {
+		return 0, 0, 0, false
+	}
+	return parseMP4Info(moov)
+}
+
+
+// readMP4Box reads the first top level box of the given kind, header included, walking the box
+// headers of r so that the media data in between is skipped. Boxes larger than maxMP4MovieBox
+// are not read.
+func readMP4Box(r io.ReaderAt, size int64, kind string) []byte {
+	header := make([]byte, 16)
+	for offset := int64(0); offset+8 <= size; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return nil
+		}
+
+		boxSize, headerSize := int64(binary.BigEndian.Uint32(header)), int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = size - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return nil
+			}
+			boxSize, headerSize = int64(binary.BigEndian.Uint64(header[8:])), 16
+		}
+		if boxSize < headerSize || boxSize > size-offset {
+			return nil
+		}
+
+		if string(header[4:8]) == kind {
+			if boxSize > maxMP4MovieBox {
+				return nil
+			}
+			box := make([]byte, boxSize)
+			if _, err := r.ReadAt(box, offset); err != nil {
+				return nil
+			}
+			return box
+		}
+		offset += boxSize
+	}
+	return nil
+}
+
+
+// parseMP4Info reads the duration from the movie header and the display size from the track
+// header of the first video track, swapping the sides of rotated tracks.
+func parseMP4Info(data []byte) (uint32, int, int, bool) {
+	moov := findMP4Box(data, "moov")
+	if moov == nil {
+		return 0, 0, 0, false
+	}
+
+	var seconds uint32
+	if mvhd := findMP4Box(moov, "mvhd"); len(mvhd) >= 32 {
+		var timescale, duration uint64
+		if mvhd[0] == 1 {
+			timescale = uint64(binary.BigEndian.Uint32(mvhd[20:]))
+			duration = binary.BigEndian.Uint64(mvhd[24:])
+		} else {
+			timescale = uint64(binary.BigEndian.Uint32(mvhd[12:]))
+			duration = uint64(binary.BigEndian.Uint32(mvhd[16:]))
+		}
+		if timescale > 0 {
+			seconds = uint32(math.Ceil(float64(duration) / float64(timescale)))
+		}
+	}
+
+	for _, trak := range mp4Boxes(moov) {
+		if trak.kind != "trak" {
+			continue
+		}
+		hdlr := findMP4Box(findMP4Box(trak.body, "mdia"), "hdlr")
+		if len(hdlr) < 12 || string(hdlr[8:12]) != "vide" {
+			continue
+		}
+
+		tkhd := findMP4Box(trak.body, "tkhd")
+		matrix := 40
+		if len(tkhd) > 0 && tkhd[0] == 1 {
+			matrix = 52
+		}
+		if len(tkhd) < matrix+44 {
+			break
+		}
+
+		width := int(binary.BigEndian.Uint32(tkhd[matrix+36:]) >> 16)
+		height := int(binary.BigEndian.Uint32(tkhd[matrix+40:]) >> 16)
+		a, b := int32(binary.BigEndian.Uint32(tkhd[matrix:])), int32(binary.BigEndian.Uint32(tkhd[matrix+4:]))
+		if a == 0 && b != 0 {
+			width, height = height, width
+		}
+		return seconds, width, height, true
+	}
+
+	return seconds, 0, 0, seconds > 0
+}
+
+
+type mp4Box struct {
+	kind string
+	body []byte
+}
+
+
+// mp4Boxes splits data into its top level boxes, stopping at the first malformed one.
+func mp4Boxes(data []byte) []mp4Box {
+	var boxes []mp4Box
+	for len(data) >= 8 {
+		size, header := uint64(binary.BigEndian.Uint32(data)), uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return boxes
+			}
+			size, header = binary.BigEndian.Uint64(data[8:]), 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return boxes
+		}
+
+		boxes = append(boxes, mp4Box{kind: string(data[4:8]), body: data[header:size]})
+		data = data[size:]
+	}
+	return boxes
+}
+
+
+func findMP4Box(data []byte, kind string) []byte {
+	for _, box := range mp4Boxes(data) {
+		if box.kind == kind {
+			return box.body
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// box builds an MP4 box with a 32-bit size
+func box(kind string, body ...[]byte) []byte {
+	payload := concat(body...)
+	data := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+	data = append(data, kind...)
+	return append(data, payload...)
+}
+
+// concat joins parts into a new slice
+func concat(parts ...[]byte) []byte {
+	var data []byte
+	for _, part := range parts {
+		data = append(data, part...)
+	}
+	return data
+}
+
+// largeBox builds an MP4 box with size 1 and a 64-bit size after the type
+func largeBox(kind string, body []byte) []byte {
+	data := binary.BigEndian.AppendUint32(nil, 1)
+	data = append(data, kind...)
+	data = binary.BigEndian.AppendUint64(data, uint64(16+len(body)))
+	return append(data, body...)
+}
+
+// openBox builds an MP4 box with size 0, which extends to the end of the data
+func openBox(kind string, body []byte) []byte {
+	data := binary.BigEndian.AppendUint32(nil, 0)
+	data = append(data, kind...)
+	return append(data, body...)
+}
+
+func mvhdV0(timescale, duration uint32) []byte {
+	body := make([]byte, 100)
+	binary.BigEndian.PutUint32(body[12:], timescale)
+	binary.BigEndian.PutUint32(body[16:], duration)
+	return box("mvhd", body)
+}
+
+func mvhdV1(timescale uint32, duration uint64) []byte {
+	body := make([]byte, 112)
+	body[0] = 1
+	binary.BigEndian.PutUint32(body[20:], timescale)
+	binary.BigEndian.PutUint64(body[24:], duration)
+	return box("mvhd", body)
+}
+
+// identity and rotate90 are transformation matrices of a track header
+var (
+	identity = [9]int32{0x10000, 0, 0, 0, 0x10000, 0, 0, 0, 0x40000000}
+	rotate90 = [9]int32{0, 0x10000, 0, -0x10000, 0, 0, 0, 0, 0x40000000}
+)
+
+func tkhd(version byte, matrix [9]int32, width, height uint32) []byte {
+	offset := 40
+	if version == 1 {
+		offset = 52
+	}
+	body := make([]byte, offset+44)
+	body[0] = version
+	for i, v := range matrix {
+		binary.BigEndian.PutUint32(body[offset+4*i:], uint32(v))
+	}
+	binary.BigEndian.PutUint32(body[offset+36:], width<<16)
+	binary.BigEndian.PutUint32(body[offset+40:], height<<16)
+	return box("tkhd", body)
+}
+
+func trak(handler string, header []byte) []byte {
+	hdlr := append(make([]byte, 8), handler...)
+	hdlr = append(hdlr, make([]byte, 13)...)
+	return box("trak", header, box("mdia", box("hdlr", hdlr)))
+}
+
+func mp4File(moov ...[]byte) []byte {
+	ftyp := box("ftyp", []byte("isom"), make([]byte, 4), []byte("isomiso2mp41"))
+	return concat(ftyp, box("mdat", make([]byte, 32)), box("moov", moov...))
+}
+
+func TestParseMP4Info(t *testing.T) {
+	audio := trak("soun", tkhd(0, identity, 0, 0))
+	video := trak("vide", tkhd(0, identity, 1280, 720))
+	truncated := mp4File(mvhdV0(1000, 4500), video)
+
+	tests := []struct {
+		name          string
+		data          []byte
+		seconds       uint32
+		width, height int
+		ok            bool
+	}{
+		{"version 0", mp4File(mvhdV0(1000, 4500), audio, video), 5, 1280, 720, true},
+		{"version 1", mp4File(mvhdV1(90000, 90000*61), trak("vide", tkhd(1, identity, 640, 480))), 61, 640, 480, true},
+		{"rotated track", mp4File(mvhdV0(600, 1200), trak("vide", tkhd(0, rotate90, 1920, 1080))), 2, 1080, 1920, true},
+		{"audio only", mp4File(mvhdV0(1000, 3000), audio), 3, 0, 0, true},
+		{"short track header", mp4File(mvhdV0(1000, 3000), trak("vide", box("tkhd", make([]byte, 60)))), 3, 0, 0, true},
+		{"zero timescale", mp4File(mvhdV0(0, 3000), video), 0, 1280, 720, true},
+		{"size 1 moov", concat(box("ftyp", []byte("isom")), largeBox("moov", concat(mvhdV0(1000, 2000), video))), 2, 1280, 720, true},
+		{"size 0 moov", concat(box("ftyp", []byte("isom")), openBox("moov", concat(mvhdV0(1000, 2000), video))), 2, 1280, 720, true},
+		{"truncated moov", truncated[:len(truncated)-10], 0, 0, 0, false},
+		{"no moov", box("ftyp", []byte("isom")), 0, 0, 0, false},
+		{"empty", nil, 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seconds, width, height, ok := parseMP4Info(tt.data)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.seconds, seconds)
+			assert.Equal(t, tt.width, width)
+			assert.Equal(t, tt.height, height)
+
+			seconds, width, height, ok = parseMP4File(writeTestFile(t, tt.data))
+			assert.Equal(t, tt.ok, ok, "from file")
+			assert.Equal(t, tt.seconds, seconds, "from file")
+			assert.Equal(t, tt.width, width, "from file")
+			assert.Equal(t, tt.height, height, "from file")
+		})
+	}
+}
+
+func TestMP4Boxes(t *testing.T) {
+	free := box("free", []byte("abcd"))
+	large := largeBox("mdat", []byte("payload"))
+
+	tests := []struct {
+		name  string
+		data  []byte
+		kinds []string
+	}{
+		{"sequence", concat(box("ftyp", []byte("isom")), free, box("moov")), []string{"ftyp", "free", "moov"}},
+		{"size 1", concat(large, free), []string{"mdat", "free"}},
+		{"size 1 without room for the large size", concat(free, []byte{0, 0, 0, 1, 'm', 'd', 'a', 't', 0, 0}), []string{"free"}},
+		{"size 1 smaller than its header", concat(free, binary.BigEndian.AppendUint64([]byte{0, 0, 0, 1, 'm', 'd', 'a', 't'}, 8)), []string{"free"}},
+		{"size 0 runs to the end", concat(free, openBox("mdat", []byte("rest of the file"))), []string{"free", "mdat"}},
+		{"size past the end", concat(free, large[:len(large)-1]), []string{"free"}},
+		{"size smaller than the header", concat(free, []byte{0, 0, 0, 4, 'b', 'a', 'd', '!'}), []string{"free"}},
+		{"trailing bytes", concat(free, []byte{0, 0, 0}), []string{"free"}},
+		{"empty", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var kinds []string
+			for _, b := range mp4Boxes(tt.data) {
+				kinds = append(kinds, b.kind)
+			}
+			assert.Equal(t, tt.kinds, kinds)
+		})
+	}
+
+	boxes := mp4Boxes(concat(large, free))
+	assert.Equal(t, []byte("payload"), boxes[0].body, "the body of a size 1 box starts after the large size")
+	assert.Equal(t, []byte("abcd"), boxes[1].body)
+}